@@ -0,0 +1,85 @@
+package erasure
+
+// Arithmetic in GF(2^8), generated by the primitive polynomial
+// x^8 + x^4 + x^3 + x^2 + 1 (0x11d) with generator 2.
+
+const (
+	fieldSize            = 256
+	generatingPolynomial = 0x11d
+)
+
+var (
+	logTable [fieldSize]byte
+	expTable [fieldSize * 2]byte
+	mulTable [fieldSize][fieldSize]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < fieldSize-1; i++ {
+		expTable[i] = byte(x)
+		expTable[i+fieldSize-1] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x >= fieldSize {
+			x ^= generatingPolynomial
+		}
+	}
+	for a := 0; a < fieldSize; a++ {
+		for b := 0; b < fieldSize; b++ {
+			mulTable[a][b] = galMultiply(byte(a), byte(b))
+		}
+	}
+}
+
+func galAdd(a, b byte) byte {
+	return a ^ b
+}
+
+func galMultiply(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func galDivide(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	if b == 0 {
+		panic("divide by zero")
+	}
+	logResult := int(logTable[a]) - int(logTable[b])
+	if logResult < 0 {
+		logResult += fieldSize - 1
+	}
+	return expTable[logResult]
+}
+
+func galExp(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	logResult := (int(logTable[a]) * n) % (fieldSize - 1)
+	return expTable[logResult]
+}
+
+// galMulSliceXor computes out ^= c * in
+func galMulSliceXor(c byte, in, out []byte) {
+	mt := &mulTable[c]
+	for i, v := range in {
+		out[i] ^= mt[v]
+	}
+}
+
+// galMulSlice computes out = c * in
+func galMulSlice(c byte, in, out []byte) {
+	mt := &mulTable[c]
+	for i, v := range in {
+		out[i] = mt[v]
+	}
+}
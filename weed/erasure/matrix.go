@@ -0,0 +1,98 @@
+package erasure
+
+import (
+	"errors"
+)
+
+var ErrSingularMatrix = errors.New("Matrix is singular")
+
+type matrix [][]byte
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for r := range m {
+		m[r] = make([]byte, cols)
+	}
+	return m
+}
+
+func identityMatrix(size int) matrix {
+	m := newMatrix(size, size)
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m
+}
+
+// vandermonde returns a matrix whose row r is [r^0, r^1, r^2, ...],
+// any square sub matrix of it is invertible.
+func vandermonde(rows, cols int) matrix {
+	m := newMatrix(rows, cols)
+	for r := range m {
+		for c := range m[r] {
+			m[r][c] = galExp(byte(r), c)
+		}
+	}
+	return m
+}
+
+func (m matrix) multiply(right matrix) matrix {
+	result := newMatrix(len(m), len(right[0]))
+	for r := range result {
+		for c := range result[r] {
+			var value byte
+			for i := range m[r] {
+				value ^= galMultiply(m[r][i], right[i][c])
+			}
+			result[r][c] = value
+		}
+	}
+	return result
+}
+
+func (m matrix) subMatrix(rmin, cmin, rmax, cmax int) matrix {
+	result := newMatrix(rmax-rmin, cmax-cmin)
+	for r := rmin; r < rmax; r++ {
+		copy(result[r-rmin], m[r][cmin:cmax])
+	}
+	return result
+}
+
+// invert uses Gauss-Jordan elimination on the augmented matrix [m|I]
+func (m matrix) invert() (matrix, error) {
+	size := len(m)
+	work := newMatrix(size, size*2)
+	for r := range m {
+		copy(work[r], m[r])
+		work[r][size+r] = 1
+	}
+	for r := 0; r < size; r++ {
+		if work[r][r] == 0 {
+			for below := r + 1; below < size; below++ {
+				if work[below][r] != 0 {
+					work[r], work[below] = work[below], work[r]
+					break
+				}
+			}
+		}
+		if work[r][r] == 0 {
+			return nil, ErrSingularMatrix
+		}
+		if work[r][r] != 1 {
+			scale := galDivide(1, work[r][r])
+			for c := range work[r] {
+				work[r][c] = galMultiply(work[r][c], scale)
+			}
+		}
+		for other := 0; other < size; other++ {
+			if other == r || work[other][r] == 0 {
+				continue
+			}
+			scale := work[other][r]
+			for c := range work[other] {
+				work[other][c] ^= galMultiply(scale, work[r][c])
+			}
+		}
+	}
+	return work.subMatrix(0, size, size, size*2), nil
+}
@@ -0,0 +1,193 @@
+package erasure
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidShardNum  = errors.New("Invalid number of shards")
+	ErrShardSize        = errors.New("Shards have different sizes or are empty")
+	ErrTooFewShards     = errors.New("Too few shards given to reconstruct")
+	ErrInvalidShardSize = errors.New("Shard size does not match")
+)
+
+/*
+ReedSolomon is a systematic Reed-Solomon codec.
+
+The first DataShards shards hold the original data unchanged,
+the following ParityShards shards are computed from them.
+Any DataShards shards out of the total are enough to recover all the others.
+*/
+type ReedSolomon struct {
+	DataShards   int
+	ParityShards int
+	TotalShards  int
+	m            matrix
+	parity       matrix
+}
+
+func NewReedSolomon(dataShards, parityShards int) (*ReedSolomon, error) {
+	if dataShards <= 0 || parityShards <= 0 || dataShards+parityShards > fieldSize {
+		return nil, ErrInvalidShardNum
+	}
+	r := &ReedSolomon{
+		DataShards:   dataShards,
+		ParityShards: parityShards,
+		TotalShards:  dataShards + parityShards,
+	}
+	// make the top square of the vandermonde matrix an identity matrix,
+	// so the data shards are kept as is
+	vm := vandermonde(r.TotalShards, dataShards)
+	top, err := vm.subMatrix(0, 0, dataShards, dataShards).invert()
+	if err != nil {
+		return nil, err
+	}
+	r.m = vm.multiply(top)
+	r.parity = r.m.subMatrix(dataShards, 0, r.TotalShards, dataShards)
+	return r, nil
+}
+
+// Encode fills the parity shards, all shards must be allocated with the same size
+func (r *ReedSolomon) Encode(shards [][]byte) error {
+	if len(shards) != r.TotalShards {
+		return ErrInvalidShardNum
+	}
+	if err := checkShards(shards, false); err != nil {
+		return err
+	}
+	r.codeSomeShards(r.parity, shards[:r.DataShards], shards[r.DataShards:])
+	return nil
+}
+
+// Verify checks whether the parity shards match the data shards
+func (r *ReedSolomon) Verify(shards [][]byte) (bool, error) {
+	if len(shards) != r.TotalShards {
+		return false, ErrInvalidShardNum
+	}
+	if err := checkShards(shards, false); err != nil {
+		return false, err
+	}
+	size := len(shards[0])
+	for i, row := range r.parity {
+		buf := make([]byte, size)
+		for c, in := range shards[:r.DataShards] {
+			galMulSliceXor(row[c], in, buf)
+		}
+		for j, b := range buf {
+			if b != shards[r.DataShards+i][j] {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+/*
+Reconstruct recreates the missing shards.
+Missing shards are nil or zero length entries, and at least DataShards
+shards must be present. The recreated shards are allocated in place.
+*/
+func (r *ReedSolomon) Reconstruct(shards [][]byte) error {
+	return r.reconstruct(shards, false)
+}
+
+// ReconstructData recreates only the missing data shards
+func (r *ReedSolomon) ReconstructData(shards [][]byte) error {
+	return r.reconstruct(shards, true)
+}
+
+func (r *ReedSolomon) reconstruct(shards [][]byte, dataOnly bool) error {
+	if len(shards) != r.TotalShards {
+		return ErrInvalidShardNum
+	}
+	if err := checkShards(shards, true); err != nil {
+		return err
+	}
+	shardSize := 0
+	present := 0
+	for _, shard := range shards {
+		if len(shard) != 0 {
+			shardSize = len(shard)
+			present++
+		}
+	}
+	if present == r.TotalShards {
+		return nil
+	}
+	if present < r.DataShards {
+		return ErrTooFewShards
+	}
+
+	// pick the first DataShards present shards, and the matching encoding rows
+	subShards := make([][]byte, r.DataShards)
+	subMatrix := newMatrix(r.DataShards, r.DataShards)
+	subIndex := 0
+	for i := 0; i < r.TotalShards && subIndex < r.DataShards; i++ {
+		if len(shards[i]) != 0 {
+			subShards[subIndex] = shards[i]
+			copy(subMatrix[subIndex], r.m[i])
+			subIndex++
+		}
+	}
+	decode, err := subMatrix.invert()
+	if err != nil {
+		return err
+	}
+
+	var (
+		outputs [][]byte
+		rows    matrix
+	)
+	for i := 0; i < r.DataShards; i++ {
+		if len(shards[i]) == 0 {
+			shards[i] = make([]byte, shardSize)
+			outputs = append(outputs, shards[i])
+			rows = append(rows, decode[i])
+		}
+	}
+	r.codeSomeShards(rows, subShards, outputs)
+	if dataOnly {
+		return nil
+	}
+
+	outputs, rows = nil, nil
+	for i := r.DataShards; i < r.TotalShards; i++ {
+		if len(shards[i]) == 0 {
+			shards[i] = make([]byte, shardSize)
+			outputs = append(outputs, shards[i])
+			rows = append(rows, r.parity[i-r.DataShards])
+		}
+	}
+	r.codeSomeShards(rows, shards[:r.DataShards], outputs)
+	return nil
+}
+
+func (r *ReedSolomon) codeSomeShards(rows matrix, inputs, outputs [][]byte) {
+	for i, out := range outputs {
+		galMulSlice(rows[i][0], inputs[0], out)
+		for c := 1; c < len(inputs); c++ {
+			galMulSliceXor(rows[i][c], inputs[c], out)
+		}
+	}
+}
+
+func checkShards(shards [][]byte, allowMissing bool) error {
+	size := 0
+	for _, shard := range shards {
+		if len(shard) == 0 {
+			if !allowMissing {
+				return ErrShardSize
+			}
+			continue
+		}
+		if size == 0 {
+			size = len(shard)
+		} else if len(shard) != size {
+			return ErrShardSize
+		}
+	}
+	if size == 0 {
+		return ErrShardSize
+	}
+	return nil
+}
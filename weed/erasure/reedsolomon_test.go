@@ -0,0 +1,80 @@
+package erasure
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestGaloisInverse(t *testing.T) {
+	for a := 1; a < fieldSize; a++ {
+		inv := galDivide(1, byte(a))
+		if galMultiply(byte(a), inv) != 1 {
+			t.Fatalf("%d * %d != 1", a, inv)
+		}
+	}
+}
+
+func TestEncodeAndReconstruct(t *testing.T) {
+	r, err := NewReedSolomon(10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := make([][]byte, r.TotalShards)
+	for i := range shards {
+		shards[i] = make([]byte, 1000)
+		if i < r.DataShards {
+			rand.Read(shards[i])
+		}
+	}
+	if err = r.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := r.Verify(shards); !ok || err != nil {
+		t.Fatal("verify failed", err)
+	}
+	expected := make([][]byte, len(shards))
+	for i := range shards {
+		expected[i] = append([]byte(nil), shards[i]...)
+	}
+
+	// lose any 4 shards
+	for _, lost := range [][]int{{0, 1, 2, 3}, {10, 11, 12, 13}, {0, 5, 9, 13}, {2, 7}} {
+		for _, i := range lost {
+			shards[i] = nil
+		}
+		if err = r.Reconstruct(shards); err != nil {
+			t.Fatal(err)
+		}
+		for i := range shards {
+			if !bytes.Equal(shards[i], expected[i]) {
+				t.Fatalf("shard %d differs after losing %v", i, lost)
+			}
+		}
+	}
+
+	for _, i := range []int{0, 1, 2, 3, 4} {
+		shards[i] = nil
+	}
+	if err = r.Reconstruct(shards); err != ErrTooFewShards {
+		t.Fatal("expect too few shards error, got", err)
+	}
+}
+
+func TestReconstructData(t *testing.T) {
+	r, _ := NewReedSolomon(4, 2)
+	shards := [][]byte{[]byte("ab"), []byte("cd"), []byte("ef"), []byte("gh"), make([]byte, 2), make([]byte, 2)}
+	if err := r.Encode(shards); err != nil {
+		t.Fatal(err)
+	}
+	shards[1], shards[4] = nil, nil
+	if err := r.ReconstructData(shards); err != nil {
+		t.Fatal(err)
+	}
+	if string(shards[1]) != "cd" {
+		t.Fatal("data shard is not recovered:", string(shards[1]))
+	}
+	if shards[4] != nil {
+		t.Fatal("parity shard should not be recovered")
+	}
+}
@@ -2,7 +2,9 @@ package cassandra_store
 
 import (
 	"fmt"
+	"path"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 
 	"github.com/gocql/gocql"
@@ -15,18 +17,25 @@ Basically you need a table just like this:
 CREATE TABLE seaweed_files (
    path varchar,
    fids list<varchar>,
+   meta blob,
    PRIMARY KEY (path)
 );
 
+The meta column keeps filer.EncodeFileEntry(entry), add it to an existing table with
+ALTER TABLE seaweed_files ADD meta blob;
+the rows without it are migrated on first access. Until the column is added, only
+the file ids are kept, with the default attributes.
+
 Need to match flat_namespace.FlatNamespaceStore interface
-	Put(fullFileName string, fid string) (err error)
-	Get(fullFileName string) (fid string, err error)
+	Put(fullFileName string, entry *filer.FileEntry) (err error)
+	Get(fullFileName string) (entry *filer.FileEntry, isLegacy bool, err error)
 	Delete(fullFileName string) (fid string, err error)
 
 */
 type CassandraStore struct {
 	cluster *gocql.ClusterConfig
 	session *gocql.Session
+	hasMeta bool
 }
 
 func NewCassandraStore(keyspace string, hosts ...string) (c *CassandraStore, err error) {
@@ -37,34 +46,60 @@ func NewCassandraStore(keyspace string, hosts ...string) (c *CassandraStore, err
 	c.session, err = c.cluster.CreateSession()
 	if err != nil {
 		glog.V(0).Infof("Failed to open cassandra store, hosts %v, keyspace %s", hosts, keyspace)
+		return
+	}
+	if e := c.session.Query(`SELECT meta FROM seaweed_files LIMIT 1`).Exec(); e != nil {
+		glog.V(0).Infof("No meta column in seaweed_files, the file attributes are not kept: %v", e)
+	} else {
+		c.hasMeta = true
 	}
 	return
 }
 
-func (c *CassandraStore) Put(fullFileName string, fid string) (err error) {
+func (c *CassandraStore) Put(fullFileName string, entry *filer.FileEntry) (err error) {
+	meta, err := filer.EncodeFileEntry(entry)
+	if err != nil {
+		return err
+	}
 	var input []string
-	input = append(input, fid)
-	if err := c.session.Query(
-		`INSERT INTO seaweed_files (path, fids) VALUES (?, ?)`,
-		fullFileName, input).Exec(); err != nil {
-		glog.V(0).Infof("Failed to save file %s with id %s: %v", fullFileName, fid, err)
+	input = append(input, string(entry.Id))
+	query := c.session.Query(`INSERT INTO seaweed_files (path, fids) VALUES (?, ?)`, fullFileName, input)
+	if c.hasMeta {
+		query = c.session.Query(`INSERT INTO seaweed_files (path, fids, meta) VALUES (?, ?, ?)`, fullFileName, input, meta)
+	}
+	if err := query.Exec(); err != nil {
+		glog.V(0).Infof("Failed to save file %s with id %s: %v", fullFileName, entry.Id, err)
 		return err
 	}
 	return nil
 }
-func (c *CassandraStore) Get(fullFileName string) (fid string, err error) {
+func (c *CassandraStore) Get(fullFileName string) (entry *filer.FileEntry, isLegacy bool, err error) {
 	var output []string
-	if err := c.session.Query(
-		`select fids FROM seaweed_files WHERE path = ? LIMIT 1`,
-		fullFileName).Consistency(gocql.One).Scan(&output); err != nil {
+	var meta []byte
+	query := c.session.Query(`select fids FROM seaweed_files WHERE path = ? LIMIT 1`, fullFileName)
+	dest := []interface{}{&output}
+	if c.hasMeta {
+		query = c.session.Query(`select fids, meta FROM seaweed_files WHERE path = ? LIMIT 1`, fullFileName)
+		dest = append(dest, &meta)
+	}
+	if err := query.Consistency(gocql.One).Scan(dest...); err != nil {
 		if err != gocql.ErrNotFound {
-			glog.V(0).Infof("Failed to find file %s: %v", fullFileName, fid, err)
+			glog.V(0).Infof("Failed to find file %s: %v", fullFileName, err)
 		}
 	}
+	if len(meta) > 0 {
+		return filer.DecodeFileEntry(path.Base(fullFileName), meta)
+	}
 	if len(output) == 0 {
-		return "", fmt.Errorf("No file id found for %s", fullFileName)
+		return nil, false, fmt.Errorf("No file id found for %s", fullFileName)
+	}
+	entry = &filer.FileEntry{Name: path.Base(fullFileName), Id: filer.FileId(output[0])}
+	if !c.hasMeta {
+		// there is nowhere to migrate the entry to
+		entry.Mode = filer.DefaultFileMode
+		return entry, false, nil
 	}
-	return output[0], nil
+	return entry, true, nil
 }
 
 // Currently the fid is not returned
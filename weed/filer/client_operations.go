@@ -11,9 +11,18 @@ import (
 )
 
 type ApiRequest struct {
-	Command   string //"listFiles", "listDirectories"
-	Directory string
-	FileName  string
+	Command      string //"listFiles", "listDirectories", "createFile", "deleteFile", "mkdir", "rmdir", "move", "status"
+	Directory    string
+	FileName     string
+	LastFileName string     `json:",omitempty"`
+	Limit        int        `json:",omitempty"`
+	Entry        *FileEntry `json:",omitempty"`
+	From         string     `json:",omitempty"`
+	To           string     `json:",omitempty"`
+}
+
+type ApiResult struct {
+	Error string `json:"error,omitempty"`
 }
 
 type ListFilesResult struct {
@@ -33,6 +42,19 @@ func ListFiles(server string, directory string, fileName string) (*ListFilesResu
 	}
 }
 
+// ListFilesAfter lists at most limit files of the directory, sorted by name after lastFileName
+func ListFilesAfter(server string, directory string, lastFileName string, limit int) (*ListFilesResult, error) {
+	var ret ListFilesResult
+	if err := call(server, ApiRequest{Command: "listFiles", Directory: directory, LastFileName: lastFileName, Limit: limit}, &ret); err == nil {
+		if ret.Error != "" {
+			return nil, errors.New(ret.Error)
+		}
+		return &ret, nil
+	} else {
+		return nil, err
+	}
+}
+
 type ListDirectoriesResult struct {
 	Directories []DirectoryEntry
 	Error       string `json:"error,omitempty"`
@@ -50,6 +72,56 @@ func ListDirectories(server string, directory string) (*ListDirectoriesResult, e
 	}
 }
 
+// CreateFileEntry saves the entry, the content of the replaced entry is deleted by the filer
+func CreateFileEntry(server string, directory string, entry *FileEntry) error {
+	return callForError(server, ApiRequest{Command: "createFile", Directory: directory, FileName: entry.Name, Entry: entry})
+}
+
+// DeleteFileEntry deletes the entry and its content
+func DeleteFileEntry(server string, directory string, fileName string) error {
+	return callForError(server, ApiRequest{Command: "deleteFile", Directory: directory, FileName: fileName})
+}
+
+func CreateDirectory(server string, directory string) error {
+	return callForError(server, ApiRequest{Command: "mkdir", Directory: directory})
+}
+
+// DeleteDirectory deletes an empty directory
+func DeleteDirectory(server string, directory string) error {
+	return callForError(server, ApiRequest{Command: "rmdir", Directory: directory})
+}
+
+func Move(server string, from string, to string) error {
+	return callForError(server, ApiRequest{Command: "move", From: from, To: to})
+}
+
+type FilerStatusResult struct {
+	Master string
+	Error  string `json:"error,omitempty"`
+}
+
+func FilerStatus(server string) (*FilerStatusResult, error) {
+	var ret FilerStatusResult
+	if err := call(server, ApiRequest{Command: "status"}, &ret); err != nil {
+		return nil, err
+	}
+	if ret.Error != "" {
+		return nil, errors.New(ret.Error)
+	}
+	return &ret, nil
+}
+
+func callForError(server string, request ApiRequest) error {
+	var ret ApiResult
+	if err := call(server, request, &ret); err != nil {
+		return err
+	}
+	if ret.Error != "" {
+		return errors.New(ret.Error)
+	}
+	return nil
+}
+
 func call(server string, request ApiRequest, ret interface{}) error {
 	b, err := json.Marshal(request)
 	if err != nil {
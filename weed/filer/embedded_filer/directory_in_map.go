@@ -250,6 +250,9 @@ func (dm *DirectoryManagerInMap) makeDirectory(dirPath string) (dir *DirectoryEn
 
 func (dm *DirectoryManagerInMap) MakeDirectory(dirPath string) (filer.DirectoryId, error) {
 	dir, _ := dm.makeDirectory(dirPath)
+	if dir == nil {
+		return 0, fmt.Errorf("Fail to make directory %s", dirPath)
+	}
 	return dir.Id, nil
 }
 
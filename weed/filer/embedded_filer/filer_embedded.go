@@ -6,17 +6,20 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/chrislusf/seaweedfs/weed/filer"
+	fs "github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/security"
 )
 
 type FilerEmbedded struct {
 	master      string
+	secret      security.Secret
 	directories *DirectoryManagerInMap
 	files       *FileListInLevelDb
 }
 
-func NewFilerEmbedded(master string, dir string) (filer *FilerEmbedded, err error) {
+func NewFilerEmbedded(master string, dir string, secret string) (filer *FilerEmbedded, err error) {
 	dm, de := NewDirectoryManagerInMap(filepath.Join(dir, "dir.log"))
 	if de != nil {
 		return nil, de
@@ -27,6 +30,7 @@ func NewFilerEmbedded(master string, dir string) (filer *FilerEmbedded, err erro
 	}
 	filer = &FilerEmbedded{
 		master:      master,
+		secret:      security.Secret(secret),
 		directories: dm,
 		files:       fl,
 	}
@@ -34,33 +38,88 @@ func NewFilerEmbedded(master string, dir string) (filer *FilerEmbedded, err erro
 }
 
 func (filer *FilerEmbedded) CreateFile(filePath string, fid string) (err error) {
+	return filer.CreateFileEntry(filePath, fs.NewFileEntry(filepath.Base(filePath), fid))
+}
+func (filer *FilerEmbedded) CreateFileEntry(filePath string, entry *fs.FileEntry) (err error) {
 	dir, file := filepath.Split(filePath)
 	dirId, e := filer.directories.MakeDirectory(dir)
 	if e != nil {
 		return e
 	}
-	return filer.files.CreateFile(dirId, file, fid)
+	return filer.files.CreateFile(dirId, file, entry)
 }
 func (filer *FilerEmbedded) FindFile(filePath string) (fid string, err error) {
+	entry, err := filer.FindFileEntry(filePath)
+	if err != nil {
+		return "", err
+	}
+	return string(entry.Id), nil
+}
+func (filer *FilerEmbedded) FindFileEntry(filePath string) (entry *fs.FileEntry, err error) {
 	dir, file := filepath.Split(filePath)
 	dirId, e := filer.directories.FindDirectory(dir)
 	if e != nil {
-		return "", e
+		return nil, e
+	}
+	entry, isLegacy, err := filer.files.FindFile(dirId, file)
+	if err != nil {
+		return nil, err
+	}
+	if isLegacy {
+		entry = filer.migrate(dirId, file, string(entry.Id))
+	}
+	return entry, nil
+}
+
+// migrate fills the attributes of a legacy entry, it is saved only if the file can be found
+// and the entry is not changed in between
+func (filer *FilerEmbedded) migrate(dirId fs.DirectoryId, fileName string, fid string) *fs.FileEntry {
+	entry, err := fs.StatFileEntry(filer.master, filer.secret, fileName, fid)
+	if err == nil {
+		if current, isLegacy, fe := filer.files.FindFile(dirId, fileName); fe != nil || !isLegacy || string(current.Id) != fid {
+			return entry
+		}
+		if err = filer.files.CreateFile(dirId, fileName, entry); err != nil {
+			glog.V(0).Infof("migrate file entry %s in directory %d: %v", fileName, dirId, err)
+		}
 	}
-	return filer.files.FindFile(dirId, file)
+	return entry
+}
+func (filer *FilerEmbedded) CreateDirectory(dirPath string) (err error) {
+	_, err = filer.directories.MakeDirectory(dirPath)
+	return
 }
-func (filer *FilerEmbedded) FindDirectory(dirPath string) (dirId filer.DirectoryId, err error) {
+func (filer *FilerEmbedded) FindDirectory(dirPath string) (dirId fs.DirectoryId, err error) {
 	return filer.directories.FindDirectory(dirPath)
 }
-func (filer *FilerEmbedded) ListDirectories(dirPath string) (dirs []filer.DirectoryEntry, err error) {
+func (filer *FilerEmbedded) ListDirectories(dirPath string) (dirs []fs.DirectoryEntry, err error) {
 	return filer.directories.ListDirectories(dirPath)
 }
-func (filer *FilerEmbedded) ListFiles(dirPath string, lastFileName string, limit int) (files []filer.FileEntry, err error) {
+func (filer *FilerEmbedded) ListFiles(dirPath string, lastFileName string, limit int) (files []fs.FileEntry, err error) {
 	dirId, e := filer.directories.FindDirectory(dirPath)
 	if e != nil {
 		return nil, e
 	}
-	return filer.files.ListFiles(dirId, lastFileName, limit), nil
+	files, legacyFiles := filer.files.ListFiles(dirId, lastFileName, limit)
+	if len(legacyFiles) > 0 {
+		// the legacy entries are listed with the default attributes, and migrated in the background
+		isLegacy := make(map[string]bool)
+		for _, name := range legacyFiles {
+			isLegacy[name] = true
+		}
+		var legacyEntries []fs.FileEntry
+		for _, entry := range files {
+			if isLegacy[entry.Name] {
+				legacyEntries = append(legacyEntries, entry)
+			}
+		}
+		go func() {
+			for _, entry := range legacyEntries {
+				filer.migrate(dirId, entry.Name, string(entry.Id))
+			}
+		}()
+	}
+	return files, nil
 }
 func (filer *FilerEmbedded) DeleteDirectory(dirPath string, recursive bool) (err error) {
 	dirId, e := filer.directories.FindDirectory(dirPath)
@@ -77,7 +136,7 @@ func (filer *FilerEmbedded) DeleteDirectory(dirPath string, recursive bool) (err
 			}
 		}
 	}
-	list := filer.files.ListFiles(dirId, "", 100)
+	list, _ := filer.files.ListFiles(dirId, "", 100)
 	if len(list) != 0 && !recursive {
 		if !recursive {
 			return fmt.Errorf("Fail to delete non-empty directory %s!", dirPath)
@@ -89,7 +148,7 @@ func (filer *FilerEmbedded) DeleteDirectory(dirPath string, recursive bool) (err
 		}
 		var fids []string
 		for _, fileEntry := range list {
-			fids = append(fids, string(fileEntry.Id))
+			fids = append(fids, fileEntry.Fids()...)
 		}
 		if result_list, delete_file_err := operation.DeleteFiles(filer.master, fids); delete_file_err != nil {
 			return delete_file_err
@@ -99,7 +158,7 @@ func (filer *FilerEmbedded) DeleteDirectory(dirPath string, recursive bool) (err
 			}
 		}
 		lastFile := list[len(list)-1]
-		list = filer.files.ListFiles(dirId, lastFile.Name, 100)
+		list, _ = filer.files.ListFiles(dirId, lastFile.Name, 100)
 	}
 
 }
@@ -110,7 +169,11 @@ func (filer *FilerEmbedded) DeleteFile(filePath string) (fid string, err error)
 	if e != nil {
 		return "", e
 	}
-	return filer.files.DeleteFile(dirId, file)
+	entry, err := filer.files.DeleteFile(dirId, file)
+	if entry != nil {
+		fid = string(entry.Id)
+	}
+	return
 }
 
 /*
@@ -129,13 +192,16 @@ func (filer *FilerEmbedded) Move(fromPath string, toPath string) error {
 		// move folder to a new folder
 		return filer.directories.MoveUnderDirectory(fromPath, filepath.Dir(toPath), filepath.Base(toPath))
 	}
-	if fid, file_err := filer.DeleteFile(fromPath); file_err == nil {
+	if entry, file_err := filer.FindFileEntry(fromPath); file_err == nil {
+		if _, err := filer.DeleteFile(fromPath); err != nil {
+			return err
+		}
 		if _, err := filer.FindDirectory(toPath); err == nil {
 			// move file under an existing folder
-			return filer.CreateFile(filepath.Join(toPath, filepath.Base(fromPath)), fid)
+			return filer.CreateFileEntry(filepath.Join(toPath, filepath.Base(fromPath)), entry)
 		}
 		// move to a folder with new name
-		return filer.CreateFile(toPath, fid)
+		return filer.CreateFileEntry(toPath, entry)
 	}
 	return fmt.Errorf("File %s is not found!", fromPath)
 }
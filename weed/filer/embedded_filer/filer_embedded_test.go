@@ -0,0 +1,241 @@
+package embedded_filer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+)
+
+func TestFileEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the server is both the master and the volume server
+	mtime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dir/lookup" {
+			w.Write([]byte(`{"volumeId":"3","locations":[{"url":"` + r.Host + `"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "123")
+		w.Header().Set("Last-Modified", mtime.Format(http.TimeFormat))
+	}))
+	defer server.Close()
+	master := strings.TrimPrefix(server.URL, "http://")
+
+	f, err := NewFilerEmbedded(master, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	entry := &filer.FileEntry{
+		Id: "4,01637037d6",
+		Attributes: filer.Attributes{
+			Size:   456,
+			Mtime:  now,
+			Crtime: now,
+			Mode:   0600,
+			Uid:    1000,
+			Mime:   "image/png",
+		},
+		Chunks: []filer.FileChunk{{Fid: "4,01637037d7", Offset: 0, Size: 456}},
+	}
+	if err = f.CreateFileEntry("/a/b.png", entry); err != nil {
+		t.Fatal(err)
+	}
+	found, err := f.FindFileEntry("/a/b.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Name != "b.png" || found.Size != 456 || found.Mode != 0600 || found.Uid != 1000 ||
+		found.Mime != "image/png" || !found.Mtime.Equal(now) || len(found.Chunks) != 1 {
+		t.Errorf("unexpected entry %+v", found)
+	}
+	if fids := found.Fids(); len(fids) != 2 {
+		t.Errorf("unexpected fids %v", fids)
+	}
+
+	// entries written by older versions only have the file id
+	dirId, _ := f.FindDirectory("/a/")
+	if err = f.files.db.Put(genKey(dirId, "c.txt"), []byte("3,01637037d6"), nil); err != nil {
+		t.Fatal(err)
+	}
+	files, err := f.ListFiles("/a/", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[1].Name != "c.txt" || files[1].Id != "3,01637037d6" || files[1].Mode != filer.DefaultFileMode {
+		t.Errorf("legacy entry is not listed: %+v", files)
+	}
+	// the listed legacy entries are migrated in the background
+	var migrated *filer.FileEntry
+	for i := 0; i < 100; i++ {
+		var isLegacy bool
+		if migrated, isLegacy, err = f.files.FindFile(dirId, "c.txt"); err == nil && !isLegacy {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if migrated == nil || migrated.Size != 123 || migrated.Mime != "text/plain" ||
+		!migrated.Mtime.Equal(mtime) || migrated.Mode != filer.DefaultFileMode {
+		t.Errorf("legacy entry is not migrated: %+v", migrated)
+	}
+	if fid, _ := f.FindFile("/a/c.txt"); fid != "3,01637037d6" {
+		t.Errorf("unexpected fid %s", fid)
+	}
+
+	// the created files are not stated, the server would say 123 bytes
+	if err = f.CreateFile("/a/e.txt", "3,01637037d8"); err != nil {
+		t.Fatal(err)
+	}
+	if created, err := f.FindFileEntry("/a/e.txt"); err != nil || created.Size != 0 ||
+		created.Mode != filer.DefaultFileMode || created.Mtime.IsZero() {
+		t.Errorf("unexpected created entry %+v %v", created, err)
+	}
+
+	if err = f.Move("/a/b.png", "/d.png"); err != nil {
+		t.Fatal(err)
+	}
+	if moved, err := f.FindFileEntry("/d.png"); err != nil || moved.Mime != "image/png" || moved.Name != "d.png" {
+		t.Errorf("attributes are lost after moving: %+v %v", moved, err)
+	}
+}
+
+func TestTrashFiler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the server is both the master and the volume server
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/dedup/release":
+			w.Write([]byte(`{"delete":["` + strings.Join(r.Form["fid"], `","`) + `"]}`))
+		case "/vol/lookup":
+			w.Write([]byte(`{"5":{"volumeId":"5","locations":[{"url":"` + r.Host + `"}]}}`))
+		case "/delete":
+			deleted = append(deleted, r.Form["fid"]...)
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+	embedded, err := NewFilerEmbedded(strings.TrimPrefix(server.URL, "http://"), dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := filer.NewTrashFiler(embedded)
+	for _, name := range []string{"/a/b.txt", "/a/c/d.txt"} {
+		if err = f.CreateFileEntry(name, &filer.FileEntry{Id: "5,01637037d6"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fid, err := f.DeleteFile("/a/b.txt"); err != nil || fid != "" {
+		t.Fatalf("trashed file should not return its fid: %q %v", fid, err)
+	}
+	if _, err = f.FindFileEntry("/a/b.txt"); err == nil {
+		t.Errorf("deleted file is still found")
+	}
+	if err = f.DeleteDirectory("/a/c/", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.FindDirectory("/a/c/"); err == nil {
+		t.Errorf("deleted directory is still found")
+	}
+	trashed, err := f.ListDirectories(filer.TrashDir)
+	if err != nil || len(trashed) == 0 {
+		t.Fatalf("nothing in the trash: %v", err)
+	}
+	var found []string
+	for _, d := range trashed {
+		for _, name := range []string{"/a/b.txt", "/a/c/d.txt"} {
+			if _, err := f.FindFileEntry(filer.TrashDir + "/" + d.Name + name); err == nil {
+				found = append(found, name)
+			}
+		}
+	}
+	if len(found) != 2 || len(deleted) != 0 {
+		t.Fatalf("deleted entries should be kept with their content, found %v, deleted %v", found, deleted)
+	}
+
+	if err = f.Purge(time.Now().Add(-time.Hour)); err != nil || len(deleted) != 0 {
+		t.Errorf("recent entries should not be purged: %v %v", deleted, err)
+	}
+	if err = f.Purge(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("content of the purged entries should be deleted: %v", deleted)
+	}
+	if trashed, _ = f.ListDirectories(filer.TrashDir); len(trashed) != 0 {
+		t.Errorf("purged entries are left: %v", trashed)
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := NewFilerEmbedded("127.0.0.1:1", dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := filer.NewVersioning(f, "/configs, /docs/", 2, time.Hour)
+	if !v.IsVersioned("/configs/app.yaml") || !v.IsVersioned("/docs/a/b.txt") || v.IsVersioned("/configsx/app.yaml") {
+		t.Errorf("unexpected versioned directories")
+	}
+
+	now := time.Now()
+	var expired []filer.FileEntry
+	for i, fid := range []string{"3,01", "3,02", "3,03"} {
+		if expired, err = v.SaveVersion("/configs/app.yaml", &filer.FileEntry{Name: "app.yaml", Id: filer.FileId(fid)}, now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(expired) != 1 || expired[0].Id != "3,01" {
+		t.Errorf("the oldest version should be expired: %+v", expired)
+	}
+	versions, err := v.ListVersions("/configs/app.yaml")
+	if err != nil || len(versions) != 2 || versions[0].Id != "3,03" || versions[1].Id != "3,02" {
+		t.Fatalf("unexpected versions %+v %v", versions, err)
+	}
+
+	if err = f.CreateFileEntry("/configs/app.yaml", &filer.FileEntry{Name: "app.yaml", Id: "3,04"}); err != nil {
+		t.Fatal(err)
+	}
+	if expired, err = v.Restore("/configs/app.yaml", versions[1].Name, now.Add(3*time.Second)); err != nil || len(expired) != 0 {
+		t.Fatalf("restore: %+v %v", expired, err)
+	}
+	if fid, _ := f.FindFile("/configs/app.yaml"); fid != "3,02" {
+		t.Errorf("restored file has fid %s", fid)
+	}
+	if versions, _ = v.ListVersions("/configs/app.yaml"); len(versions) != 2 || versions[0].Id != "3,04" || versions[1].Id != "3,03" {
+		t.Errorf("replaced entry should be the latest version: %+v", versions)
+	}
+	if _, err = v.FindVersion("/configs/app.yaml", "20170101-000000.000000000"); err == nil {
+		t.Errorf("missing version should not be found")
+	}
+
+	if expired, err = v.SaveVersion("/configs/app.yaml", &filer.FileEntry{Name: "app.yaml", Id: "3,05"}, now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 2 {
+		t.Errorf("versions older than an hour should be expired: %+v", expired)
+	}
+}
@@ -12,7 +12,7 @@ import (
 /*
 The entry in level db has this format:
   key: genKey(dirId, fileName)
-  value: []byte(fid)
+  value: filer.EncodeFileEntry(entry), or []byte(fid) for legacy entries
 And genKey(dirId, fileName) use first 4 bytes to store dirId, and rest for fileName
 */
 
@@ -37,28 +37,34 @@ func genKey(dirId filer.DirectoryId, fileName string) []byte {
 	return ret
 }
 
-func (fl *FileListInLevelDb) CreateFile(dirId filer.DirectoryId, fileName string, fid string) (err error) {
-	glog.V(4).Infoln("directory", dirId, "fileName", fileName, "fid", fid)
-	return fl.db.Put(genKey(dirId, fileName), []byte(fid), nil)
+func (fl *FileListInLevelDb) CreateFile(dirId filer.DirectoryId, fileName string, entry *filer.FileEntry) (err error) {
+	glog.V(4).Infoln("directory", dirId, "fileName", fileName, "fid", entry.Id)
+	data, err := filer.EncodeFileEntry(entry)
+	if err != nil {
+		return err
+	}
+	return fl.db.Put(genKey(dirId, fileName), data, nil)
 }
-func (fl *FileListInLevelDb) DeleteFile(dirId filer.DirectoryId, fileName string) (fid string, err error) {
-	if fid, err = fl.FindFile(dirId, fileName); err != nil {
+func (fl *FileListInLevelDb) DeleteFile(dirId filer.DirectoryId, fileName string) (entry *filer.FileEntry, err error) {
+	if entry, _, err = fl.FindFile(dirId, fileName); err != nil {
 		if err == leveldb.ErrNotFound {
-			return "", nil
+			return nil, nil
 		}
 		return
 	}
 	err = fl.db.Delete(genKey(dirId, fileName), nil)
-	return fid, err
+	return entry, err
 }
-func (fl *FileListInLevelDb) FindFile(dirId filer.DirectoryId, fileName string) (fid string, err error) {
+func (fl *FileListInLevelDb) FindFile(dirId filer.DirectoryId, fileName string) (entry *filer.FileEntry, isLegacy bool, err error) {
 	data, e := fl.db.Get(genKey(dirId, fileName), nil)
 	if e != nil {
-		return "", e
+		return nil, false, e
 	}
-	return string(data), nil
+	return filer.DecodeFileEntry(fileName, data)
 }
-func (fl *FileListInLevelDb) ListFiles(dirId filer.DirectoryId, lastFileName string, limit int) (files []filer.FileEntry) {
+
+// ListFiles lists the file entries, and the names of legacy entries which only have the file id
+func (fl *FileListInLevelDb) ListFiles(dirId filer.DirectoryId, lastFileName string, limit int) (files []filer.FileEntry, legacyFiles []string) {
 	glog.V(4).Infoln("directory", dirId, "lastFileName", lastFileName, "limit", limit)
 	dirKey := genKey(dirId, "")
 	iter := fl.db.NewIterator(&util.Range{Start: genKey(dirId, lastFileName)}, nil)
@@ -78,7 +84,15 @@ func (fl *FileListInLevelDb) ListFiles(dirId filer.DirectoryId, lastFileName str
 				break
 			}
 		}
-		files = append(files, filer.FileEntry{Name: fileName, Id: filer.FileId(string(iter.Value()))})
+		entry, isLegacy, err := filer.DecodeFileEntry(fileName, iter.Value())
+		if err != nil {
+			glog.V(0).Infof("decode file entry %s in directory %d: %v", fileName, dirId, err)
+			continue
+		}
+		if isLegacy {
+			legacyFiles = append(legacyFiles, fileName)
+		}
+		files = append(files, *entry)
 	}
 	iter.Release()
 	return
@@ -0,0 +1,80 @@
+package filer
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/security"
+)
+
+const DefaultFileMode = 0644
+
+/*
+The file entries are stored as json, without the name which is part of the key.
+Entries written by older versions only have the file id, they are decoded as
+legacy entries with the default attributes, and the stores rewrite them with the
+attributes of the file on first access, or in the background when they are listed.
+*/
+func EncodeFileEntry(entry *FileEntry) ([]byte, error) {
+	e := *entry
+	e.Name = ""
+	return json.Marshal(&e)
+}
+
+func DecodeFileEntry(name string, data []byte) (entry *FileEntry, isLegacy bool, err error) {
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return &FileEntry{Name: name, Id: FileId(data), Attributes: Attributes{Mode: DefaultFileMode}}, true, nil
+	}
+	entry = &FileEntry{}
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil, false, err
+	}
+	entry.Name = name
+	return entry, false, nil
+}
+
+// NewFileEntry creates an entry for the file with the default attributes,
+// the callers knowing the attributes set them and use CreateFileEntry instead.
+func NewFileEntry(name string, fid string) *FileEntry {
+	now := time.Now()
+	return &FileEntry{
+		Name: name,
+		Id:   FileId(fid),
+		Attributes: Attributes{
+			Mode:   DefaultFileMode,
+			Mtime:  now,
+			Crtime: now,
+		},
+	}
+}
+
+// StatFileEntry creates an entry for a legacy file, with the attributes from the volume server,
+// asked with a token signed by the secret if the reads are secured.
+// The error is returned together with an entry of default attributes if the file can not be found.
+func StatFileEntry(master string, secret security.Secret, name string, fid string) (*FileEntry, error) {
+	entry := NewFileEntry(name, fid)
+	stat, err := operation.StatFile(master, fid, "", security.GenReadJwt(secret, fid))
+	if err != nil {
+		glog.V(1).Infof("stat file %s %s: %v", name, fid, err)
+		return entry, err
+	}
+	entry.Size = uint64(stat.Size)
+	entry.Mime = stat.Mime
+	entry.Mtime = stat.LastModified
+	entry.Crtime = stat.LastModified
+	return entry, nil
+}
+
+// Fids lists the file id and the chunk file ids, to delete the file content
+func (entry *FileEntry) Fids() (fids []string) {
+	if entry.Id != "" {
+		fids = append(fids, string(entry.Id))
+	}
+	for _, c := range entry.Chunks {
+		fids = append(fids, c.Fid)
+	}
+	return
+}
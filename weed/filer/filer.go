@@ -1,10 +1,37 @@
 package filer
 
+import (
+	"os"
+	"time"
+)
+
 type FileId string //file id in SeaweedFS
 
+type Attributes struct {
+	Size        uint64      `json:"size"`
+	Mtime       time.Time   `json:"mtime"`
+	Crtime      time.Time   `json:"crtime"`
+	Mode        os.FileMode `json:"mode"`
+	Uid         uint32      `json:"uid"`
+	Gid         uint32      `json:"gid"`
+	Mime        string      `json:"mime,omitempty"`
+	Replication string      `json:"replication,omitempty"`
+	Collection  string      `json:"collection,omitempty"`
+	Ttl         string      `json:"ttl,omitempty"`
+}
+
+// FileChunk is a part of a file, for files written in several uploads.
+type FileChunk struct {
+	Fid    string `json:"fid"`
+	Offset int64  `json:"offset"`
+	Size   uint64 `json:"size"`
+}
+
 type FileEntry struct {
 	Name string `json:"name,omitempty"` //file name without path
 	Id   FileId `json:"fid,omitempty"`
+	Attributes
+	Chunks []FileChunk `json:"chunks,omitempty"`
 }
 
 type DirectoryId int32
@@ -18,8 +45,11 @@ type Filer interface {
 	CreateFile(fullFileName string, fid string) (err error)
 	FindFile(fullFileName string) (fid string, err error)
 	DeleteFile(fullFileName string) (fid string, err error)
+	CreateFileEntry(fullFileName string, entry *FileEntry) (err error)
+	FindFileEntry(fullFileName string) (entry *FileEntry, err error)
 
 	//Optional functions. embedded filer support these
+	CreateDirectory(dirPath string) (err error)
 	FindDirectory(dirPath string) (dirId DirectoryId, err error)
 	ListDirectories(dirPath string) (dirs []DirectoryEntry, err error)
 	ListFiles(dirPath string, lastFileName string, limit int) (files []FileEntry, err error)
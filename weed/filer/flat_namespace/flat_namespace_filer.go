@@ -2,12 +2,17 @@ package flat_namespace
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
 
-	"github.com/chrislusf/seaweedfs/weed/filer"
+	fs "github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
 )
 
 type FlatNamespaceFiler struct {
 	master string
+	secret security.Secret
 	store  FlatNamespaceStore
 }
 
@@ -15,26 +20,55 @@ var (
 	ErrNotImplemented = errors.New("Not Implemented for flat namespace meta data store")
 )
 
-func NewFlatNamespaceFiler(master string, store FlatNamespaceStore) *FlatNamespaceFiler {
+func NewFlatNamespaceFiler(master string, store FlatNamespaceStore, secret string) *FlatNamespaceFiler {
 	return &FlatNamespaceFiler{
 		master: master,
+		secret: security.Secret(secret),
 		store:  store,
 	}
 }
 
 func (filer *FlatNamespaceFiler) CreateFile(fullFileName string, fid string) (err error) {
-	return filer.store.Put(fullFileName, fid)
+	return filer.store.Put(fullFileName, fs.NewFileEntry(filepath.Base(fullFileName), fid))
+}
+func (filer *FlatNamespaceFiler) CreateFileEntry(fullFileName string, entry *fs.FileEntry) (err error) {
+	return filer.store.Put(fullFileName, entry)
 }
 func (filer *FlatNamespaceFiler) FindFile(fullFileName string) (fid string, err error) {
-	return filer.store.Get(fullFileName)
+	entry, _, err := filer.store.Get(fullFileName)
+	if err != nil || entry == nil {
+		return "", err
+	}
+	return string(entry.Id), nil
+}
+func (filer *FlatNamespaceFiler) FindFileEntry(fullFileName string) (entry *fs.FileEntry, err error) {
+	entry, isLegacy, err := filer.store.Get(fullFileName)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("File %s is not found!", fullFileName)
+	}
+	if isLegacy {
+		// fill the attributes of the legacy entry, it is saved only if the file can be found
+		if entry, err = fs.StatFileEntry(filer.master, filer.secret, filepath.Base(fullFileName), string(entry.Id)); err == nil {
+			if err = filer.store.Put(fullFileName, entry); err != nil {
+				glog.V(0).Infof("migrate file entry %s: %v", fullFileName, err)
+			}
+		}
+	}
+	return entry, nil
+}
+func (filer *FlatNamespaceFiler) CreateDirectory(dirPath string) (err error) {
+	return ErrNotImplemented
 }
-func (filer *FlatNamespaceFiler) FindDirectory(dirPath string) (dirId filer.DirectoryId, err error) {
+func (filer *FlatNamespaceFiler) FindDirectory(dirPath string) (dirId fs.DirectoryId, err error) {
 	return 0, ErrNotImplemented
 }
-func (filer *FlatNamespaceFiler) ListDirectories(dirPath string) (dirs []filer.DirectoryEntry, err error) {
+func (filer *FlatNamespaceFiler) ListDirectories(dirPath string) (dirs []fs.DirectoryEntry, err error) {
 	return nil, ErrNotImplemented
 }
-func (filer *FlatNamespaceFiler) ListFiles(dirPath string, lastFileName string, limit int) (files []filer.FileEntry, err error) {
+func (filer *FlatNamespaceFiler) ListFiles(dirPath string, lastFileName string, limit int) (files []fs.FileEntry, err error) {
 	return nil, ErrNotImplemented
 }
 func (filer *FlatNamespaceFiler) DeleteDirectory(dirPath string, recursive bool) (err error) {
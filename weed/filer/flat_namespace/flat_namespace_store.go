@@ -1,7 +1,12 @@
 package flat_namespace
 
+import (
+	"github.com/chrislusf/seaweedfs/weed/filer"
+)
+
 type FlatNamespaceStore interface {
-	Put(fullFileName string, fid string) (err error)
-	Get(fullFileName string) (fid string, err error)
+	Put(fullFileName string, entry *filer.FileEntry) (err error)
+	// Get returns a nil entry if the file is not found, isLegacy is true if the entry only has the file id
+	Get(fullFileName string) (entry *filer.FileEntry, isLegacy bool, err error)
 	Delete(fullFileName string) (fid string, err error)
 }
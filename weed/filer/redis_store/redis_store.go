@@ -1,9 +1,16 @@
 package redis_store
 
 import (
+	"path"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	redis "gopkg.in/redis.v2"
 )
 
+/*
+The value is filer.EncodeFileEntry(entry), or the file id for entries written by older versions.
+*/
+
 type RedisStore struct {
 	Client *redis.Client
 }
@@ -17,15 +24,22 @@ func NewRedisStore(hostPort string, password string, database int) *RedisStore {
 	return &RedisStore{Client: client}
 }
 
-func (s *RedisStore) Get(fullFileName string) (fid string, err error) {
-	fid, err = s.Client.Get(fullFileName).Result()
+func (s *RedisStore) Get(fullFileName string) (entry *filer.FileEntry, isLegacy bool, err error) {
+	value, err := s.Client.Get(fullFileName).Result()
 	if err == redis.Nil {
-		err = nil
+		return nil, false, nil
 	}
-	return fid, err
+	if err != nil {
+		return nil, false, err
+	}
+	return filer.DecodeFileEntry(path.Base(fullFileName), []byte(value))
 }
-func (s *RedisStore) Put(fullFileName string, fid string) (err error) {
-	_, err = s.Client.Set(fullFileName, fid).Result()
+func (s *RedisStore) Put(fullFileName string, entry *filer.FileEntry) (err error) {
+	value, err := filer.EncodeFileEntry(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.Set(fullFileName, string(value)).Result()
 	if err == redis.Nil {
 		err = nil
 	}
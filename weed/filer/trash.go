@@ -0,0 +1,91 @@
+package filer
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// TrashDir keeps the entries deleted through a TrashFiler, under the time of
+// the deletion and their original path, e.g. /.trash/20171017-203450.123/dir/file.txt.
+// Moving them back restores them.
+const TrashDir = "/.trash"
+
+const trashTimeFormat = "20060102-150405.000"
+
+// TrashFiler moves the deleted files and directories into TrashDir instead of
+// dropping them, so their content is kept until they are purged.
+// Deleting the entries inside TrashDir removes them for good.
+type TrashFiler struct {
+	Filer
+}
+
+func NewTrashFiler(f Filer) *TrashFiler {
+	return &TrashFiler{Filer: f}
+}
+
+// InTrash tells whether the path is inside TrashDir
+func InTrash(fullPath string) bool {
+	fullPath = filepath.Clean("/" + fullPath)
+	return fullPath == TrashDir || strings.HasPrefix(fullPath, TrashDir+"/")
+}
+
+func trashPath(fullPath string, now time.Time) string {
+	return filepath.Join(TrashDir, now.UTC().Format(trashTimeFormat), filepath.Clean("/"+fullPath))
+}
+
+// DeleteFile moves the file into the trash, and returns no fid
+// since the content is still referenced.
+func (t *TrashFiler) DeleteFile(fullFileName string) (fid string, err error) {
+	if InTrash(fullFileName) {
+		return t.Filer.DeleteFile(fullFileName)
+	}
+	entry, err := t.Filer.FindFileEntry(fullFileName)
+	if err != nil {
+		return "", err
+	}
+	if err = t.Filer.CreateFileEntry(trashPath(fullFileName, time.Now()), entry); err != nil {
+		return "", err
+	}
+	_, err = t.Filer.DeleteFile(fullFileName)
+	return "", err
+}
+
+// DeleteDirectory moves the directory into the trash if it is deleted recursively.
+// The empty directories are just deleted.
+func (t *TrashFiler) DeleteDirectory(dirPath string, recursive bool) (err error) {
+	if !recursive || InTrash(dirPath) {
+		return t.Filer.DeleteDirectory(dirPath, recursive)
+	}
+	if filepath.Clean("/"+dirPath) == "/" {
+		return errors.New("can not move the root directory into the trash")
+	}
+	to := trashPath(dirPath, time.Now())
+	if err = t.Filer.CreateDirectory(filepath.Dir(to)); err != nil {
+		return err
+	}
+	return t.Filer.Move(dirPath, to)
+}
+
+// Purge removes the entries deleted before the time, with their content.
+func (t *TrashFiler) Purge(before time.Time) error {
+	dirs, err := t.Filer.ListDirectories(TrashDir)
+	if err != nil {
+		// nothing is deleted yet
+		return nil
+	}
+	for _, dir := range dirs {
+		deletedAt, err := time.Parse(trashTimeFormat, dir.Name)
+		if err != nil || !deletedAt.Before(before) {
+			continue
+		}
+		if err = t.Filer.DeleteDirectory(filepath.Join(TrashDir, dir.Name), true); err != nil {
+			return err
+		}
+		glog.V(1).Infof("purged %s/%s", TrashDir, dir.Name)
+	}
+	return nil
+}
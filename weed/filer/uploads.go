@@ -0,0 +1,152 @@
+package filer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// UploadsDir keeps the sessions of the resumable uploads, one directory per upload id.
+// The session entry records the collection, the replication and the ttl of the upload,
+// and its mtime is the time of the last activity. The parts are the entries named by
+// their part numbers, e.g. /.uploads/0123456789abcdef0123456789abcdef/00001.
+const UploadsDir = "/.uploads"
+
+const (
+	MaxPartNumber    = 10000
+	uploadSessionKey = "session"
+)
+
+// UploadSessions keeps the parts of the files uploaded in several requests,
+// until they are joined into one file or aborted.
+type UploadSessions struct {
+	filer Filer
+}
+
+func NewUploadSessions(f Filer) *UploadSessions {
+	return &UploadSessions{filer: f}
+}
+
+func uploadDir(uploadId string) string {
+	return UploadsDir + "/" + uploadId
+}
+
+func partFileName(partNumber int) string {
+	return fmt.Sprintf("%05d", partNumber)
+}
+
+// Create starts a session, and returns its upload id
+func (u *UploadSessions) Create(collection, replication, ttl string, now time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	uploadId := hex.EncodeToString(b)
+	session := &FileEntry{
+		Name: uploadSessionKey,
+		Attributes: Attributes{
+			Mtime:       now,
+			Crtime:      now,
+			Mode:        DefaultFileMode,
+			Collection:  collection,
+			Replication: replication,
+			Ttl:         ttl,
+		},
+	}
+	if err := u.filer.CreateFileEntry(filepath.Join(uploadDir(uploadId), uploadSessionKey), session); err != nil {
+		return "", err
+	}
+	return uploadId, nil
+}
+
+// Session finds the session entry of the upload
+func (u *UploadSessions) Session(uploadId string) (*FileEntry, error) {
+	if _, err := hex.DecodeString(uploadId); err != nil || len(uploadId) != 32 {
+		return nil, fmt.Errorf("invalid upload id %q", uploadId)
+	}
+	session, err := u.filer.FindFileEntry(filepath.Join(uploadDir(uploadId), uploadSessionKey))
+	if err != nil {
+		return nil, fmt.Errorf("upload %s is not found", uploadId)
+	}
+	return session, nil
+}
+
+// PutPart records the uploaded part, and returns the file id of the part it replaces
+func (u *UploadSessions) PutPart(uploadId string, partNumber int, fid string, size uint64, now time.Time) (replaced string, err error) {
+	if partNumber < 1 || partNumber > MaxPartNumber {
+		return "", fmt.Errorf("part number %d is not between 1 and %d", partNumber, MaxPartNumber)
+	}
+	session, err := u.Session(uploadId)
+	if err != nil {
+		return "", err
+	}
+	partPath := filepath.Join(uploadDir(uploadId), partFileName(partNumber))
+	if old, e := u.filer.FindFileEntry(partPath); e == nil {
+		replaced = string(old.Id)
+	}
+	part := &FileEntry{
+		Name: partFileName(partNumber),
+		Id:   FileId(fid),
+		Attributes: Attributes{
+			Size:   size,
+			Mtime:  now,
+			Crtime: now,
+			Mode:   DefaultFileMode,
+		},
+	}
+	if err = u.filer.CreateFileEntry(partPath, part); err != nil {
+		return "", err
+	}
+	session.Mtime = now
+	return replaced, u.filer.CreateFileEntry(filepath.Join(uploadDir(uploadId), uploadSessionKey), session)
+}
+
+// Parts lists the uploaded parts by their part numbers, the entry names are the part numbers
+func (u *UploadSessions) Parts(uploadId string) ([]FileEntry, error) {
+	files, err := u.filer.ListFiles(uploadDir(uploadId), "", 0)
+	if err != nil {
+		return nil, err
+	}
+	parts := []FileEntry{}
+	for _, f := range files {
+		if partNumber, e := strconv.Atoi(f.Name); e == nil {
+			f.Name = strconv.Itoa(partNumber)
+			parts = append(parts, f)
+		}
+	}
+	return parts, nil
+}
+
+// Remove drops the session and its parts, the content of the parts is left to the caller
+func (u *UploadSessions) Remove(uploadId string) error {
+	files, err := u.filer.ListFiles(uploadDir(uploadId), "", 0)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if _, err = u.filer.DeleteFile(filepath.Join(uploadDir(uploadId), f.Name)); err != nil {
+			return err
+		}
+	}
+	return u.filer.DeleteDirectory(uploadDir(uploadId), false)
+}
+
+// Expired lists the uploads without activity since the time
+func (u *UploadSessions) Expired(before time.Time) ([]string, error) {
+	dirs, err := u.filer.ListDirectories(UploadsDir)
+	if err != nil {
+		// no upload is started yet
+		return nil, nil
+	}
+	var expired []string
+	for _, dir := range dirs {
+		session, err := u.filer.FindFileEntry(filepath.Join(uploadDir(dir.Name), uploadSessionKey))
+		if err != nil || session.Mtime.Before(before) {
+			expired = append(expired, dir.Name)
+		}
+	}
+	return expired, nil
+}
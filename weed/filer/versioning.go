@@ -0,0 +1,138 @@
+package filer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VersionsDir keeps the previous entries of the versioned files, under their path
+// and the time they were replaced, e.g. /.versions/configs/app.yaml/20171017-203450.123456789.
+const VersionsDir = "/.versions"
+
+const versionTimeFormat = "20060102-150405.000000000"
+
+// Versioning keeps the previous entries of the files written under its directories.
+// The versions beyond MaxVersions, or older than MaxAge, are expired when a new
+// version is saved, and their content should be deleted by the caller.
+type Versioning struct {
+	filer       Filer
+	dirs        []string
+	MaxVersions int           // 0 keeps any number of versions
+	MaxAge      time.Duration // 0 keeps the versions forever
+}
+
+// NewVersioning keeps the versions of the files in the comma separated directories
+func NewVersioning(f Filer, dirs string, maxVersions int, maxAge time.Duration) *Versioning {
+	v := &Versioning{filer: f, MaxVersions: maxVersions, MaxAge: maxAge}
+	for _, dir := range strings.Split(dirs, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			v.dirs = append(v.dirs, filepath.Clean("/"+dir))
+		}
+	}
+	return v
+}
+
+// IsVersioned tells whether the previous entries of the file are kept
+func (v *Versioning) IsVersioned(fullPath string) bool {
+	fullPath = filepath.Clean("/" + fullPath)
+	if fullPath == VersionsDir || strings.HasPrefix(fullPath, VersionsDir+"/") {
+		return false
+	}
+	for _, dir := range v.dirs {
+		if dir == "/" || fullPath == dir || strings.HasPrefix(fullPath, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func versionsPath(fullPath string) string {
+	return filepath.Join(VersionsDir, filepath.Clean("/"+fullPath))
+}
+
+// SaveVersion keeps the replaced entry of the file as a version,
+// and returns the versions expired by the new one.
+func (v *Versioning) SaveVersion(fullPath string, entry *FileEntry, now time.Time) (expired []FileEntry, err error) {
+	version := *entry
+	version.Name = now.UTC().Format(versionTimeFormat)
+	if err = v.filer.CreateFileEntry(filepath.Join(versionsPath(fullPath), version.Name), &version); err != nil {
+		return nil, err
+	}
+	return v.expire(fullPath, now)
+}
+
+// ListVersions lists the previous entries of the file, the latest first.
+// The entry names are the version ids.
+func (v *Versioning) ListVersions(fullPath string) ([]FileEntry, error) {
+	if _, err := v.filer.FindDirectory(versionsPath(fullPath)); err != nil {
+		// the file is never replaced
+		return []FileEntry{}, nil
+	}
+	versions, err := v.filer.ListFiles(versionsPath(fullPath), "", 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(byVersion(versions)))
+	return versions, nil
+}
+
+type byVersion []FileEntry
+
+func (s byVersion) Len() int           { return len(s) }
+func (s byVersion) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byVersion) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// FindVersion finds the previous entry of the file by its version id
+func (v *Versioning) FindVersion(fullPath string, version string) (*FileEntry, error) {
+	if _, err := time.Parse(versionTimeFormat, version); err != nil {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+	entry, err := v.filer.FindFileEntry(filepath.Join(versionsPath(fullPath), version))
+	if err != nil {
+		return nil, fmt.Errorf("version %s of %s is not found", version, fullPath)
+	}
+	return entry, nil
+}
+
+// Restore makes the version the current entry of the file. The replaced entry
+// is kept as a new version, and the versions expired by it are returned.
+func (v *Versioning) Restore(fullPath string, version string, now time.Time) (expired []FileEntry, err error) {
+	entry, err := v.FindVersion(fullPath, version)
+	if err != nil {
+		return nil, err
+	}
+	current, _ := v.filer.FindFileEntry(fullPath)
+	entry.Name = filepath.Base(fullPath)
+	if err = v.filer.CreateFileEntry(fullPath, entry); err != nil {
+		return nil, err
+	}
+	if _, err = v.filer.DeleteFile(filepath.Join(versionsPath(fullPath), version)); err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return v.expire(fullPath, now)
+	}
+	return v.SaveVersion(fullPath, current, now)
+}
+
+// expire removes the versions beyond the limits, and returns them to delete their content
+func (v *Versioning) expire(fullPath string, now time.Time) (expired []FileEntry, err error) {
+	versions, err := v.ListVersions(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	for i, version := range versions {
+		replacedAt, _ := time.Parse(versionTimeFormat, version.Name)
+		if (v.MaxVersions <= 0 || i < v.MaxVersions) && (v.MaxAge <= 0 || now.Sub(replacedAt) < v.MaxAge) {
+			continue
+		}
+		if _, err = v.filer.DeleteFile(filepath.Join(versionsPath(fullPath), version.Name)); err != nil {
+			return expired, err
+		}
+		expired = append(expired, version)
+	}
+	return expired, nil
+}
@@ -12,24 +12,38 @@ import (
 
 //many code is copied from http://camlistore.org/pkg/images/images.go
 func FixJpgOrientation(data []byte) (oriented []byte) {
+	angle, flipMode := jpgOrientation(data)
+	if angle == 0 && flipMode == 0 {
+		return data
+	}
+
+	if srcImage, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		dstImage := flip(rotate(srcImage, angle), flipMode)
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, dstImage, nil)
+		return buf.Bytes()
+	}
+
+	return data
+}
+
+// jpgOrientation reads the rotation and the flip of the jpg image from its exif data
+func jpgOrientation(data []byte) (angle int, flipMode FlipDirection) {
 	ex, err := exif.Decode(bytes.NewReader(data))
 	if err != nil {
-		return data
+		return
 	}
 	tag, err := ex.Get(exif.Orientation)
 	if err != nil {
-		return data
+		return
 	}
-	angle := 0
-	flipMode := FlipDirection(0)
 	orient, err := tag.Int(0)
 	if err != nil {
-		return data
+		return
 	}
 	switch orient {
 	case topLeftSide:
 		// do nothing
-		return data
 	case topRightSide:
 		flipMode = 2
 	case bottomRightSide:
@@ -48,15 +62,7 @@ func FixJpgOrientation(data []byte) (oriented []byte) {
 	case leftSideBottom:
 		angle = 90
 	}
-
-	if srcImage, _, err := image.Decode(bytes.NewReader(data)); err == nil {
-		dstImage := flip(rotate(srcImage, angle), flipMode)
-		var buf bytes.Buffer
-		jpeg.Encode(&buf, dstImage, nil)
-		return buf.Bytes()
-	}
-
-	return data
+	return
 }
 
 // Exif Orientation Tag values
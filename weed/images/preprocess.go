@@ -20,7 +20,7 @@ func MaybePreprocessImage(filename string, data []byte, width, height int) (resi
 	case ".png", ".gif":
 		return Resized(ext, data, width, height)
 	case ".jpg", ".jpeg":
-		data = FixJpgOrientation(data)
+		data = StripExif(FixJpgOrientation(data))
 		return Resized(ext, data, width, height)
 	}
 	return data, 0, 0, nil
@@ -18,16 +18,9 @@ func Resized(ext string, data []byte, width, height int) (resized []byte, w int,
 	}
 	srcImage, _, err := image.Decode(bytes.NewReader(data))
 	if err == nil {
-		bounds := srcImage.Bounds()
-		var dstImage *image.NRGBA
-		if bounds.Dx() > width && width != 0 || bounds.Dy() > height && height != 0 {
-			if width == height && bounds.Dx() != bounds.Dy() {
-				dstImage = imaging.Thumbnail(srcImage, width, height, imaging.Lanczos)
-				w, h = width, height
-			} else {
-				dstImage = imaging.Resize(srcImage, width, height, imaging.Lanczos)
-			}
-		} else {
+		dstImage, ok := resize(srcImage, width, height)
+		if !ok {
+			bounds := srcImage.Bounds()
 			return data, bounds.Dx(), bounds.Dy(), nil
 		}
 		var buf bytes.Buffer
@@ -45,3 +38,16 @@ func Resized(ext string, data []byte, width, height int) (resized []byte, w int,
 	}
 	return data, 0, 0, err
 }
+
+// resize shrinks the image to the width and the height, or to one of them keeping the aspect ratio,
+// and reports false if the image is small enough already
+func resize(srcImage image.Image, width, height int) (*image.NRGBA, bool) {
+	bounds := srcImage.Bounds()
+	if bounds.Dx() > width && width != 0 || bounds.Dy() > height && height != 0 {
+		if width == height && bounds.Dx() != bounds.Dy() {
+			return imaging.Thumbnail(srcImage, width, height, imaging.Lanczos), true
+		}
+		return imaging.Resize(srcImage, width, height, imaging.Lanczos), true
+	}
+	return nil, false
+}
@@ -0,0 +1,196 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// the ways to resize an image to both a width and a height
+const (
+	ModeFit  = "fit"  // fit in the width and the height, keeping the aspect ratio
+	ModeFill = "fill" // cover the width and the height, keeping the aspect ratio and cutting the sides
+	ModePad  = "pad"  // fit in the width and the height, and pad the sides
+)
+
+// MaxDimension is the largest width and height of a rendition and of its crop area,
+// so that a request can not make the server allocate a huge image
+const MaxDimension = 8192
+
+// MaxSourcePixels is the largest number of pixels of an image to transform, since it is
+// decoded in memory with 4 to 8 bytes per pixel
+const MaxSourcePixels = 64 << 20
+
+// Options describes a rendition of an image, the transformations are applied in the order of the fields.
+type Options struct {
+	Crop      image.Rectangle // the area kept, in the pixels of the image
+	Rotate    int             // clockwise, in degrees, 90, 180 or 270
+	Width     int
+	Height    int
+	Mode      string // ModeFit, ModeFill or ModePad, or else the image is only shrunk as by Resized
+	Quality   int    // the jpeg quality, from 1 to 100
+	Format    string // the extension of the rendition, ".jpg", ".png" or ".gif", the image's by default
+	StripExif bool   // remove the exif metadata of a jpg image, which is lost anyway when the image is encoded again
+}
+
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// IsImage tells whether the images of the extension can be transformed
+func IsImage(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// Transform renders the image with the options, and returns the rendition with its extension.
+// The image is returned as it is, or only without its exif metadata, if it needs no change.
+func Transform(ext string, data []byte, o Options) (transformed []byte, format string, e error) {
+	ext = strings.ToLower(ext)
+	if ext == ".jpeg" {
+		ext = ".jpg"
+	}
+	format = ext
+	if o.Format != "" {
+		format = o.Format
+	}
+	if o.Width < 0 || o.Height < 0 {
+		return data, ext, errors.New("the width and the height should not be negative")
+	}
+	if o.Width > MaxDimension || o.Height > MaxDimension || o.Crop.Dx() > MaxDimension || o.Crop.Dy() > MaxDimension {
+		return data, ext, fmt.Errorf("the width and the height should be at most %d", MaxDimension)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return data, ext, err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxSourcePixels {
+		return data, ext, fmt.Errorf("the image of %dx%d pixels is too large to transform", config.Width, config.Height)
+	}
+	srcImage, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, ext, err
+	}
+	var img image.Image = srcImage
+	changed := format != ext || o.Quality != 0
+	if ext == ".jpg" {
+		// the orientation is kept in the exif metadata, which is not encoded again
+		if angle, flipMode := jpgOrientation(data); angle != 0 || flipMode != 0 {
+			img, changed = flip(rotate(img, angle), flipMode), true
+		}
+	}
+	if !o.Crop.Empty() {
+		img, changed = imaging.Crop(img, o.Crop.Add(img.Bounds().Min)), true
+		if img.Bounds().Empty() {
+			return data, ext, errors.New("the crop area is out of the image")
+		}
+	}
+	switch o.Rotate {
+	case 0:
+	case 90:
+		img, changed = imaging.Rotate270(img), true
+	case 180:
+		img, changed = imaging.Rotate180(img), true
+	case 270:
+		img, changed = imaging.Rotate90(img), true
+	default:
+		return data, ext, errors.New("the rotation should be 90, 180 or 270 degrees")
+	}
+	if o.Mode != "" && o.Mode != ModeFit && o.Mode != ModeFill && o.Mode != ModePad {
+		return data, ext, errors.New("unknown resizing mode " + o.Mode)
+	}
+	if o.Mode == "" || o.Width == 0 || o.Height == 0 {
+		if resized, ok := resize(img, o.Width, o.Height); ok {
+			img, changed = resized, true
+		}
+	} else {
+		switch o.Mode {
+		case ModeFit:
+			img = imaging.Fit(img, o.Width, o.Height, imaging.Lanczos)
+		case ModeFill:
+			img = imaging.Fill(img, o.Width, o.Height, imaging.Center, imaging.Lanczos)
+		case ModePad:
+			background := color.Color(color.Transparent)
+			if format == ".jpg" {
+				background = color.White
+			}
+			img = imaging.PasteCenter(imaging.New(o.Width, o.Height, background), imaging.Fit(img, o.Width, o.Height, imaging.Lanczos))
+		}
+		changed = true
+	}
+
+	if !changed {
+		if o.StripExif && ext == ".jpg" {
+			return StripExif(data), ext, nil
+		}
+		return data, ext, nil
+	}
+	var buf bytes.Buffer
+	switch format {
+	case ".png":
+		err = png.Encode(&buf, img)
+	case ".jpg":
+		quality := o.Quality
+		if quality <= 0 || quality > 100 {
+			quality = defaultJpegQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case ".gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = errors.New("unsupported image format " + format)
+	}
+	if err != nil {
+		return data, ext, err
+	}
+	return buf.Bytes(), format, nil
+}
+
+// StripExif removes the APP1 segments of the jpg image, which keep its exif and xmp metadata,
+// without encoding the image again. The data is returned as it is if it is not a valid jpg.
+func StripExif(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return data
+	}
+	stripped := make([]byte, 2, len(data))
+	copy(stripped, data[0:2])
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return data
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xff:
+			// fill byte
+			i++
+			continue
+		case marker == 0xda || marker == 0xd9:
+			// the compressed image follows the start of scan
+			return append(stripped, data[i:]...)
+		case marker == 0x01 || marker >= 0xd0 && marker <= 0xd7:
+			stripped = append(stripped, data[i:i+2]...)
+			i += 2
+			continue
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if length < 2 || i+2+length > len(data) {
+			return data
+		}
+		if marker != 0xe1 {
+			stripped = append(stripped, data[i:i+2+length]...)
+		}
+		i += 2 + length
+	}
+	return data
+}
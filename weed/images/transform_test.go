@@ -0,0 +1,83 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+func TestTransform(t *testing.T) {
+	data, err := ioutil.ReadFile("sample1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = exif.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("the sample has no exif data: %v", err)
+	}
+
+	stripped, format, err := Transform(".JPG", data, Options{StripExif: true})
+	if err != nil || format != ".jpg" {
+		t.Fatalf("strip exif: %s %v", format, err)
+	}
+	if _, err = exif.Decode(bytes.NewReader(stripped)); err == nil {
+		t.Errorf("the exif data is not stripped")
+	}
+	if _, _, err = image.Decode(bytes.NewReader(stripped)); err != nil || len(stripped) >= len(data) {
+		t.Errorf("the stripped image of %d bytes is not valid: %v", len(stripped), err)
+	}
+
+	// the sample is turned by its exif orientation, from 2592x1936 to 1936x2592
+	for _, c := range []struct {
+		o             Options
+		format        string
+		width, height int
+	}{
+		{Options{Width: 100, Height: 100, Mode: ModeFill}, ".jpg", 100, 100},
+		{Options{Width: 200, Height: 200, Mode: ModePad, Format: ".png"}, ".png", 200, 200},
+		{Options{Width: 200, Height: 200, Mode: ModeFit, Quality: 50}, ".jpg", 149, 200},
+		{Options{Crop: image.Rect(100, 200, 500, 400), Rotate: 90, Format: ".gif"}, ".gif", 200, 400},
+		{Options{Crop: image.Rect(0, 0, 160, 120), Rotate: 180, Width: 80}, ".jpg", 80, 60},
+	} {
+		rendition, format, err := Transform(".jpg", data, c.o)
+		if err != nil || format != c.format {
+			t.Fatalf("%+v: %s %v", c.o, format, err)
+		}
+		m, _, err := image.DecodeConfig(bytes.NewReader(rendition))
+		if err != nil || m.Width != c.width || m.Height != c.height {
+			t.Errorf("%+v: %dx%d rendition: %v", c.o, m.Width, m.Height, err)
+		}
+	}
+
+	if _, _, err = Transform(".jpg", data, Options{Format: ".webp"}); err == nil {
+		t.Errorf("the webp format should not be supported")
+	}
+	if _, _, err = Transform(".jpg", data, Options{Rotate: 45}); err == nil {
+		t.Errorf("a rotation of 45 degrees should fail")
+	}
+	if _, _, err = Transform(".jpg", data, Options{Crop: image.Rect(5000, 5000, 5100, 5100)}); err == nil {
+		t.Errorf("a crop out of the image should fail")
+	}
+	// the screen size of a small gif says 65535x65535 pixels
+	var buf bytes.Buffer
+	gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), palette.Plan9), nil)
+	huge := buf.Bytes()
+	huge[6], huge[7], huge[8], huge[9] = 0xff, 0xff, 0xff, 0xff
+	if _, _, err = Transform(".gif", huge, Options{Width: 10}); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("an image of more than %d pixels should not be decoded: %v", MaxSourcePixels, err)
+	}
+	for _, o := range []Options{
+		{Width: 100000, Height: 100000, Mode: ModePad},
+		{Width: 100, Height: MaxDimension + 1, Mode: ModeFill},
+		{Crop: image.Rect(0, 0, MaxDimension+1, 10)},
+	} {
+		if _, _, err = Transform(".jpg", data, o); err == nil {
+			t.Errorf("%+v: a rendition larger than %d pixels should fail", o, MaxDimension)
+		}
+	}
+}
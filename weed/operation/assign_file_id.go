@@ -16,6 +16,7 @@ type AssignResult struct {
 	Url       string `json:"url,omitempty"`
 	PublicUrl string `json:"publicUrl,omitempty"`
 	Count     uint64 `json:"count,omitempty"`
+	Dedup     bool   `json:"dedup,omitempty"`
 	Error     string `json:"error,omitempty"`
 }
 
@@ -1,17 +1,43 @@
 package operation
 
 import (
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// MaxManifestChunks is the number of chunks a manifest lists at most, the chunks
+// of larger files are grouped in sub-manifests
+const MaxManifestChunks = 1000
+
+// the binary encoding of the manifests starts with the magic and the version,
+// the manifests in json are still loaded
+const (
+	manifestMagic   = "SWCM"
+	manifestVersion = 1
+)
+
+// the flags of a chunk in the binary encoding
+const (
+	chunkIsManifest = 1 << iota // the chunk is a sub-manifest
+	chunkRawFid                 // the fid is kept as a string, instead of the volume id and the key and cookie bytes
 )
 
 type ChunkInfo struct {
 	Fid    string `json:"fid"`
 	Offset int64  `json:"offset"`
 	Size   int64  `json:"size"`
+	// the chunk is a sub-manifest listing the chunks of this part of the file,
+	// with their offsets from the start of the part
+	IsManifest bool `json:"isManifest,omitempty"`
 }
 
 type ChunkList []*ChunkInfo
@@ -35,21 +61,235 @@ func LoadChunkManifest(buffer []byte, isGzipped bool) (*ChunkManifest, error) {
 		}
 	}
 	cm := ChunkManifest{}
-	if e := json.Unmarshal(buffer, &cm); e != nil {
+	if len(buffer) > len(manifestMagic) && string(buffer[:len(manifestMagic)]) == manifestMagic {
+		if e := cm.unmarshal(buffer[len(manifestMagic):]); e != nil {
+			return nil, e
+		}
+	} else if e := json.Unmarshal(buffer, &cm); e != nil {
 		return nil, e
 	}
 	sort.Sort(cm.Chunks)
 	return &cm, nil
 }
 
+// Marshal encodes the manifest in binary. The name and the mime type are followed by the size
+// and the chunks, each one with its flags, its fid, the gap from the end of the previous chunk
+// and its size, all the numbers as varints.
 func (cm *ChunkManifest) Marshal() ([]byte, error) {
-	return json.Marshal(cm)
+	buf := make([]byte, 0, len(manifestMagic)+len(cm.Name)+len(cm.Mime)+16+len(cm.Chunks)*24)
+	buf = append(buf, manifestMagic...)
+	buf = append(buf, manifestVersion)
+	buf = appendString(buf, cm.Name)
+	buf = appendString(buf, cm.Mime)
+	buf = appendVarint(buf, cm.Size)
+	buf = appendUvarint(buf, uint64(len(cm.Chunks)))
+	var end int64
+	for _, ci := range cm.Chunks {
+		var flags byte
+		if ci.IsManifest {
+			flags |= chunkIsManifest
+		}
+		vid, keyCookie, ok := compactFid(ci.Fid)
+		if !ok {
+			flags |= chunkRawFid
+		}
+		buf = append(buf, flags)
+		if ok {
+			buf = appendUvarint(buf, vid)
+			buf = appendString(buf, string(keyCookie))
+		} else {
+			buf = appendString(buf, ci.Fid)
+		}
+		buf = appendVarint(buf, ci.Offset-end)
+		buf = appendVarint(buf, ci.Size)
+		end = ci.Offset + ci.Size
+	}
+	return buf, nil
+}
+
+func (cm *ChunkManifest) unmarshal(buf []byte) error {
+	d := &manifestDecoder{buf: buf}
+	if version := d.byte(); d.err == nil && version != manifestVersion {
+		return fmt.Errorf("unknown chunk manifest version %d", version)
+	}
+	cm.Name = d.string()
+	cm.Mime = d.string()
+	cm.Size = d.varint()
+	count := d.uvarint()
+	if count > uint64(len(d.buf)) {
+		// each chunk takes a few bytes at least
+		return errCorruptedManifest
+	}
+	cm.Chunks = make(ChunkList, 0, count)
+	var end int64
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		ci := &ChunkInfo{}
+		flags := d.byte()
+		ci.IsManifest = flags&chunkIsManifest != 0
+		if flags&chunkRawFid != 0 {
+			ci.Fid = d.string()
+		} else {
+			vid := d.uvarint()
+			ci.Fid = strconv.FormatUint(vid, 10) + "," + hex.EncodeToString([]byte(d.string()))
+		}
+		ci.Offset = end + d.varint()
+		ci.Size = d.varint()
+		end = ci.Offset + ci.Size
+		cm.Chunks = append(cm.Chunks, ci)
+	}
+	return d.err
+}
+
+// compactFid splits the fid in its volume id and its key and cookie bytes,
+// if the fid is formatted the usual way and can be restored from them
+func compactFid(fid string) (vid uint64, keyCookie []byte, ok bool) {
+	volumeId, keyHex, err := ParseFileId(fid)
+	if err != nil {
+		return 0, nil, false
+	}
+	if vid, err = strconv.ParseUint(volumeId, 10, 32); err != nil || strconv.FormatUint(vid, 10) != volumeId {
+		return 0, nil, false
+	}
+	if keyCookie, err = hex.DecodeString(keyHex); err != nil || hex.EncodeToString(keyCookie) != keyHex {
+		return 0, nil, false
+	}
+	return vid, keyCookie, true
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutVarint(b[:], v)]...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	return append(appendUvarint(buf, uint64(len(s))), s...)
+}
+
+// manifestDecoder reads the binary manifest, keeping the first error
+type manifestDecoder struct {
+	buf []byte
+	err error
+}
+
+var errCorruptedManifest = errors.New("corrupted chunk manifest")
+
+func (d *manifestDecoder) byte() byte {
+	if d.err != nil || len(d.buf) == 0 {
+		d.err = errCorruptedManifest
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *manifestDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errCorruptedManifest
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *manifestDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errCorruptedManifest
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *manifestDecoder) string() string {
+	length := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if length > uint64(len(d.buf)) {
+		d.err = errCorruptedManifest
+		return ""
+	}
+	s := string(d.buf[:length])
+	d.buf = d.buf[length:]
+	return s
+}
+
+// UploadNestedChunkManifest uploads the manifest, after grouping its chunks in sub-manifests of
+// MaxManifestChunks chunks, and the sub-manifests in upper ones, until it lists at most MaxManifestChunks
+// chunks. The offsets in a sub-manifest are from its start, so that it is a chunked file itself.
+// On error the sub-manifests are deleted, but not the chunks, and the manifest is unchanged.
+func UploadNestedChunkManifest(fileUrl string, cm *ChunkManifest, jwt security.EncodedJwt,
+	master, replication, collection, ttl string, secret security.Secret) error {
+	chunks := cm.Chunks
+	var subManifests []string
+	for len(cm.Chunks) > MaxManifestChunks {
+		var nested ChunkList
+		for i := 0; i < len(cm.Chunks); i += MaxManifestChunks {
+			group := cm.Chunks[i:]
+			if len(group) > MaxManifestChunks {
+				group = group[:MaxManifestChunks]
+			}
+			start, last := group[0].Offset, group[len(group)-1]
+			sub := &ChunkManifest{Name: cm.Name, Mime: cm.Mime, Size: last.Offset + last.Size - start}
+			for _, ci := range group {
+				sub.Chunks = append(sub.Chunks, &ChunkInfo{Fid: ci.Fid, Offset: ci.Offset - start, Size: ci.Size, IsManifest: ci.IsManifest})
+			}
+			ret, err := Assign(master, 1, replication, collection, ttl)
+			if err == nil {
+				err = UploadChunkManifest(util.NormalizeUrl(ret.Url+"/"+ret.Fid), sub, security.GenJwt(secret, ret.Fid))
+			}
+			if err != nil {
+				cm.Chunks = chunks
+				deleteSubManifests(master, collection, subManifests, secret)
+				return err
+			}
+			subManifests = append(subManifests, ret.Fid)
+			nested = append(nested, &ChunkInfo{Fid: ret.Fid, Offset: start, Size: sub.Size, IsManifest: true})
+		}
+		glog.V(4).Infof("nested %d chunks of %s in %d sub-manifests", len(cm.Chunks), cm.Name, len(nested))
+		cm.Chunks = nested
+	}
+	if err := UploadChunkManifest(fileUrl, cm, jwt); err != nil {
+		cm.Chunks = chunks
+		deleteSubManifests(master, collection, subManifests, secret)
+		return err
+	}
+	return nil
+}
+
+// deleteSubManifests deletes only the sub-manifests, keeping the chunks they list
+func deleteSubManifests(master, collection string, fileIds []string, secret security.Secret) {
+	for _, fileId := range fileIds {
+		fileUrl, err := LookupFileId(master, fileId, collection, false)
+		if err == nil {
+			err = util.Delete(fileUrl+"?cm=false", security.GenJwt(secret, fileId))
+		}
+		if err != nil {
+			glog.V(0).Infof("Delete sub-manifest %s error: %v", fileId, err)
+		}
+	}
 }
 
-func (cm *ChunkManifest) DeleteChunks(master, collection string) error {
+// DeleteChunks deletes the chunks of the manifest, with the tokens of their file ids signed by the secret.
+// The volume servers delete a sub-manifest only after its own chunks, the same way as the manifest of a chunked file.
+func (cm *ChunkManifest) DeleteChunks(master, collection string, secret security.Secret) error {
 	deleteError := 0
 	for _, ci := range cm.Chunks {
-		if e := DeleteFile(master, ci.Fid, collection, ""); e != nil {
+		if e := DeleteFile(master, ci.Fid, collection, security.GenJwt(secret, ci.Fid)); e != nil {
 			deleteError++
 			glog.V(0).Infof("Delete %s error: %v, master: %s", ci.Fid, e, master)
 		}
@@ -0,0 +1,141 @@
+package operation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestChunkManifestEncoding(t *testing.T) {
+	cm := &ChunkManifest{Name: "big.bin", Mime: "application/octet-stream", Size: 3 << 30}
+	for i := int64(0); i < 96; i++ {
+		cm.Chunks = append(cm.Chunks, &ChunkInfo{Fid: fmt.Sprintf("%d,%x637037d6", 3+i%2, 256+i), Offset: i << 25, Size: 1 << 25})
+	}
+	cm.Chunks[5].IsManifest = true
+	cm.Chunks[7].Fid = "5,1637037d6" // odd number of hex digits
+	cm.Chunks[9].Fid = "03,01637037d6"
+
+	data, err := cm.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jsonData, _ := json.Marshal(cm); len(data)*2 > len(jsonData) {
+		t.Errorf("the binary manifest of %d bytes is not much smaller than %d bytes of json", len(data), len(jsonData))
+	}
+	loaded, err := LoadChunkManifest(data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, cm) {
+		t.Errorf("unexpected manifest %+v", loaded)
+	}
+
+	gzipped, _ := GzipData(data)
+	if loaded, err = LoadChunkManifest(gzipped, true); err != nil || len(loaded.Chunks) != len(cm.Chunks) {
+		t.Errorf("load the gzipped manifest: %v", err)
+	}
+	legacy := `{"name":"a.bin","size":20,"chunks":[{"fid":"3,02637037d6","offset":10,"size":10},{"fid":"3,01637037d6","offset":0,"size":10}]}`
+	if loaded, err = LoadChunkManifest([]byte(legacy), false); err != nil || loaded.Chunks[0].Fid != "3,01637037d6" {
+		t.Errorf("load the json manifest: %+v %v", loaded, err)
+	}
+	for _, n := range []int{5, 10, len(data) - 1} {
+		if _, err = LoadChunkManifest(data[:n], false); err == nil {
+			t.Errorf("a manifest truncated to %d bytes should not load", n)
+		}
+	}
+}
+
+func TestUploadNestedChunkManifest(t *testing.T) {
+	var lock sync.Mutex
+	stored := make(map[string][]byte)
+	var deleted []string
+	fileKey, failAssign := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.URL.Path == "/dir/assign":
+			if fileKey++; fileKey == failAssign {
+				fmt.Fprint(w, `{"error":"no free volumes"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"fid":"11,%02x637037d6","url":"%s","count":1}`, fileKey, r.Host)
+		case r.URL.Path == "/dir/lookup":
+			fmt.Fprintf(w, `{"volumeId":"11","locations":[{"url":"%s"}]}`, r.Host)
+		case r.Method == "DELETE":
+			if r.FormValue("cm") != "false" {
+				t.Errorf("the chunks of %s should be kept", r.URL.Path)
+			}
+			deleted = append(deleted, r.URL.Path[1:])
+		case r.Method == "POST":
+			if r.FormValue("cm") != "true" {
+				t.Errorf("%s is not uploaded as a manifest", r.URL.Path)
+			}
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := ioutil.ReadAll(file)
+			stored[r.URL.Path[1:]] = data
+			fmt.Fprintf(w, `{"size":%d}`, len(data))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	master := strings.TrimPrefix(server.URL, "http://")
+
+	cm := &ChunkManifest{Name: "big.bin"}
+	for i := 0; i < 2*MaxManifestChunks+500; i++ {
+		cm.Chunks = append(cm.Chunks, &ChunkInfo{Fid: fmt.Sprintf("3,%x637037d6", 1000+i), Offset: cm.Size, Size: 10})
+		cm.Size += 10
+	}
+	chunks := cm.Chunks
+
+	// the third sub-manifest can not be assigned
+	failAssign = 3
+	if err := UploadNestedChunkManifest(server.URL+"/11,ff637037d6", cm, "", master, "", "", "", ""); err == nil {
+		t.Fatal("the upload should fail")
+	}
+	if !reflect.DeepEqual(cm.Chunks, chunks) {
+		t.Errorf("the manifest is changed")
+	}
+	if len(deleted) != 2 || deleted[0] != "11,01637037d6" || deleted[1] != "11,02637037d6" {
+		t.Errorf("the uploaded sub-manifests should be deleted: %v", deleted)
+	}
+
+	fileKey, failAssign, stored = 0, 0, make(map[string][]byte)
+	if err := UploadNestedChunkManifest(server.URL+"/11,ff637037d6", cm, "", master, "", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	top, err := LoadChunkManifest(stored["11,ff637037d6"], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top.Size != cm.Size || len(top.Chunks) != 3 {
+		t.Fatalf("unexpected manifest %+v", top)
+	}
+	for i, ci := range top.Chunks {
+		sub, err := LoadChunkManifest(stored[ci.Fid], false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := i * MaxManifestChunks
+		if !ci.IsManifest || ci.Offset != chunks[start].Offset || ci.Size != sub.Size || ci.Size != int64(len(sub.Chunks))*10 {
+			t.Errorf("unexpected sub-manifest %+v of %d bytes in %d chunks", ci, sub.Size, len(sub.Chunks))
+		}
+		for j, c := range sub.Chunks {
+			if c.Fid != chunks[start+j].Fid || c.Offset != int64(j*10) || c.IsManifest {
+				t.Errorf("unexpected chunk %+v in sub-manifest %s", c, ci.Fid)
+				break
+			}
+		}
+	}
+}
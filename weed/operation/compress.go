@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"strings"
 
@@ -57,3 +58,21 @@ func UnGzipData(input []byte) ([]byte, error) {
 	}
 	return output, err
 }
+
+// GzipStream compresses the input like GzipData, as the returned stream is read.
+// The stream should be read to the end, or closed.
+func GzipStream(input io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w, _ := gzip.NewWriterLevel(pw, flate.BestCompression)
+		_, err := io.Copy(w, input)
+		if err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			glog.V(2).Infoln("error compressing data:", err)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,108 @@
+package operation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+type DedupResult struct {
+	Fid   string `json:"fid,omitempty"`
+	Found bool   `json:"found,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// HashContent returns the hex encoded sha256 of the content, which identifies the duplicates
+func HashContent(r io.Reader) (hash string, size int64, err error) {
+	h := sha256.New()
+	if size, err = io.Copy(h, r); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// DedupAcquire references the file stored with the same content in the collection.
+// If found, the content should not be written and the returned fid is used instead of fid.
+func DedupAcquire(master, collection, hash string, size int64, fid string, jwt security.EncodedJwt) (*DedupResult, error) {
+	values := url.Values{
+		"collection": {collection},
+		"hash":       {hash},
+		"size":       {strconv.FormatInt(size, 10)},
+		"fid":        {fid},
+	}
+	var ret DedupResult
+	if err := postDedup(master, "/dedup/acquire", values, jwt, &ret); err != nil {
+		return nil, err
+	}
+	if ret.Error != "" {
+		return nil, errors.New(ret.Error)
+	}
+	return &ret, nil
+}
+
+// DedupRelease removes a reference of each fid, and returns the fids
+// that should be deleted, which are not deduplicated or have no more references.
+func DedupRelease(master string, fids []string, jwt security.EncodedJwt) ([]string, error) {
+	var ret struct {
+		Delete []string `json:"delete"`
+		Error  string   `json:"error,omitempty"`
+	}
+	if err := postDedup(master, "/dedup/release", url.Values{"fid": fids}, jwt, &ret); err != nil {
+		return nil, err
+	}
+	if ret.Error != "" {
+		return nil, errors.New(ret.Error)
+	}
+	return ret.Delete, nil
+}
+
+// DedupRefs returns the number of references to the file, 0 if it is not deduplicated
+func DedupRefs(master, fid string) (uint64, error) {
+	var ret struct {
+		Entry struct {
+			Refs uint64 `json:"refs"`
+		} `json:"entry"`
+		Error string `json:"error,omitempty"`
+	}
+	jsonBlob, err := util.Get(master, "/dedup/status", url.Values{"fid": {fid}})
+	if err != nil {
+		return 0, err
+	}
+	if err = json.Unmarshal(jsonBlob, &ret); err != nil {
+		return 0, fmt.Errorf("/dedup/status result %s: %v", string(jsonBlob), err)
+	}
+	if ret.Error != "" {
+		return 0, errors.New(ret.Error)
+	}
+	return ret.Entry.Refs, nil
+}
+
+func postDedup(master, path string, values url.Values, jwt security.EncodedJwt, ret interface{}) error {
+	req, err := http.NewRequest("POST", util.MkUrl(master, path, nil), strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if jwt != "" {
+		req.Header.Set("Authorization", "BEARER "+string(jwt))
+	}
+	resp, err := util.HttpDo(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(ret); err != nil {
+		return fmt.Errorf("%s %s: %v", path, resp.Status, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package operation
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubmitAndDeleteDeduplicatedFile(t *testing.T) {
+	var uploads, deletes int
+	volume := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deletes++
+		} else {
+			uploads++
+		}
+		w.Write([]byte(`{"size":5}`))
+	}))
+	defer volume.Close()
+	volumeUrl := strings.TrimPrefix(volume.URL, "http://")
+
+	refs := map[string]int{}
+	hashes := map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dir/assign", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"fid":"7,0%d","url":%q,"publicUrl":%q,"count":1,"dedup":true}`, uploads+len(refs)+1, volumeUrl, volumeUrl)
+	})
+	mux.HandleFunc("/dir/lookup", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"volumeId":"7","locations":[{"url":%q,"publicUrl":%q}]}`, volumeUrl, volumeUrl)
+	})
+	mux.HandleFunc("/dedup/acquire", func(w http.ResponseWriter, r *http.Request) {
+		hash, fid := r.FormValue("hash"), r.FormValue("fid")
+		if existing, ok := hashes[hash]; ok {
+			refs[existing]++
+			fmt.Fprintf(w, `{"fid":%q,"found":true}`, existing)
+			return
+		}
+		hashes[hash], refs[fid] = fid, 1
+		fmt.Fprintf(w, `{"fid":%q}`, fid)
+	})
+	mux.HandleFunc("/dedup/release", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		fid := r.Form["fid"][0]
+		if refs[fid]--; refs[fid] > 0 {
+			w.Write([]byte(`{"delete":[]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"delete":[%q]}`, fid)
+	})
+	master := httptest.NewServer(mux)
+	defer master.Close()
+	masterUrl := strings.TrimPrefix(master.URL, "http://")
+
+	var fids []string
+	for i := 0; i < 2; i++ {
+		files := []FilePart{{Reader: bytes.NewReader([]byte("hello")), FileName: "a.txt", FileSize: 5}}
+		results, err := SubmitFiles(masterUrl, files, "", "", "", 0, "")
+		if err != nil || results[0].Error != "" {
+			t.Fatalf("submit: %v %+v", err, results)
+		}
+		fids = append(fids, results[0].Fid)
+	}
+	if uploads != 1 || fids[0] != fids[1] {
+		t.Fatalf("same content should be uploaded once, uploads %d fids %v", uploads, fids)
+	}
+
+	if err := DeleteFile(masterUrl, fids[0], "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if deletes != 0 {
+		t.Errorf("file with references should not be deleted")
+	}
+	if err := DeleteFile(masterUrl, fids[1], "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if deletes != 1 {
+		t.Errorf("file should be deleted with the last reference, deletes %d", deletes)
+	}
+}
@@ -21,7 +21,15 @@ type DeleteResult struct {
 	Error  string `json:"error,omitempty"`
 }
 
+// DeleteFile deletes the file, or only removes a reference if its content is deduplicated
 func DeleteFile(master, fileId, collection string, jwt security.EncodedJwt) error {
+	deletes, err := DedupRelease(master, []string{fileId}, jwt)
+	if err != nil {
+		return fmt.Errorf("Failed to release %s:%v", fileId, err)
+	}
+	if len(deletes) == 0 {
+		return nil
+	}
 	fileUrl, err := LookupFileId(master, fileId, collection, false)
 	if err != nil {
 		return fmt.Errorf("Failed to lookup %s:%v", fileId, err)
@@ -49,6 +57,10 @@ type DeleteFilesResult struct {
 func DeleteFiles(master string, fileIds []string) (*DeleteFilesResult, error) {
 	vid_to_fileIds := make(map[string][]string)
 	ret := &DeleteFilesResult{}
+	fileIds, err := DedupRelease(master, fileIds, "")
+	if err != nil {
+		return ret, err
+	}
 	var vids []string
 	for _, fileId := range fileIds {
 		vid, _, err := ParseFileId(fileId)
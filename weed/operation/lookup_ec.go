@@ -0,0 +1,39 @@
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+type EcShardLocations struct {
+	ShardId   int       `json:"shardId"`
+	Locations Locations `json:"locations,omitempty"`
+}
+
+type LookupEcResult struct {
+	VolumeId   string             `json:"volumeId,omitempty"`
+	Collection string             `json:"collection,omitempty"`
+	Shards     []EcShardLocations `json:"shards,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// LookupEcShards finds the data nodes holding each shard of an erasure coded volume
+func LookupEcShards(server, vid string) (*LookupEcResult, error) {
+	values := make(url.Values)
+	values.Add("volumeId", vid)
+	jsonBlob, err := util.Post(server, "/dir/lookup_ec", values)
+	if err != nil {
+		return nil, err
+	}
+	var ret LookupEcResult
+	if err = json.Unmarshal(jsonBlob, &ret); err != nil {
+		return nil, err
+	}
+	if ret.Error != "" {
+		return nil, errors.New(ret.Error)
+	}
+	return &ret, nil
+}
@@ -0,0 +1,51 @@
+package operation
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+type FileStat struct {
+	Size         int64
+	Mime         string
+	ETag         string
+	LastModified time.Time
+}
+
+// StatFile asks the volume server for the size, mime type, etag and last modified time of the file,
+// the size is the original size for gzipped and chunked files.
+func StatFile(master, fid, collection string, jwt security.EncodedJwt) (*FileStat, error) {
+	fileUrl, err := LookupFileId(master, fid, collection, true)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("HEAD", fileUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	if jwt != "" {
+		req.Header.Set("Authorization", "BEARER "+string(jwt))
+	}
+	resp, err := util.HttpDo(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HEAD %s: %s", fileUrl, resp.Status)
+	}
+	stat := &FileStat{
+		Size: resp.ContentLength,
+		Mime: resp.Header.Get("Content-Type"),
+		ETag: resp.Header.Get("Etag"),
+	}
+	if t, e := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified")); e == nil {
+		stat.LastModified = t
+	}
+	return stat, nil
+}
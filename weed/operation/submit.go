@@ -2,7 +2,9 @@ package operation
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"io/ioutil"
 	"mime"
 	"net/url"
 	"os"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 type FilePart struct {
@@ -59,16 +62,79 @@ func SubmitFiles(master string, files []FilePart,
 		file.Server = ret.Url
 		file.Replication = replication
 		file.Collection = collection
+		results[index].Fid = file.Fid
+		results[index].FileUrl = ret.PublicUrl + "/" + file.Fid
+		if ret.Dedup && (maxMB <= 0 || file.FileSize <= int64(maxMB*1024*1024)) {
+			dedup, e := file.acquireDedup(master, secret)
+			if e != nil {
+				results[index].Error = e.Error()
+				continue
+			}
+			if dedup.Found {
+				if closer, ok := file.Reader.(io.Closer); ok {
+					closer.Close()
+				}
+				results[index].Fid = dedup.Fid
+				results[index].Size = uint32(file.FileSize)
+				if results[index].FileUrl, e = lookupPublicUrl(master, dedup.Fid, collection); e != nil {
+					results[index].Error = e.Error()
+				}
+				continue
+			}
+		}
 		results[index].Size, err = file.Upload(maxMB, master, secret)
 		if err != nil {
 			results[index].Error = err.Error()
+			if ret.Dedup {
+				// forget the content registered for the failed upload
+				DedupRelease(master, []string{file.Fid}, security.GenJwt(secret, file.Fid))
+			}
 		}
-		results[index].Fid = file.Fid
-		results[index].FileUrl = ret.PublicUrl + "/" + file.Fid
 	}
 	return results, nil
 }
 
+// acquireDedup hashes the content of the file, and references the file
+// already stored with the same content, or registers the file for the content.
+func (fi *FilePart) acquireDedup(master string, secret security.Secret) (*DedupResult, error) {
+	if seeker, ok := fi.Reader.(io.ReadSeeker); ok {
+		hash, size, err := HashContent(seeker)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = seeker.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		fi.FileSize = size
+		return DedupAcquire(master, fi.Collection, hash, size, fi.Fid, security.GenJwt(secret, fi.Fid))
+	}
+	data, err := ioutil.ReadAll(fi.Reader)
+	if closer, ok := fi.Reader.(io.Closer); ok {
+		closer.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi.Reader, fi.FileSize = bytes.NewReader(data), int64(len(data))
+	hash, size, _ := HashContent(bytes.NewReader(data))
+	return DedupAcquire(master, fi.Collection, hash, size, fi.Fid, security.GenJwt(secret, fi.Fid))
+}
+
+func lookupPublicUrl(master, fileId, collection string) (string, error) {
+	vid, _, err := ParseFileId(fileId)
+	if err != nil {
+		return "", err
+	}
+	lookup, err := Lookup(master, vid, collection)
+	if err != nil {
+		return "", err
+	}
+	if len(lookup.Locations) == 0 {
+		return "", errors.New("File Not Found")
+	}
+	return lookup.Locations.Head().PublicUrl + "/" + fileId, nil
+}
+
 func NewFileParts(fullPathFilenames []string) (ret []FilePart, err error) {
 	ret = make([]FilePart, len(fullPathFilenames))
 	for index, file := range fullPathFilenames {
@@ -108,7 +174,7 @@ func newFilePart(fullPathFilename string) (ret FilePart, err error) {
 
 func (fi FilePart) Upload(maxMB int, master string, secret security.Secret) (retSize uint32, err error) {
 	jwt := security.GenJwt(secret, fi.Fid)
-	fileUrl := "http://" + fi.Server + "/" + fi.Fid
+	fileUrl := util.NormalizeUrl(fi.Server + "/" + fi.Fid)
 	if fi.ModTime != 0 {
 		fileUrl += "?ts=" + strconv.Itoa(int(fi.ModTime))
 	}
@@ -131,10 +197,10 @@ func (fi FilePart) Upload(maxMB int, master string, secret security.Secret) (ret
 				baseName+"-"+strconv.FormatInt(i+1, 10),
 				io.LimitReader(fi.Reader, chunkSize),
 				master, fi.Replication, fi.Collection, fi.Ttl,
-				jwt)
+				secret)
 			if e != nil {
 				// delete all uploaded chunks
-				cm.DeleteChunks(master, fi.Collection)
+				cm.DeleteChunks(master, fi.Collection, secret)
 				return 0, e
 			}
 			cm.Chunks = append(cm.Chunks,
@@ -146,10 +212,10 @@ func (fi FilePart) Upload(maxMB int, master string, secret security.Secret) (ret
 			)
 			retSize += count
 		}
-		err = upload_chunked_file_manifest(fileUrl, &cm, jwt)
+		err = UploadNestedChunkManifest(fileUrl, &cm, jwt, master, fi.Replication, fi.Collection, fi.Ttl, secret)
 		if err != nil {
 			// delete all uploaded chunks
-			cm.DeleteChunks(master, fi.Collection)
+			cm.DeleteChunks(master, fi.Collection, secret)
 		}
 	} else {
 		ret, e := Upload(fileUrl, baseName, fi.Reader, fi.IsGzipped, fi.MimeType, jwt)
@@ -162,23 +228,23 @@ func (fi FilePart) Upload(maxMB int, master string, secret security.Secret) (ret
 }
 
 func upload_one_chunk(filename string, reader io.Reader, master,
-	replication string, collection string, ttl string, jwt security.EncodedJwt,
+	replication string, collection string, ttl string, secret security.Secret,
 ) (fid string, size uint32, e error) {
 	ret, err := Assign(master, 1, replication, collection, ttl)
 	if err != nil {
 		return "", 0, err
 	}
-	fileUrl, fid := "http://"+ret.Url+"/"+ret.Fid, ret.Fid
+	fileUrl, fid := util.NormalizeUrl(ret.Url+"/"+ret.Fid), ret.Fid
 	glog.V(4).Info("Uploading part ", filename, " to ", fileUrl, "...")
 	uploadResult, uploadError := Upload(fileUrl, filename, reader, false,
-		"application/octet-stream", jwt)
+		"application/octet-stream", security.GenJwt(secret, fid))
 	if uploadError != nil {
 		return fid, 0, uploadError
 	}
 	return fid, uploadResult.Size, nil
 }
 
-func upload_chunked_file_manifest(fileUrl string, manifest *ChunkManifest, jwt security.EncodedJwt) error {
+func UploadChunkManifest(fileUrl string, manifest *ChunkManifest, jwt security.EncodedJwt) error {
 	buf, e := manifest.Marshal()
 	if e != nil {
 		return e
@@ -189,6 +255,6 @@ func upload_chunked_file_manifest(fileUrl string, manifest *ChunkManifest, jwt s
 	q := u.Query()
 	q.Set("cm", "true")
 	u.RawQuery = q.Encode()
-	_, e = Upload(u.String(), manifest.Name, bufReader, false, "application/json", jwt)
+	_, e = Upload(u.String(), manifest.Name, bufReader, false, "application/octet-stream", jwt)
 	return e
 }
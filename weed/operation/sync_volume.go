@@ -40,7 +40,7 @@ func GetVolumeIdxEntries(server string, vid string, eachEntryFn func(key uint64,
 	values := make(url.Values)
 	values.Add("volume", vid)
 	line := make([]byte, 16)
-	err := util.GetBufferStream("http://"+server+"/admin/sync/index", values, line, func(bytes []byte) {
+	err := util.GetBufferStream(util.NormalizeUrl(server+"/admin/sync/index"), values, line, func(bytes []byte) {
 		key := util.BytesToUint64(bytes[:8])
 		offset := util.BytesToUint32(bytes[8:12])
 		size := util.BytesToUint32(bytes[12:16])
@@ -1,7 +1,6 @@
 package operation
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,24 +15,17 @@ import (
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 type UploadResult struct {
 	Name  string `json:"name,omitempty"`
 	Size  uint32 `json:"size,omitempty"`
+	Mime  string `json:"mime,omitempty"`
+	Fid   string `json:"fid,omitempty"` // the file id saved by the filer
 	Error string `json:"error,omitempty"`
 }
 
-var (
-	client *http.Client
-)
-
-func init() {
-	client = &http.Client{Transport: &http.Transport{
-		MaxIdleConnsPerHost: 1024,
-	}}
-}
-
 var fileNameEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"")
 
 func Upload(uploadUrl string, filename string, reader io.Reader, isGzipped bool, mtype string, jwt security.EncodedJwt) (*UploadResult, error) {
@@ -42,9 +34,13 @@ func Upload(uploadUrl string, filename string, reader io.Reader, isGzipped bool,
 		return
 	}, filename, isGzipped, mtype, jwt)
 }
+
+// upload_content streams the multipart body to the volume server as it is filled,
+// so the content is never held in memory.
 func upload_content(uploadUrl string, fillBufferFunction func(w io.Writer) error, filename string, isGzipped bool, mtype string, jwt security.EncodedJwt) (*UploadResult, error) {
-	body_buf := bytes.NewBufferString("")
-	body_writer := multipart.NewWriter(body_buf)
+	body_reader, body_pipe := io.Pipe()
+	defer body_reader.Close()
+	body_writer := multipart.NewWriter(body_pipe)
 	h := make(textproto.MIMEHeader)
 	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, fileNameEscaper.Replace(filename)))
 	if mtype == "" {
@@ -56,24 +52,35 @@ func upload_content(uploadUrl string, fillBufferFunction func(w io.Writer) error
 	if isGzipped {
 		h.Set("Content-Encoding", "gzip")
 	}
-	if jwt != "" {
-		h.Set("Authorization", "BEARER "+string(jwt))
-	}
-	file_writer, cp_err := body_writer.CreatePart(h)
-	if cp_err != nil {
-		glog.V(0).Infoln("error creating form file", cp_err.Error())
-		return nil, cp_err
-	}
-	if err := fillBufferFunction(file_writer); err != nil {
-		glog.V(0).Infoln("error copying data", err)
-		return nil, err
-	}
+	go func() {
+		file_writer, cp_err := body_writer.CreatePart(h)
+		if cp_err != nil {
+			glog.V(0).Infoln("error creating form file", cp_err.Error())
+			body_pipe.CloseWithError(cp_err)
+			return
+		}
+		if err := fillBufferFunction(file_writer); err != nil {
+			glog.V(0).Infoln("error copying data", err)
+			body_pipe.CloseWithError(err)
+			return
+		}
+		if err := body_writer.Close(); err != nil {
+			glog.V(0).Infoln("error closing body", err)
+			body_pipe.CloseWithError(err)
+			return
+		}
+		body_pipe.Close()
+	}()
 	content_type := body_writer.FormDataContentType()
-	if err := body_writer.Close(); err != nil {
-		glog.V(0).Infoln("error closing body", err)
-		return nil, err
+	req, req_err := http.NewRequest("POST", uploadUrl, body_reader)
+	if req_err != nil {
+		return nil, req_err
+	}
+	req.Header.Set("Content-Type", content_type)
+	if jwt != "" {
+		req.Header.Set("Authorization", "BEARER "+string(jwt))
 	}
-	resp, post_err := client.Post(uploadUrl, content_type, body_buf)
+	resp, post_err := util.HttpDo(req)
 	if post_err != nil {
 		glog.V(0).Infoln("failing to upload to", uploadUrl, post_err.Error())
 		return nil, post_err
@@ -0,0 +1,289 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionWrite  Action = "write"
+	ActionDelete Action = "delete"
+	ActionAdmin  Action = "admin"
+)
+
+var allActions = []Action{ActionRead, ActionWrite, ActionDelete, ActionAdmin}
+
+// ParseActions parses comma separated actions, "*" for all actions
+func ParseActions(s string) (actions []Action, err error) {
+	for _, a := range strings.Split(s, ",") {
+		a = strings.TrimSpace(a)
+		switch Action(a) {
+		case "":
+		case "*":
+			return allActions, nil
+		case ActionRead, ActionWrite, ActionDelete, ActionAdmin:
+			actions = append(actions, Action(a))
+		default:
+			return nil, fmt.Errorf("unknown action %s", a)
+		}
+	}
+	return
+}
+
+// Scope is the actions allowed on the collections, all collections if it has "*".
+type Scope struct {
+	Collections []string `json:"collections"`
+	Actions     []Action `json:"actions"`
+}
+
+func (s Scope) Allows(action Action, collection string) bool {
+	return s.hasAction(action) && s.hasCollection(collection)
+}
+
+func (s Scope) hasAction(action Action) bool {
+	for _, a := range s.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Scope) hasCollection(collection string) bool {
+	for _, c := range s.Collections {
+		if c == "*" || c == collection {
+			return true
+		}
+	}
+	return false
+}
+
+// Narrow returns the part of the scope also in the other scope,
+// an empty field of the other scope does not narrow the scope.
+func (s Scope) Narrow(other Scope) Scope {
+	ret := s
+	if len(other.Actions) > 0 {
+		ret.Actions = nil
+		for _, a := range other.Actions {
+			if s.hasAction(a) {
+				ret.Actions = append(ret.Actions, a)
+			}
+		}
+	}
+	if len(other.Collections) > 0 {
+		ret.Collections = nil
+		for _, c := range other.Collections {
+			if s.hasCollection(c) {
+				ret.Collections = append(ret.Collections, c)
+			}
+		}
+	}
+	return ret
+}
+
+// AccessKey is given to a client to get the JWTs of its scope from the master, or to sign
+// its S3 requests. The masters keep the hash of the secret, and the secret sealed with
+// the secure.secret of the masters to derive the signing keys, never the plain secret.
+type AccessKey struct {
+	Id           string `json:"id"`
+	Secret       string `json:"secret,omitempty"`
+	SecretHash   string `json:"secretHash,omitempty"`
+	SealedSecret string `json:"sealedSecret,omitempty"`
+	Scope
+}
+
+// HashAccessKeySecret returns the hex encoded sha256 of the secret
+func HashAccessKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Hashed returns a copy of the key with the hash of its secret instead of the secret
+func (k *AccessKey) Hashed() *AccessKey {
+	if k.Secret == "" {
+		return k
+	}
+	hashed := *k
+	hashed.SecretHash, hashed.Secret = HashAccessKeySecret(k.Secret), ""
+	return &hashed
+}
+
+// Seal seals the secret of the key with the secret of the masters, with AES-GCM
+func (k *AccessKey) Seal(masterSecret Secret) error {
+	gcm, err := newAccessKeyGCM(masterSecret)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(k.Secret)+gcm.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	k.SealedSecret = base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(k.Secret), []byte(k.Id)))
+	return nil
+}
+
+// OpenSecret returns the plain secret of the key sealed by Seal
+func (k *AccessKey) OpenSecret(masterSecret Secret) (string, error) {
+	if k.SealedSecret == "" {
+		return "", fmt.Errorf("access key %s has no sealed secret, set it again", k.Id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(k.SealedSecret)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newAccessKeyGCM(masterSecret)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("sealed secret is too short")
+	}
+	secret, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(k.Id))
+	if err != nil {
+		return "", fmt.Errorf("open the secret of access key %s: %v", k.Id, err)
+	}
+	return string(secret), nil
+}
+
+func newAccessKeyGCM(masterSecret Secret) (cipher.AEAD, error) {
+	if masterSecret == "" {
+		return nil, errors.New("no secure.secret to seal the access key secrets")
+	}
+	key := sha256.Sum256([]byte(masterSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenAccessKeySecret generates a random secret for an access key
+func GenAccessKeySecret() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessKeys is the access keys stored on the master, optionally saved in a file
+type AccessKeys struct {
+	keys  map[string]*AccessKey
+	file  string
+	mutex sync.RWMutex
+}
+
+func NewAccessKeys() *AccessKeys {
+	return &AccessKeys{keys: make(map[string]*AccessKey)}
+}
+
+// Load loads the keys from the file, and saves the changes to it later.
+// The secrets saved in clear by the previous versions are replaced by their hashes.
+func (ak *AccessKeys) Load(file string) error {
+	ak.mutex.Lock()
+	defer ak.mutex.Unlock()
+	ak.file = file
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var keys []*AccessKey
+	if err = json.Unmarshal(content, &keys); err != nil {
+		return fmt.Errorf("invalid access key file %s: %v", file, err)
+	}
+	cleared := false
+	for _, k := range keys {
+		if k.Secret != "" {
+			k, cleared = k.Hashed(), true
+		}
+		ak.keys[k.Id] = k
+	}
+	if cleared {
+		return ak.save()
+	}
+	return nil
+}
+
+func (ak *AccessKeys) save() error {
+	if ak.file == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(ak.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := ak.file + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ak.file)
+}
+
+func (ak *AccessKeys) Get(id string) (*AccessKey, bool) {
+	ak.mutex.RLock()
+	defer ak.mutex.RUnlock()
+	k, ok := ak.keys[id]
+	return k, ok
+}
+
+func (ak *AccessKeys) Set(k *AccessKey) error {
+	ak.mutex.Lock()
+	defer ak.mutex.Unlock()
+	ak.keys[k.Id] = k.Hashed()
+	return ak.save()
+}
+
+func (ak *AccessKeys) Delete(id string) error {
+	ak.mutex.Lock()
+	defer ak.mutex.Unlock()
+	delete(ak.keys, id)
+	return ak.save()
+}
+
+// List returns the keys sorted by id
+func (ak *AccessKeys) List() []*AccessKey {
+	ak.mutex.RLock()
+	defer ak.mutex.RUnlock()
+	return ak.list()
+}
+
+func (ak *AccessKeys) list() []*AccessKey {
+	keys := make(accessKeyList, 0, len(ak.keys))
+	for _, k := range ak.keys {
+		keys = append(keys, k)
+	}
+	sort.Sort(keys)
+	return keys
+}
+
+type accessKeyList []*AccessKey
+
+func (l accessKeyList) Len() int           { return len(l) }
+func (l accessKeyList) Less(i, j int) bool { return l[i].Id < l[j].Id }
+func (l accessKeyList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// Authenticate returns the key if the hash of the secret matches
+func (ak *AccessKeys) Authenticate(id, secret string) (*AccessKey, error) {
+	k, ok := ak.Get(id)
+	if !ok || subtle.ConstantTimeCompare([]byte(k.SecretHash), []byte(HashAccessKeySecret(secret))) != 1 {
+		return nil, ErrUnauthorized
+	}
+	return k, nil
+}
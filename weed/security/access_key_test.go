@@ -0,0 +1,189 @@
+package security
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScope(t *testing.T) {
+	actions, err := ParseActions("read, write")
+	if err != nil || len(actions) != 2 {
+		t.Fatalf("parse actions: %v %v", actions, err)
+	}
+	if _, err = ParseActions("read,own"); err == nil {
+		t.Errorf("expect unknown action error")
+	}
+	if actions, _ = ParseActions("*"); len(actions) != len(allActions) {
+		t.Errorf("* should be all actions, got %v", actions)
+	}
+
+	s := Scope{Collections: []string{"pics", "logs"}, Actions: actions}
+	if !s.Allows(ActionAdmin, "pics") || s.Allows(ActionRead, "other") || s.Allows(ActionRead, "*") {
+		t.Errorf("unexpected permissions of %+v", s)
+	}
+	narrowed := s.Narrow(Scope{Collections: []string{"pics", "other"}, Actions: []Action{ActionRead}})
+	if len(narrowed.Collections) != 1 || narrowed.Collections[0] != "pics" || len(narrowed.Actions) != 1 {
+		t.Errorf("unexpected narrowed scope %+v", narrowed)
+	}
+	if narrowed = s.Narrow(Scope{}); len(narrowed.Collections) != 2 || len(narrowed.Actions) != 4 {
+		t.Errorf("empty scope should not narrow, got %+v", narrowed)
+	}
+	all := Scope{Collections: []string{"*"}, Actions: []Action{ActionRead}}
+	if narrowed = all.Narrow(Scope{Collections: []string{"pics"}}); !narrowed.Allows(ActionRead, "pics") || narrowed.Allows(ActionRead, "logs") {
+		t.Errorf("unexpected narrowed scope %+v", narrowed)
+	}
+}
+
+func TestAccessKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "access_keys")
+
+	keys := NewAccessKeys()
+	if err = keys.Load(file); err != nil {
+		t.Fatal(err)
+	}
+	keys.Set(&AccessKey{Id: "b", Secret: "s2", Scope: Scope{Collections: []string{"*"}, Actions: []Action{ActionRead}}})
+	keys.Set(&AccessKey{Id: "a", Secret: "s1", Scope: Scope{Collections: []string{"pics"}, Actions: []Action{ActionWrite}}})
+	if _, err = keys.Authenticate("a", "s2"); err != ErrUnauthorized {
+		t.Errorf("expect wrong secret error, got %v", err)
+	}
+	if content, _ := ioutil.ReadFile(file); strings.Contains(string(content), `"s1"`) || !strings.Contains(string(content), HashAccessKeySecret("s1")) {
+		t.Errorf("the secrets should be saved hashed: %s", content)
+	}
+
+	loaded := NewAccessKeys()
+	if err = loaded.Load(file); err != nil {
+		t.Fatal(err)
+	}
+	list := loaded.List()
+	if len(list) != 2 || list[0].Id != "a" {
+		t.Fatalf("unexpected keys %v", list)
+	}
+	k, err := loaded.Authenticate("a", "s1")
+	if err != nil || !k.Allows(ActionWrite, "pics") {
+		t.Errorf("unexpected key %+v: %v", k, err)
+	}
+	loaded.Delete("a")
+	if _, ok := loaded.Get("a"); ok {
+		t.Errorf("key a should be deleted")
+	}
+
+	// the secrets saved in clear are hashed when loaded
+	legacy := filepath.Join(dir, "legacy_keys")
+	ioutil.WriteFile(legacy, []byte(`[{"id":"c","secret":"s3","collections":["*"],"actions":["read"]}]`), 0600)
+	if err = loaded.Load(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loaded.Authenticate("c", "s3"); err != nil {
+		t.Errorf("authenticate the legacy key: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(legacy); strings.Contains(string(content), `"s3"`) {
+		t.Errorf("the legacy secrets should be hashed: %s", content)
+	}
+	if _, err = k.OpenSecret("secret"); err == nil {
+		t.Errorf("the key without a sealed secret should fail to open")
+	}
+}
+
+func TestAccessKeySeal(t *testing.T) {
+	k := &AccessKey{Id: "a", Secret: "s1"}
+	if err := k.Seal("secret"); err != nil {
+		t.Fatal(err)
+	}
+	hashed := k.Hashed()
+	if hashed.Secret != "" || strings.Contains(hashed.SealedSecret, "s1") {
+		t.Fatalf("unexpected hashed key %+v", hashed)
+	}
+	if secret, err := hashed.OpenSecret("secret"); err != nil || secret != "s1" {
+		t.Errorf("open the secret: %s %v", secret, err)
+	}
+	if _, err := hashed.OpenSecret("other"); err == nil {
+		t.Errorf("the secret should not open with another master secret")
+	}
+	moved := *hashed
+	moved.Id = "b"
+	if _, err := moved.OpenSecret("secret"); err == nil {
+		t.Errorf("the sealed secret should not open for another key")
+	}
+}
+
+func TestGuardAuthorize(t *testing.T) {
+	g := NewGuard(nil, "secret")
+	token, _ := GenScopedJwt(g.GetSecretKey(), "a", Scope{Collections: []string{"pics"}, Actions: []Action{ActionRead, ActionWrite}}, time.Minute)
+	legacy := GenJwt(g.GetSecretKey(), "3,01637037d6")
+	read := GenReadJwt(g.GetSecretKey(), "3,01637037d6")
+	other, _ := GenScopedJwt("other", "a", Scope{Collections: []string{"*"}, Actions: allActions}, time.Minute)
+	expired, _ := GenScopedJwt(g.GetSecretKey(), "a", Scope{Collections: []string{"*"}, Actions: allActions}, -time.Minute)
+
+	cases := []struct {
+		token      EncodedJwt
+		action     Action
+		collection string
+		fileId     string
+		allowed    bool
+	}{
+		{"", ActionWrite, "pics", "3,01637037d6", false},
+		{token, ActionWrite, "pics", "3,01637037d6", true},
+		{token, ActionWrite, "logs", "3,01637037d6", false},
+		{token, ActionDelete, "pics", "3,01637037d6", false},
+		{token, ActionAdmin, "*", "", false},
+		{legacy, ActionWrite, "logs", "3,01637037d6", true},
+		{legacy, ActionDelete, "logs", "3,01637037d7", false},
+		{legacy, ActionRead, "logs", "3,01637037d6", false},
+		{read, ActionRead, "logs", "3,01637037d6", true},
+		{read, ActionRead, "logs", "3,01637037d7", false},
+		{read, ActionWrite, "logs", "3,01637037d6", false},
+		{other, ActionWrite, "pics", "3,01637037d6", false},
+		{expired, ActionWrite, "pics", "3,01637037d6", false},
+		{g.InternalJwt(), ActionAdmin, "*", "", true},
+	}
+	for i, c := range cases {
+		r := httptest.NewRequest("POST", "/"+c.fileId, nil)
+		if c.token != "" {
+			r.Header.Set("Authorization", "BEARER "+string(c.token))
+		}
+		if err := g.Authorize(r, c.action, c.collection, c.fileId); (err == nil) != c.allowed {
+			t.Errorf("case %d: %s on %q allowed %v, got %v", i, c.action, c.collection, c.allowed, err)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/3,01637037d6", nil)
+	if err := g.AuthorizeRead(r, "pics", "3,01637037d6"); err != nil {
+		t.Errorf("reads are not secured: %v", err)
+	}
+	g.SetReadSecured(true)
+	if err := g.AuthorizeRead(r, "pics", "3,01637037d6"); err == nil {
+		t.Errorf("secured reads need a token")
+	}
+	r = httptest.NewRequest("GET", "/3,01637037d6?jwt="+string(token), nil)
+	if err := g.AuthorizeRead(r, "pics", "3,01637037d6"); err != nil {
+		t.Errorf("read with token: %v", err)
+	}
+
+	// the tokens of the deleted keys are rejected where the keys are known
+	g.SetKeyChecker(func(id string) bool { return id != "a" })
+	r = httptest.NewRequest("POST", "/3,01637037d6", nil)
+	r.Header.Set("Authorization", "BEARER "+string(token))
+	if err := g.Authorize(r, ActionWrite, "pics", "3,01637037d6"); err == nil {
+		t.Errorf("the token of a deleted key should be rejected")
+	}
+	r.Header.Set("Authorization", "BEARER "+string(g.InternalJwt()))
+	if err := g.Authorize(r, ActionWrite, "pics", "3,01637037d6"); err != nil {
+		t.Errorf("the internal token is not of a key: %v", err)
+	}
+
+	whiteListed := NewGuard([]string{"192.0.2.1"}, "secret")
+	r = httptest.NewRequest("POST", "/3,01637037d6", nil)
+	if err := whiteListed.Authorize(r, ActionAdmin, "*", ""); err != nil {
+		t.Errorf("white listed request: %v", err)
+	}
+}
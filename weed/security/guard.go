@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 )
@@ -17,20 +18,24 @@ var (
 
 /*
 Guard is to ensure data access security.
-There are 2 ways to check access:
+There are 3 ways to check access:
 1. white list. It's checking request ip address.
-2. JSON Web Token(JWT) generated from secretKey.
+2. client certificate. It's checking the request is over TLS with a certificate signed by the CA,
+  which replaces the white list if required.
+3. JSON Web Token(JWT) generated from secretKey.
   The jwt can come from:
   1. url parameter jwt=...
   2. request header "Authorization"
   3. cookie with the name "jwt"
 
-The white list is checked first because it is easy.
+The white list or the client certificate is checked first because it is easy.
 Then the JWT is checked.
 
 The Guard will also check these claims if provided:
 1. "exp" Expiration Time
 2. "nbf" Not Before
+3. "col" and "act", the collections and actions allowed, see GenScopedJwt
+4. "sub" the only file id allowed
 
 Generating JWT:
 1. use HS256 to sign
@@ -47,35 +52,59 @@ type Guard struct {
 	whiteList []string
 	secretKey Secret
 
-	isActive bool
+	isActive           bool
+	readSecured        bool
+	clientCertRequired bool
+	keyExists          func(id string) bool
 }
 
 func NewGuard(whiteList []string, secretKey string) *Guard {
 	g := &Guard{whiteList: whiteList, secretKey: Secret(secretKey)}
-	g.isActive = len(g.whiteList) != 0 || len(g.secretKey) != 0
+	g.updateActive()
 	return g
 }
 
+func (g *Guard) updateActive() {
+	g.isActive = len(g.whiteList) != 0 || len(g.secretKey) != 0 || g.clientCertRequired
+}
+
 func (g *Guard) SetSecretKey(k string) {
 	g.secretKey = Secret(k)
-	g.isActive = len(g.whiteList) != 0 || len(g.secretKey) != 0
+	g.updateActive()
 }
 
 func (g *Guard) GetSecretKey() Secret {
 	return g.secretKey
 }
 
+// SetReadSecured makes the reads also require a JWT if the secret key is set
+func (g *Guard) SetReadSecured(b bool) {
+	g.readSecured = b
+}
+
+func (g *Guard) IsReadSecured() bool {
+	return g.readSecured
+}
+
 func (g *Guard) SetWhiteList(l []string) {
 	g.whiteList = l
-	g.isActive = len(g.whiteList) != 0 || len(g.secretKey) != 0
+	g.updateActive()
+}
+
+// SetClientCertRequired makes the requests checked by the white list
+// also pass with a verified client certificate, and fail without one if the white list is empty.
+func (g *Guard) SetClientCertRequired(b bool) {
+	g.clientCertRequired = b
+	g.updateActive()
 }
 
 func (g *Guard) WhiteList(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
-	if !g.isActive {
-		//if no security needed, just skip all checkings
-		return f
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		//if no security needed, just skip all checkings
+		if !g.isActive {
+			f(w, r)
+			return
+		}
 		if err := g.checkWhiteList(w, r); err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
@@ -84,20 +113,110 @@ func (g *Guard) WhiteList(f func(w http.ResponseWriter, r *http.Request)) func(w
 	}
 }
 
-func (g *Guard) Secure(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
-	if !g.isActive {
-		//if no security needed, just skip all checkings
-		return f
+// Secure requires the whitelist or a JWT allowing the action on the collection in the form
+func (g *Guard) Secure(action Action, f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := g.Authorize(r, action, r.FormValue("collection"), ""); err != nil {
+			writeUnauthorized(w, err)
+			return
+		}
+		f(w, r)
 	}
+}
+
+// Admin requires the whitelist or a JWT allowing the admin calls on the collection in the form,
+// or on all collections if the form has no collection.
+func (g *Guard) Admin(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := g.checkJwt(w, r); err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+		collection := r.FormValue("collection")
+		if collection == "" {
+			collection = "*"
+		}
+		if err := g.Authorize(r, ActionAdmin, collection, ""); err != nil {
+			writeUnauthorized(w, err)
 			return
 		}
 		f(w, r)
 	}
 }
 
+// AdminAll requires the whitelist or a JWT allowing the admin calls on all collections,
+// for the calls not limited to a collection.
+func (g *Guard) AdminAll(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := g.Authorize(r, ActionAdmin, "*", ""); err != nil {
+			writeUnauthorized(w, err)
+			return
+		}
+		f(w, r)
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	fmt.Fprintf(w, "{\"error\":%q}", err.Error())
+}
+
+// Authorize checks the request is from the whitelist, or has a JWT allowing the action.
+// The fileId is empty if the action is not on a file.
+func (g *Guard) Authorize(r *http.Request, action Action, collection, fileId string) error {
+	if !g.isActive {
+		return nil
+	}
+	whiteListErr := g.checkWhiteList(nil, r)
+	if (len(g.whiteList) > 0 || g.clientCertRequired) && whiteListErr == nil {
+		return nil
+	}
+	if len(g.secretKey) == 0 {
+		return whiteListErr
+	}
+	tokenStr := GetJwt(r)
+	if tokenStr == "" {
+		return ErrUnauthorized
+	}
+	scope, sub, keyId, err := DecodeScope(g.secretKey, tokenStr)
+	if err != nil {
+		glog.V(1).Infof("Token verification error from %s: %v", r.RemoteAddr, err)
+		return ErrUnauthorized
+	}
+	if keyId != "" && g.keyExists != nil && !g.keyExists(keyId) {
+		glog.V(1).Infof("Token of the deleted access key %s from %s", keyId, r.RemoteAddr)
+		return ErrUnauthorized
+	}
+	if sub != "" && sub != fileId {
+		return fmt.Errorf("token is only for file %s", sub)
+	}
+	if !scope.Allows(action, collection) {
+		glog.V(1).Infof("No %s permission on collection %q from %s", action, collection, r.RemoteAddr)
+		return fmt.Errorf("no %s permission on collection %q", action, collection)
+	}
+	return nil
+}
+
+// SetKeyChecker makes the guard reject the tokens of the access keys that no longer exist.
+// Only the masters know the keys, the other servers rely on the short expiry of the tokens.
+func (g *Guard) SetKeyChecker(keyExists func(id string) bool) {
+	g.keyExists = keyExists
+}
+
+// AuthorizeRead checks the reads only if they are secured
+func (g *Guard) AuthorizeRead(r *http.Request, collection, fileId string) error {
+	if !g.readSecured {
+		return nil
+	}
+	return g.Authorize(r, ActionRead, collection, fileId)
+}
+
+// InternalJwt returns a short lived JWT allowing everything, for the calls between the servers
+func (g *Guard) InternalJwt() EncodedJwt {
+	encoded, err := GenScopedJwt(g.secretKey, "", Scope{Collections: []string{"*"}, Actions: allActions}, time.Minute)
+	if err != nil {
+		glog.V(0).Infof("Failed to sign internal token: %v", err)
+	}
+	return encoded
+}
+
 func GetActualRemoteHost(r *http.Request) (host string, err error) {
 	host = r.Header.Get("HTTP_X_FORWARDED_FOR")
 	if host == "" {
@@ -113,6 +232,15 @@ func GetActualRemoteHost(r *http.Request) (host string, err error) {
 }
 
 func (g *Guard) checkWhiteList(w http.ResponseWriter, r *http.Request) error {
+	if g.clientCertRequired {
+		if HasVerifiedClientCert(r) {
+			return nil
+		}
+		if len(g.whiteList) == 0 {
+			glog.V(1).Infof("No verified client certificate: %s", r.RemoteAddr)
+			return fmt.Errorf("No verified client certificate: %s", r.RemoteAddr)
+		}
+	}
 	if len(g.whiteList) == 0 {
 		return nil
 	}
@@ -148,33 +276,3 @@ func (g *Guard) checkWhiteList(w http.ResponseWriter, r *http.Request) error {
 	glog.V(1).Infof("Not in whitelist: %s", r.RemoteAddr)
 	return fmt.Errorf("Not in whitelis: %s", r.RemoteAddr)
 }
-
-func (g *Guard) checkJwt(w http.ResponseWriter, r *http.Request) error {
-	if g.checkWhiteList(w, r) == nil {
-		return nil
-	}
-
-	if len(g.secretKey) == 0 {
-		return nil
-	}
-
-	tokenStr := GetJwt(r)
-
-	if tokenStr == "" {
-		return ErrUnauthorized
-	}
-
-	// Verify the token
-	token, err := DecodeJwt(g.secretKey, tokenStr)
-	if err != nil {
-		glog.V(1).Infof("Token verification error from %s: %v", r.RemoteAddr, err)
-		return ErrUnauthorized
-	}
-	if !token.Valid {
-		glog.V(1).Infof("Token invliad from %s: %v", r.RemoteAddr, tokenStr)
-		return ErrUnauthorized
-	}
-
-	glog.V(1).Infof("No permission from %s", r.RemoteAddr)
-	return fmt.Errorf("No write permisson from %s", r.RemoteAddr)
-}
@@ -21,7 +21,7 @@ func GenJwt(secret Secret, fileId string) EncodedJwt {
 	t := jwt.New(jwt.GetSigningMethod("HS256"))
 	t.Claims["exp"] = time.Now().Unix() + 10
 	t.Claims["sub"] = fileId
-	encoded, e := t.SignedString(secret)
+	encoded, e := t.SignedString([]byte(secret))
 	if e != nil {
 		glog.V(0).Infof("Failed to sign claims: %v", t.Claims)
 		return ""
@@ -29,6 +29,22 @@ func GenJwt(secret Secret, fileId string) EncodedJwt {
 	return EncodedJwt(encoded)
 }
 
+// GenReadJwt returns a short lived JWT allowing only to read the file id,
+// for the servers reading the chunks of a file they serve.
+func GenReadJwt(secret Secret, fileId string) EncodedJwt {
+	encoded, e := EncodeJwt(secret, map[string]interface{}{
+		"exp": time.Now().Unix() + 10,
+		"sub": fileId,
+		"col": []string{"*"},
+		"act": []Action{ActionRead},
+	})
+	if e != nil {
+		glog.V(0).Infof("Failed to sign read token of %s: %v", fileId, e)
+		return ""
+	}
+	return encoded
+}
+
 func GetJwt(r *http.Request) EncodedJwt {
 
 	// Get token from query params
@@ -60,13 +76,62 @@ func EncodeJwt(secret Secret, claims map[string]interface{}) (EncodedJwt, error)
 
 	t := jwt.New(jwt.GetSigningMethod("HS256"))
 	t.Claims = claims
-	encoded, e := t.SignedString(secret)
+	encoded, e := t.SignedString([]byte(secret))
 	return EncodedJwt(encoded), e
 }
 
 func DecodeJwt(secret Secret, tokenString EncodedJwt) (token *jwt.Token, err error) {
 	// check exp, nbf
 	return jwt.Parse(string(tokenString), func(token *jwt.Token) (interface{}, error) {
-		return secret, nil
+		return []byte(secret), nil
+	})
+}
+
+// GenScopedJwt signs the scope of an access key into the claims "col" and "act",
+// the key id is in "kid".
+func GenScopedJwt(secret Secret, keyId string, scope Scope, expiresIn time.Duration) (EncodedJwt, error) {
+	if secret == "" {
+		return "", nil
+	}
+	return EncodeJwt(secret, map[string]interface{}{
+		"exp": time.Now().Add(expiresIn).Unix(),
+		"kid": keyId,
+		"col": scope.Collections,
+		"act": scope.Actions,
 	})
 }
+
+// DecodeScope verifies the token and returns its scope, with the id of the access key it was issued for.
+// The tokens by GenJwt have no scope, they can write or delete the file id in "sub".
+func DecodeScope(secret Secret, tokenString EncodedJwt) (scope Scope, fileId, keyId string, err error) {
+	token, err := DecodeJwt(secret, tokenString)
+	if err != nil {
+		return
+	}
+	if !token.Valid {
+		err = ErrUnauthorized
+		return
+	}
+	fileId, _ = token.Claims["sub"].(string)
+	keyId, _ = token.Claims["kid"].(string)
+	if _, scoped := token.Claims["act"]; !scoped {
+		if fileId == "" {
+			err = ErrUnauthorized
+			return
+		}
+		return Scope{Collections: []string{"*"}, Actions: []Action{ActionWrite, ActionDelete}}, fileId, "", nil
+	}
+	actions, _ := token.Claims["act"].([]interface{})
+	for _, a := range actions {
+		if action, ok := a.(string); ok {
+			scope.Actions = append(scope.Actions, Action(action))
+		}
+	}
+	collections, _ := token.Claims["col"].([]interface{})
+	for _, c := range collections {
+		if collection, ok := c.(string); ok {
+			scope.Collections = append(scope.Collections, collection)
+		}
+	}
+	return
+}
@@ -0,0 +1,250 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+The AWS Signature Version 4 of the S3 clients is verified with a signing key derived
+from the secret of the access key for one day, one region and one service. The masters
+derive the signing keys, so the servers verifying the signatures never see the secrets.
+
+The requests are signed in the Authorization header, or presigned in the query.
+*/
+
+const (
+	SigV4Algorithm        = "AWS4-HMAC-SHA256"
+	SigV4UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	SigV4StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4MaxSkew    = 15 * time.Minute
+	sigV4MaxExpires = 7 * 24 * time.Hour
+)
+
+// ErrSigV4Missing is returned by ParseSigV4 if the request is not signed with SigV4
+var ErrSigV4Missing = errors.New("no AWS signature version 4")
+
+// SigV4Credential is the access key id with the scope of the signing key
+type SigV4Credential struct {
+	AccessKeyId string
+	Date        string // yyyymmdd
+	Region      string
+	Service     string
+}
+
+func (c SigV4Credential) scope() string {
+	return c.Date + "/" + c.Region + "/" + c.Service + "/aws4_request"
+}
+
+// SigV4Signature is the signature of a request, with what it signs
+type SigV4Signature struct {
+	Credential    SigV4Credential
+	SignedHeaders []string
+	Signature     string
+	// PayloadHash is the hex encoded sha256 of the body, or one of SigV4UnsignedPayload
+	// and SigV4StreamingPayload. The body is not verified here.
+	PayloadHash string
+	date        time.Time
+	expires     time.Duration // only for the presigned requests
+}
+
+// SigV4SigningKey derives the signing key of the secret for the date (yyyymmdd), the region and the service
+func SigV4SigningKey(secret, date, region, service string) []byte {
+	key := hmacSha256([]byte("AWS4"+secret), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, service)
+	return hmacSha256(key, "aws4_request")
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// ParseSigV4 parses the signature of the request, from the Authorization header or the query
+func ParseSigV4(r *http.Request) (*SigV4Signature, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != "" {
+		return parsePresignedSigV4(query)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, SigV4Algorithm+" ") {
+		return nil, ErrSigV4Missing
+	}
+	s := &SigV4Signature{}
+	for _, field := range strings.Split(auth[len(SigV4Algorithm)+1:], ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid authorization field %q", field)
+		}
+		switch kv[0] {
+		case "Credential":
+			c, err := parseSigV4Credential(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			s.Credential = c
+		case "SignedHeaders":
+			s.SignedHeaders = strings.Split(kv[1], ";")
+		case "Signature":
+			s.Signature = kv[1]
+		}
+	}
+	if s.Credential.AccessKeyId == "" || len(s.SignedHeaders) == 0 || s.Signature == "" {
+		return nil, errors.New("incomplete authorization header")
+	}
+	var err error
+	if amzDate := r.Header.Get("X-Amz-Date"); amzDate != "" {
+		s.date, err = time.Parse(sigV4TimeFormat, amzDate)
+	} else {
+		s.date, err = http.ParseTime(r.Header.Get("Date"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid request date: %v", err)
+	}
+	if s.PayloadHash = r.Header.Get("X-Amz-Content-Sha256"); s.PayloadHash == "" {
+		return nil, errors.New("missing x-amz-content-sha256")
+	}
+	return s, nil
+}
+
+func parsePresignedSigV4(query url.Values) (*SigV4Signature, error) {
+	if query.Get("X-Amz-Algorithm") != SigV4Algorithm {
+		return nil, fmt.Errorf("unsupported algorithm %s", query.Get("X-Amz-Algorithm"))
+	}
+	c, err := parseSigV4Credential(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return nil, err
+	}
+	s := &SigV4Signature{
+		Credential:    c,
+		SignedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		Signature:     query.Get("X-Amz-Signature"),
+		PayloadHash:   SigV4UnsignedPayload,
+	}
+	if s.date, err = time.Parse(sigV4TimeFormat, query.Get("X-Amz-Date")); err != nil {
+		return nil, fmt.Errorf("invalid X-Amz-Date: %v", err)
+	}
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > sigV4MaxExpires {
+		return nil, fmt.Errorf("invalid X-Amz-Expires %q", query.Get("X-Amz-Expires"))
+	}
+	s.expires = time.Duration(seconds) * time.Second
+	if s.Signature == "" || query.Get("X-Amz-SignedHeaders") == "" {
+		return nil, errors.New("incomplete presigned query")
+	}
+	return s, nil
+}
+
+// parseSigV4Credential parses "<access key id>/<yyyymmdd>/<region>/<service>/aws4_request"
+func parseSigV4Credential(s string) (c SigV4Credential, err error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 5 || parts[0] == "" || len(parts[1]) != 8 || parts[4] != "aws4_request" {
+		return c, fmt.Errorf("invalid credential %q", s)
+	}
+	return SigV4Credential{AccessKeyId: parts[0], Date: parts[1], Region: parts[2], Service: parts[3]}, nil
+}
+
+// Verify checks the signature of the request with the signing key of its credential,
+// and that the request is signed for now
+func (s *SigV4Signature) Verify(r *http.Request, signingKey []byte, now time.Time) error {
+	if s.date.Format("20060102") != s.Credential.Date {
+		return errors.New("the request date is not the date of the credential")
+	}
+	if s.expires > 0 {
+		if now.Before(s.date.Add(-sigV4MaxSkew)) || now.After(s.date.Add(s.expires)) {
+			return errors.New("the presigned request is expired")
+		}
+	} else if now.Sub(s.date) > sigV4MaxSkew || s.date.Sub(now) > sigV4MaxSkew {
+		return errors.New("the request time is too skewed")
+	}
+	signature := hex.EncodeToString(hmacSha256(signingKey, s.stringToSign(r)))
+	if !hmac.Equal([]byte(signature), []byte(s.Signature)) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+func (s *SigV4Signature) stringToSign(r *http.Request) string {
+	canonicalRequest := s.canonicalRequest(r)
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{
+		SigV4Algorithm,
+		s.date.UTC().Format(sigV4TimeFormat),
+		s.Credential.scope(),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+func (s *SigV4Signature) canonicalRequest(r *http.Request) string {
+	var headers []string
+	for _, name := range s.SignedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = r.Header.Get("Content-Length")
+			if value == "" && r.ContentLength >= 0 {
+				value = strconv.FormatInt(r.ContentLength, 10)
+			}
+		default:
+			var values []string
+			for _, v := range r.Header[http.CanonicalHeaderKey(name)] {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		headers = append(headers, name+":"+value+"\n")
+	}
+	return strings.Join([]string{
+		r.Method,
+		sigV4Escape(r.URL.Path, false),
+		s.canonicalQuery(r.URL.Query()),
+		strings.Join(headers, ""),
+		strings.Join(s.SignedHeaders, ";"),
+		s.PayloadHash,
+	}, "\n")
+}
+
+func (s *SigV4Signature) canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, values := range query {
+		if k == "X-Amz-Signature" && s.expires > 0 {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, sigV4Escape(k, true)+"="+sigV4Escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape percent encodes all but the unreserved characters, and the slashes if not escapeSlash
+func sigV4Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+/*
+The servers can talk to each other over TLS.
+Each server has a certificate signed by a shared CA, which it uses both
+to serve https and as the client certificate when calling the other servers,
+so the certificate should allow both server and client authentication.
+*/
+
+// NewServerTLSConfig loads the certificate to serve https.
+// The client certificates signed by the CA are verified if given,
+// and the Guard can require them, see Guard.SetClientCertRequired.
+func NewServerTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate %s: %v", certFile, err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile != "" {
+		if config.ClientCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// NewClientTLSConfig verifies the servers by the CA, or the system CAs if caFile is empty,
+// and presents the certificate if given.
+func NewClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	var err error
+	if caFile != "" {
+		if config.RootCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load certificate %s: %v", certFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	content, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate %s: %v", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, errors.New("no certificate found in " + caFile)
+	}
+	return pool, nil
+}
+
+// HasVerifiedClientCert checks the request comes with a client certificate signed by the CA
+func HasVerifiedClientCert(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
@@ -0,0 +1,111 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a certificate signed by the parent, self signed if parent is nil
+func writeTestCert(t *testing.T, dir, name string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	ioutil.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := func(name string) string { return filepath.Join(dir, name) }
+	ca, caKey := writeTestCert(t, dir, "ca", 1, nil, nil)
+	writeTestCert(t, dir, "server", 2, ca, caKey)
+	otherCa, otherCaKey := writeTestCert(t, dir, "other", 3, nil, nil)
+	writeTestCert(t, dir, "stranger", 4, otherCa, otherCaKey)
+
+	serverConfig, err := NewServerTLSConfig(file("server.crt"), file("server.key"), file("ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGuard(nil, "")
+	g.SetClientCertRequired(true)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(g.WhiteList(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(cert string) (int, error) {
+		config, err := NewClientTLSConfig(file(cert+".crt"), file(cert+".key"), file("ca.crt"))
+		if cert == "" {
+			config, err = NewClientTLSConfig("", "", file("ca.crt"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	if code, err := get("server"); err != nil || code != http.StatusOK {
+		t.Errorf("with a client certificate: %d %v", code, err)
+	}
+	if code, err := get(""); err != nil || code != http.StatusUnauthorized {
+		t.Errorf("without a client certificate: %d %v", code, err)
+	}
+	if code, _ := get("stranger"); code == http.StatusOK {
+		t.Errorf("client certificate by other CA should be rejected")
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{}}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("server certificate should not be verified by the system CAs")
+	}
+	if _, err = NewServerTLSConfig(file("server.crt"), file("server.key"), file("server.key")); err == nil {
+		t.Errorf("expect no certificate in the CA file")
+	}
+}
@@ -0,0 +1,140 @@
+package sequence
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// Reserver reserves count file ids not less than min in the cluster,
+// and returns the first reserved file id.
+type Reserver func(min, count uint64) (start uint64, err error)
+
+// BatchSequencer issues the file ids from batches reserved in the cluster,
+// e.g. with a raft command, so no file id is issued twice after a restart or
+// a leader change. The reserved max is the replicated state, updated by
+// ApplyReservation on every master, and also saved in a local file in case
+// the raft log is cleared.
+type BatchSequencer struct {
+	counter      uint64 // the next file id
+	limit        uint64 // the file ids up to limit are reserved for this master
+	step         uint64
+	reserve      Reserver
+	sequenceLock sync.Mutex
+
+	reserved     uint64 // the max file id reserved in the cluster
+	stateFile    string
+	reservedLock sync.Mutex
+}
+
+// NewBatchSequencer loads the reserved max from the state file if it is not empty
+func NewBatchSequencer(step uint64, stateFile string) (*BatchSequencer, error) {
+	if step == 0 {
+		step = 1
+	}
+	m := &BatchSequencer{counter: 1, step: step, stateFile: stateFile}
+	if stateFile == "" {
+		return m, nil
+	}
+	content, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if m.reserved, err = strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64); err != nil {
+		return nil, errors.New("invalid sequencer state file " + stateFile)
+	}
+	glog.V(0).Infoln("reserved file ids up to", m.reserved, "in", stateFile)
+	return m, nil
+}
+
+func (m *BatchSequencer) SetReserver(reserve Reserver) {
+	m.sequenceLock.Lock()
+	defer m.sequenceLock.Unlock()
+	m.reserve = reserve
+}
+
+// NextFileId returns 0 count if the file ids can not be reserved
+func (m *BatchSequencer) NextFileId(count uint64) (uint64, uint64) {
+	m.sequenceLock.Lock()
+	defer m.sequenceLock.Unlock()
+	if m.limit == 0 || m.counter+count-1 > m.limit {
+		if err := m.reserveBatch(count); err != nil {
+			glog.V(0).Infof("reserve %d file ids from %d: %v", count, m.counter, err)
+			return 0, 0
+		}
+	}
+	ret := m.counter
+	m.counter += count
+	return ret, count
+}
+
+func (m *BatchSequencer) reserveBatch(count uint64) error {
+	if m.reserve == nil {
+		return errors.New("no file id reserver")
+	}
+	size := m.step
+	if size < count {
+		size = count
+	}
+	start, err := m.reserve(m.counter, size)
+	if err != nil {
+		return err
+	}
+	m.counter, m.limit = start, start+size-1
+	return nil
+}
+
+// ApplyReservation reserves count file ids after the reserved ones and not less than min.
+// It is deterministic, so all masters have the same reserved max.
+func (m *BatchSequencer) ApplyReservation(min, count uint64) (start uint64) {
+	m.reservedLock.Lock()
+	defer m.reservedLock.Unlock()
+	start = m.reserved + 1
+	if start < min {
+		start = min
+	}
+	m.reserved = start + count - 1
+	if m.stateFile != "" {
+		if err := m.saveState(); err != nil {
+			glog.V(0).Infof("save sequencer state to %s: %v", m.stateFile, err)
+		}
+	}
+	return start
+}
+
+func (m *BatchSequencer) saveState() error {
+	tmp := m.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatUint(m.reserved, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.stateFile)
+}
+
+func (m *BatchSequencer) Reserved() uint64 {
+	m.reservedLock.Lock()
+	defer m.reservedLock.Unlock()
+	return m.reserved
+}
+
+// SetMax skips the file ids seen on the volume servers,
+// a new batch is reserved if they are beyond the current one.
+func (m *BatchSequencer) SetMax(seenValue uint64) {
+	m.sequenceLock.Lock()
+	defer m.sequenceLock.Unlock()
+	if m.counter <= seenValue {
+		m.counter = seenValue + 1
+	}
+}
+
+func (m *BatchSequencer) Peek() uint64 {
+	m.sequenceLock.Lock()
+	defer m.sequenceLock.Unlock()
+	return m.counter
+}
@@ -0,0 +1,119 @@
+package sequence
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cluster applies the reservations on all the masters, like the raft log
+type cluster []*BatchSequencer
+
+func (c cluster) reserve(min, count uint64) (start uint64, err error) {
+	for _, m := range c {
+		start = m.ApplyReservation(min, count)
+	}
+	return start, nil
+}
+
+func TestBatchSequencerLeaderChange(t *testing.T) {
+	a, _ := NewBatchSequencer(100, "")
+	b, _ := NewBatchSequencer(100, "")
+	masters := cluster{a, b}
+	a.SetReserver(masters.reserve)
+	b.SetReserver(masters.reserve)
+
+	seen := make(map[uint64]bool)
+	issue := func(m *BatchSequencer, count uint64) {
+		start, n := m.NextFileId(count)
+		if n != count {
+			t.Fatalf("expect %d file ids, got %d", count, n)
+		}
+		for id := start; id < start+n; id++ {
+			if seen[id] {
+				t.Fatalf("file id %d is issued twice", id)
+			}
+			seen[id] = true
+		}
+	}
+	for i := 0; i < 30; i++ {
+		issue(a, 7)
+	}
+	// b becomes the leader, and a comes back later with its unused batch
+	for i := 0; i < 30; i++ {
+		issue(b, 9)
+	}
+	issue(a, 3)
+	issue(b, 250)
+	if a.Reserved() != b.Reserved() {
+		t.Errorf("reserved max differs: %d %d", a.Reserved(), b.Reserved())
+	}
+
+	// file ids seen on the volume servers are skipped
+	b.SetMax(100000)
+	if start, _ := b.NextFileId(1); start != 100001 {
+		t.Errorf("expect file id after the max seen, got %d", start)
+	}
+}
+
+func TestBatchSequencerStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sequence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "sequence")
+
+	m, err := NewBatchSequencer(10, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetReserver(cluster{m}.reserve)
+	m.NextFileId(15)
+	last, _ := m.NextFileId(1)
+
+	// restarted with a cleared raft log
+	m, err = NewBatchSequencer(10, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetReserver(cluster{m}.reserve)
+	if start, _ := m.NextFileId(1); start <= last {
+		t.Errorf("file id %d is issued again after restart, last %d", start, last)
+	}
+
+	m.SetReserver(func(min, count uint64) (uint64, error) {
+		return 0, errors.New("not leader")
+	})
+	m.SetMax(1000)
+	if _, count := m.NextFileId(1); count != 0 {
+		t.Errorf("file ids should not be issued without a reservation")
+	}
+}
+
+func TestSnowflakeSequencer(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	m := NewSnowflakeSequencer(5)
+	m.now = func() time.Time { return now }
+
+	first, count := m.NextFileId(4000)
+	if count != 4000 || (first>>snowflakeSeqBits)&SnowflakeMaxNode != 5 {
+		t.Fatalf("unexpected file id %x count %d", first, count)
+	}
+	// not enough sequence numbers left in the millisecond
+	second, count := m.NextFileId(100)
+	if count != 100 || second <= first+4000 {
+		t.Errorf("expect the next millisecond, got %x after %x", second, first)
+	}
+	if _, count = m.NextFileId(10000); count != snowflakeMaxSeq {
+		t.Errorf("expect at most %d file ids, got %d", snowflakeMaxSeq, count)
+	}
+	// the clock goes back
+	now = now.Add(-time.Second)
+	if third, _ := m.NextFileId(1); third <= second {
+		t.Errorf("file ids should keep growing, got %x after %x", third, second)
+	}
+}
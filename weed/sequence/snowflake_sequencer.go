@@ -0,0 +1,66 @@
+package sequence
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	snowflakeEpoch    = 1420070400000 // 2015-01-01 in unix milliseconds
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	SnowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1 << snowflakeSeqBits
+)
+
+// SnowflakeSequencer issues the file ids from the time in milliseconds, the node id
+// and a sequence number in the millisecond, so it needs no coordination between
+// the masters, as long as they have different node ids.
+type SnowflakeSequencer struct {
+	node         uint64
+	lastMs       uint64 // never goes back even if the clock does
+	seq          uint64
+	now          func() time.Time
+	sequenceLock sync.Mutex
+}
+
+func NewSnowflakeSequencer(node int) *SnowflakeSequencer {
+	return &SnowflakeSequencer{node: uint64(node) & SnowflakeMaxNode, now: time.Now}
+}
+
+// NextFileId returns at most 4096 file ids at a time, which share the millisecond
+func (m *SnowflakeSequencer) NextFileId(count uint64) (uint64, uint64) {
+	m.sequenceLock.Lock()
+	defer m.sequenceLock.Unlock()
+	if count > snowflakeMaxSeq {
+		count = snowflakeMaxSeq
+	}
+	if ms := m.millis(); ms > m.lastMs {
+		m.lastMs, m.seq = ms, 0
+	}
+	if m.seq+count > snowflakeMaxSeq {
+		// borrow the next millisecond, the clock catches up soon
+		m.lastMs, m.seq = m.lastMs+1, 0
+	}
+	ret := m.lastMs<<(snowflakeNodeBits+snowflakeSeqBits) | m.node<<snowflakeSeqBits | m.seq
+	m.seq += count
+	return ret, count
+}
+
+func (m *SnowflakeSequencer) millis() uint64 {
+	ms := m.now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+	if ms < 0 {
+		return 0
+	}
+	return uint64(ms)
+}
+
+// SetMax is not needed, the file ids always grow with the time
+func (m *SnowflakeSequencer) SetMax(seenValue uint64) {
+}
+
+func (m *SnowflakeSequencer) Peek() uint64 {
+	m.sequenceLock.Lock()
+	defer m.sequenceLock.Unlock()
+	return m.lastMs<<(snowflakeNodeBits+snowflakeSeqBits) | m.node<<snowflakeSeqBits | m.seq
+}
@@ -0,0 +1,221 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector writes metrics in the Prometheus text exposition format.
+type Collector interface {
+	Collect(w io.Writer)
+}
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	HttpRequests = NewCounterVec("seaweedfs_http_requests_total",
+		"Number of http requests.", "server", "handler", "method", "code")
+	HttpRequestDuration = NewHistogramVec("seaweedfs_http_request_duration_seconds",
+		"Latency of http requests.", DefaultBuckets, "server", "handler")
+	HttpRequestBytes = NewCounterVec("seaweedfs_http_request_bytes_total",
+		"Bytes received in http request bodies.", "server", "handler")
+	HttpResponseBytes = NewCounterVec("seaweedfs_http_response_bytes_total",
+		"Bytes sent in http response bodies.", "server", "handler")
+)
+
+// HttpCollectors are the request metrics shared by all servers in the process,
+// the server label tells them apart when running "weed server".
+var HttpCollectors = []Collector{HttpRequests, HttpRequestDuration, HttpRequestBytes, HttpResponseBytes}
+
+func WriteMetrics(w io.Writer, collectors ...Collector) {
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+}
+
+type metricVec struct {
+	name       string
+	help       string
+	labelNames []string
+	mutex      sync.Mutex
+}
+
+func (m *metricVec) key(labelValues []string) string {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d labels, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeSample(w io.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, v float64) {
+	io.WriteString(w, name)
+	if len(labelNames) > 0 || extraName != "" {
+		io.WriteString(w, "{")
+		for i, n := range labelNames {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", n, escapeLabelValue(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		io.WriteString(w, "}")
+	}
+	fmt.Fprintf(w, " %s\n", formatFloat(v))
+}
+
+func escapeLabelValue(s string) string {
+	if !strings.ContainsAny(s, "\\\"\n") {
+		return s
+	}
+	s = strings.Replace(s, "\\", `\\`, -1)
+	s = strings.Replace(s, "\"", `\"`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type CounterVec struct {
+	metricVec
+	values map[string]float64
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{
+		metricVec: metricVec{name: name, help: help, labelNames: labelNames},
+		values:    make(map[string]float64),
+	}
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	k := c.key(labelValues)
+	c.mutex.Lock()
+	c.values[k] += v
+	c.mutex.Unlock()
+}
+
+func (c *CounterVec) Get(labelValues ...string) float64 {
+	k := c.key(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[k]
+}
+
+func (c *CounterVec) Collect(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	for _, k := range sortedKeys(c.values) {
+		writeSample(w, c.name, c.labelNames, splitKey(k), "", "", c.values[k])
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	metricVec
+	buckets []float64
+	values  map[string]*histogram
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{
+		metricVec: metricVec{name: name, help: help, labelNames: labelNames},
+		buckets:   buckets,
+		values:    make(map[string]*histogram),
+	}
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	k := h.key(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hv
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *HistogramVec) Collect(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		hv, labelValues := h.values[k], splitKey(k)
+		for i, upper := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labelNames, labelValues, "le", formatFloat(upper), float64(hv.counts[i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labelNames, labelValues, "le", "+Inf", float64(hv.count))
+		writeSample(w, h.name+"_sum", h.labelNames, labelValues, "", "", hv.sum)
+		writeSample(w, h.name+"_count", h.labelNames, labelValues, "", "", float64(hv.count))
+	}
+}
+
+// GaugeFunc reads the gauge values when collected, fn calls set once for each sample.
+type GaugeFunc struct {
+	metricVec
+	fn func(set func(v float64, labelValues ...string))
+}
+
+func NewGaugeFunc(name, help string, labelNames []string, fn func(set func(v float64, labelValues ...string))) *GaugeFunc {
+	return &GaugeFunc{
+		metricVec: metricVec{name: name, help: help, labelNames: labelNames},
+		fn:        fn,
+	}
+}
+
+func (g *GaugeFunc) Collect(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	g.fn(func(v float64, labelValues ...string) {
+		g.key(labelValues)
+		writeSample(w, g.name, g.labelNames, labelValues, "", "", v)
+	})
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func splitKey(k string) []string {
+	return strings.Split(k, "\xff")
+}
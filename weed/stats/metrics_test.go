@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Number of requests.", "handler", "code")
+	requests.Add(1, "/dir/assign", "200")
+	requests.Add(2, "/dir/assign", "200")
+	requests.Add(1, `/a"b`, "404")
+	latency := NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "handler")
+	latency.Observe(0.05, "/")
+	latency.Observe(0.5, "/")
+	latency.Observe(5, "/")
+	leader := NewGaugeFunc("test_is_leader", "Leader.", nil, func(set func(float64, ...string)) {
+		set(1)
+	})
+
+	out := &bytes.Buffer{}
+	WriteMetrics(out, requests, latency, leader)
+	expected := `# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{handler="/a\"b",code="404"} 1
+test_requests_total{handler="/dir/assign",code="200"} 3
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{handler="/",le="0.1"} 1
+test_latency_seconds_bucket{handler="/",le="1"} 2
+test_latency_seconds_bucket{handler="/",le="+Inf"} 3
+test_latency_seconds_sum{handler="/"} 5.55
+test_latency_seconds_count{handler="/"} 3
+# HELP test_is_leader Leader.
+# TYPE test_is_leader gauge
+test_is_leader 1
+`
+	if out.String() != expected {
+		t.Errorf("unexpected metrics:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+	if requests.Get("/dir/assign", "200") != 3 {
+		t.Errorf("counter value is %v", requests.Get("/dir/assign", "200"))
+	}
+}
+
+func TestMetricsLabelCount(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "expects 2 labels") {
+			t.Errorf("expect panic on wrong label count, got %v", r)
+		}
+	}()
+	NewCounterVec("test_total", "Test.", "a", "b").Add(1, "a")
+}
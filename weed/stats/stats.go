@@ -58,8 +58,8 @@ func DeleteRequest() {
 	ServStats.DeleteRequests.Add(NewTimedValue(time.Now(), 1))
 }
 func BytesIn(val int64) {
-	ServStats.BytesIn.Add(NewTimedValue(time.Now(), 1))
+	ServStats.BytesIn.Add(NewTimedValue(time.Now(), val))
 }
 func BytesOut(val int64) {
-	ServStats.BytesOut.Add(NewTimedValue(time.Now(), 1))
+	ServStats.BytesOut.Add(NewTimedValue(time.Now(), val))
 }
@@ -4,10 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"sync"
 
+	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
@@ -18,12 +23,17 @@ var (
 	ErrInvalidRange = errors.New("Invalid range")
 )
 
+// DefaultChunkPrefetch is the number of chunks read in parallel by default
+const DefaultChunkPrefetch = 4
+
 // seekable chunked file reader
 type ChunkedFileReader struct {
 	Manifest   *operation.ChunkManifest
 	Master     string
 	Collection string
 	Store      *Store
+	Prefetch   int             // the number of chunks read in parallel, DefaultChunkPrefetch if 0
+	Secret     security.Secret // signs the tokens to read the chunks from the other volume servers
 	pos        int64
 	pr         *io.PipeReader
 	pw         *io.PipeWriter
@@ -37,7 +47,7 @@ func (cf *ChunkedFileReader) Seek(offset int64, whence int) (int64, error) {
 	case 1:
 		offset += cf.pos
 	case 2:
-		offset = cf.Manifest.Size - offset
+		offset += cf.Manifest.Size
 	}
 	if offset > cf.Manifest.Size {
 		err = ErrInvalidRange
@@ -49,89 +59,225 @@ func (cf *ChunkedFileReader) Seek(offset int64, whence int) (int64, error) {
 	return cf.pos, err
 }
 
-func (cf *ChunkedFileReader) readRemoteChunkNeedle(fid string, w io.Writer, offset int64) (written int64, e error) {
-	// stream data
-	fileUrl, lookupError := operation.LookupFileId(cf.Master, fid, cf.Collection, true)
-	if lookupError != nil {
-		return 0, lookupError
+// chunkPart is the part of a chunk overlapping the range read
+type chunkPart struct {
+	fid        string
+	isManifest bool
+	offset     int64 // in the chunk
+	size       int64
+	data       []byte
+	manifest   *operation.ChunkManifest // the sub-manifest loaded for the part
+	err        error
+	done       chan struct{}
+}
+
+// WriteRange writes the length bytes of the file at the offset, reading only the chunks
+// overlapping them. The next chunks are read ahead in parallel, up to Prefetch at a time.
+// Only the sub-manifests overlapping the range are loaded, and read the same way.
+func (cf *ChunkedFileReader) WriteRange(w io.Writer, offset, length int64) (n int64, err error) {
+	if offset < 0 || length < 0 || offset+length > cf.Manifest.Size {
+		return 0, ErrInvalidRange
+	}
+	var parts []*chunkPart
+	covered, end := offset, offset+length
+	for _, ci := range cf.Manifest.Chunks {
+		start, stop := ci.Offset, ci.Offset+ci.Size
+		if start < covered {
+			start = covered
+		}
+		if stop > end {
+			stop = end
+		}
+		if start >= stop {
+			continue
+		}
+		if start != covered {
+			return 0, fmt.Errorf("no chunk at offset %d of %s", covered, cf.Manifest.Name)
+		}
+		parts = append(parts, &chunkPart{fid: ci.Fid, isManifest: ci.IsManifest,
+			offset: start - ci.Offset, size: stop - start, done: make(chan struct{})})
+		covered = stop
+	}
+	if covered != end {
+		return 0, fmt.Errorf("no chunk at offset %d of %s", covered, cf.Manifest.Name)
+	}
+
+	prefetch := cf.Prefetch
+	if prefetch <= 0 {
+		prefetch = DefaultChunkPrefetch
+	}
+	fetch := func(p *chunkPart) {
+		go func() {
+			if p.isManifest {
+				p.manifest, p.err = cf.readManifest(p.fid)
+			} else {
+				p.data, p.err = cf.readChunk(p.fid, p.offset, p.size)
+			}
+			close(p.done)
+		}()
+	}
+	for i := 0; i < len(parts) && i < prefetch; i++ {
+		fetch(parts[i])
+	}
+	for i, p := range parts {
+		<-p.done
+		if p.err != nil {
+			return n, p.err
+		}
+		if i+prefetch < len(parts) {
+			fetch(parts[i+prefetch])
+		}
+		var wn int64
+		var e error
+		if p.manifest != nil {
+			sub := &ChunkedFileReader{
+				Manifest:   p.manifest,
+				Master:     cf.Master,
+				Collection: cf.Collection,
+				Store:      cf.Store,
+				Prefetch:   cf.Prefetch,
+				Secret:     cf.Secret,
+			}
+			wn, e = sub.WriteRange(w, p.offset, p.size)
+		} else {
+			var written int
+			written, e = w.Write(p.data)
+			wn = int64(written)
+		}
+		n += wn
+		p.data, p.manifest = nil, nil
+		if e != nil {
+			return n, e
+		}
+	}
+	return n, nil
+}
+
+// readChunk reads the part of the chunk from the local volume if any, or else from the replicas
+// of the volume, trying the next one if a replica fails
+func (cf *ChunkedFileReader) readChunk(fileId string, offset, size int64) ([]byte, error) {
+	fid, err := ParseFileId(fileId)
+	if err != nil {
+		return nil, err
+	}
+	if n := cf.readLocalNeedle(fid); n != nil && int64(len(n.Data)) >= offset+size {
+		return n.Data[offset : offset+size], nil
+	}
+	return cf.readReplicas(fid, func(server string) ([]byte, error) {
+		return readRemoteChunk(util.MkUrl(server, "/"+fileId, nil), offset, size, security.GenReadJwt(cf.Secret, fileId))
+	})
+}
+
+// readManifest loads the sub-manifest of the chunk, the same way as readChunk
+func (cf *ChunkedFileReader) readManifest(fileId string) (*operation.ChunkManifest, error) {
+	fid, err := ParseFileId(fileId)
+	if err != nil {
+		return nil, err
+	}
+	if n := cf.readLocalNeedle(fid); n != nil && n.IsChunkedManifest() {
+		return operation.LoadChunkManifest(n.Data, n.IsGzipped())
+	}
+	data, err := cf.readReplicas(fid, func(server string) ([]byte, error) {
+		// the manifest itself instead of the chunks it lists
+		return readRemoteManifest(util.MkUrl(server, "/"+fileId, url.Values{"cm": {"false"}}), security.GenReadJwt(cf.Secret, fileId))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return operation.LoadChunkManifest(data, false)
+}
+
+func (cf *ChunkedFileReader) readLocalNeedle(fid *FileId) *Needle {
+	if cf.Store == nil || !cf.Store.HasVolume(fid.VolumeId) {
+		return nil
+	}
+	n, err := cf.Store.ReadLocalNeedle(fid)
+	if err != nil {
+		glog.V(0).Infof("read local chunk %s: %v", fid, err)
+		return nil
+	}
+	return n
+}
+
+func (cf *ChunkedFileReader) readReplicas(fid *FileId, read func(server string) ([]byte, error)) ([]byte, error) {
+	lookup, err := operation.Lookup(cf.Master, fid.VolumeId.String(), cf.Collection)
+	if err != nil {
+		return nil, err
 	}
+	if len(lookup.Locations) == 0 {
+		return nil, fmt.Errorf("chunk %s is not found", fid)
+	}
+	first := rand.Intn(len(lookup.Locations))
+	for i := range lookup.Locations {
+		location := lookup.Locations[(first+i)%len(lookup.Locations)]
+		var data []byte
+		if data, err = read(location.Url); err == nil {
+			return data, nil
+		}
+		glog.V(0).Infof("read chunk %s from %s: %v", fid, location.Url, err)
+	}
+	return nil, err
+}
 
+func readRemoteManifest(fileUrl string, jwt security.EncodedJwt) ([]byte, error) {
 	req, err := http.NewRequest("GET", fileUrl, nil)
 	if err != nil {
-		return written, err
+		return nil, err
+	}
+	if jwt != "" {
+		req.Header.Set("Authorization", "BEARER "+string(jwt))
+	}
+	resp, err := util.HttpDo(req)
+	if err != nil {
+		return nil, err
 	}
-	if offset > 0 {
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Read chunk manifest error: [%d] %s", resp.StatusCode, fileUrl)
 	}
+	return ioutil.ReadAll(resp.Body)
+}
 
+func readRemoteChunk(fileUrl string, offset, size int64, jwt security.EncodedJwt) ([]byte, error) {
+	req, err := http.NewRequest("GET", fileUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+	if jwt != "" {
+		req.Header.Set("Authorization", "BEARER "+string(jwt))
+	}
 	resp, err := util.HttpDo(req)
 	if err != nil {
-		return written, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	case http.StatusRequestedRangeNotSatisfiable:
-		return written, ErrInvalidRange
+		return nil, ErrInvalidRange
 	case http.StatusOK:
-		if offset > 0 {
-			return written, ErrRangeRequestsNotSupported
+		// the range is ignored, skip to the offset
+		if _, err = io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+			return nil, err
 		}
 	case http.StatusPartialContent:
 		break
 	default:
-		return written, fmt.Errorf("Read chunk needle error: [%d] %s", resp.StatusCode, fileUrl)
-
+		return nil, fmt.Errorf("Read chunk needle error: [%d] %s", resp.StatusCode, fileUrl)
 	}
-	return io.Copy(w, resp.Body)
-}
-
-func (cf *ChunkedFileReader) readLocalChunkNeedle(fid *FileId, w io.Writer, offset int64) (written int64, e error) {
-	n, e := cf.Store.ReadLocalNeedle(fid)
-	if e != nil {
-		return 0, e
+	data := make([]byte, size)
+	if _, err = io.ReadFull(resp.Body, data); err != nil {
+		return nil, err
 	}
-	wn, e := w.Write(n.Data[offset:])
-	return int64(wn), e
+	return data, nil
 }
 
+// WriteTo writes the file from the current position to the end
 func (cf *ChunkedFileReader) WriteTo(w io.Writer) (n int64, err error) {
-	cm := cf.Manifest
-	chunkIndex := -1
-	chunkStartOffset := int64(0)
-	for i, ci := range cm.Chunks {
-		if cf.pos >= ci.Offset && cf.pos < ci.Offset+ci.Size {
-			chunkIndex = i
-			chunkStartOffset = cf.pos - ci.Offset
-			break
-		}
-	}
-	if chunkIndex < 0 {
-		return n, ErrInvalidRange
-	}
-	for ; chunkIndex < cm.Chunks.Len(); chunkIndex++ {
-		ci := cm.Chunks[chunkIndex]
-		fid, e := ParseFileId(ci.Fid)
-		if e != nil {
-			return n, e
-		}
-		var wn int64
-		if cf.Store != nil && cf.Store.HasVolume(fid.VolumeId) {
-			wn, e = cf.readLocalChunkNeedle(fid, w, chunkStartOffset)
-		} else {
-			wn, e = cf.readRemoteChunkNeedle(ci.Fid, w, chunkStartOffset)
-		}
-
-		if e != nil {
-			return n, e
-		} else {
-			n += wn
-			cf.pos += wn
-		}
-
-		chunkStartOffset = 0
-	}
-	return n, nil
+	n, err = cf.WriteRange(w, cf.pos, cf.Manifest.Size-cf.pos)
+	cf.pos += n
+	return n, err
 }
 
 func (cf *ChunkedFileReader) ReadAt(p []byte, off int64) (n int, err error) {
@@ -140,7 +286,9 @@ func (cf *ChunkedFileReader) ReadAt(p []byte, off int64) (n int, err error) {
 }
 
 func (cf *ChunkedFileReader) Read(p []byte) (int, error) {
-	return cf.getPipeReader().Read(p)
+	n, err := cf.getPipeReader().Read(p)
+	cf.pos += int64(n)
+	return n, err
 }
 
 func (cf *ChunkedFileReader) Close() (e error) {
@@ -173,9 +321,9 @@ func (cf *ChunkedFileReader) getPipeReader() io.Reader {
 	}
 	cf.closePipe()
 	cf.pr, cf.pw = io.Pipe()
-	go func(pw *io.PipeWriter) {
-		_, e := cf.WriteTo(pw)
+	go func(pw *io.PipeWriter, offset int64) {
+		_, e := cf.WriteRange(pw, offset, cf.Manifest.Size-offset)
 		pw.CloseWithError(e)
-	}(cf.pw)
+	}(cf.pw, cf.pos)
 	return cf.pr
 }
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+)
+
+func TestChunkedFileReader(t *testing.T) {
+	content := make([]byte, 4500)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	cm := &operation.ChunkManifest{Name: "big.bin", Size: int64(len(content))}
+	chunks := make(map[string][]byte)
+	for i := 0; i*1000 < len(content); i++ {
+		fid := fmt.Sprintf("9,%02x637037d6", i+1)
+		end := (i + 1) * 1000
+		if end > len(content) {
+			end = len(content)
+		}
+		chunks[fid] = content[i*1000 : end]
+		cm.Chunks = append(cm.Chunks, &operation.ChunkInfo{Fid: fid, Offset: int64(i * 1000), Size: int64(end - i*1000)})
+	}
+
+	// a failing replica, and a replica which is also the master
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	var lock sync.Mutex
+	reads := make(map[string]int)
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dir/lookup" {
+			fmt.Fprintf(w, `{"volumeId":"9","locations":[{"url":"%s"},{"url":"%s"}]}`,
+				strings.TrimPrefix(failing.URL, "http://"), r.Host)
+			return
+		}
+		fid := r.URL.Path[1:]
+		lock.Lock()
+		reads[fid]++
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(chunks[fid]))
+		lock.Lock()
+		inFlight--
+		lock.Unlock()
+	}))
+	defer server.Close()
+	cf := &ChunkedFileReader{
+		Manifest: cm,
+		Master:   strings.TrimPrefix(server.URL, "http://"),
+		Prefetch: 2,
+	}
+	defer cf.Close()
+
+	var buf bytes.Buffer
+	if _, err := cf.WriteRange(&buf, 1500, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), content[1500:2500]) {
+		t.Errorf("unexpected range content")
+	}
+	if len(reads) != 2 || reads["9,02637037d6"] != 1 || reads["9,03637037d6"] != 1 {
+		t.Errorf("the range should read the 2nd and the 3rd chunks only: %v", reads)
+	}
+
+	buf.Reset()
+	if n, err := cf.WriteTo(&buf); err != nil || n != int64(len(content)) {
+		t.Fatalf("write %d bytes: %v", n, err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("unexpected content")
+	}
+	if maxInFlight > cf.Prefetch {
+		t.Errorf("%d chunks are read in parallel, instead of at most %d", maxInFlight, cf.Prefetch)
+	}
+
+	if _, err := cf.Seek(2200, 0); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadAll(cf); err != nil || !bytes.Equal(data, content[2200:]) {
+		t.Errorf("read %d bytes from 2200: %v", len(data), err)
+	}
+	if _, err := cf.WriteRange(&buf, 4000, 1000); err != ErrInvalidRange {
+		t.Errorf("a range past the end should be invalid: %v", err)
+	}
+}
+
+func TestNestedChunkedFileReader(t *testing.T) {
+	content := make([]byte, 4500)
+	for i := range content {
+		content[i] = byte(i * 11)
+	}
+	stored := make(map[string][]byte)
+	chunk := func(key int, offset, size int64) *operation.ChunkInfo {
+		fid := fmt.Sprintf("10,%02x637037d6", key)
+		stored[fid] = content[offset : offset+size]
+		return &operation.ChunkInfo{Fid: fid, Offset: offset, Size: size}
+	}
+	subManifest := func(key int, offset int64, chunks ...*operation.ChunkInfo) *operation.ChunkInfo {
+		sub := &operation.ChunkManifest{}
+		for _, ci := range chunks {
+			sub.Chunks = append(sub.Chunks, &operation.ChunkInfo{Fid: ci.Fid, Offset: ci.Offset - offset, Size: ci.Size, IsManifest: ci.IsManifest})
+			sub.Size = ci.Offset + ci.Size - offset
+		}
+		fid := fmt.Sprintf("10,%02x637037d6", key)
+		stored[fid], _ = sub.Marshal()
+		return &operation.ChunkInfo{Fid: fid, Offset: offset, Size: sub.Size, IsManifest: true}
+	}
+	// two levels of sub-manifests
+	cm := &operation.ChunkManifest{Name: "nested.bin", Size: int64(len(content)), Chunks: []*operation.ChunkInfo{
+		subManifest(20, 0, chunk(1, 0, 1000), chunk(2, 1000, 1000)),
+		chunk(3, 2000, 1000),
+		subManifest(21, 3000, chunk(4, 3000, 1000), subManifest(22, 4000, chunk(5, 4000, 500))),
+	}}
+
+	var lock sync.Mutex
+	reads := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dir/lookup" {
+			fmt.Fprintf(w, `{"volumeId":"10","locations":[{"url":"%s"}]}`, r.Host)
+			return
+		}
+		fid := r.URL.Path[1:]
+		lock.Lock()
+		reads[fid]++
+		lock.Unlock()
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(stored[fid]))
+	}))
+	defer server.Close()
+	cf := &ChunkedFileReader{Manifest: cm, Master: strings.TrimPrefix(server.URL, "http://")}
+
+	var buf bytes.Buffer
+	if _, err := cf.WriteRange(&buf, 2500, 1600); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), content[2500:4100]) {
+		t.Errorf("unexpected range content")
+	}
+	if len(reads) != 5 || reads["10,20637037d6"] != 0 {
+		t.Errorf("the range should read the chunks and the sub-manifests it overlaps only: %v", reads)
+	}
+
+	buf.Reset()
+	if n, err := cf.WriteTo(&buf); err != nil || n != int64(len(content)) {
+		t.Fatalf("write %d bytes: %v", n, err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("unexpected content")
+	}
+}
@@ -1,16 +1,30 @@
 package storage
 
-import "github.com/chrislusf/seaweedfs/weed/weedpb"
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/weedpb"
+)
 
 type SettingKey int
 
 const (
 	keyReplicatePlacement SettingKey = iota
 	keyGarbageThreshold
+	keyTtl
+	keyMaxVolumeCount
+	keyTier
+	keyMaxBytes
+	keyMaxFileCount
+	keyDedup
+	keyTrashRetention
 )
 
 type CollectionSettings struct {
 	settings map[string]map[SettingKey]interface{}
+	mutex    sync.RWMutex
 }
 
 func NewCollectionSettings(defaultReplicatePlacement, defaultGarbageThreshold string) *CollectionSettings {
@@ -31,30 +45,183 @@ func NewCollectionSettingsFromPbMessage(msg []*weedpb.CollectionSetting) *Collec
 		settings: make(map[string]map[SettingKey]interface{}),
 	}
 	for _, m := range msg {
-		c.SetGarbageThreshold(m.Collection, m.VacuumGarbageThreshold)
-		c.SetReplicaPlacement(m.Collection, m.ReplicaPlacement)
+		c.Update(m)
 	}
 	return c
 }
 
 func (cs *CollectionSettings) ToPbMessage() []*weedpb.CollectionSetting {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
 	msg := make([]*weedpb.CollectionSetting, 0, len(cs.settings))
-	for collection, m := range cs.settings {
-		setting := &weedpb.CollectionSetting{
-			Collection: collection,
+	for collection := range cs.settings {
+		msg = append(msg, cs.toPbMessage(collection))
+	}
+	return msg
+}
+
+// PbMessage returns the settings of the collection itself, without the defaults
+func (cs *CollectionSettings) PbMessage(collection string) (*weedpb.CollectionSetting, bool) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	if _, ok := cs.settings[collection]; !ok {
+		return nil, false
+	}
+	return cs.toPbMessage(collection), true
+}
+
+func (cs *CollectionSettings) toPbMessage(collection string) *weedpb.CollectionSetting {
+	m := cs.settings[collection]
+	setting := &weedpb.CollectionSetting{
+		Collection: collection,
+	}
+	if v, ok := m[keyReplicatePlacement]; ok && v != nil {
+		setting.ReplicaPlacement = v.(*ReplicaPlacement).String()
+	}
+	if v, ok := m[keyGarbageThreshold]; ok && v != nil {
+		setting.VacuumGarbageThreshold = v.(string)
+	}
+	if v, ok := m[keyTtl]; ok && v != nil {
+		setting.Ttl = v.(*TTL).String()
+	}
+	if v, ok := m[keyMaxVolumeCount]; ok && v != nil {
+		setting.MaxVolumeCount = uint32(v.(int))
+	}
+	if v, ok := m[keyTier]; ok && v != nil {
+		setting.Tier = v.(string)
+	}
+	if v, ok := m[keyMaxBytes]; ok && v != nil {
+		setting.MaxBytes = v.(uint64)
+	}
+	if v, ok := m[keyMaxFileCount]; ok && v != nil {
+		setting.MaxFileCount = v.(uint64)
+	}
+	if v, ok := m[keyDedup]; ok && v != nil {
+		setting.Dedup = v.(bool)
+	}
+	if v, ok := m[keyTrashRetention]; ok && v != nil {
+		setting.TrashRetention = v.(*TTL).String()
+	}
+	return setting
+}
+
+// Update replaces the settings of the collection with the message,
+// the empty fields fall back to the defaults.
+func (cs *CollectionSettings) Update(m *weedpb.CollectionSetting) error {
+	rp, ttl, err := parseCollectionSetting(m)
+	if err != nil {
+		return err
+	}
+	trashRetention, err := parseTrashRetention(m.TrashRetention)
+	if err != nil {
+		return err
+	}
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if m.Collection != "" {
+		delete(cs.settings, m.Collection)
+	}
+	if rp != nil {
+		cs.set(m.Collection, keyReplicatePlacement, rp)
+	}
+	if m.VacuumGarbageThreshold != "" {
+		cs.set(m.Collection, keyGarbageThreshold, m.VacuumGarbageThreshold)
+	}
+	if ttl != nil {
+		cs.set(m.Collection, keyTtl, ttl)
+	}
+	if m.MaxVolumeCount > 0 {
+		cs.set(m.Collection, keyMaxVolumeCount, int(m.MaxVolumeCount))
+	}
+	if m.Tier != "" {
+		cs.set(m.Collection, keyTier, m.Tier)
+	}
+	if m.MaxBytes > 0 {
+		cs.set(m.Collection, keyMaxBytes, m.MaxBytes)
+	}
+	if m.MaxFileCount > 0 {
+		cs.set(m.Collection, keyMaxFileCount, m.MaxFileCount)
+	}
+	if m.Dedup {
+		cs.set(m.Collection, keyDedup, true)
+	}
+	if trashRetention != nil {
+		cs.set(m.Collection, keyTrashRetention, trashRetention)
+	}
+	if cs.settings[m.Collection] == nil {
+		// keep the collection listed even if it has no own settings
+		cs.settings[m.Collection] = make(map[SettingKey]interface{})
+	}
+	return nil
+}
+
+// ValidateCollectionSetting checks the fields of the message before it is applied
+func ValidateCollectionSetting(m *weedpb.CollectionSetting) error {
+	_, _, err := parseCollectionSetting(m)
+	if err == nil {
+		_, err = parseTrashRetention(m.TrashRetention)
+	}
+	return err
+}
+
+// parseTrashRetention reads the retention like a ttl, e.g. 12h or 7d
+func parseTrashRetention(s string) (*TTL, error) {
+	if s == "" {
+		return nil, nil
+	}
+	retention, err := ReadTTL(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trash retention %s: %v", s, err)
+	}
+	if retention.String() == "" {
+		return nil, nil
+	}
+	return retention, nil
+}
+
+func parseCollectionSetting(m *weedpb.CollectionSetting) (rp *ReplicaPlacement, ttl *TTL, err error) {
+	if m.ReplicaPlacement != "" {
+		if rp, err = NewReplicaPlacementFromString(m.ReplicaPlacement); err != nil {
+			return
 		}
-		if v, ok := m[keyReplicatePlacement]; ok && v != nil {
-			setting.ReplicaPlacement = v.(*ReplicaPlacement).String()
+	}
+	if m.Ttl != "" {
+		if ttl, err = ReadTTL(m.Ttl); err != nil {
+			return
 		}
-		if v, ok := m[keyGarbageThreshold]; ok && v != nil {
-			setting.VacuumGarbageThreshold = v.(string)
+		if ttl.String() == "" {
+			ttl = nil
 		}
-		msg = append(msg, setting)
 	}
-	return msg
+	return
+}
+
+// Delete removes the settings of the collection, the defaults can not be deleted
+func (cs *CollectionSettings) Delete(collection string) {
+	if collection == "" {
+		return
+	}
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	delete(cs.settings, collection)
+}
+
+// Collections lists the collections with settings, excluding the defaults
+func (cs *CollectionSettings) Collections() (collections []string) {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
+	for collection := range cs.settings {
+		if collection != "" {
+			collections = append(collections, collection)
+		}
+	}
+	sort.Strings(collections)
+	return
 }
 
 func (cs *CollectionSettings) get(collection string, key SettingKey) interface{} {
+	cs.mutex.RLock()
+	defer cs.mutex.RUnlock()
 	if m, ok := cs.settings[collection]; ok {
 		if v, ok := m[key]; ok {
 			return v
@@ -66,6 +233,12 @@ func (cs *CollectionSettings) get(collection string, key SettingKey) interface{}
 	return nil
 }
 
+func (cs *CollectionSettings) lockedSet(collection string, key SettingKey, value interface{}) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.set(collection, key, value)
+}
+
 func (cs *CollectionSettings) set(collection string, key SettingKey, value interface{}) {
 	m := cs.settings[collection]
 	if m == nil {
@@ -88,9 +261,9 @@ func (cs *CollectionSettings) GetGarbageThreshold(collection string) string {
 
 func (cs *CollectionSettings) SetGarbageThreshold(collection string, gt string) {
 	if gt == "" {
-		cs.set(collection, keyGarbageThreshold, nil)
+		cs.lockedSet(collection, keyGarbageThreshold, nil)
 	} else {
-		cs.set(collection, keyGarbageThreshold, gt)
+		cs.lockedSet(collection, keyGarbageThreshold, gt)
 
 	}
 }
@@ -106,12 +279,76 @@ func (cs *CollectionSettings) GetReplicaPlacement(collection string) *ReplicaPla
 
 func (cs *CollectionSettings) SetReplicaPlacement(collection, t string) error {
 	if t == "" {
-		cs.set(collection, keyReplicatePlacement, nil)
+		cs.lockedSet(collection, keyReplicatePlacement, nil)
 		return nil
 	}
 	rp, e := NewReplicaPlacementFromString(t)
 	if e == nil {
-		cs.set(collection, keyReplicatePlacement, rp)
+		cs.lockedSet(collection, keyReplicatePlacement, rp)
 	}
 	return e
 }
+
+// GetTtl returns the default ttl of the new volumes in the collection
+func (cs *CollectionSettings) GetTtl(collection string) *TTL {
+	v := cs.get(collection, keyTtl)
+	if v == nil {
+		return nil
+	}
+	return v.(*TTL)
+}
+
+// GetMaxVolumeCount returns 0 if the volumes in the collection are not limited
+func (cs *CollectionSettings) GetMaxVolumeCount(collection string) int {
+	v := cs.get(collection, keyMaxVolumeCount)
+	if v == nil {
+		return 0
+	}
+	return v.(int)
+}
+
+// GetTier returns the disk tier of the new volumes in the collection
+func (cs *CollectionSettings) GetTier(collection string) string {
+	v := cs.get(collection, keyTier)
+	if v == nil {
+		return ""
+	}
+	return v.(string)
+}
+
+// GetMaxBytes returns 0 if the bytes in the collection are not limited
+func (cs *CollectionSettings) GetMaxBytes(collection string) uint64 {
+	v := cs.get(collection, keyMaxBytes)
+	if v == nil {
+		return 0
+	}
+	return v.(uint64)
+}
+
+// GetMaxFileCount returns 0 if the files in the collection are not limited
+func (cs *CollectionSettings) GetMaxFileCount(collection string) uint64 {
+	v := cs.get(collection, keyMaxFileCount)
+	if v == nil {
+		return 0
+	}
+	return v.(uint64)
+}
+
+// GetDedup returns true if the same content in the collection is stored only once
+func (cs *CollectionSettings) GetDedup(collection string) bool {
+	v := cs.get(collection, keyDedup)
+	if v == nil {
+		return false
+	}
+	return v.(bool)
+}
+
+// GetTrashRetention returns how long the deleted files in the collection
+// are kept recoverable, nil if they are removed by the next vacuum
+func (cs *CollectionSettings) GetTrashRetention(collection string) *TTL {
+	v := cs.get(collection, keyTrashRetention)
+	if v == nil {
+		return nil
+	}
+	return v.(*TTL)
+}
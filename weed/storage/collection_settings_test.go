@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/weedpb"
 )
 
 func TestCollectionSettings(t *testing.T) {
@@ -30,3 +32,31 @@ func TestCollectionSettings(t *testing.T) {
 		t.Fatal("PbMessage convert incorrect.")
 	}
 }
+
+func TestCollectionSettingsUpdate(t *testing.T) {
+	cs := NewCollectionSettings("000", "0.3")
+	err := cs.Update(&weedpb.CollectionSetting{Collection: "logs", Ttl: "7d", MaxVolumeCount: 5, Tier: "cold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.GetTtl("logs").String() != "7d" || cs.GetMaxVolumeCount("logs") != 5 || cs.GetTier("logs") != "cold" ||
+		cs.GetReplicaPlacement("logs").String() != "000" || cs.GetTtl("") != nil || cs.GetMaxVolumeCount("") != 0 {
+		t.Fatal("Value incorrect.")
+	}
+	if err = cs.Update(&weedpb.CollectionSetting{Collection: "logs", ReplicaPlacement: "009"}); err == nil {
+		t.Errorf("expect invalid replica placement error")
+	}
+	if !reflect.DeepEqual(cs, NewCollectionSettingsFromPbMessage(cs.ToPbMessage())) {
+		t.Fatal("PbMessage convert incorrect.")
+	}
+	// the settings are replaced, not merged
+	cs.Update(&weedpb.CollectionSetting{Collection: "logs", Tier: "hot"})
+	if cs.GetTtl("logs") != nil || cs.GetTier("logs") != "hot" {
+		t.Errorf("expect only the tier is set, got %v", cs.ToPbMessage())
+	}
+	cs.Delete("logs")
+	cs.Delete("")
+	if len(cs.Collections()) != 0 || cs.GetTier("logs") != "" || cs.GetReplicaPlacement("logs") == nil {
+		t.Errorf("expect the defaults after delete, got %v", cs.ToPbMessage())
+	}
+}
@@ -12,7 +12,10 @@ import (
 type DiskLocation struct {
 	Directory      string
 	MaxVolumeCount int
+	Tier           string
 	volumes        map[VolumeId]*Volume
+	ecVolumes      map[VolumeId]*EcVolume
+	keys           KeyProvider
 	mutex          sync.RWMutex
 }
 
@@ -21,20 +24,24 @@ func NewDiskLocation(dir string, maxVolCount int) *DiskLocation {
 		Directory:      dir,
 		MaxVolumeCount: maxVolCount,
 		volumes:        make(map[VolumeId]*Volume),
+		ecVolumes:      make(map[VolumeId]*EcVolume),
 	}
 }
 
+// ParseDiskTier splits the tier from a directory tagged like /ssd:hot
+func ParseDiskTier(dirname string) (dir, tier string) {
+	if i := strings.LastIndex(dirname, ":"); i > 0 && !strings.ContainsAny(dirname[i+1:], "/\\") {
+		return dirname[:i], dirname[i+1:]
+	}
+	return dirname, ""
+}
+
 func (l *DiskLocation) LoadExistingVolumes(needleMapKind NeedleMapType) {
 	if dirs, err := ioutil.ReadDir(l.Directory); err == nil {
 		for _, dir := range dirs {
 			name := dir.Name()
 			if !dir.IsDir() && strings.HasSuffix(name, ".dat") {
-				collection := ""
-				base := name[:len(name)-len(".dat")]
-				i := strings.LastIndex(base, "_")
-				if i > 0 {
-					collection, base = base[0:i], base[i+1:]
-				}
+				collection, base := parseCollectionVolumeId(name[:len(name)-len(".dat")])
 				if vid, err := NewVolumeId(base); err == nil {
 					if !l.HasVolume(vid) {
 						if v, e := NewVolume(l.Directory, collection, vid, needleMapKind, nil); e == nil {
@@ -46,21 +53,55 @@ func (l *DiskLocation) LoadExistingVolumes(needleMapKind NeedleMapType) {
 					}
 				}
 			}
+			if !dir.IsDir() && strings.HasSuffix(name, ".ecx") {
+				collection, base := parseCollectionVolumeId(name[:len(name)-len(".ecx")])
+				if vid, err := NewVolumeId(base); err == nil && !l.HasEcVolume(vid) {
+					if ev, e := NewEcVolume(l.Directory, collection, vid); e == nil {
+						l.AddEcVolume(vid, ev)
+						glog.V(1).Infof("ec volume %s, shards=%v", l.Directory+"/"+name, ev.ShardBits().ShardIds())
+					} else {
+						glog.V(0).Infof("new ec volume %s error %s", name, e)
+					}
+				}
+			}
 		}
 	}
-	glog.V(0).Infoln("Store started on dir:", l.Directory, "with", l.VolumeCount(), "volumes", "max", l.MaxVolumeCount)
+	glog.V(0).Infoln("Store started on dir:", l.Directory, "with", l.VolumeCount(), "volumes", l.EcVolumeCount(), "ec volumes", "max", l.MaxVolumeCount)
+}
+
+func parseCollectionVolumeId(base string) (collection string, vid string) {
+	i := strings.LastIndex(base, "_")
+	if i > 0 {
+		return base[0:i], base[i+1:]
+	}
+	return "", base
 }
 
 func (l *DiskLocation) AddVolume(vid VolumeId, v *Volume) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
+	v.keys = l.keys
 	l.volumes[vid] = v
 }
 
+// SetKeyProvider sets the encryption keys of the existing and new volumes
+func (l *DiskLocation) SetKeyProvider(keys KeyProvider) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.keys = keys
+	for _, v := range l.volumes {
+		v.keys = keys
+	}
+}
+
 func (l *DiskLocation) DeleteVolume(vid VolumeId) (e error) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 	if v, ok := l.volumes[vid]; ok {
+		// the volumes are sealed read only before they are moved or erasure coded
+		if e = v.SetReadOnly(false); e != nil {
+			return
+		}
 		e = v.Destroy()
 	}
 	delete(l.volumes, vid)
@@ -79,6 +120,14 @@ func (l *DiskLocation) DeleteCollection(collection string) (e error) {
 			delete(l.volumes, k)
 		}
 	}
+	for k, ev := range l.ecVolumes {
+		if ev.Collection == collection {
+			if e = ev.Destroy(); e != nil {
+				return
+			}
+			delete(l.ecVolumes, k)
+		}
+	}
 	return
 }
 
@@ -108,6 +157,9 @@ func (l *DiskLocation) CloseAllVolume() {
 	for _, v := range l.volumes {
 		v.Close()
 	}
+	for _, ev := range l.ecVolumes {
+		ev.Close()
+	}
 }
 
 // break walk when walker fuc return an error
@@ -124,3 +176,52 @@ func (l *DiskLocation) WalkVolume(vw VolumeWalker) (e error) {
 	}
 	return
 }
+
+func (l *DiskLocation) AddEcVolume(vid VolumeId, ev *EcVolume) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.ecVolumes[vid] = ev
+}
+
+func (l *DiskLocation) DeleteEcVolume(vid VolumeId) (e error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if ev, ok := l.ecVolumes[vid]; ok {
+		e = ev.Destroy()
+	}
+	delete(l.ecVolumes, vid)
+	return
+}
+
+func (l *DiskLocation) HasEcVolume(vid VolumeId) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	_, ok := l.ecVolumes[vid]
+	return ok
+}
+
+func (l *DiskLocation) GetEcVolume(vid VolumeId) (ev *EcVolume, ok bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	ev, ok = l.ecVolumes[vid]
+	return
+}
+
+func (l *DiskLocation) EcVolumeCount() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return len(l.ecVolumes)
+}
+
+type EcVolumeWalker func(ev *EcVolume) (e error)
+
+func (l *DiskLocation) WalkEcVolume(walker EcVolumeWalker) (e error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for _, ev := range l.ecVolumes {
+		if e = walker(ev); e != nil {
+			return e
+		}
+	}
+	return
+}
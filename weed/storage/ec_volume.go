@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/erasure"
+)
+
+/*
+An erasure coded volume splits the .dat file into rows of EcDataShards blocks,
+block i of a row goes to data shard i, and each row gets EcParityShards
+parity blocks. The shards are saved as <volume>.ec00 ~ <volume>.ec13,
+and the index file is kept as <volume>.ecx on every server holding a shard.
+*/
+const (
+	EcDataShards   = 10
+	EcParityShards = 4
+	EcTotalShards  = EcDataShards + EcParityShards
+	EcBlockSize    = 1024 * 1024
+	EcRowSize      = EcBlockSize * EcDataShards
+
+	EcShardLocationsRefreshInterval = time.Minute
+)
+
+var ErrEcShardMissing = errors.New("Erasure coding shard is missing")
+
+// each bit is a shard id
+type EcShardBits uint32
+
+func (b EcShardBits) Has(shardId int) bool {
+	return b&(1<<uint(shardId)) > 0
+}
+
+func (b EcShardBits) Add(shardId int) EcShardBits {
+	return b | 1<<uint(shardId)
+}
+
+func (b EcShardBits) Remove(shardId int) EcShardBits {
+	return b &^ (1 << uint(shardId))
+}
+
+func (b EcShardBits) ShardIds() (ids []int) {
+	for i := 0; i < EcTotalShards; i++ {
+		if b.Has(i) {
+			ids = append(ids, i)
+		}
+	}
+	return
+}
+
+func (b EcShardBits) Count() (count int) {
+	for i := 0; i < EcTotalShards; i++ {
+		if b.Has(i) {
+			count++
+		}
+	}
+	return
+}
+
+func EcShardExt(shardId int) string {
+	return fmt.Sprintf(".ec%02d", shardId)
+}
+
+func NewEcCodec() *erasure.ReedSolomon {
+	rs, err := erasure.NewReedSolomon(EcDataShards, EcParityShards)
+	if err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+// ecInterval is a continuous part of the .dat file inside one shard block
+type ecInterval struct {
+	ShardId     int
+	ShardOffset int64
+	Size        int64
+}
+
+func locateEcData(offset, size int64) (intervals []ecInterval) {
+	for size > 0 {
+		row, inRow := offset/EcRowSize, offset%EcRowSize
+		blockOffset := inRow % EcBlockSize
+		n := EcBlockSize - blockOffset
+		if n > size {
+			n = size
+		}
+		intervals = append(intervals, ecInterval{
+			ShardId:     int(inRow / EcBlockSize),
+			ShardOffset: row*EcBlockSize + blockOffset,
+			Size:        n,
+		})
+		offset += n
+		size -= n
+	}
+	return
+}
+
+type EcVolume struct {
+	Id         VolumeId
+	Collection string
+	dir        string
+	nm         *NeedleMap
+	version    Version
+	shards     map[int]*os.File
+
+	shardLocations        map[int][]string
+	shardLocationsRefresh time.Time
+
+	mutex sync.RWMutex
+}
+
+func NewEcVolume(dirname string, collection string, id VolumeId) (ev *EcVolume, e error) {
+	ev = &EcVolume{
+		Id:         id,
+		Collection: collection,
+		dir:        dirname,
+		shards:     make(map[int]*os.File),
+	}
+	var indexFile *os.File
+	if indexFile, e = os.OpenFile(ev.FileName()+".ecx", os.O_RDONLY, 0644); e != nil {
+		return nil, fmt.Errorf("cannot read ec index %s.ecx: %v", ev.FileName(), e)
+	}
+	if ev.nm, e = LoadNeedleMap(indexFile); e != nil {
+		indexFile.Close()
+		return nil, fmt.Errorf("cannot load ec index %s.ecx: %v", ev.FileName(), e)
+	}
+	for i := 0; i < EcTotalShards; i++ {
+		if f, err := os.OpenFile(ev.FileName()+EcShardExt(i), os.O_RDONLY, 0644); err == nil {
+			ev.shards[i] = f
+		}
+	}
+	return ev, nil
+}
+
+func (ev *EcVolume) String() string {
+	return fmt.Sprintf("Id:%v, dir:%s, Collection:%s, shards:%v", ev.Id, ev.dir, ev.Collection, ev.ShardBits().ShardIds())
+}
+
+func (ev *EcVolume) FileName() (fileName string) {
+	if ev.Collection == "" {
+		fileName = path.Join(ev.dir, ev.Id.String())
+	} else {
+		fileName = path.Join(ev.dir, ev.Collection+"_"+ev.Id.String())
+	}
+	return
+}
+
+func (ev *EcVolume) ShardBits() (b EcShardBits) {
+	ev.mutex.RLock()
+	defer ev.mutex.RUnlock()
+	for id := range ev.shards {
+		b = b.Add(id)
+	}
+	return
+}
+
+func (ev *EcVolume) HasShard(shardId int) bool {
+	ev.mutex.RLock()
+	defer ev.mutex.RUnlock()
+	_, ok := ev.shards[shardId]
+	return ok
+}
+
+// MountShard opens a shard file copied to the volume directory
+func (ev *EcVolume) MountShard(shardId int) error {
+	f, e := os.OpenFile(ev.FileName()+EcShardExt(shardId), os.O_RDONLY, 0644)
+	if e != nil {
+		return e
+	}
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	if old, ok := ev.shards[shardId]; ok {
+		old.Close()
+	}
+	ev.shards[shardId] = f
+	return nil
+}
+
+// DeleteShard closes and removes the shard file
+func (ev *EcVolume) DeleteShard(shardId int) error {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	f, ok := ev.shards[shardId]
+	if !ok {
+		return nil
+	}
+	f.Close()
+	delete(ev.shards, shardId)
+	return os.Remove(ev.FileName() + EcShardExt(shardId))
+}
+
+func (ev *EcVolume) ShardCount() int {
+	ev.mutex.RLock()
+	defer ev.mutex.RUnlock()
+	return len(ev.shards)
+}
+
+func (ev *EcVolume) FileCount() int {
+	return ev.nm.FileCount()
+}
+
+func (ev *EcVolume) ReadShardAt(shardId int, buf []byte, offset int64) (int, error) {
+	ev.mutex.RLock()
+	defer ev.mutex.RUnlock()
+	f, ok := ev.shards[shardId]
+	if !ok {
+		return 0, ErrEcShardMissing
+	}
+	n, e := f.ReadAt(buf, offset)
+	if e == io.EOF && n == len(buf) {
+		e = nil
+	}
+	return n, e
+}
+
+func (ev *EcVolume) getShardLocations() (map[int][]string, bool) {
+	ev.mutex.RLock()
+	defer ev.mutex.RUnlock()
+	return ev.shardLocations, time.Since(ev.shardLocationsRefresh) < EcShardLocationsRefreshInterval
+}
+
+func (ev *EcVolume) setShardLocations(locations map[int][]string) {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	ev.shardLocations = locations
+	ev.shardLocationsRefresh = time.Now()
+}
+
+func (ev *EcVolume) getVersion() Version {
+	ev.mutex.RLock()
+	defer ev.mutex.RUnlock()
+	return ev.version
+}
+
+func (ev *EcVolume) setVersion(version Version) {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	ev.version = version
+}
+
+func (ev *EcVolume) Close() {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	for _, f := range ev.shards {
+		f.Close()
+	}
+	ev.shards = make(map[int]*os.File)
+	ev.nm.Close()
+}
+
+// Destroy removes all local shards and the index
+func (ev *EcVolume) Destroy() (err error) {
+	ids := ev.ShardBits().ShardIds()
+	ev.Close()
+	for _, id := range ids {
+		if e := os.Remove(ev.FileName() + EcShardExt(id)); e != nil {
+			err = e
+		}
+	}
+	if e := os.Remove(ev.FileName() + ".ecx"); e != nil {
+		err = e
+	}
+	return
+}
+
+// WriteEcFiles generates all the shards and the ec index from the .dat and .idx files
+func WriteEcFiles(baseFileName string) (err error) {
+	dat, err := os.Open(baseFileName + ".dat")
+	if err != nil {
+		return err
+	}
+	defer dat.Close()
+	fi, err := dat.Stat()
+	if err != nil {
+		return err
+	}
+
+	outputs := make([]*os.File, EcTotalShards)
+	defer func() {
+		for _, f := range outputs {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i := range outputs {
+		if outputs[i], err = os.OpenFile(baseFileName+EcShardExt(i), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+			return err
+		}
+	}
+
+	rs := NewEcCodec()
+	buffers := make([][]byte, EcTotalShards)
+	for i := range buffers {
+		buffers[i] = make([]byte, EcBlockSize)
+	}
+	for rowOffset := int64(0); rowOffset < fi.Size(); rowOffset += EcRowSize {
+		for i := 0; i < EcDataShards; i++ {
+			n, e := dat.ReadAt(buffers[i], rowOffset+int64(i)*EcBlockSize)
+			if e != nil && e != io.EOF {
+				return e
+			}
+			for j := n; j < EcBlockSize; j++ {
+				buffers[i][j] = 0
+			}
+		}
+		if err = rs.Encode(buffers); err != nil {
+			return err
+		}
+		for i, buf := range buffers {
+			if _, err = outputs[i].Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+	return copyFile(baseFileName+".idx", baseFileName+".ecx")
+}
+
+// RemoveEcFiles removes the generated shards and ec index
+func RemoveEcFiles(baseFileName string) {
+	for i := 0; i < EcTotalShards; i++ {
+		os.Remove(baseFileName + EcShardExt(i))
+	}
+	os.Remove(baseFileName + ".ecx")
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
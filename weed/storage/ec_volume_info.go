@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/weedpb"
+)
+
+// EcVolumeInfo describes the shards of an ec volume held by one data node
+type EcVolumeInfo struct {
+	Id         VolumeId
+	Collection string
+	ShardBits  EcShardBits
+}
+
+func NewEcVolumeInfo(m *weedpb.EcShardInformationMessage) *EcVolumeInfo {
+	return &EcVolumeInfo{
+		Id:         VolumeId(m.Id),
+		Collection: m.Collection,
+		ShardBits:  EcShardBits(m.EcIndexBits),
+	}
+}
+
+func (ei EcVolumeInfo) String() string {
+	return fmt.Sprintf("Id:%d, Collection:%s, Shards:%v", ei.Id, ei.Collection, ei.ShardBits.ShardIds())
+}
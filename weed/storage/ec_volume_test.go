@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestLocateEcData(t *testing.T) {
+	intervals := locateEcData(EcBlockSize-10, 20)
+	if len(intervals) != 2 {
+		t.Fatal("expect 2 intervals, got", intervals)
+	}
+	if intervals[0].ShardId != 0 || intervals[0].ShardOffset != EcBlockSize-10 || intervals[0].Size != 10 {
+		t.Fatal("wrong first interval", intervals[0])
+	}
+	if intervals[1].ShardId != 1 || intervals[1].ShardOffset != 0 || intervals[1].Size != 10 {
+		t.Fatal("wrong second interval", intervals[1])
+	}
+	intervals = locateEcData(EcRowSize+5, 1)
+	if intervals[0].ShardId != 0 || intervals[0].ShardOffset != EcBlockSize+5 {
+		t.Fatal("wrong interval in the second row", intervals[0])
+	}
+}
+
+func TestEcVolumeReadWithMissingShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := NewVolume(dir, "", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var needles []*Needle
+	for i := 1; i <= 20; i++ {
+		n := &Needle{Id: uint64(i), Cookie: 0x1234, Data: []byte(fmt.Sprintf("needle data %d", i))}
+		n.Checksum = NewCRC(n.Data)
+		if _, err = v.write(n); err != nil {
+			t.Fatal(err)
+		}
+		needles = append(needles, n)
+	}
+	v.Close()
+
+	task := &EcEncodeTask{V: v}
+	if info := task.Info(); info.Get("volume") != "1" || info.Get("shards") != "0" {
+		t.Errorf("unexpected task info before the encoding %v", info)
+	}
+	if err = WriteEcFiles(v.FileName()); err != nil {
+		t.Fatal(err)
+	}
+	for _, shardId := range []int{0, 3, 11, 13} {
+		os.Remove(v.FileName() + EcShardExt(shardId))
+	}
+	if info := task.Info(); info.Get("shards") != strconv.Itoa(EcTotalShards-4) {
+		t.Errorf("unexpected task info %v", info)
+	}
+	ev, err := NewEcVolume(dir, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ev.Close()
+	if ev.ShardCount() != EcTotalShards-4 {
+		t.Fatal("expect 10 shards, got", ev.ShardBits().ShardIds())
+	}
+
+	s := &Store{masterNodes: NewMasterNodes("")}
+	for _, expected := range needles {
+		n := &Needle{Id: expected.Id}
+		if _, err = s.readEcNeedle(ev, n); err != nil {
+			t.Fatal(err)
+		}
+		if n.Cookie != expected.Cookie || !bytes.Equal(n.Data, expected.Data) {
+			t.Fatalf("needle %d read %s, expected %s", n.Id, n.Data, expected.Data)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"net/textproto"
 	"path"
 	"strconv"
 	"strings"
@@ -53,9 +56,10 @@ func (n *Needle) String() (str string) {
 	return
 }
 
-func ParseUpload(r *http.Request) (
-	fileName string, data []byte, mimeType string, isGzipped bool,
-	modifiedTime uint64, ttl *TTL, isChunkedFile bool, e error) {
+// ReadUploadPart finds the file part of the multipart upload, the first part with a file name,
+// or else the first part. The content of the file part is left to be read from data,
+// only a first part without file name is read into memory.
+func ReadUploadPart(r *http.Request) (fileName string, header textproto.MIMEHeader, data io.Reader, e error) {
 	form, fe := r.MultipartReader()
 	if fe != nil {
 		glog.V(0).Infoln("MultipartReader [ERROR]", fe)
@@ -70,42 +74,44 @@ func ParseUpload(r *http.Request) (
 		e = fe
 		return
 	}
+	header = part.Header
 
 	fileName = part.FileName()
 	if fileName != "" {
-		fileName = path.Base(fileName)
+		return path.Base(fileName), header, part, nil
 	}
 
-	data, e = ioutil.ReadAll(part)
+	firstData, e := ioutil.ReadAll(part)
 	if e != nil {
 		glog.V(0).Infoln("Reading Content [ERROR]", e)
 		return
 	}
 
 	//if the filename is empty string, do a search on the other multi-part items
-	for fileName == "" {
+	for {
 		part2, fe := form.NextPart()
 		if fe != nil {
 			break // no more or on error, just safely break
 		}
 
-		fName := part2.FileName()
-
 		//found the first <file type> multi-part has filename
-		if fName != "" {
-			data2, fe2 := ioutil.ReadAll(part2)
-			if fe2 != nil {
-				glog.V(0).Infoln("Reading Content [ERROR]", fe2)
-				e = fe2
-				return
-			}
-
-			//update
-			data = data2
-			fileName = path.Base(fName)
-			break
+		if fName := part2.FileName(); fName != "" {
+			return path.Base(fName), header, part2, nil
 		}
 	}
+	return "", header, bytes.NewReader(firstData), nil
+}
+
+// ParseUploadStream parses the upload like ParseUpload, but returns the file content
+// as a stream, which is compressed while it is read if the file is gzippable, unless
+// the part is sent with "Content-Encoding: identity".
+func ParseUploadStream(r *http.Request) (
+	fileName string, data io.Reader, mimeType string, isGzipped bool,
+	modifiedTime uint64, ttl *TTL, isChunkedFile bool, e error) {
+	var header textproto.MIMEHeader
+	if fileName, header, data, e = ReadUploadPart(r); e != nil {
+		return
+	}
 
 	dotIndex := strings.LastIndex(fileName, ".")
 	ext, mtype := "", ""
@@ -113,17 +119,15 @@ func ParseUpload(r *http.Request) (
 		ext = strings.ToLower(fileName[dotIndex:])
 		mtype = mime.TypeByExtension(ext)
 	}
-	contentType := part.Header.Get("Content-Type")
+	contentType := header.Get("Content-Type")
 	if contentType != "" && mtype != contentType {
 		mimeType = contentType //only return mime type if not deductable
 		mtype = contentType
 	}
-	if part.Header.Get("Content-Encoding") == "gzip" {
+	if encoding := header.Get("Content-Encoding"); encoding == "gzip" {
 		isGzipped = true
-	} else if operation.IsGzippable(ext, mtype) {
-		if data, e = operation.GzipData(data); e != nil {
-			return
-		}
+	} else if encoding != "identity" && operation.IsGzippable(ext, mtype) {
+		data = operation.GzipStream(data)
 		isGzipped = true
 	}
 	if ext == ".gz" {
@@ -138,10 +142,51 @@ func ParseUpload(r *http.Request) (
 	isChunkedFile, _ = strconv.ParseBool(r.FormValue("cm"))
 	return
 }
+
+func ParseUpload(r *http.Request) (
+	fileName string, data []byte, mimeType string, isGzipped bool,
+	modifiedTime uint64, ttl *TTL, isChunkedFile bool, e error) {
+	var reader io.Reader
+	fileName, reader, mimeType, isGzipped, modifiedTime, ttl, isChunkedFile, e = ParseUploadStream(r)
+	if e != nil {
+		return
+	}
+	if data, e = ioutil.ReadAll(reader); e != nil {
+		glog.V(0).Infoln("Reading Content [ERROR]", e)
+	}
+	return
+}
+
 func NewNeedle(r *http.Request, fixJpgOrientation bool) (n *Needle, e error) {
-	fname, mimeType, isGzipped, isChunkedFile := "", "", false, false
+	n, data, e := NewNeedleStream(r, fixJpgOrientation)
+	if e != nil || data == nil {
+		return
+	}
+	if n.Data, e = ioutil.ReadAll(data); e != nil {
+		glog.V(0).Infoln("Reading Content [ERROR]", e)
+		return
+	}
+	n.Checksum = NewCRC(n.Data)
+	return
+}
+
+// NewNeedleStream parses the upload into a needle without its data, which is left
+// to be read from the returned stream. The data is read into the needle, and the
+// stream is nil, if the jpg orientation has to be fixed on the whole image.
+func NewNeedleStream(r *http.Request, fixJpgOrientation bool) (n *Needle, data io.Reader, e error) {
 	n = new(Needle)
-	fname, n.Data, mimeType, isGzipped, n.LastModified, n.Ttl, isChunkedFile, e = ParseUpload(r)
+	commaSep := strings.LastIndex(r.URL.Path, ",")
+	dotSep := strings.LastIndex(r.URL.Path, ".")
+	nid := r.URL.Path[commaSep+1:]
+	if dotSep > 0 {
+		nid = r.URL.Path[commaSep+1 : dotSep]
+	}
+	if e = n.ParseNid(nid); e != nil {
+		return
+	}
+
+	fname, mimeType, isGzipped, isChunkedFile := "", "", false, false
+	fname, data, mimeType, isGzipped, n.LastModified, n.Ttl, isChunkedFile, e = ParseUploadStream(r)
 	if e != nil {
 		return
 	}
@@ -171,21 +216,14 @@ func NewNeedle(r *http.Request, fixJpgOrientation bool) (n *Needle, e error) {
 	if fixJpgOrientation {
 		loweredName := strings.ToLower(fname)
 		if mimeType == "image/jpeg" || strings.HasSuffix(loweredName, ".jpg") || strings.HasSuffix(loweredName, ".jpeg") {
+			if n.Data, e = ioutil.ReadAll(data); e != nil {
+				return
+			}
 			n.Data = images.FixJpgOrientation(n.Data)
+			n.Checksum = NewCRC(n.Data)
+			data = nil
 		}
 	}
-
-	n.Checksum = NewCRC(n.Data)
-
-	commaSep := strings.LastIndex(r.URL.Path, ",")
-	dotSep := strings.LastIndex(r.URL.Path, ".")
-	nid := r.URL.Path[commaSep+1:]
-	if dotSep > 0 {
-		nid = r.URL.Path[commaSep+1 : dotSep]
-	}
-
-	e = n.ParseNid(nid)
-
 	return
 }
 func (n *Needle) ParseNid(nid string) (err error) {
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+/*
+The needle data of encrypted collections is sealed with AES-GCM. The random
+nonce is stored in front of the sealed data, and the id of the key is stored
+in the FlagKeyIdMask bits of the flags, so keys can be rotated while the
+needles written with older keys are still readable.
+
+The checksum on disk covers the stored data, so compaction, volume sync and
+backups copy the encrypted needles without the keys. After decryption the
+checksum is computed again on the plain data, to keep the same etag.
+*/
+
+const MaxKeyId = FlagKeyIdMask >> flagKeyIdShift
+
+const flagKeyIdShift = 5
+
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// KeyProvider gives the encryption keys of the collections. It is implemented
+// by FileKeyProvider, or can be backed by a key management service.
+type KeyProvider interface {
+	// CurrentKey returns the key to encrypt new needles of the collection,
+	// the key id is 0 if the collection is not encrypted.
+	CurrentKey(collection string) (keyId byte, key []byte, err error)
+	// Key returns the key by the id recorded in the needle flags.
+	Key(collection string, keyId byte) ([]byte, error)
+}
+
+func (n *Needle) KeyId() byte {
+	return (n.Flags & FlagKeyIdMask) >> flagKeyIdShift
+}
+
+func (n *Needle) IsEncrypted() bool {
+	return n.Flags&FlagKeyIdMask > 0
+}
+
+// Encrypt seals the needle data, the needle must not share the data with the caller
+func (n *Needle) Encrypt(keyId byte, key []byte) error {
+	if keyId == 0 || keyId > MaxKeyId {
+		return fmt.Errorf("invalid key id %d", keyId)
+	}
+	if n.IsEncrypted() {
+		return fmt.Errorf("needle %x is already encrypted", n.Id)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(n.Data)+gcm.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	n.Data = gcm.Seal(nonce, nonce, n.Data, n.additionalData())
+	n.Flags = n.Flags&^FlagKeyIdMask | keyId<<flagKeyIdShift
+	n.Checksum = NewCRC(n.Data)
+	return nil
+}
+
+// Decrypt opens the needle data if it is encrypted
+func (n *Needle) Decrypt(keys KeyProvider, collection string) error {
+	if !n.IsEncrypted() {
+		return nil
+	}
+	if keys == nil {
+		return fmt.Errorf("needle %x is encrypted, but no encryption keys are configured", n.Id)
+	}
+	key, err := keys.Key(collection, n.KeyId())
+	if err != nil {
+		return fmt.Errorf("key %d of collection %q: %v", n.KeyId(), collection, err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	if len(n.Data) < gcm.NonceSize() {
+		return fmt.Errorf("needle %x: encrypted data too short", n.Id)
+	}
+	nonce, sealed := n.Data[:gcm.NonceSize()], n.Data[gcm.NonceSize():]
+	data, err := gcm.Open(nil, nonce, sealed, n.additionalData())
+	if err != nil {
+		return fmt.Errorf("needle %x: %v", n.Id, err)
+	}
+	n.Data = data
+	n.DataSize = uint32(len(data))
+	n.Flags &^= FlagKeyIdMask
+	n.Checksum = NewCRC(n.Data)
+	return nil
+}
+
+// additionalData binds the sealed data to the needle, so it can not be swapped with another one
+func (n *Needle) additionalData() []byte {
+	b := make([]byte, 12)
+	util.Uint64toBytes(b[0:8], n.Id)
+	util.Uint32toBytes(b[8:12], n.Cookie)
+	return b
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+/*
+FileKeyProvider reads the keys from a local file, one key per line:
+
+	collection:keyId:hexKey
+
+The collection is empty for the volumes without collection, key ids are from 1
+to 3, and the keys are 16, 24 or 32 bytes for AES-128, AES-192 or AES-256.
+The last key of a collection encrypts the new needles. Lines starting with "#"
+are comments.
+*/
+type FileKeyProvider struct {
+	fileName string
+	keys     map[string]map[byte][]byte
+	current  map[string]byte
+	mutex    sync.RWMutex
+}
+
+func NewFileKeyProvider(fileName string) (*FileKeyProvider, error) {
+	kp := &FileKeyProvider{fileName: fileName}
+	if err := kp.Reload(); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// Reload reads the key file again, e.g. after a new key is added
+func (kp *FileKeyProvider) Reload() error {
+	f, err := os.Open(kp.fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	keys := make(map[string]map[byte][]byte)
+	current := make(map[string]byte)
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("%s:%d: expecting collection:keyId:hexKey", kp.fileName, lineNumber)
+		}
+		collection := parts[0]
+		keyId, err := strconv.ParseUint(parts[1], 10, 8)
+		if err != nil || keyId == 0 || keyId > MaxKeyId {
+			return fmt.Errorf("%s:%d: key id should be from 1 to %d", kp.fileName, lineNumber, MaxKeyId)
+		}
+		key, err := hex.DecodeString(parts[2])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", kp.fileName, lineNumber, err)
+		}
+		if _, err = aes.NewCipher(key); err != nil {
+			return fmt.Errorf("%s:%d: %v", kp.fileName, lineNumber, err)
+		}
+		if keys[collection] == nil {
+			keys[collection] = make(map[byte][]byte)
+		}
+		keys[collection][byte(keyId)] = key
+		current[collection] = byte(keyId)
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	kp.mutex.Lock()
+	kp.keys, kp.current = keys, current
+	kp.mutex.Unlock()
+	return nil
+}
+
+func (kp *FileKeyProvider) CurrentKey(collection string) (byte, []byte, error) {
+	kp.mutex.RLock()
+	defer kp.mutex.RUnlock()
+	keyId, ok := kp.current[collection]
+	if !ok {
+		return 0, nil, nil
+	}
+	return keyId, kp.keys[collection][keyId], nil
+}
+
+func (kp *FileKeyProvider) Key(collection string, keyId byte) ([]byte, error) {
+	kp.mutex.RLock()
+	defer kp.mutex.RUnlock()
+	if key, ok := kp.keys[collection][keyId]; ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
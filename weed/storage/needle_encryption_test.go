@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testKey1 = "000102030405060708090a0b0c0d0e0f"
+	testKey2 = "101112131415161718191a1b1c1d1e1f101112131415161718191a1b1c1d1e1f"
+)
+
+func TestEncryptedVolume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encryption")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "keys")
+	if err = ioutil.WriteFile(keyFile, []byte("# test keys\nsecret:1:"+testKey1+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewFileKeyProvider(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewVolume(dir, "secret", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	v.keys = keys
+	write := func(id uint64) *Needle {
+		n := &Needle{Id: id, Cookie: 0x1234, Data: []byte(fmt.Sprintf("confidential data %d", id))}
+		n.Checksum = NewCRC(n.Data)
+		size, err := v.write(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(size) != len(n.Data) {
+			t.Fatalf("needle %d written size %d, expected %d", id, size, len(n.Data))
+		}
+		return n
+	}
+	read := func(expected *Needle) {
+		n := &Needle{Id: expected.Id}
+		if _, err := v.readNeedle(n); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(n.Data, expected.Data) || n.Etag() != expected.Etag() || n.IsEncrypted() {
+			t.Fatalf("needle %d read %q, expected %q", n.Id, n.Data, expected.Data)
+		}
+	}
+
+	n1, n2 := write(1), write(2)
+	read(n1)
+	read(n2)
+	dat, err := ioutil.ReadFile(v.FileName() + ".dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(dat, []byte("confidential")) {
+		t.Fatal("plain data found in the .dat file")
+	}
+
+	// rotate the key, the older needles are still readable
+	if err = ioutil.WriteFile(keyFile, []byte("secret:1:"+testKey1+"\nsecret:2:"+testKey2+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = keys.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	n3 := write(3)
+	if _, err = v.delete(&Needle{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.commitCompact(); err != nil {
+		t.Fatal(err)
+	}
+	read(n1)
+	read(n3)
+
+	keyIds := make(map[uint64]byte)
+	err = ScanVolumeFile(dir, "secret", 1, NeedleMapInMemory, func(SuperBlock) error { return nil }, true,
+		func(n *Needle, offset int64) error {
+			keyIds[n.Id] = n.KeyId()
+			if n.Id == 3 {
+				return n.Decrypt(keys, "secret")
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyIds[1] != 1 || keyIds[3] != 2 {
+		t.Errorf("unexpected key ids %v", keyIds)
+	}
+}
+
+func TestNeedleDecryptErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encryption")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "keys")
+	if err = ioutil.WriteFile(keyFile, []byte(":1:"+testKey1+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewFileKeyProvider(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyId, _, _ := keys.CurrentKey("other"); keyId != 0 {
+		t.Errorf("collection without keys should not be encrypted")
+	}
+
+	keyId, key, err := keys.CurrentKey("")
+	if err != nil || keyId != 1 {
+		t.Fatalf("current key %d: %v", keyId, err)
+	}
+	n := &Needle{Id: 7, Cookie: 9, Data: []byte("abc"), Flags: FlagGzip}
+	if err = n.Encrypt(keyId, key); err != nil {
+		t.Fatal(err)
+	}
+	if n.KeyId() != 1 || !n.IsGzipped() {
+		t.Fatalf("unexpected flags %x", n.Flags)
+	}
+	if err = n.Decrypt(nil, ""); err == nil {
+		t.Errorf("expected error without keys")
+	}
+	if err = n.Decrypt(keys, "other"); err == nil {
+		t.Errorf("expected error with missing key")
+	}
+	swapped := *n
+	swapped.Id = 8
+	if err = swapped.Decrypt(keys, ""); err == nil {
+		t.Errorf("expected error decrypting the data of another needle")
+	}
+	if err = n.Decrypt(keys, ""); err != nil || string(n.Data) != "abc" || n.Flags != FlagGzip {
+		t.Errorf("decrypt: %q %x %v", n.Data, n.Flags, err)
+	}
+
+	for _, bad := range []string{"a:4:" + testKey1, "a:1:0102", "a:1", "a:x:" + testKey1} {
+		if err = ioutil.WriteFile(keyFile, []byte(bad), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = NewFileKeyProvider(keyFile); err == nil {
+			t.Errorf("expected error for key file %q", bad)
+		}
+	}
+}
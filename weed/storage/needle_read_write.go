@@ -16,7 +16,7 @@ const (
 	FlagHasMime             = 0x04
 	FlagHasLastModifiedDate = 0x08
 	FlagHasTtl              = 0x10
-	FlagIsExtendNeedle      = 0x40 // TODO: Reserve flag, use extent file to save big needle
+	FlagKeyIdMask           = 0x60 // id of the encryption key, 0 for plain data
 	FlagIsChunkManifest     = 0x80
 	LastModifiedBytesLength = 5
 	TtlBytesLength          = 2
@@ -149,6 +149,11 @@ func (n *Needle) ReadData(r *os.File, offset int64, size uint32, version Version
 	if err != nil {
 		return err
 	}
+	return n.ReadBytes(bytes, size, version)
+}
+
+// ReadBytes parses a needle blob read by ReadNeedleBlob
+func (n *Needle) ReadBytes(bytes []byte, size uint32, version Version) (err error) {
 	n.ParseNeedleHeader(bytes)
 	if n.Size != size {
 		return fmt.Errorf("File Entry Not Found. Needle %d Memory %d", n.Size, size)
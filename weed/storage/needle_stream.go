@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// the largest data of a needle, leaving room for the name, the mime type and the other fields
+const maxStreamDataSize = math.MaxUint32 - 4 - 1 - 2*(1+math.MaxUint8) - LastModifiedBytesLength - TtlBytesLength
+
+// crcWriter computes the checksum and counts the bytes written through it
+type crcWriter struct {
+	w     io.Writer
+	crc   CRC
+	count int64
+}
+
+func (c *crcWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.crc = c.crc.Update(p[:n])
+	c.count += int64(n)
+	return n, err
+}
+
+// AppendStream writes the needle like Append, with its data read from the stream instead of n.Data.
+// The size is not known before the data is read, so the header is written with a zero size first,
+// and patched once the stream is consumed. The writer is left at the end of the needle.
+func (n *Needle) AppendStream(w io.WriteSeeker, data io.Reader, version Version) (size uint32, err error) {
+	if version != Version1 && version != Version2 {
+		return 0, fmt.Errorf("Unsupported Version! (%d)", version)
+	}
+	start, err := w.Seek(0, 1)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot Read Current Volume Position: %v", err)
+	}
+	bw := bufio.NewWriterSize(w, 64*1024)
+	header := make([]byte, NeedleHeaderSize)
+	util.Uint32toBytes(header[0:4], n.Cookie)
+	util.Uint64toBytes(header[4:12], n.Id)
+	headerSize := NeedleHeaderSize
+	if version == Version2 {
+		// the data size follows the header
+		header = append(header, 0, 0, 0, 0)
+		headerSize += 4
+	}
+	if _, err = bw.Write(header); err != nil {
+		return
+	}
+	cw := &crcWriter{w: bw}
+	if _, err = io.Copy(cw, io.LimitReader(data, maxStreamDataSize+1)); err != nil {
+		return
+	}
+	if cw.count > maxStreamDataSize {
+		return 0, fmt.Errorf("needle %x is larger than %d bytes", n.Id, int64(maxStreamDataSize))
+	}
+	n.Data, n.Checksum = nil, cw.crc
+	n.DataSize, n.NameSize, n.MimeSize = uint32(cw.count), uint8(len(n.Name)), uint8(len(n.Mime))
+	n.Size = n.DataSize
+	if version == Version2 {
+		n.Size = 4 + n.DataSize + 1
+		util.Uint8toBytes(header[0:1], n.Flags)
+		if _, err = bw.Write(header[0:1]); err != nil {
+			return
+		}
+		if n.HasName() {
+			n.Size = n.Size + 1 + uint32(n.NameSize)
+			util.Uint8toBytes(header[0:1], n.NameSize)
+			if _, err = bw.Write(header[0:1]); err != nil {
+				return
+			}
+			if _, err = bw.Write(n.Name); err != nil {
+				return
+			}
+		}
+		if n.HasMime() {
+			n.Size = n.Size + 1 + uint32(n.MimeSize)
+			util.Uint8toBytes(header[0:1], n.MimeSize)
+			if _, err = bw.Write(header[0:1]); err != nil {
+				return
+			}
+			if _, err = bw.Write(n.Mime); err != nil {
+				return
+			}
+		}
+		if n.HasLastModifiedDate() {
+			n.Size = n.Size + LastModifiedBytesLength
+			util.Uint64toBytes(header[0:8], n.LastModified)
+			if _, err = bw.Write(header[8-LastModifiedBytesLength : 8]); err != nil {
+				return
+			}
+		}
+		if n.HasTtl() {
+			n.Size = n.Size + TtlBytesLength
+			if n.Ttl != nil {
+				n.Ttl.ToBytes(header[0:TtlBytesLength])
+			} else {
+				header[0], header[1] = 0, 0
+			}
+			if _, err = bw.Write(header[0:TtlBytesLength]); err != nil {
+				return
+			}
+		}
+	}
+	padding := NeedlePaddingSize - ((NeedleHeaderSize + n.Size + NeedleChecksumSize) % NeedlePaddingSize)
+	util.Uint32toBytes(header[0:NeedleChecksumSize], n.Checksum.Value())
+	for i := NeedleChecksumSize; i < NeedleChecksumSize+int(padding); i++ {
+		header[i] = 0
+	}
+	if _, err = bw.Write(header[0 : NeedleChecksumSize+padding]); err != nil {
+		return
+	}
+	if err = bw.Flush(); err != nil {
+		return
+	}
+
+	// patch the sizes in the header
+	util.Uint32toBytes(header[0:4], n.Size)
+	util.Uint32toBytes(header[4:8], n.DataSize)
+	if _, err = w.Seek(start+12, 0); err != nil {
+		return
+	}
+	if _, err = w.Write(header[0 : headerSize-12]); err != nil {
+		return
+	}
+	_, err = w.Seek(0, 2)
+	return n.DataSize, err
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+)
+
+func newUploadRequest(t *testing.T, fid, fileName string, data []byte) *http.Request {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="`+fileName+`"`)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	w.Close()
+	r, err := http.NewRequest("POST", "http://127.0.0.1:8080/"+fid+"?ts=1500000000", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	// as the volume server does before parsing the upload
+	r.ParseForm()
+	return r
+}
+
+func TestNeedleStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	v, err := NewVolume(dir, "", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	text := []byte(strings.Repeat("streamed text content\n", 10000))
+	binary := bytes.Repeat([]byte{0, 1, 2, 3, 4, 5, 6}, 30000)
+	for _, c := range []struct {
+		fid, fileName string
+		data          []byte
+		gzipped       bool
+	}{
+		{"1,0163703700", "a.txt", text, true},
+		{"1,0263703700", "b.bin", binary, false},
+		{"1,0363703700", "empty.bin", nil, false},
+	} {
+		n, data, err := NewNeedleStream(newUploadRequest(t, c.fid, c.fileName, c.data), false)
+		if err != nil || data == nil {
+			t.Fatalf("%s: %v", c.fileName, err)
+		}
+		if _, err = v.writeStream(n, data); err != nil {
+			t.Fatalf("%s: %v", c.fileName, err)
+		}
+		stored := &Needle{Id: n.Id}
+		if _, err = v.readNeedle(stored); err != nil {
+			t.Fatalf("%s: %v", c.fileName, err)
+		}
+		content := stored.Data
+		if stored.IsGzipped() != c.gzipped {
+			t.Errorf("%s: gzipped %v", c.fileName, stored.IsGzipped())
+		} else if c.gzipped {
+			if content, err = operation.UnGzipData(content); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(content, c.data) || string(stored.Name) != c.fileName || stored.LastModified != 1500000000 {
+			t.Errorf("%s: unexpected needle %v", c.fileName, stored)
+		}
+		reader, err := v.dataReader(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw, _ := ioutil.ReadAll(reader); !bytes.Equal(raw, stored.Data) {
+			t.Errorf("%s: data reader returns %d bytes instead of %d", c.fileName, len(raw), len(stored.Data))
+		}
+	}
+
+	// the needles written in memory and streamed are read back the same way
+	n, err := NewNeedle(newUploadRequest(t, "1,0463703700", "c.bin", binary), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.write(n); err != nil {
+		t.Fatal(err)
+	}
+	stored := &Needle{Id: n.Id}
+	if _, err = v.readNeedle(stored); err != nil || !bytes.Equal(stored.Data, binary) || stored.Checksum != n.Checksum {
+		t.Errorf("unexpected needle %v: %v", stored, err)
+	}
+
+	// the volume is written while a slow client still sends its stream
+	pr, pw := io.Pipe()
+	streamed := make(chan error)
+	go func() {
+		_, err := v.writeStream(&Needle{Id: 5, Cookie: 0x63703700}, pr)
+		streamed <- err
+	}()
+	pw.Write(text[:100])
+	n = &Needle{Id: 6, Cookie: 0x63703700, Data: []byte("written meanwhile")}
+	n.Checksum = NewCRC(n.Data)
+	if _, err = v.write(n); err != nil {
+		t.Fatal(err)
+	}
+	pw.Write(text[100:200])
+	pw.Close()
+	if err = <-streamed; err != nil {
+		t.Fatal(err)
+	}
+	stored = &Needle{Id: 5}
+	if _, err = v.readNeedle(stored); err != nil || !bytes.Equal(stored.Data, text[:200]) {
+		t.Errorf("unexpected streamed needle %v: %v", stored, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.stream*")); len(files) > 0 {
+		t.Errorf("the spooled streams are left: %v", files)
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"time"
 
 	"sync"
 
 	"encoding/json"
 
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -109,6 +111,7 @@ type Store struct {
 	TaskManager     *TaskManager
 	mutex           sync.RWMutex
 	needleCache     *lru.ARCCache
+	keys            KeyProvider
 }
 
 func (s *Store) String() (str string) {
@@ -127,7 +130,9 @@ func NewStore(port int, ip, publicUrl string, dirnames []string, maxVolumeCounts
 	}
 	s.Locations = make([]*DiskLocation, 0)
 	for i := 0; i < len(dirnames); i++ {
-		location := NewDiskLocation(dirnames[i], maxVolumeCounts[i])
+		dir, tier := ParseDiskTier(dirnames[i])
+		location := NewDiskLocation(dir, maxVolumeCounts[i])
+		location.Tier = tier
 		location.LoadExistingVolumes(needleMapKind)
 		s.Locations = append(s.Locations, location)
 	}
@@ -139,7 +144,16 @@ func NewStore(port int, ip, publicUrl string, dirnames []string, maxVolumeCounts
 	}
 	return
 }
-func (s *Store) AddVolume(volumeListString string, collection string, ttlString string) error {
+
+// SetKeyProvider enables the encryption of the collections with keys
+func (s *Store) SetKeyProvider(keys KeyProvider) {
+	s.keys = keys
+	for _, location := range s.Locations {
+		location.SetKeyProvider(keys)
+	}
+}
+
+func (s *Store) AddVolume(volumeListString string, collection string, ttlString string, tier string) error {
 	ttl, e := ReadTTL(ttlString)
 	if e != nil {
 		return e
@@ -151,7 +165,7 @@ func (s *Store) AddVolume(volumeListString string, collection string, ttlString
 			if err != nil {
 				return fmt.Errorf("Volume Id %s is not a valid unsigned integer!", id_string)
 			}
-			e = s.addVolume(VolumeId(id), collection, ttl)
+			e = s.addVolume(VolumeId(id), collection, ttl, tier)
 		} else {
 			pair := strings.Split(range_string, "-")
 			start, start_err := strconv.ParseUint(pair[0], 10, 64)
@@ -163,7 +177,7 @@ func (s *Store) AddVolume(volumeListString string, collection string, ttlString
 				return fmt.Errorf("Volume End Id %s is not a valid unsigned integer!", pair[1])
 			}
 			for id := start; id <= end; id++ {
-				if err := s.addVolume(VolumeId(id), collection, ttl); err != nil {
+				if err := s.addVolume(VolumeId(id), collection, ttl, tier); err != nil {
 					e = err
 				}
 			}
@@ -186,9 +200,14 @@ func (s *Store) findVolume(vid VolumeId) *Volume {
 	}
 	return nil
 }
-func (s *Store) findFreeLocation() (ret *DiskLocation) {
+
+// findFreeLocation picks the location with the most free slots, only in the tier if it is not empty
+func (s *Store) findFreeLocation(tier string) (ret *DiskLocation) {
 	max := 0
 	for _, location := range s.Locations {
+		if tier != "" && location.Tier != tier {
+			continue
+		}
 		currentFreeCount := location.MaxVolumeCount - location.VolumeCount()
 		if currentFreeCount > max {
 			max = currentFreeCount
@@ -197,20 +216,24 @@ func (s *Store) findFreeLocation() (ret *DiskLocation) {
 	}
 	return ret
 }
-func (s *Store) addVolume(vid VolumeId, collection string, ttl *TTL) error {
+func (s *Store) addVolume(vid VolumeId, collection string, ttl *TTL, tier string) error {
 	if s.findVolume(vid) != nil {
 		return fmt.Errorf("Volume Id %d already exists!", vid)
 	}
-	if location := s.findFreeLocation(); location != nil {
-		glog.V(0).Infof("In dir %s adds volume:%v collection:%s ttl:%v",
-			location.Directory, vid, collection, ttl)
+	if location := s.findFreeLocation(tier); location != nil {
+		glog.V(0).Infof("In dir %s adds volume:%v collection:%s ttl:%v tier:%s",
+			location.Directory, vid, collection, ttl, location.Tier)
 		if volume, err := NewVolume(location.Directory, collection, vid, s.needleMapKind, ttl); err == nil {
+			volume.SetTrashRetention(s.trashRetention(collection))
 			location.AddVolume(vid, volume)
 			return nil
 		} else {
 			return err
 		}
 	}
+	if tier != "" {
+		return fmt.Errorf("No more free space left on tier %s", tier)
+	}
 	return fmt.Errorf("No more free space left")
 }
 
@@ -227,7 +250,10 @@ func (s *Store) Status() []*VolumeInfo {
 				DeleteCount:      v.nm.DeletedCount(),
 				DeletedByteCount: v.nm.DeletedSize(),
 				ReadOnly:         v.IsReadOnly(),
-				Ttl:              v.Ttl}
+				Ttl:              v.Ttl,
+				Tier:             location.Tier,
+				LastModified:     v.LastModified(),
+				ReadCount:        v.ReadCount()}
 			stats = append(stats, s)
 			return nil
 		})
@@ -257,10 +283,22 @@ func (s *Store) SendHeartbeatToMaster(callback SettingChanged) error {
 		return err
 	}
 	var volumeMessages []*weedpb.VolumeInformationMessage
+	var ecShardMessages []*weedpb.EcShardInformationMessage
 	maxVolumeCount := 0
 	var maxFileKey uint64
+	var diskTierMessages []*weedpb.DiskTierMessage
+	tierIndex := make(map[string]*weedpb.DiskTierMessage)
 	for _, location := range s.Locations {
 		maxVolumeCount = maxVolumeCount + location.MaxVolumeCount
+		if location.Tier != "" {
+			dt, ok := tierIndex[location.Tier]
+			if !ok {
+				dt = &weedpb.DiskTierMessage{Tier: location.Tier}
+				tierIndex[location.Tier] = dt
+				diskTierMessages = append(diskTierMessages, dt)
+			}
+			dt.MaxVolumeCount += uint32(location.MaxVolumeCount)
+		}
 		volumeToDelete := []VolumeId{}
 		location.WalkVolume(func(v *Volume) (e error) {
 			if maxFileKey < v.nm.MaxFileKey() {
@@ -277,6 +315,9 @@ func (s *Store) SendHeartbeatToMaster(callback SettingChanged) error {
 					ReadOnly:         v.IsReadOnly(),
 					Version:          uint32(v.Version()),
 					Ttl:              v.Ttl.ToUint32(),
+					Tier:             location.Tier,
+					LastModified:     v.LastModified(),
+					ReadCount:        v.ReadCount(),
 				}
 				volumeMessages = append(volumeMessages, volumeMessage)
 			} else {
@@ -292,6 +333,14 @@ func (s *Store) SendHeartbeatToMaster(callback SettingChanged) error {
 		for _, vid := range volumeToDelete {
 			location.DeleteVolume(vid)
 		}
+		location.WalkEcVolume(func(ev *EcVolume) (e error) {
+			ecShardMessages = append(ecShardMessages, &weedpb.EcShardInformationMessage{
+				Id:          uint32(ev.Id),
+				Collection:  ev.Collection,
+				EcIndexBits: uint32(ev.ShardBits()),
+			})
+			return nil
+		})
 	}
 
 	joinMsgV2 := &weedpb.JoinMessageV2{
@@ -304,6 +353,8 @@ func (s *Store) SendHeartbeatToMaster(callback SettingChanged) error {
 		DataCenter:     s.dataCenter,
 		Rack:           s.rack,
 		Volumes:        volumeMessages,
+		EcShards:       ecShardMessages,
+		DiskTiers:      diskTierMessages,
 	}
 	ret := &weedpb.JoinResponse{}
 	joinUrl := util.MkUrl(masterNode, "/dir/join2", nil)
@@ -367,6 +418,39 @@ func (s *Store) Write(i VolumeId, n *Needle) (size uint32, err error) {
 	err = fmt.Errorf("Volume %d not found!", i)
 	return
 }
+
+// WriteStream writes the needle with its data read from the stream
+func (s *Store) WriteStream(i VolumeId, n *Needle, data io.Reader) (size uint32, err error) {
+	if v := s.findVolume(i); v != nil {
+		if v.IsReadOnly() {
+			err = fmt.Errorf("Volume %d is read only", i)
+			return
+		}
+		if MaxPossibleVolumeSize >= v.ContentSize() {
+			size, err = v.writeStream(n, data)
+		} else {
+			err = fmt.Errorf("Volume Size Limit %d Exceeded! Current size is %d", s.GetVolumeSizeLimit(), v.ContentSize())
+		}
+		if s.GetVolumeSizeLimit() < v.ContentSize()+3*uint64(size) {
+			glog.V(0).Infoln("volume", i, "size", v.ContentSize(), "will exceed limit", s.GetVolumeSizeLimit())
+			if e := s.SendHeartbeatToMaster(nil); e != nil {
+				glog.V(0).Infoln("error when reporting size:", e)
+			}
+		}
+		return
+	}
+	glog.V(0).Infoln("volume", i, "not found!")
+	err = fmt.Errorf("Volume %d not found!", i)
+	return
+}
+
+// ReadVolumeNeedleData reads the data of a needle written by WriteStream, without loading it into memory
+func (s *Store) ReadVolumeNeedleData(i VolumeId, n *Needle) (io.Reader, error) {
+	if v := s.findVolume(i); v != nil {
+		return v.dataReader(n)
+	}
+	return nil, fmt.Errorf("Volume %v not found!", i)
+}
 func (s *Store) Delete(i VolumeId, n *Needle) (uint32, error) {
 	if v := s.findVolume(i); v != nil && !v.IsReadOnly() {
 		return v.delete(n)
@@ -384,11 +468,15 @@ func (s *Store) ReadLocalNeedle(fid *FileId) (n *Needle, err error) {
 	cacheKey := fid.String()
 	if cn, cacheHit := s.needleCache.Get(cacheKey); cacheHit {
 		glog.V(2).Infoln("Local needle cache hit:", fid)
+		if v := s.findVolume(fid.VolumeId); v != nil {
+			v.countRead()
+		}
 		return cn.(*Needle), nil
 	}
 	glog.V(2).Infoln("Local needle cache miss:", fid)
 
 	if v := s.findVolume(fid.VolumeId); v != nil {
+		v.countRead()
 		n = &Needle{
 			Id: fid.Key,
 		}
@@ -398,6 +486,16 @@ func (s *Store) ReadLocalNeedle(fid *FileId) (n *Needle, err error) {
 		if n.Cookie != fid.Cookie {
 			return nil, fmt.Errorf("request (%v,%x) with unmaching cookie seen: %x expected: %x", fid.VolumeId, fid.Key, fid.Cookie, n.Cookie)
 		}
+	} else if ev := s.findEcVolume(fid.VolumeId); ev != nil {
+		n = &Needle{
+			Id: fid.Key,
+		}
+		if _, err := s.readEcNeedle(ev, n); err != nil {
+			return nil, err
+		}
+		if n.Cookie != fid.Cookie {
+			return nil, fmt.Errorf("request (%v,%x) with unmaching cookie seen: %x expected: %x", fid.VolumeId, fid.Key, fid.Cookie, n.Cookie)
+		}
 	} else {
 		return nil, fmt.Errorf("Volume %v not found!", fid.VolumeId)
 	}
@@ -420,15 +518,25 @@ func (s *Store) ReadRemoteNeedle(fid *FileId, collection string) (*Needle, error
 	if err != nil || len(lookupResult.Locations) == 0 {
 		return nil, errors.New("lookup error:" + err.Error())
 	}
-	u, _ := url.Parse(util.NormalizeUrl(lookupResult.Locations.PickForRead().Url))
+	n, err := readNeedleFromServer(lookupResult.Locations.PickForRead().Url, fid)
+	if err != nil {
+		return nil, err
+	}
+	s.needleCache.Add(cacheKey, n)
+	return n, nil
+}
+
+// readNeedleFromServer reads the needle from the volume server with /admin/sync/needle
+func readNeedleFromServer(server string, fid *FileId) (*Needle, error) {
+	u, _ := url.Parse(util.NormalizeUrl(server))
 	u.Path = "/admin/sync/needle"
 	args := url.Values{
-		"volume": {vid},
+		"volume": {fid.VolumeId.String()},
 		"nid":    {fid.Nid()},
 	}
 	u.RawQuery = args.Encode()
 	req, _ := http.NewRequest("GET", u.String(), nil)
-	resp, err := util.HttpDo(req)
+	resp, err := util.InternalDo(req)
 	if err != nil {
 		return nil, err
 	}
@@ -483,7 +591,12 @@ func (s *Store) ReadRemoteNeedle(fid *FileId, collection string) (*Needle, error
 		n.Mime = []byte(h)
 		n.SetHasMime()
 	}
-	s.needleCache.Add(cacheKey, n)
+	if h := resp.Header.Get("Seaweed-Ttl"); h != "" {
+		if ttl, err := ReadTTL(h); err == nil {
+			n.Ttl = ttl
+			n.SetHasTtl()
+		}
+	}
 	return n, nil
 }
 
@@ -553,8 +666,24 @@ func (s *Store) GetCollectionSettings() *CollectionSettings {
 
 func (s *Store) SetCollectionSettings(cs *CollectionSettings) {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 	s.colSettings = cs
+	s.mutex.Unlock()
+	s.WalkVolume(func(v *Volume) error {
+		v.SetTrashRetention(s.trashRetention(v.Collection))
+		return nil
+	})
+}
+
+// trashRetention returns how long the deleted files of the collection are recoverable
+func (s *Store) trashRetention(collection string) time.Duration {
+	cs := s.GetCollectionSettings()
+	if cs == nil {
+		return 0
+	}
+	if retention := cs.GetTrashRetention(collection); retention != nil {
+		return time.Duration(retention.Minutes()) * time.Minute
+	}
+	return 0
 }
 
 func (s *Store) GetVolumeReplicaPlacement(volumeId VolumeId) *ReplicaPlacement {
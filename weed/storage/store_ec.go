@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func (s *Store) findEcVolume(vid VolumeId) *EcVolume {
+	for _, location := range s.Locations {
+		if ev, found := location.GetEcVolume(vid); found {
+			return ev
+		}
+	}
+	return nil
+}
+
+func (s *Store) findEcVolumeLocation(vid VolumeId) *DiskLocation {
+	for _, location := range s.Locations {
+		if location.HasEcVolume(vid) {
+			return location
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetEcVolume(vid VolumeId) *EcVolume {
+	return s.findEcVolume(vid)
+}
+
+func (s *Store) HasEcVolume(vid VolumeId) bool {
+	return s.findEcVolume(vid) != nil
+}
+
+func (s *Store) EcStatus() (stats []*EcVolumeInfo) {
+	s.WalkEcVolume(func(ev *EcVolume) error {
+		stats = append(stats, &EcVolumeInfo{
+			Id:         ev.Id,
+			Collection: ev.Collection,
+			ShardBits:  ev.ShardBits(),
+		})
+		return nil
+	})
+	return
+}
+
+func (s *Store) WalkEcVolume(walker EcVolumeWalker) error {
+	for _, location := range s.Locations {
+		if e := location.WalkEcVolume(walker); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// MountEcShards loads the shards copied into the volume directory
+func (s *Store) MountEcShards(location *DiskLocation, collection string, vid VolumeId, shardIds []int) error {
+	if ev, ok := location.GetEcVolume(vid); ok {
+		for _, id := range shardIds {
+			if e := ev.MountShard(id); e != nil {
+				return e
+			}
+		}
+		return nil
+	}
+	ev, e := NewEcVolume(location.Directory, collection, vid)
+	if e != nil {
+		return e
+	}
+	location.AddEcVolume(vid, ev)
+	return nil
+}
+
+// DeleteEcShards removes the local shards, the ec volume is destroyed when no shard is left
+func (s *Store) DeleteEcShards(vid VolumeId, shardIds []int) error {
+	location := s.findEcVolumeLocation(vid)
+	if location == nil {
+		return fmt.Errorf("Ec volume %d not found!", vid)
+	}
+	ev, _ := location.GetEcVolume(vid)
+	for _, id := range shardIds {
+		if e := ev.DeleteShard(id); e != nil {
+			return e
+		}
+	}
+	if ev.ShardCount() == 0 {
+		return location.DeleteEcVolume(vid)
+	}
+	return nil
+}
+
+// DeleteVolume removes a normal volume, after it has been erasure coded
+func (s *Store) DeleteVolume(vid VolumeId) error {
+	for _, location := range s.Locations {
+		if location.HasVolume(vid) {
+			return location.DeleteVolume(vid)
+		}
+	}
+	return fmt.Errorf("Volume %d not found!", vid)
+}
+
+func (s *Store) readEcNeedle(ev *EcVolume, n *Needle) (int, error) {
+	nv, ok := ev.nm.Get(n.Id)
+	if !ok || nv.Offset == 0 {
+		return -1, errors.New("Not Found")
+	}
+	version, err := s.ecVolumeVersion(ev)
+	if err != nil {
+		return 0, err
+	}
+	padding := NeedlePaddingSize - ((NeedleHeaderSize + nv.Size + NeedleChecksumSize) % NeedlePaddingSize)
+	bytes, err := s.readEcData(ev, int64(nv.Offset)*NeedlePaddingSize, int64(NeedleHeaderSize+nv.Size+NeedleChecksumSize+padding))
+	if err != nil {
+		return 0, err
+	}
+	if err = n.ReadBytes(bytes, nv.Size, version); err != nil {
+		return 0, err
+	}
+	if err = n.Decrypt(s.keys, ev.Collection); err != nil {
+		return 0, err
+	}
+	return len(n.Data), nil
+}
+
+func (s *Store) ecVolumeVersion(ev *EcVolume) (Version, error) {
+	if version := ev.getVersion(); version != 0 {
+		return version, nil
+	}
+	header, err := s.readEcData(ev, 0, SuperBlockSize)
+	if err != nil {
+		return 0, err
+	}
+	superBlock, err := ParseSuperBlock(header)
+	if err != nil {
+		return 0, err
+	}
+	ev.setVersion(superBlock.Version())
+	return superBlock.Version(), nil
+}
+
+// readEcData reads a range of the original .dat file from the shards
+func (s *Store) readEcData(ev *EcVolume, offset, size int64) ([]byte, error) {
+	data := make([]byte, 0, size)
+	for _, interval := range locateEcData(offset, size) {
+		buf, err := s.readEcInterval(ev, interval)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, buf...)
+	}
+	return data, nil
+}
+
+func (s *Store) readEcInterval(ev *EcVolume, interval ecInterval) ([]byte, error) {
+	buf := make([]byte, interval.Size)
+	if _, err := ev.ReadShardAt(interval.ShardId, buf, interval.ShardOffset); err == nil {
+		return buf, nil
+	} else if err != ErrEcShardMissing {
+		glog.V(0).Infof("read ec volume %d shard %d error: %v", ev.Id, interval.ShardId, err)
+	}
+	if buf, err := s.readRemoteEcShard(ev, interval); err == nil {
+		return buf, nil
+	} else {
+		glog.V(1).Infof("read remote ec volume %d shard %d error: %v", ev.Id, interval.ShardId, err)
+	}
+	return s.recoverEcInterval(ev, interval)
+}
+
+func (s *Store) ecShardLocations(ev *EcVolume) (map[int][]string, error) {
+	locations, fresh := ev.getShardLocations()
+	if fresh {
+		return locations, nil
+	}
+	ret, err := operation.LookupEcShards(s.GetMaster(), ev.Id.String())
+	if err != nil {
+		if locations != nil {
+			return locations, nil
+		}
+		return nil, err
+	}
+	locations = make(map[int][]string)
+	for _, shard := range ret.Shards {
+		for _, l := range shard.Locations {
+			locations[shard.ShardId] = append(locations[shard.ShardId], l.Url)
+		}
+	}
+	ev.setShardLocations(locations)
+	return locations, nil
+}
+
+func (s *Store) readRemoteEcShard(ev *EcVolume, interval ecInterval) (buf []byte, err error) {
+	locations, err := s.ecShardLocations(ev)
+	if err != nil {
+		return nil, err
+	}
+	err = ErrEcShardMissing
+	selfUrl := s.GetIP() + ":" + strconv.Itoa(s.Port)
+	for _, server := range locations[interval.ShardId] {
+		if server == selfUrl {
+			continue
+		}
+		args := url.Values{
+			"volume": {ev.Id.String()},
+			"shard":  {strconv.Itoa(interval.ShardId)},
+			"offset": {strconv.FormatInt(interval.ShardOffset, 10)},
+			"size":   {strconv.FormatInt(interval.Size, 10)},
+		}
+		if buf, err = util.Get(server, "/admin/ec/read", args); err == nil {
+			if int64(len(buf)) == interval.Size {
+				return buf, nil
+			}
+			err = fmt.Errorf("read %d bytes from %s, expected %d", len(buf), server, interval.Size)
+		}
+	}
+	return nil, err
+}
+
+// recoverEcInterval rebuilds the interval from the same range of any EcDataShards other shards
+func (s *Store) recoverEcInterval(ev *EcVolume, interval ecInterval) ([]byte, error) {
+	shards := make([][]byte, EcTotalShards)
+	found := 0
+	for i := 0; i < EcTotalShards && found < EcDataShards; i++ {
+		if i == interval.ShardId {
+			continue
+		}
+		buf, err := s.readEcShardRange(ev, ecInterval{ShardId: i, ShardOffset: interval.ShardOffset, Size: interval.Size})
+		if err != nil {
+			glog.V(1).Infof("ec volume %d skips shard %d for recovery: %v", ev.Id, i, err)
+			continue
+		}
+		shards[i] = buf
+		found++
+	}
+	if found < EcDataShards {
+		return nil, fmt.Errorf("Ec volume %d has only %d shards available, cannot recover shard %d", ev.Id, found, interval.ShardId)
+	}
+	rs := NewEcCodec()
+	var err error
+	if interval.ShardId < EcDataShards {
+		err = rs.ReconstructData(shards)
+	} else {
+		err = rs.Reconstruct(shards)
+	}
+	if err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("ec volume %d recovered shard %d offset %d size %d", ev.Id, interval.ShardId, interval.ShardOffset, interval.Size)
+	return shards[interval.ShardId], nil
+}
+
+func (s *Store) readEcShardRange(ev *EcVolume, interval ecInterval) ([]byte, error) {
+	buf := make([]byte, interval.Size)
+	if _, err := ev.ReadShardAt(interval.ShardId, buf, interval.ShardOffset); err == nil {
+		return buf, nil
+	}
+	return s.readRemoteEcShard(ev, interval)
+}
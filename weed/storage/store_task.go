@@ -14,6 +14,11 @@ const (
 	TaskVacuum    = "vacuum"
 	TaskReplicate = "replicate"
 	TaskBalance   = "balance"
+	TaskEcEncode  = "ec_encode"
+	TaskEcCopy    = "ec_copy"
+	TaskScrub     = "scrub"
+	TaskTier      = "tier"
+	TaskMirror    = "mirror"
 )
 
 var (
@@ -97,6 +102,17 @@ func (tm *TaskManager) NewTask(s *Store, args url.Values) (tid string, e error)
 	case TaskReplicate:
 		tw, e = NewReplicaTask(s, args)
 	case TaskBalance:
+		tw, e = NewBalanceTask(s, args)
+	case TaskEcEncode:
+		tw, e = NewEcEncodeTask(s, args)
+	case TaskEcCopy:
+		tw, e = NewEcCopyTask(s, args)
+	case TaskScrub:
+		tw, e = NewScrubTask(s, args)
+	case TaskTier:
+		tw, e = NewTierTask(s, args)
+	case TaskMirror:
+		tw, e = NewMirrorTask(s, args)
 	}
 	if e != nil {
 		return
@@ -158,3 +174,29 @@ func (tm *TaskManager) ElapsedDuration(tid string) (time.Duration, error) {
 	}
 	return time.Since(t.startTime), nil
 }
+
+type TaskStatus struct {
+	Id        string     `json:"id"`
+	StartTime time.Time  `json:"startTime"`
+	Finished  bool       `json:"finished"`
+	Error     string     `json:"error,omitempty"`
+	Info      url.Values `json:"info,omitempty"`
+}
+
+func (tm *TaskManager) AllTasks() (ret []TaskStatus) {
+	tm.lock.RLock()
+	defer tm.lock.RUnlock()
+	for tid, t := range tm.taskList {
+		ts := TaskStatus{
+			Id:        tid,
+			StartTime: t.startTime,
+			Finished:  t.result != ErrTaskNotFinish,
+			Info:      t.worker.Info(),
+		}
+		if ts.Finished && t.result != nil {
+			ts.Error = t.result.Error()
+		}
+		ret = append(ret, ts)
+	}
+	return
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// BalanceTask moves a volume from the source data node to this one.
+// The volume is copied like a replica, and the source copy is deleted
+// only after the new copy is loaded, so it is readable all the time.
+type BalanceTask struct {
+	*ReplicaTask
+}
+
+func NewBalanceTask(s *Store, args url.Values) (*BalanceTask, error) {
+	rt, err := NewReplicaTask(s, args)
+	if err != nil {
+		return nil, err
+	}
+	if s.HasVolume(rt.VID) {
+		return nil, fmt.Errorf("volume %d already exists", rt.VID)
+	}
+	return &BalanceTask{ReplicaTask: rt}, nil
+}
+
+func (t *BalanceTask) Commit() error {
+	if e := t.ReplicaTask.Commit(); e != nil {
+		return e
+	}
+	t.deleteSource()
+	return nil
+}
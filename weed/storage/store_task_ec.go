@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// EcEncodeTask converts a local sealed volume into erasure coded shards,
+// the volume is replaced by the shards on commit
+type EcEncodeTask struct {
+	V        *Volume
+	s        *Store
+	location *DiskLocation
+}
+
+func NewEcEncodeTask(s *Store, args url.Values) (*EcEncodeTask, error) {
+	volumeIdString := args.Get("volume")
+	vid, err := NewVolumeId(volumeIdString)
+	if err != nil {
+		return nil, fmt.Errorf("Volume Id %s is not a valid unsigned integer", volumeIdString)
+	}
+	for _, location := range s.Locations {
+		if v, ok := location.GetVolume(vid); ok {
+			if !v.IsReadOnly() {
+				return nil, fmt.Errorf("volume %d is writable, set it readonly first", vid)
+			}
+			if v.Ttl != nil && v.Ttl.Minutes() != 0 {
+				return nil, fmt.Errorf("volume %d has ttl %s, no need to erasure code it", vid, v.Ttl.String())
+			}
+			if s.HasEcVolume(vid) {
+				return nil, fmt.Errorf("ec volume %d already exists", vid)
+			}
+			return &EcEncodeTask{V: v, s: s, location: location}, nil
+		}
+	}
+	return nil, fmt.Errorf("volume id %d is not found", vid)
+}
+
+func (t *EcEncodeTask) Run() error {
+	return WriteEcFiles(t.V.FileName())
+}
+
+func (t *EcEncodeTask) Commit() error {
+	ev, e := NewEcVolume(t.location.Directory, t.V.Collection, t.V.Id)
+	if e != nil {
+		return e
+	}
+	t.location.AddEcVolume(t.V.Id, ev)
+	if e = t.location.DeleteVolume(t.V.Id); e != nil {
+		return e
+	}
+	t.s.SendHeartbeatToMaster(nil)
+	return nil
+}
+
+func (t *EcEncodeTask) Clean() error {
+	if !t.location.HasEcVolume(t.V.Id) {
+		RemoveEcFiles(t.V.FileName())
+	}
+	return nil
+}
+
+// Info tells the volume and how many shard files are written so far
+func (t *EcEncodeTask) Info() url.Values {
+	written := 0
+	for id := 0; id < EcTotalShards; id++ {
+		if _, e := os.Stat(t.V.FileName() + EcShardExt(id)); e == nil {
+			written++
+		}
+	}
+	return url.Values{
+		"volume":     {t.V.Id.String()},
+		"collection": {t.V.Collection},
+		"shards":     {strconv.Itoa(written)},
+	}
+}
+
+// EcCopyTask copies some shards of an ec volume from another data node
+type EcCopyTask struct {
+	VID         VolumeId
+	Collection  string
+	ShardIds    []int
+	SrcDataNode string
+	s           *Store
+	location    *DiskLocation
+	copyIndex   bool
+}
+
+func NewEcCopyTask(s *Store, args url.Values) (*EcCopyTask, error) {
+	volumeIdString := args.Get("volume")
+	vid, err := NewVolumeId(volumeIdString)
+	if err != nil {
+		return nil, fmt.Errorf("Volume Id %s is not a valid unsigned integer", volumeIdString)
+	}
+	source := args.Get("source")
+	if source == "" {
+		return nil, errors.New("Invalid source data node.")
+	}
+	shardIds, err := ParseEcShardIds(args.Get("shards"))
+	if err != nil {
+		return nil, err
+	}
+	t := &EcCopyTask{
+		VID:         vid,
+		Collection:  args.Get("collection"),
+		ShardIds:    shardIds,
+		SrcDataNode: source,
+		s:           s,
+	}
+	if t.location = s.findEcVolumeLocation(vid); t.location == nil {
+		if t.location = s.findFreeLocation(""); t.location == nil {
+			return nil, errors.New("No more free space left")
+		}
+		t.copyIndex = true
+	}
+	return t, nil
+}
+
+func (t *EcCopyTask) exts() (exts []string) {
+	for _, id := range t.ShardIds {
+		exts = append(exts, EcShardExt(id))
+	}
+	if t.copyIndex {
+		exts = append(exts, ".ecx")
+	}
+	return
+}
+
+func (t *EcCopyTask) Run() error {
+	ch := make(chan error)
+	exts := t.exts()
+	for _, ext := range exts {
+		go func(ext string) {
+			fileUrl := util.MkUrl(t.SrcDataNode, "/admin/ec/file", url.Values{"volume": {t.VID.String()}, "ext": {ext}})
+			e := util.DownloadToFile(fileUrl, t.FileName()+ext+".cpd")
+			if e != nil {
+				e = fmt.Errorf("Copy ec shard error: %s, %v", fileUrl, e)
+			}
+			ch <- e
+		}(ext)
+	}
+	errs := make([]error, 0)
+	for range exts {
+		if e := <-ch; e != nil {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v", errs)
+}
+
+func (t *EcCopyTask) Commit() error {
+	for _, ext := range t.exts() {
+		if e := os.Rename(t.FileName()+ext+".cpd", t.FileName()+ext); e != nil {
+			return e
+		}
+	}
+	if e := t.s.MountEcShards(t.location, t.Collection, t.VID, t.ShardIds); e != nil {
+		return e
+	}
+	t.s.SendHeartbeatToMaster(nil)
+	return nil
+}
+
+func (t *EcCopyTask) Clean() error {
+	for _, ext := range t.exts() {
+		os.Remove(t.FileName() + ext + ".cpd")
+	}
+	return nil
+}
+
+// Info tells the shards copied and how many bytes are downloaded so far
+func (t *EcCopyTask) Info() url.Values {
+	var copied int64
+	for _, ext := range t.exts() {
+		if fi, e := os.Stat(t.FileName() + ext + ".cpd"); e == nil {
+			copied += fi.Size()
+		}
+	}
+	return url.Values{
+		"volume":     {t.VID.String()},
+		"collection": {t.Collection},
+		"source":     {t.SrcDataNode},
+		"shards":     {joinEcShardIds(t.ShardIds)},
+		"copied":     {strconv.FormatInt(copied, 10)},
+	}
+}
+
+func (t *EcCopyTask) FileName() (fileName string) {
+	if t.Collection == "" {
+		fileName = path.Join(t.location.Directory, t.VID.String())
+	} else {
+		fileName = path.Join(t.location.Directory, t.Collection+"_"+t.VID.String())
+	}
+	return
+}
+
+func joinEcShardIds(ids []int) string {
+	var s []string
+	for _, id := range ids {
+		s = append(s, strconv.Itoa(id))
+	}
+	return strings.Join(s, ",")
+}
+
+// ParseEcShardIds parses shard id list like "0,1,5"
+func ParseEcShardIds(s string) (ids []int, err error) {
+	for _, str := range strings.Split(s, ",") {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			continue
+		}
+		id, e := strconv.Atoi(str)
+		if e != nil || id < 0 || id >= EcTotalShards {
+			return nil, fmt.Errorf("Invalid ec shard id %s", str)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("No ec shard id")
+	}
+	return
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// MirrorTask follows a volume of another cluster incrementally with Volume.Synchronize.
+// The volume is created if it does not exist yet, and kept read-only, also across
+// restarts, so the local master does not assign writes into it. The synchronization
+// still writes into the read-only volume.
+type MirrorTask struct {
+	v      *Volume
+	s      *Store
+	source string
+}
+
+func NewMirrorTask(s *Store, args url.Values) (*MirrorTask, error) {
+	volumeIdString := args.Get("volume")
+	vid, err := NewVolumeId(volumeIdString)
+	if err != nil {
+		return nil, fmt.Errorf("Volume Id %s is not a valid unsigned integer", volumeIdString)
+	}
+	source := args.Get("source")
+	if source == "" {
+		return nil, errors.New("Invalid source data node.")
+	}
+	collection := args.Get("collection")
+	v := s.findVolume(vid)
+	if v == nil {
+		ttl, err := ReadTTL(args.Get("ttl"))
+		if err != nil {
+			return nil, err
+		}
+		if err = s.addVolume(vid, collection, ttl, args.Get("tier")); err != nil {
+			return nil, err
+		}
+		v = s.findVolume(vid)
+		glog.V(0).Infof("created volume %d of collection %q to mirror %s", vid, collection, source)
+	} else if v.Collection != collection {
+		return nil, fmt.Errorf("volume %d is in collection %q, not %q", vid, v.Collection, collection)
+	}
+	if err = v.SetReadOnly(true); err != nil {
+		return nil, err
+	}
+	return &MirrorTask{v: v, s: s, source: source}, nil
+}
+
+func (t *MirrorTask) Run() error {
+	return t.v.Synchronize(t.source)
+}
+
+func (t *MirrorTask) Commit() error {
+	// report the new volume without waiting for the next heartbeat
+	t.s.SendHeartbeatToMaster(nil)
+	return nil
+}
+
+func (t *MirrorTask) Clean() error {
+	return nil
+}
+
+func (t *MirrorTask) Info() url.Values {
+	status := t.v.GetVolumeSyncStatus()
+	return url.Values{
+		"source":          {t.source},
+		"tailOffset":      {strconv.FormatUint(status.TailOffset, 10)},
+		"compactRevision": {strconv.Itoa(int(status.CompactRevision))},
+	}
+}
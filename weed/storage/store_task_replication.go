@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 
+	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
@@ -29,7 +30,7 @@ func NewReplicaTask(s *Store, args url.Values) (*ReplicaTask, error) {
 		return nil, errors.New("Invalid source data node.")
 
 	}
-	location := s.findFreeLocation()
+	location := s.findFreeLocation(args.Get("tier"))
 	if location == nil {
 		return nil, errors.New("No more free space left")
 	}
@@ -94,6 +95,14 @@ func (t *ReplicaTask) Commit() error {
 	return e
 }
 
+// deleteSource deletes the volume on the source data node, once the copy is loaded.
+// The extra copy is harmless if the deletion fails, it can be cleaned up later.
+func (t *ReplicaTask) deleteSource() {
+	if _, e := util.RemoteApiCall(t.SrcDataNode, "/admin/delete_volume", url.Values{"volume": {t.VID.String()}}); e != nil {
+		glog.V(0).Infof("delete volume %v on source %s error: %v", t.VID, t.SrcDataNode, e)
+	}
+}
+
 func (t *ReplicaTask) Clean() error {
 	os.Remove(t.FileName() + ".repx")
 	os.Remove(t.FileName() + ".repd")
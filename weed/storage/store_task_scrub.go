@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ScrubTask verifies the checksums of all the needles in a volume, reading
+// at most rateMB per second. The corrupted needles are rewritten with the
+// copies read from the other replicas, and reported to the master.
+type ScrubTask struct {
+	v        *Volume
+	s        *Store
+	replicas []string
+	rate     int64 // bytes per second, 0 for no limit
+
+	startTime time.Time
+	bytesRead int64
+
+	mutex     sync.Mutex
+	checked   int
+	corrupted []*corruptedNeedle
+	repaired  []*FileId
+}
+
+type corruptedNeedle struct {
+	fid    *FileId
+	offset uint32
+}
+
+func NewScrubTask(s *Store, args url.Values) (*ScrubTask, error) {
+	volumeIdString := args.Get("volume")
+	vid, err := NewVolumeId(volumeIdString)
+	if err != nil {
+		return nil, fmt.Errorf("Volume Id %s is not a valid unsigned integer", volumeIdString)
+	}
+	v := s.findVolume(vid)
+	if v == nil {
+		return nil, fmt.Errorf("volume id %d is not found", vid)
+	}
+	t := &ScrubTask{v: v, s: s}
+	if replicas := args.Get("replicas"); replicas != "" {
+		t.replicas = strings.Split(replicas, ",")
+	}
+	if rateMB := args.Get("rateMB"); rateMB != "" {
+		mb, err := strconv.Atoi(rateMB)
+		if err != nil || mb < 0 {
+			return nil, fmt.Errorf("invalid rateMB %s", rateMB)
+		}
+		t.rate = int64(mb) * 1024 * 1024
+	}
+	return t, nil
+}
+
+func (t *ScrubTask) Run() error {
+	t.startTime = time.Now()
+	checked := make(map[uint64]bool)
+	// scan the data file sequentially, and verify the needles still in the index
+	err := ScanVolumeFile(t.v.dir, t.v.Collection, t.v.Id, t.v.needleMapKind,
+		func(SuperBlock) error { return nil }, false, func(n *Needle, offset int64) error {
+			nv, ok := t.v.nm.Get(n.Id)
+			if !ok || int64(nv.Offset)*NeedlePaddingSize != offset || nv.Size != n.Size || checked[n.Id] {
+				return nil
+			}
+			checked[n.Id] = true
+			t.verify(n.Id)
+			return nil
+		})
+	if err != nil {
+		glog.V(0).Infof("scrub volume %d: %v", t.v.Id, err)
+	}
+	// a corrupted needle header can make the scan miss the needles after it
+	indexContent, err := t.v.nm.IndexFileContent()
+	if err != nil {
+		return fmt.Errorf("read volume %d index: %v", t.v.Id, err)
+	}
+	for i := 0; i+16 <= len(indexContent); i += 16 {
+		key, _, _ := idxFileEntry(indexContent[i : i+16])
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+		t.verify(key)
+	}
+
+	t.mutex.Lock()
+	corrupted := t.corrupted
+	t.mutex.Unlock()
+	repaired := 0
+	for _, c := range corrupted {
+		if err := t.repair(c); err != nil {
+			glog.V(0).Infof("scrub volume %d: repair %s: %v", t.v.Id, c.fid, err)
+			continue
+		}
+		glog.V(0).Infof("scrub volume %d: repaired %s", t.v.Id, c.fid)
+		repaired++
+		t.mutex.Lock()
+		t.repaired = append(t.repaired, c.fid)
+		t.mutex.Unlock()
+	}
+	t.report()
+	if repaired < len(corrupted) {
+		return fmt.Errorf("volume %d has %d corrupted needles, %d repaired", t.v.Id, len(corrupted), repaired)
+	}
+	return nil
+}
+
+// verify reads the needle with its current index entry, and records it if the checksum does not match
+func (t *ScrubTask) verify(key uint64) {
+	n := new(Needle)
+	t.v.mutex.RLock()
+	nv, ok := t.v.nm.Get(key)
+	if !ok || nv.Offset == 0 || nv.Size == 0 {
+		t.v.mutex.RUnlock()
+		return
+	}
+	err := n.ReadData(t.v.dataFile, int64(nv.Offset)*NeedlePaddingSize, nv.Size, t.v.Version())
+	t.v.mutex.RUnlock()
+	if err == nil && n.Id != key {
+		err = fmt.Errorf("needle id %x does not match the index", n.Id)
+	}
+	t.mutex.Lock()
+	t.checked++
+	if err != nil {
+		fid := NewFileId(t.v.Id, key, n.Cookie)
+		glog.V(0).Infof("scrub volume %d: needle %s at offset %d is corrupted: %v", t.v.Id, fid, int64(nv.Offset)*NeedlePaddingSize, err)
+		t.corrupted = append(t.corrupted, &corruptedNeedle{fid: fid, offset: nv.Offset})
+	}
+	t.mutex.Unlock()
+	t.throttle(int64(nv.Size))
+}
+
+// throttle sleeps to keep the reading under the rate limit
+func (t *ScrubTask) throttle(size int64) {
+	if t.rate <= 0 {
+		return
+	}
+	t.bytesRead += size
+	expected := time.Duration(float64(t.bytesRead) / float64(t.rate) * float64(time.Second))
+	if d := expected - time.Since(t.startTime); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// repair rewrites the needle with the first healthy copy on the other replicas
+func (t *ScrubTask) repair(c *corruptedNeedle) error {
+	replicas, err := t.replicaServers()
+	if err != nil {
+		return err
+	}
+	if len(replicas) == 0 {
+		return fmt.Errorf("no other replica")
+	}
+	for _, server := range replicas {
+		n, err := readNeedleFromServer(server, c.fid)
+		if err != nil {
+			glog.V(1).Infof("read %s from %s: %v", c.fid, server, err)
+			continue
+		}
+		if nv, ok := t.v.nm.Get(c.fid.Key); !ok || nv.Offset != c.offset {
+			// deleted or overwritten in the meantime
+			return nil
+		}
+		_, err = t.v.write(n)
+		return err
+	}
+	return fmt.Errorf("no healthy copy in %v", replicas)
+}
+
+// replicaServers are given by the master, or looked up if the task is started manually
+func (t *ScrubTask) replicaServers() ([]string, error) {
+	if t.replicas != nil {
+		return t.replicas, nil
+	}
+	if t.s == nil {
+		return nil, nil
+	}
+	lookupResult, err := operation.Lookup(t.s.GetMaster(), t.v.Id.String(), t.v.Collection)
+	if err != nil {
+		return nil, err
+	}
+	self := net.JoinHostPort(t.s.GetIP(), strconv.Itoa(t.s.Port))
+	var replicas []string
+	for _, loc := range lookupResult.Locations {
+		if loc.Url != self {
+			replicas = append(replicas, loc.Url)
+		}
+	}
+	return replicas, nil
+}
+
+// report sends the corrupted needles to the master, which keeps them for the operators
+func (t *ScrubTask) report() {
+	if t.s == nil || t.s.GetMaster() == "" {
+		return
+	}
+	t.mutex.Lock()
+	args := url.Values{
+		"volume":     {t.v.Id.String()},
+		"collection": {t.v.Collection},
+		"url":        {net.JoinHostPort(t.s.GetIP(), strconv.Itoa(t.s.Port))},
+		"checked":    {strconv.Itoa(t.checked)},
+	}
+	for _, c := range t.corrupted {
+		args.Add("corrupted", c.fid.String())
+	}
+	for _, fid := range t.repaired {
+		args.Add("repaired", fid.String())
+	}
+	t.mutex.Unlock()
+	if _, err := util.Post(t.s.GetMaster(), "/vol/scrub/report", args); err != nil {
+		glog.V(0).Infof("scrub volume %d: report to master %s: %v", t.v.Id, t.s.GetMaster(), err)
+	}
+}
+
+func (t *ScrubTask) Commit() error {
+	return nil
+}
+
+func (t *ScrubTask) Clean() error {
+	return nil
+}
+
+func (t *ScrubTask) Info() url.Values {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return url.Values{
+		"checked":   {strconv.Itoa(t.checked)},
+		"corrupted": {strconv.Itoa(len(t.corrupted))},
+		"repaired":  {strconv.Itoa(len(t.repaired))},
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestScrubTaskRepairsCorruptedNeedle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scrub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, sub := range []string{"a", "b"} {
+		if err = os.Mkdir(dir+"/"+sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := NewVolume(dir+"/a", "", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	replica, err := NewVolume(dir+"/b", "", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	for id := uint64(1); id <= 3; id++ {
+		for _, vol := range []*Volume{v, replica} {
+			n := &Needle{Id: id, Cookie: 0x5678, Data: []byte(fmt.Sprintf("some data of needle %d", id))}
+			n.Checksum = NewCRC(n.Data)
+			if _, err = vol.write(n); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// serves /admin/sync/needle like the volume server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fid, err := NewFileIdFromNid(r.FormValue("volume"), r.FormValue("nid"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n := &Needle{Id: fid.Key}
+		if _, err = replica.readNeedle(n); err != nil || n.Cookie != fid.Cookie {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Seaweed-Flags", strconv.FormatInt(int64(n.Flags), 16))
+		w.Header().Set("Seaweed-Checksum", strconv.FormatInt(int64(n.Checksum), 16))
+		w.Write(n.Data)
+	}))
+	defer ts.Close()
+
+	// flip a byte in the data of needle 2
+	nv, _ := v.nm.Get(2)
+	f, err := os.OpenFile(v.FileName()+".dat", os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt([]byte{'X'}, int64(nv.Offset)*NeedlePaddingSize+NeedleHeaderSize+6); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err = v.readNeedle(&Needle{Id: 2}); err == nil {
+		t.Fatal("expected CRC error reading the corrupted needle")
+	}
+
+	task := &ScrubTask{v: v, replicas: []string{strings.TrimPrefix(ts.URL, "http://")}}
+	if err = task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	info := task.Info()
+	if info.Get("checked") != "3" || info.Get("corrupted") != "1" || info.Get("repaired") != "1" {
+		t.Errorf("unexpected scrub result %v", info)
+	}
+	n := &Needle{Id: 2}
+	if _, err = v.readNeedle(n); err != nil || string(n.Data) != "some data of needle 2" {
+		t.Errorf("repaired needle: %q %v", n.Data, err)
+	}
+
+	// the scrub is clean after the repair, but can not repair without replicas
+	task = &ScrubTask{v: v, replicas: []string{}}
+	if err = task.Run(); err != nil || task.Info().Get("corrupted") != "0" {
+		t.Errorf("scrub after repair: %v %v", task.Info(), err)
+	}
+	nv, _ = v.nm.Get(3)
+	f, _ = os.OpenFile(v.FileName()+".dat", os.O_RDWR, 0644)
+	f.WriteAt([]byte{'X'}, int64(nv.Offset)*NeedlePaddingSize+NeedleHeaderSize+6)
+	f.Close()
+	task = &ScrubTask{v: v, replicas: []string{}}
+	if err = task.Run(); err == nil || task.Info().Get("corrupted") != "1" || task.Info().Get("repaired") != "0" {
+		t.Errorf("expected unrepaired needle: %v %v", task.Info(), err)
+	}
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// TierTask moves a volume to a location of another tier. The volume is
+// copied like a replica, from the source data node or from this one, and
+// the old copy is deleted only after the new copy is loaded.
+type TierTask struct {
+	*ReplicaTask
+	oldLocation *DiskLocation // the local copy to delete, nil if the source is another node
+}
+
+func NewTierTask(s *Store, args url.Values) (*TierTask, error) {
+	volumeIdString := args.Get("volume")
+	vid, err := NewVolumeId(volumeIdString)
+	if err != nil {
+		return nil, fmt.Errorf("Volume Id %s is not a valid unsigned integer", volumeIdString)
+	}
+	source := args.Get("source")
+	if source == "" {
+		return nil, errors.New("Invalid source data node.")
+	}
+	tier := args.Get("tier")
+	if tier == "" {
+		return nil, errors.New("Invalid tier.")
+	}
+	t := &TierTask{}
+	for _, location := range s.Locations {
+		if location.HasVolume(vid) {
+			t.oldLocation = location
+		}
+	}
+	self := net.JoinHostPort(s.GetIP(), strconv.Itoa(s.Port))
+	if t.oldLocation != nil && source != self {
+		return nil, fmt.Errorf("volume %d already exists", vid)
+	}
+	if t.oldLocation == nil && source == self {
+		return nil, fmt.Errorf("volume id %d is not found", vid)
+	}
+	if t.oldLocation != nil && t.oldLocation.Tier == tier {
+		return nil, fmt.Errorf("volume %d is already on tier %s", vid, tier)
+	}
+	location := s.findFreeLocation(tier)
+	if location == nil {
+		return nil, fmt.Errorf("No more free space left on tier %s", tier)
+	}
+	t.ReplicaTask = &ReplicaTask{
+		VID:         vid,
+		Collection:  args.Get("collection"),
+		SrcDataNode: source,
+		s:           s,
+		location:    location,
+	}
+	return t, nil
+}
+
+func (t *TierTask) Commit() error {
+	if e := t.ReplicaTask.Commit(); e != nil {
+		return e
+	}
+	// the moved volume is still sealed
+	if v, ok := t.location.GetVolume(t.VID); ok {
+		v.SetReadOnly(true)
+	}
+	if t.oldLocation != nil {
+		if e := t.oldLocation.DeleteVolume(t.VID); e != nil {
+			glog.V(0).Infof("delete volume %v in %s error: %v", t.VID, t.oldLocation.Directory, e)
+		}
+		t.s.SendHeartbeatToMaster(nil)
+		return nil
+	}
+	t.deleteSource()
+	return nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestParseDiskTier(t *testing.T) {
+	tests := []struct {
+		dirname, dir, tier string
+	}{
+		{"/ssd:hot", "/ssd", "hot"},
+		{"/data/hdd", "/data/hdd", ""},
+		{"/data:2/hdd", "/data:2/hdd", ""},
+		{`C:\data`, `C:\data`, ""},
+		{"/data:", "/data", ""},
+	}
+	for _, tt := range tests {
+		if dir, tier := ParseDiskTier(tt.dirname); dir != tt.dir || tier != tt.tier {
+			t.Errorf("%s: expect %s %s, got %s %s", tt.dirname, tt.dir, tt.tier, dir, tier)
+		}
+	}
+}
+
+func TestTierTaskMovesVolumeOnSameNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, sub := range []string{"ssd", "hdd"} {
+		if err = os.Mkdir(dir+"/"+sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var s *Store
+	// serves the volume files like the volume server
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vid, _ := NewVolumeId(r.FormValue("volume"))
+		v, ok := s.Locations[0].GetVolume(vid)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Path {
+		case "/admin/sync/index":
+			content, _ := v.IndexFileContent()
+			w.Write(content)
+		case "/admin/sync/vol_data":
+			http.ServeFile(w, r, v.FileName()+".dat")
+		}
+	}))
+	defer ts.Close()
+	host, portString, _ := net.SplitHostPort(ts.URL[len("http://"):])
+	port, _ := strconv.Atoi(portString)
+
+	s = NewStore(port, host, "", []string{dir + "/ssd:hot", dir + "/hdd:cold"}, []int{2, 2}, NeedleMapInMemory)
+	defer s.Close()
+	s.SetBootstrapMaster("127.0.0.1:1")
+	if s.Locations[0].Directory != dir+"/ssd" || s.Locations[1].Tier != "cold" {
+		t.Fatalf("unexpected locations %+v %+v", s.Locations[0], s.Locations[1])
+	}
+	if err = s.AddVolume("1", "", "", "warm"); err == nil {
+		t.Errorf("expect no free space on the warm tier")
+	}
+	if err = s.AddVolume("1", "", "", "hot"); err != nil {
+		t.Fatal(err)
+	}
+	n := &Needle{Id: 1, Cookie: 0x1234, Data: []byte("cold data")}
+	n.Checksum = NewCRC(n.Data)
+	if _, err = s.Write(1, n); err != nil {
+		t.Fatal(err)
+	}
+	s.findVolume(1).SetReadOnly(true)
+
+	args := url.Values{"volume": {"1"}, "source": {net.JoinHostPort(host, portString)}, "tier": {"hot"}}
+	if _, err = NewTierTask(s, args); err == nil {
+		t.Errorf("expect the volume is already on the tier")
+	}
+	args.Set("tier", "cold")
+	task, err := NewTierTask(s, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err = task.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Locations[0].HasVolume(1) || !s.Locations[1].HasVolume(1) {
+		t.Fatalf("volume is not moved to the cold tier")
+	}
+	if !s.findVolume(1).IsReadOnly() {
+		t.Errorf("moved volume should stay read only")
+	}
+	read, err := s.ReadLocalNeedle(NewFileId(1, 1, 0x1234))
+	if err != nil || string(read.Data) != "cold data" {
+		t.Errorf("read moved needle: %v %v", read, err)
+	}
+}
@@ -5,15 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 type Volume struct {
+	readCount     uint64 // accessed atomically, keep it first for the 64-bit alignment
 	Id            VolumeId
 	dir           string
 	Collection    string
@@ -21,6 +25,9 @@ type Volume struct {
 	nm            NeedleMapper
 	needleMapKind NeedleMapType
 	readOnly      bool
+	keys          KeyProvider
+
+	trashRetention time.Duration
 
 	SuperBlock
 
@@ -133,6 +140,10 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 			}
 		}
 	}
+	if _, err := os.Stat(fileName + ".readonly"); err == nil {
+		// sealed by SetReadOnly, the files are still opened for the synchronization
+		v.readOnly = true
+	}
 	return e
 }
 func (v *Volume) Version() Version {
@@ -167,6 +178,9 @@ func (v *Volume) isFileUnchanged(n *Needle) bool {
 	if ok && nv.Offset > 0 {
 		oldNeedle := new(Needle)
 		err := oldNeedle.ReadData(v.dataFile, int64(nv.Offset)*NeedlePaddingSize, nv.Size, v.Version())
+		if err == nil {
+			err = oldNeedle.Decrypt(v.keys, v.Collection)
+		}
 		if err != nil {
 			glog.V(0).Infof("Failed to check updated file %v", err)
 			return false
@@ -190,18 +204,17 @@ func (v *Volume) Destroy() (err error) {
 	if err != nil {
 		return
 	}
+	v.destroySnapshots()
+	os.Remove(v.trashFileName())
 	err = v.nm.Destroy()
 	return
 }
 
-// AppendBlob append a blob to end of the data file, used in replication
+// AppendBlob append a blob to end of the data file, used in replication.
+// The synchronization also appends to the read-only volumes, like the mirrors.
 func (v *Volume) AppendBlob(b []byte) (offset int64, err error) {
 	v.mutex.Lock()
 	defer v.mutex.Unlock()
-	if v.readOnly {
-		err = fmt.Errorf("%s is read-only", v.dataFile.Name())
-		return
-	}
 	if offset, err = v.dataFile.Seek(0, 2); err != nil {
 		glog.V(0).Infof("failed to seek the end of file: %v", err)
 		return
@@ -246,7 +259,86 @@ func (v *Volume) write(n *Needle) (size uint32, err error) {
 		}
 	}
 
-	if size, err = n.Append(v.dataFile, v.Version()); err != nil {
+	stored, err := v.encrypted(n)
+	if err != nil {
+		return
+	}
+	if size, err = stored.Append(v.dataFile, v.Version()); err != nil {
+		if e := v.dataFile.Truncate(offset); e != nil {
+			err = fmt.Errorf("%s\ncannot truncate %s: %v", err, v.dataFile.Name(), e)
+		}
+		return
+	}
+	if stored != n {
+		size = uint32(len(n.Data))
+	}
+	nv, ok := v.nm.Get(n.Id)
+	if !ok || int64(nv.Offset)*NeedlePaddingSize < offset {
+		if err = v.nm.Put(n.Id, uint32(offset/NeedlePaddingSize), stored.Size); err != nil {
+			glog.V(4).Infof("failed to save in needle map %d: %v", n.Id, err)
+		}
+	}
+	if v.lastModifiedTime < n.LastModified {
+		v.lastModifiedTime = n.LastModified
+	}
+	return
+}
+
+// writeStream appends the needle with its data read from the stream, without loading it into memory.
+// The stream is first spooled into a file next to the volume, so that the volume is only locked
+// to copy the file, not while a slow client sends the data. For encrypted collections the data
+// is read into the needle and written by write, since it is sealed as a whole.
+func (v *Volume) writeStream(n *Needle, data io.Reader) (size uint32, err error) {
+	if v.keys != nil {
+		if keyId, _, e := v.keys.CurrentKey(v.Collection); e != nil || keyId != 0 {
+			if n.Data, err = ioutil.ReadAll(data); err != nil {
+				return
+			}
+			n.Checksum = NewCRC(n.Data)
+			return v.write(n)
+		}
+	}
+	if v.IsReadOnly() {
+		return 0, fmt.Errorf("%s is read-only", v.dataFile.Name())
+	}
+	spool, err := ioutil.TempFile(v.dir, v.Id.String()+".stream")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	if _, err = io.Copy(spool, io.LimitReader(data, maxStreamDataSize+1)); err != nil {
+		return
+	}
+	if _, err = spool.Seek(0, 0); err != nil {
+		return
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	glog.V(4).Infof("streaming needle %s", NewFileIdFromNeedle(v.Id, n).String())
+	if v.readOnly {
+		err = fmt.Errorf("%s is read-only", v.dataFile.Name())
+		return
+	}
+	var offset int64
+	if offset, err = v.dataFile.Seek(0, 2); err != nil {
+		glog.V(0).Infof("failed to seek the end of file: %v", err)
+		return
+	}
+
+	//ensure file writing starting from aligned positions
+	if offset%NeedlePaddingSize != 0 {
+		offset = offset + (NeedlePaddingSize - offset%NeedlePaddingSize)
+		if offset, err = v.dataFile.Seek(offset, 0); err != nil {
+			glog.V(0).Infof("failed to align in datafile %s: %v", v.dataFile.Name(), err)
+			return
+		}
+	}
+
+	if size, err = n.AppendStream(v.dataFile, spool, v.Version()); err != nil {
 		if e := v.dataFile.Truncate(offset); e != nil {
 			err = fmt.Errorf("%s\ncannot truncate %s: %v", err, v.dataFile.Name(), e)
 		}
@@ -264,20 +356,52 @@ func (v *Volume) write(n *Needle) (size uint32, err error) {
 	return
 }
 
+// dataReader reads the data of the stored needle from the data file, without loading it into memory.
+// The needle should not be encrypted.
+func (v *Volume) dataReader(n *Needle) (io.Reader, error) {
+	nv, ok := v.nm.Get(n.Id)
+	if !ok || nv.Offset == 0 {
+		return nil, errors.New("Not Found")
+	}
+	offset, size := int64(nv.Offset)*NeedlePaddingSize+NeedleHeaderSize, int64(nv.Size)
+	if v.Version() == Version2 && nv.Size > 0 {
+		b := make([]byte, 4)
+		v.mutex.RLock()
+		_, err := v.dataFile.ReadAt(b, offset)
+		v.mutex.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		offset, size = offset+4, int64(util.BytesToUint32(b))
+	}
+	return io.NewSectionReader(v.dataFile, offset, size), nil
+}
+
 func (v *Volume) delete(n *Needle) (uint32, error) {
 	v.mutex.Lock()
 	defer v.mutex.Unlock()
-	glog.V(4).Infof("delete needle %s", NewFileIdFromNeedle(v.Id, n).String())
 	if v.readOnly {
 		return 0, fmt.Errorf("%s is read-only", v.dataFile.Name())
 	}
+	return v.deleteNeedle(n)
+}
+
+// deleteNeedle appends the deletion of the needle, with the lock held and
+// whether the volume is read-only or not
+func (v *Volume) deleteNeedle(n *Needle) (uint32, error) {
+	glog.V(4).Infof("delete needle %s", NewFileIdFromNeedle(v.Id, n).String())
 	nv, ok := v.nm.Get(n.Id)
 	//fmt.Println("key", n.Id, "volume offset", nv.Offset, "data_size", n.Size, "cached size", nv.Size)
 	if ok {
-		size := nv.Size
+		size, offset := nv.Size, nv.Offset
 		if err := v.nm.Delete(n.Id); err != nil {
 			return size, err
 		}
+		if v.trashRetention > 0 && offset > 0 && size > 0 {
+			if err := v.appendTrash(&TrashEntry{Key: n.Id, Offset: offset, Size: size, DeletedAt: time.Now().Unix()}); err != nil {
+				glog.V(0).Infof("trash needle %s: %v", NewFileIdFromNeedle(v.Id, n).String(), err)
+			}
+		}
 		if _, err := v.dataFile.Seek(0, 2); err != nil {
 			return size, err
 		}
@@ -288,6 +412,23 @@ func (v *Volume) delete(n *Needle) (uint32, error) {
 	return 0, nil
 }
 
+// encrypted returns a copy of the needle encrypted with the current key of the collection,
+// or the needle itself if the collection is not encrypted
+func (v *Volume) encrypted(n *Needle) (*Needle, error) {
+	if v.keys == nil || len(n.Data) == 0 {
+		return n, nil
+	}
+	keyId, key, err := v.keys.CurrentKey(v.Collection)
+	if err != nil || keyId == 0 {
+		return n, err
+	}
+	stored := *n
+	if err = stored.Encrypt(keyId, key); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
 // read fills in Needle content by looking up n.Id from NeedleMapper
 func (v *Volume) readNeedle(n *Needle) (int, error) {
 	nv, ok := v.nm.Get(n.Id)
@@ -297,6 +438,9 @@ func (v *Volume) readNeedle(n *Needle) (int, error) {
 	v.mutex.RLock()
 	err := n.ReadData(v.dataFile, int64(nv.Offset)*NeedlePaddingSize, nv.Size, v.Version())
 	v.mutex.RUnlock()
+	if err == nil {
+		err = n.Decrypt(v.keys, v.Collection)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -441,9 +585,12 @@ func (v *Volume) expiredLongEnough(maxDelayMinutes uint32) bool {
 	return false
 }
 
+// SetReadOnly seals the volume, or opens it for writes again. The state is kept
+// across restarts by the file .readonly next to the .dat file.
 func (v *Volume) SetReadOnly(isReadOnly bool) error {
 	v.mutex.Lock()
 	defer v.mutex.Unlock()
+	marker := v.FileName() + ".readonly"
 	if isReadOnly == false {
 		if fi, e := v.dataFile.Stat(); e != nil {
 			return e
@@ -452,6 +599,13 @@ func (v *Volume) SetReadOnly(isReadOnly bool) error {
 				return errors.New(v.FileName() + ".dat is READONLY")
 			}
 		}
+		if e := os.Remove(marker); e != nil && !os.IsNotExist(e) {
+			return e
+		}
+	} else if !v.readOnly {
+		if e := ioutil.WriteFile(marker, nil, 0644); e != nil {
+			return e
+		}
 	}
 	v.readOnly = isReadOnly
 	return nil
@@ -462,3 +616,19 @@ func (v *Volume) IsReadOnly() bool {
 	defer v.mutex.RUnlock()
 	return v.readOnly
 }
+
+func (v *Volume) countRead() {
+	atomic.AddUint64(&v.readCount, 1)
+}
+
+// ReadCount is the number of needle reads since the volume is loaded
+func (v *Volume) ReadCount() uint64 {
+	return atomic.LoadUint64(&v.readCount)
+}
+
+// LastModified is the unix time in seconds of the last write
+func (v *Volume) LastModified() uint64 {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.lastModifiedTime
+}
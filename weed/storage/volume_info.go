@@ -17,6 +17,9 @@ type VolumeInfo struct {
 	DeleteCount      int
 	DeletedByteCount uint64
 	ReadOnly         bool
+	Tier             string
+	LastModified     uint64
+	ReadCount        uint64
 }
 
 func NewVolumeInfo(m *weedpb.VolumeInformationMessage) (vi *VolumeInfo, err error) {
@@ -29,6 +32,9 @@ func NewVolumeInfo(m *weedpb.VolumeInformationMessage) (vi *VolumeInfo, err erro
 		DeletedByteCount: m.DeletedByteCount,
 		ReadOnly:         m.ReadOnly,
 		Version:          Version(m.Version),
+		Tier:             m.Tier,
+		LastModified:     m.LastModified,
+		ReadCount:        m.ReadCount,
 	}
 	vi.Ttl = LoadTTLFromUint32(m.Ttl)
 	return vi, nil
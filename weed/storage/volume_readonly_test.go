@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVolumeReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := NewVolume(dir, "", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &Needle{Id: 1, Cookie: 0x1234, Data: []byte("mirrored content")}
+	n.Checksum = NewCRC(n.Data)
+	if _, err = v.write(n); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.SetReadOnly(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v.write(&Needle{Id: 2, Cookie: 0x1234, Data: []byte("x")}); err == nil {
+		t.Errorf("the read-only volume should not be written")
+	}
+	if _, err = v.delete(&Needle{Id: 1}); err == nil {
+		t.Errorf("the read-only volume should not be deleted from")
+	}
+	// the synchronization still removes the needles
+	v.removeNeedle(1)
+	if _, err = v.readNeedle(&Needle{Id: 1}); err == nil {
+		t.Errorf("the removed needle is still readable")
+	}
+	v.Close()
+
+	if v, err = NewVolume(dir, "", 1, NeedleMapInMemory, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !v.IsReadOnly() {
+		t.Errorf("the volume is writable again after reloading")
+	}
+	if err = v.SetReadOnly(false); err != nil {
+		t.Fatal(err)
+	}
+	v.Close()
+	if v, err = NewVolume(dir, "", 1, NeedleMapInMemory, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	if v.IsReadOnly() {
+		t.Errorf("the volume is still read-only after reloading")
+	}
+}
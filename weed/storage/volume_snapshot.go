@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// VolumeSnapshot is the state of a volume at a point of time.
+// Volumes are append only, so the state is well defined by the length of the
+// .dat and .idx files, as long as the volume is not compacted, which is
+// tracked by the CompactRevision.
+// The index of the snapshot is also frozen into its own file, which is
+// rewritten by each compaction, so the needles it refers to survive vacuum
+// until the snapshot is released.
+type VolumeSnapshot struct {
+	Name            string
+	DatSize         int64
+	IdxSize         int64
+	CompactRevision uint16
+	FileCount       int
+	Created         time.Time
+}
+
+var snapshotNameRegexp = regexp.MustCompile(`^[\w.-]+$`)
+
+func (v *Volume) snapshotsFileName() string {
+	return v.FileName() + ".snp"
+}
+
+func (v *Volume) snapshotIndexFileName(name string) string {
+	return v.FileName() + "." + name + ".snx"
+}
+
+func (v *Volume) loadSnapshots() (snapshots []*VolumeSnapshot, err error) {
+	b, err := ioutil.ReadFile(v.snapshotsFileName())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &snapshots)
+	return
+}
+
+func (v *Volume) saveSnapshots(snapshots []*VolumeSnapshot) error {
+	if len(snapshots) == 0 {
+		if err := os.Remove(v.snapshotsFileName()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+	tmp := v.snapshotsFileName() + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.snapshotsFileName())
+}
+
+// loadSnapshotNeedleMap loads the frozen index of the snapshot.
+// The caller closes it.
+func (v *Volume) loadSnapshotNeedleMap(name string) (*NeedleMap, error) {
+	f, err := os.OpenFile(v.snapshotIndexFileName(name), os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	nm, err := LoadNeedleMap(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return nm, nil
+}
+
+func (v *Volume) Snapshots() ([]*VolumeSnapshot, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.loadSnapshots()
+}
+
+func (v *Volume) CreateSnapshot(name string) (*VolumeSnapshot, error) {
+	if !snapshotNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid snapshot name %q", name)
+	}
+	// writes are blocked, so the data and the index files are consistent
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if _, err := os.Stat(v.FileName() + ".cpd"); err == nil {
+		return nil, fmt.Errorf("volume %d is being compacted", v.Id)
+	}
+	snapshots, err := v.loadSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.Name == name {
+			return nil, fmt.Errorf("snapshot %s of volume %d already exists", name, v.Id)
+		}
+	}
+	stat, err := v.dataFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &VolumeSnapshot{
+		Name:            name,
+		DatSize:         stat.Size(),
+		IdxSize:         int64(v.nm.IndexFileSize()),
+		CompactRevision: v.SuperBlock.CompactRevision,
+		Created:         time.Now(),
+	}
+	if err = copyFilePrefix(v.nm.IndexFileName(), v.snapshotIndexFileName(name), snapshot.IdxSize); err != nil {
+		os.Remove(v.snapshotIndexFileName(name))
+		return nil, err
+	}
+	nm, err := v.loadSnapshotNeedleMap(name)
+	if err != nil {
+		os.Remove(v.snapshotIndexFileName(name))
+		return nil, err
+	}
+	snapshot.FileCount = nm.FileCount() - nm.DeletedCount()
+	nm.Close()
+	if err = v.saveSnapshots(append(snapshots, snapshot)); err != nil {
+		os.Remove(v.snapshotIndexFileName(name))
+		return nil, err
+	}
+	glog.V(0).Infof("created snapshot %s of volume %d at offset %d", name, v.Id, snapshot.DatSize)
+	return snapshot, nil
+}
+
+// ReleaseSnapshot forgets the snapshot, and its needles are
+// removed by the next compaction if they are deleted.
+func (v *Volume) ReleaseSnapshot(name string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	snapshots, err := v.loadSnapshots()
+	if err != nil {
+		return err
+	}
+	for i, s := range snapshots {
+		if s.Name == name {
+			if err = v.saveSnapshots(append(snapshots[:i], snapshots[i+1:]...)); err != nil {
+				return err
+			}
+			os.Remove(v.snapshotIndexFileName(name))
+			os.Remove(v.snapshotIndexFileName(name) + ".cps")
+			glog.V(0).Infof("released snapshot %s of volume %d", name, v.Id)
+			return nil
+		}
+	}
+	return fmt.Errorf("snapshot %s of volume %d is not found", name, v.Id)
+}
+
+// RestoreSnapshot rebuilds the volume as of the snapshot, by compacting it
+// with the needles of the snapshot as the live ones.
+// Files written after the snapshot are dropped, unless other snapshots keep them,
+// and the snapshot itself is retained until released.
+func (v *Volume) RestoreSnapshot(name string) error {
+	if _, err := os.Stat(v.snapshotIndexFileName(name)); err != nil {
+		return fmt.Errorf("snapshot %s of volume %d is not found", name, v.Id)
+	}
+	nm, err := v.loadSnapshotNeedleMap(name)
+	if err != nil {
+		return err
+	}
+	defer nm.Close()
+	filePath := v.FileName()
+	if err = v.copyNeedles(filePath+".cpd", filePath+".cpx", nm); err != nil {
+		v.cleanCompact()
+		return err
+	}
+	if err = v.commitCompact(); err != nil {
+		v.cleanCompact()
+		return err
+	}
+	glog.V(0).Infof("restored volume %d to snapshot %s", v.Id, name)
+	return nil
+}
+
+func (v *Volume) destroySnapshots() {
+	snapshots, _ := v.loadSnapshots()
+	for _, s := range snapshots {
+		os.Remove(v.snapshotIndexFileName(s.Name))
+	}
+	os.Remove(v.snapshotsFileName())
+}
+
+func copyFilePrefix(srcName, dstName string, size int64) error {
+	src, err := os.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dstName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.CopyN(dst, src, size); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// commitSnapshotIndexes replaces the snapshot indexes with
+// the ones rewritten for the compacted data file.
+func (v *Volume) commitSnapshotIndexes() error {
+	snapshots, err := v.loadSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		if err = os.Rename(v.snapshotIndexFileName(s.Name)+".cps", v.snapshotIndexFileName(s.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
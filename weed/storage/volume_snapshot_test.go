@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVolumeSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := NewVolume(dir, "pics", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	write := func(id uint64, content string) {
+		n := &Needle{Id: id, Cookie: 0x1234, Data: []byte(content)}
+		n.Checksum = NewCRC(n.Data)
+		if _, err := v.write(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(id uint64) string {
+		n := &Needle{Id: id}
+		if _, err := v.readNeedle(n); err != nil {
+			return ""
+		}
+		return string(n.Data)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		write(i, fmt.Sprintf("file %d", i))
+	}
+
+	snapshot, err := v.CreateSnapshot("daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.FileCount != 3 || snapshot.CompactRevision != 0 || snapshot.DatSize <= SuperBlockSize {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	if _, err = v.CreateSnapshot("daily"); err == nil {
+		t.Errorf("duplicated snapshot name should fail")
+	}
+	if _, err = v.CreateSnapshot("../daily"); err == nil {
+		t.Errorf("invalid snapshot name should fail")
+	}
+
+	// the bulk accident after the snapshot
+	for i := uint64(1); i <= 3; i++ {
+		if _, err = v.delete(&Needle{Id: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(2, "file 2 overwritten")
+	write(4, "file 4")
+
+	if err = v.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.commitCompact(); err != nil {
+		t.Fatal(err)
+	}
+	if read(1) != "" || read(2) != "file 2 overwritten" || read(4) != "file 4" {
+		t.Fatalf("unexpected files after vacuum: %q %q %q", read(1), read(2), read(4))
+	}
+	dat, _ := ioutil.ReadFile(v.FileName() + ".dat")
+	if !bytes.Contains(dat, []byte("file 1")) {
+		t.Fatalf("needles of the snapshot are removed by vacuum")
+	}
+
+	if err = v.RestoreSnapshot("daily"); err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if content := read(i); content != fmt.Sprintf("file %d", i) {
+			t.Errorf("file %d restored as %q", i, content)
+		}
+	}
+	if read(4) != "" {
+		t.Errorf("file written after the snapshot should be dropped")
+	}
+	if v.SuperBlock.CompactRevision != 2 {
+		t.Errorf("expect compact revision 2, got %d", v.SuperBlock.CompactRevision)
+	}
+
+	// released, the deleted needles are gone with the next vacuum
+	if err = v.ReleaseSnapshot("daily"); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, _ := v.Snapshots(); len(snapshots) != 0 {
+		t.Errorf("released snapshot is still listed: %+v", snapshots)
+	}
+	if _, err = v.delete(&Needle{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.commitCompact(); err != nil {
+		t.Fatal(err)
+	}
+	dat, _ = ioutil.ReadFile(v.FileName() + ".dat")
+	if bytes.Contains(dat, []byte("file 1")) || bytes.Contains(dat, []byte("overwritten")) {
+		t.Errorf("needles of the released snapshot are kept")
+	}
+	if read(2) != "file 2" || read(3) != "file 3" {
+		t.Errorf("live files are lost: %q %q", read(2), read(3))
+	}
+}
@@ -119,7 +119,7 @@ func (v *Volume) trySynchronizing(volumeServer string, masterMap *CompactMap, co
 
 	// make up the delta
 	fetchCount := 0
-	volumeDataContentHandlerUrl := "http://" + volumeServer + "/admin/sync/data"
+	volumeDataContentHandlerUrl := util.NormalizeUrl(volumeServer + "/admin/sync/data")
 	for _, needleValue := range delta {
 		if needleValue.Size == 0 {
 			// remove file entry from local
@@ -176,11 +176,13 @@ func (v *Volume) IndexFileContent() ([]byte, error) {
 	return v.nm.IndexFileContent()
 }
 
-// removeNeedle removes one needle by needle key
+// removeNeedle removes one needle by needle key, also from the read-only volumes
 func (v *Volume) removeNeedle(key Key) {
 	n := new(Needle)
 	n.Id = uint64(key)
-	v.delete(n)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.deleteNeedle(n)
 }
 
 // fetchNeedle fetches a remote volume needle by vid, id, offset
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// The deleted needles are logged into the .trh file when the collection has a trash retention.
+// Each row has the key, the offset and the size of the needle, and the deletion time.
+// A row with offset 0 means the needle is undeleted, or dropped by the vacuum.
+const trashRowSize = 24
+
+// TrashEntry is a deleted needle, with its data still in the volume.
+type TrashEntry struct {
+	Key       uint64
+	Offset    uint32
+	Size      uint32
+	DeletedAt int64
+	Cookie    uint32 // not logged, read from the needle when listed
+}
+
+func (v *Volume) trashFileName() string {
+	return v.FileName() + ".trh"
+}
+
+func (v *Volume) SetTrashRetention(retention time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.trashRetention = retention
+}
+
+func (v *Volume) TrashRetention() time.Duration {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.trashRetention
+}
+
+func appendTrashRow(w io.Writer, e *TrashEntry) error {
+	row := make([]byte, trashRowSize)
+	util.Uint64toBytes(row[0:8], e.Key)
+	util.Uint32toBytes(row[8:12], e.Offset)
+	util.Uint32toBytes(row[12:16], e.Size)
+	util.Uint64toBytes(row[16:24], uint64(e.DeletedAt))
+	_, err := w.Write(row)
+	return err
+}
+
+// appendTrash logs the trash row, the caller holds the volume lock
+func (v *Volume) appendTrash(e *TrashEntry) error {
+	f, err := os.OpenFile(v.trashFileName(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err = appendTrashRow(f, e); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadTrash replays the trash rows, the later rows of a key replace the earlier ones
+func (v *Volume) loadTrash() (map[uint64]*TrashEntry, error) {
+	trash := make(map[uint64]*TrashEntry)
+	f, err := os.Open(v.trashFileName())
+	if os.IsNotExist(err) {
+		return trash, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	row := make([]byte, trashRowSize)
+	for {
+		if _, err = io.ReadFull(f, row); err == io.EOF {
+			return trash, nil
+		} else if err != nil {
+			return nil, err
+		}
+		e := &TrashEntry{
+			Key:       util.BytesToUint64(row[0:8]),
+			Offset:    util.BytesToUint32(row[8:12]),
+			Size:      util.BytesToUint32(row[12:16]),
+			DeletedAt: int64(util.BytesToUint64(row[16:24])),
+		}
+		if e.Offset == 0 {
+			delete(trash, e.Key)
+		} else {
+			trash[e.Key] = e
+		}
+	}
+}
+
+// Trash lists the deleted needles which can be undeleted, in the order of deletion
+func (v *Volume) Trash() ([]*TrashEntry, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	trash, err := v.loadTrash()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*TrashEntry, 0, len(trash))
+	for _, e := range trash {
+		n, _, err := ReadNeedleHeader(v.dataFile, v.Version(), int64(e.Offset)*NeedlePaddingSize)
+		if err != nil {
+			return nil, err
+		}
+		e.Cookie = n.Cookie
+		entries = append(entries, e)
+	}
+	sort.Sort(trashEntries(entries))
+	return entries, nil
+}
+
+type trashEntries []*TrashEntry
+
+func (s trashEntries) Len() int      { return len(s) }
+func (s trashEntries) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s trashEntries) Less(i, j int) bool {
+	if s[i].DeletedAt != s[j].DeletedAt {
+		return s[i].DeletedAt < s[j].DeletedAt
+	}
+	return s[i].Key < s[j].Key
+}
+
+// Undelete makes the deleted needle live again, if it is still in the trash.
+// The cookie has to match the one of the needle.
+func (v *Volume) Undelete(n *Needle) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.readOnly {
+		return fmt.Errorf("%s is read-only", v.dataFile.Name())
+	}
+	if nv, ok := v.nm.Get(n.Id); ok && nv.Offset > 0 && nv.Size > 0 {
+		return fmt.Errorf("needle %x is not deleted", n.Id)
+	}
+	trash, err := v.loadTrash()
+	if err != nil {
+		return err
+	}
+	e, ok := trash[n.Id]
+	if !ok {
+		return errors.New("Not Found")
+	}
+	stored := &Needle{}
+	if err = stored.ReadData(v.dataFile, int64(e.Offset)*NeedlePaddingSize, e.Size, v.Version()); err != nil {
+		return err
+	}
+	if stored.Cookie != n.Cookie {
+		return fmt.Errorf("mismatching cookie %x", n.Cookie)
+	}
+	if err = v.nm.Put(n.Id, e.Offset, e.Size); err != nil {
+		return err
+	}
+	glog.V(2).Infof("undeleted needle %s", NewFileIdFromNeedle(v.Id, n).String())
+	return v.appendTrash(&TrashEntry{Key: n.Id})
+}
+
+// keptTrash returns the trash entries younger than the retention,
+// which the vacuum keeps, and the rest are removed for good.
+func (v *Volume) keptTrash(now time.Time) (map[uint64]*TrashEntry, error) {
+	retention := v.TrashRetention()
+	v.mutex.RLock()
+	trash, err := v.loadTrash()
+	v.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	for key, e := range trash {
+		if retention <= 0 || now.Sub(time.Unix(e.DeletedAt, 0)) >= retention {
+			delete(trash, key)
+		}
+	}
+	return trash, nil
+}
+
+// commitTrash replaces the trash file with the one rewritten for the compacted data file
+func (v *Volume) commitTrash() error {
+	stat, err := os.Stat(v.trashFileName() + ".cpt")
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if stat.Size() == 0 {
+		os.Remove(v.trashFileName() + ".cpt")
+		if err = os.Remove(v.trashFileName()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.Rename(v.trashFileName()+".cpt", v.trashFileName())
+}
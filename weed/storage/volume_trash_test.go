@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVolumeTrash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := NewVolume(dir, "pics", 1, NeedleMapInMemory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	v.SetTrashRetention(time.Hour)
+	for i := uint64(1); i <= 3; i++ {
+		n := &Needle{Id: i, Cookie: 0x1234, Data: []byte("trashed content")}
+		n.Checksum = NewCRC(n.Data)
+		if _, err = v.write(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(id uint64) bool {
+		_, err := v.readNeedle(&Needle{Id: id})
+		return err == nil
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if _, err = v.delete(&Needle{Id: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := v.Trash()
+	if err != nil || len(entries) != 3 || entries[0].Cookie != 0x1234 {
+		t.Fatalf("expect 3 trashed needles, got %+v %v", entries, err)
+	}
+
+	if err = v.Undelete(&Needle{Id: 1, Cookie: 0x4321}); err == nil {
+		t.Errorf("undelete with a wrong cookie should fail")
+	}
+	if err = v.Undelete(&Needle{Id: 1, Cookie: 0x1234}); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(1) {
+		t.Errorf("undeleted needle is not readable")
+	}
+	if err = v.Undelete(&Needle{Id: 1, Cookie: 0x1234}); err == nil {
+		t.Errorf("live needle should not be undeleted")
+	}
+
+	// the vacuum keeps the needles younger than the retention
+	if err = v.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.commitCompact(); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Undelete(&Needle{Id: 2, Cookie: 0x1234}); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(1) || !exists(2) || exists(3) {
+		t.Errorf("unexpected needles after vacuum: %v %v %v", exists(1), exists(2), exists(3))
+	}
+
+	// without the retention, the trash is emptied by the vacuum
+	v.SetTrashRetention(0)
+	if err = v.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.commitCompact(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ = v.Trash(); len(entries) != 0 {
+		t.Errorf("trash should be emptied: %+v", entries)
+	}
+	if err = v.Undelete(&Needle{Id: 3, Cookie: 0x1234}); err == nil {
+		t.Errorf("vacuumed needle should not be undeleted")
+	}
+	if _, err = os.Stat(v.trashFileName()); !os.IsNotExist(err) {
+		t.Errorf("empty trash file is left: %v", err)
+	}
+}
@@ -38,6 +38,12 @@ func (v *Volume) commitCompact() error {
 	if e = os.Rename(v.FileName()+".cpx", v.FileName()+".idx"); e != nil {
 		return e
 	}
+	if e = v.commitSnapshotIndexes(); e != nil {
+		return e
+	}
+	if e = v.commitTrash(); e != nil {
+		return e
+	}
 	//glog.V(3).Infof("Pretending to be vacuuming...")
 	//time.Sleep(20 * time.Second)
 	glog.V(3).Infof("Loading Commit file...")
@@ -51,10 +57,22 @@ func (v *Volume) commitCompact() error {
 func (v *Volume) cleanCompact() error {
 	os.Remove(v.FileName() + ".cpd")
 	os.Remove(v.FileName() + ".cpx")
+	os.Remove(v.trashFileName() + ".cpt")
+	snapshots, _ := v.loadSnapshots()
+	for _, s := range snapshots {
+		os.Remove(v.snapshotIndexFileName(s.Name) + ".cps")
+	}
 	return nil
 }
 
 func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string) (err error) {
+	return v.copyNeedles(dstName, idxName, v.nm)
+}
+
+// copyNeedles copies the needles live in the given needle map, and the ones
+// still referenced by the snapshots or kept in the trash, which get their
+// indexes rewritten into temporary files renamed by commitCompact.
+func (v *Volume) copyNeedles(dstName, idxName string, live NeedleMapper) (err error) {
 	var (
 		dst, idx *os.File
 	)
@@ -68,11 +86,52 @@ func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string) (err erro
 	}
 	defer idx.Close()
 
+	snapshots, err := v.loadSnapshots()
+	if err != nil {
+		return
+	}
+	var snapshotMaps, newSnapshotMaps []*NeedleMap
+	defer func() {
+		for _, m := range snapshotMaps {
+			m.Close()
+		}
+		for _, m := range newSnapshotMaps {
+			m.Close()
+		}
+	}()
+	for _, s := range snapshots {
+		var m *NeedleMap
+		if m, err = v.loadSnapshotNeedleMap(s.Name); err != nil {
+			return fmt.Errorf("cannot load snapshot %s: %s", s.Name, err)
+		}
+		snapshotMaps = append(snapshotMaps, m)
+		var f *os.File
+		if f, err = os.OpenFile(v.snapshotIndexFileName(s.Name)+".cps", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+			return
+		}
+		newSnapshotMaps = append(newSnapshotMaps, NewNeedleMap(f))
+	}
+
+	trash, err := v.keptTrash(time.Now())
+	if err != nil {
+		return
+	}
+	var newTrash *os.File
+	if newTrash, err = os.OpenFile(v.trashFileName()+".cpt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return
+	}
+	defer newTrash.Close()
+
 	nm := NewNeedleMap(idx)
 	new_offset := int64(SuperBlockSize)
 
 	now := uint64(time.Now().Unix())
 
+	isAt := func(m NeedleMapper, key uint64, offset int64) bool {
+		nv, ok := m.Get(key)
+		return ok && int64(nv.Offset)*NeedlePaddingSize == offset && nv.Size > 0
+	}
+
 	err = ScanVolumeFile(v.dir, v.Collection, v.Id, v.needleMapKind,
 		func(superBlock SuperBlock) error {
 			superBlock.CompactRevision++
@@ -82,18 +141,41 @@ func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string) (err erro
 			if n.HasTtl() && now >= n.LastModified+uint64(v.Ttl.Minutes()*60) {
 				return nil
 			}
-			nv, ok := v.nm.Get(n.Id)
-			glog.V(4).Infoln("needle expected offset ", offset, "ok", ok, "nv", nv)
-			if ok && int64(nv.Offset)*NeedlePaddingSize == offset && nv.Size > 0 {
+			var referenced []*NeedleMap
+			for i, m := range snapshotMaps {
+				if isAt(m, n.Id, offset) {
+					referenced = append(referenced, newSnapshotMaps[i])
+				}
+			}
+			isLive := isAt(live, n.Id, offset)
+			trashed, isTrashed := trash[n.Id]
+			isTrashed = isTrashed && !isLive && int64(trashed.Offset)*NeedlePaddingSize == offset
+			glog.V(4).Infoln("needle expected offset ", offset, "live", isLive, "snapshots", len(referenced), "trashed", isTrashed)
+			if !isLive && !isTrashed && len(referenced) == 0 {
+				return nil
+			}
+			if isTrashed {
+				e := *trashed
+				e.Offset = uint32(new_offset / NeedlePaddingSize)
+				if err = appendTrashRow(newTrash, &e); err != nil {
+					return fmt.Errorf("cannot trash needle: %s", err)
+				}
+			}
+			if isLive {
 				if err = nm.Put(n.Id, uint32(new_offset/NeedlePaddingSize), n.Size); err != nil {
 					return fmt.Errorf("cannot put needle: %s", err)
 				}
-				if _, err = n.Append(dst, v.Version()); err != nil {
-					return fmt.Errorf("cannot append needle: %s", err)
+			}
+			for _, m := range referenced {
+				if err = m.Put(n.Id, uint32(new_offset/NeedlePaddingSize), n.Size); err != nil {
+					return fmt.Errorf("cannot put snapshot needle: %s", err)
 				}
-				new_offset += n.DiskSize()
-				glog.V(3).Infoln("saving key", n.Id, "volume offset", offset, "=>", new_offset, "data_size", n.Size)
 			}
+			if _, err = n.Append(dst, v.Version()); err != nil {
+				return fmt.Errorf("cannot append needle: %s", err)
+			}
+			new_offset += n.DiskSize()
+			glog.V(3).Infoln("saving key", n.Id, "volume offset", offset, "=>", new_offset, "data_size", n.Size)
 			return nil
 		})
 
@@ -19,6 +19,9 @@ func AllocateVolume(dn *DataNode, vid storage.VolumeId, option *VolumeGrowOption
 	values.Add("volume", vid.String())
 	values.Add("collection", option.Collection)
 	values.Add("ttl", option.Ttl.String())
+	if option.Tier != "" {
+		values.Add("tier", option.Tier)
+	}
 	jsonBlob, err := util.Post(dn.Url(), "/admin/assign_volume", values)
 	if err != nil {
 		return err
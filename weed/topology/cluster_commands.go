@@ -3,6 +3,7 @@ package topology
 import (
 	"github.com/chrislusf/raft"
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/sequence"
 	"github.com/chrislusf/seaweedfs/weed/storage"
 )
 
@@ -29,3 +30,35 @@ func (c *MaxVolumeIdCommand) Apply(server raft.Server) (interface{}, error) {
 
 	return nil, nil
 }
+
+// FileIdReservationCommand reserves a batch of file ids for the leader,
+// so the file ids are never issued twice after the leader changes.
+type FileIdReservationCommand struct {
+	Min   uint64 `json:"min"`
+	Count uint64 `json:"count"`
+}
+
+func NewFileIdReservationCommand(min, count uint64) *FileIdReservationCommand {
+	return &FileIdReservationCommand{
+		Min:   min,
+		Count: count,
+	}
+}
+
+func (c *FileIdReservationCommand) CommandName() string {
+	return "FileIdReservation"
+}
+
+func (c *FileIdReservationCommand) Apply(server raft.Server) (interface{}, error) {
+	topo := server.Context().(*Topology)
+	seq, ok := topo.Sequence.(*sequence.BatchSequencer)
+	if !ok {
+		// the file ids are not reserved by other sequencers
+		return c.Min, nil
+	}
+	start := seq.ApplyReservation(c.Min, c.Count)
+
+	glog.V(4).Infoln("reserved file ids", start, "-", start+c.Count-1)
+
+	return start, nil
+}
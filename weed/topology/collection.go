@@ -2,6 +2,7 @@ package topology
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/chrislusf/seaweedfs/weed/storage"
 	"github.com/chrislusf/seaweedfs/weed/util"
@@ -12,6 +13,7 @@ type Collection struct {
 	volumeSizeLimit          uint64
 	rp                       *storage.ReplicaPlacement
 	storageType2VolumeLayout *util.ConcurrentMap
+	rpLock                   sync.RWMutex
 }
 
 func NewCollection(name string, rp *storage.ReplicaPlacement, volumeSizeLimit uint64) *Collection {
@@ -30,11 +32,41 @@ func (c *Collection) GetOrCreateVolumeLayout(ttl *storage.TTL) *VolumeLayout {
 		keyString += ttl.String()
 	}
 	vl := c.storageType2VolumeLayout.GetOrNew(keyString, func() interface{} {
-		return NewVolumeLayout(c.rp, ttl, c.volumeSizeLimit)
+		return NewVolumeLayout(c.ReplicaPlacement(), ttl, c.volumeSizeLimit)
 	})
 	return vl.(*VolumeLayout)
 }
 
+func (c *Collection) ReplicaPlacement() *storage.ReplicaPlacement {
+	c.rpLock.RLock()
+	defer c.rpLock.RUnlock()
+	return c.rp
+}
+
+// SetReplicaPlacement changes the replica placement of the collection and its volume layouts,
+// the volumes not matching it are no longer writable until they are replicated.
+func (c *Collection) SetReplicaPlacement(rp *storage.ReplicaPlacement) {
+	c.rpLock.Lock()
+	c.rp = rp
+	c.rpLock.Unlock()
+	c.storageType2VolumeLayout.Walk(func(k string, vl interface{}) (e error) {
+		if vl != nil {
+			vl.(*VolumeLayout).SetReplicaPlacement(rp)
+		}
+		return nil
+	})
+}
+
+func (c *Collection) VolumeCount() (count int) {
+	c.storageType2VolumeLayout.Walk(func(k string, vl interface{}) (e error) {
+		if vl != nil {
+			count += vl.(*VolumeLayout).VolumeCount()
+		}
+		return nil
+	})
+	return
+}
+
 func (c *Collection) Lookup(vid storage.VolumeId) (vll *VolumeLocationList) {
 	c.storageType2VolumeLayout.Walk(func(k string, vl interface{}) (e error) {
 		if vl != nil {
@@ -14,7 +14,9 @@ import (
 type DataNode struct {
 	NodeImpl
 	volumes   map[storage.VolumeId]*storage.VolumeInfo
-	lastSeen  int64 // unix time in seconds
+	ecShards  map[storage.VolumeId]*storage.EcVolumeInfo
+	tiers     map[string]int // max volume count of each disk tier
+	lastSeen  int64          // unix time in seconds
 	dead      bool
 	Ip        string
 	Port      int
@@ -26,6 +28,7 @@ func NewDataNode(id string) *DataNode {
 	s.id = NodeId(id)
 	s.nodeType = "DataNode"
 	s.volumes = make(map[storage.VolumeId]*storage.VolumeInfo)
+	s.ecShards = make(map[storage.VolumeId]*storage.EcVolumeInfo)
 	s.NodeImpl.value = s
 	return s
 }
@@ -66,6 +69,41 @@ func (dn *DataNode) SetDead(b bool) {
 	dn.dead = b
 }
 
+func (dn *DataNode) SetTiers(tiers map[string]int) {
+	dn.mutex.Lock()
+	defer dn.mutex.Unlock()
+	dn.tiers = tiers
+}
+
+func (dn *DataNode) Tiers() (tiers []string) {
+	dn.mutex.RLock()
+	defer dn.mutex.RUnlock()
+	for tier := range dn.tiers {
+		tiers = append(tiers, tier)
+	}
+	return
+}
+
+// FreeTierSpace is the free slots on the disks of the tier
+func (dn *DataNode) FreeTierSpace(tier string) int {
+	freeSpace := dn.FreeSpace()
+	if tier == "" {
+		return freeSpace
+	}
+	dn.mutex.RLock()
+	tierFreeSpace := dn.tiers[tier]
+	for _, v := range dn.volumes {
+		if v.Tier == tier {
+			tierFreeSpace--
+		}
+	}
+	dn.mutex.RUnlock()
+	if tierFreeSpace < freeSpace {
+		return tierFreeSpace
+	}
+	return freeSpace
+}
+
 func (dn *DataNode) AddOrUpdateVolume(v *storage.VolumeInfo) {
 	if dn.GetVolume(v.Id) == nil {
 		dn.SetVolume(v)
@@ -136,6 +174,53 @@ func (dn *DataNode) UpdateVolumes(actualVolumes []*storage.VolumeInfo) (deletedV
 	return
 }
 
+func (dn *DataNode) EcShards() (list []*storage.EcVolumeInfo) {
+	dn.mutex.RLock()
+	defer dn.mutex.RUnlock()
+	list = make([]*storage.EcVolumeInfo, 0, len(dn.ecShards))
+	for _, ei := range dn.ecShards {
+		list = append(list, ei)
+	}
+	return list
+}
+
+func (dn *DataNode) GetEcShards(vid storage.VolumeId) *storage.EcVolumeInfo {
+	dn.mutex.RLock()
+	defer dn.mutex.RUnlock()
+	return dn.ecShards[vid]
+}
+
+// UpdateEcShards replaces the ec shards of the data node, and returns the shards changed
+func (dn *DataNode) UpdateEcShards(actualShards []*storage.EcVolumeInfo) (newShards, deletedShards []*storage.EcVolumeInfo) {
+	actualShardMap := make(map[storage.VolumeId]*storage.EcVolumeInfo)
+	for _, ei := range actualShards {
+		actualShardMap[ei.Id] = ei
+	}
+	dn.mutex.Lock()
+	defer dn.mutex.Unlock()
+	for vid, ei := range dn.ecShards {
+		actual, ok := actualShardMap[vid]
+		if !ok {
+			deletedShards = append(deletedShards, ei)
+		} else if deleted := ei.ShardBits &^ actual.ShardBits; deleted != 0 {
+			deletedShards = append(deletedShards, &storage.EcVolumeInfo{Id: vid, Collection: ei.Collection, ShardBits: deleted})
+		}
+	}
+	for vid, actual := range actualShardMap {
+		var added storage.EcShardBits
+		if ei, ok := dn.ecShards[vid]; ok {
+			added = actual.ShardBits &^ ei.ShardBits
+		} else {
+			added = actual.ShardBits
+		}
+		if added != 0 {
+			newShards = append(newShards, &storage.EcVolumeInfo{Id: vid, Collection: actual.Collection, ShardBits: added})
+		}
+	}
+	dn.ecShards = actualShardMap
+	return
+}
+
 func (dn *DataNode) GetDataCenter() *DataCenter {
 	return dn.Parent().Parent().GetValue().(*DataCenter)
 }
@@ -167,6 +252,13 @@ func (dn *DataNode) ToMap() interface{} {
 	ret["Volumes"] = dn.GetVolumeCount()
 	ret["Max"] = dn.GetMaxVolumeCount()
 	ret["Free"] = dn.FreeSpace()
+	if tiers := dn.Tiers(); len(tiers) > 0 {
+		freeTiers := make(map[string]int)
+		for _, tier := range tiers {
+			freeTiers[tier] = dn.FreeTierSpace(tier)
+		}
+		ret["FreeTiers"] = freeTiers
+	}
 	ret["PublicUrl"] = dn.PublicUrl
 	return ret
 }
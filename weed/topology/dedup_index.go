@@ -0,0 +1,163 @@
+package topology
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// DedupEntry is the stored file of a content hash, shared by Refs references
+type DedupEntry struct {
+	Fid  string `json:"fid"`
+	Size uint64 `json:"size"`
+	Refs uint64 `json:"refs"`
+}
+
+// DedupSavings sums up the entries of a collection
+type DedupSavings struct {
+	Collection string `json:"collection"`
+	Files      uint64 `json:"files"`
+	References uint64 `json:"references"`
+	SavedBytes uint64 `json:"savedBytes"`
+}
+
+// DedupIndex maps the content hash of the collections to the stored file,
+// and the file back to the hash.
+//
+//	"h" collection \x00 hash -> DedupEntry
+//	"f" fid                  -> collection \x00 hash
+type DedupIndex struct {
+	db    *leveldb.DB
+	mutex sync.Mutex
+}
+
+// NewDedupIndex opens the index in the directory, or keeps it in memory if dir is empty
+func NewDedupIndex(dir string) (*DedupIndex, error) {
+	var db *leveldb.DB
+	var err error
+	if dir == "" {
+		db, err = leveldb.Open(storage.NewMemStorage(), nil)
+	} else {
+		db, err = leveldb.OpenFile(dir, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DedupIndex{db: db}, nil
+}
+
+func dedupHashKey(collection, hash string) []byte {
+	return []byte("h" + collection + "\x00" + hash)
+}
+
+func dedupFidKey(fid string) []byte {
+	return []byte("f" + fid)
+}
+
+// Get returns nil if the hash is not in the collection
+func (di *DedupIndex) Get(collection, hash string) (*DedupEntry, error) {
+	data, err := di.db.Get(dedupHashKey(collection, hash), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	e := &DedupEntry{}
+	if err = json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetByFid returns the collection, hash and entry of the stored file, a nil entry if not indexed
+func (di *DedupIndex) GetByFid(fid string) (collection, hash string, e *DedupEntry, err error) {
+	data, err := di.db.Get(dedupFidKey(fid), nil)
+	if err == leveldb.ErrNotFound {
+		return "", "", nil, nil
+	} else if err != nil {
+		return "", "", nil, err
+	}
+	parts := strings.SplitN(string(data), "\x00", 2)
+	if len(parts) != 2 {
+		return "", "", nil, nil
+	}
+	collection, hash = parts[0], parts[1]
+	e, err = di.Get(collection, hash)
+	return
+}
+
+// Put stores the entry of the hash, an entry without references is deleted
+func (di *DedupIndex) Put(collection, hash string, e *DedupEntry) error {
+	di.mutex.Lock()
+	defer di.mutex.Unlock()
+	batch := new(leveldb.Batch)
+	old, err := di.Get(collection, hash)
+	if err != nil {
+		return err
+	}
+	if old != nil && (e == nil || old.Fid != e.Fid) {
+		batch.Delete(dedupFidKey(old.Fid))
+	}
+	if e == nil || e.Refs == 0 {
+		batch.Delete(dedupHashKey(collection, hash))
+	} else {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		batch.Put(dedupHashKey(collection, hash), data)
+		batch.Put(dedupFidKey(e.Fid), []byte(collection+"\x00"+hash))
+	}
+	return di.db.Write(batch, nil)
+}
+
+// DeleteCollection drops all entries of the collection
+func (di *DedupIndex) DeleteCollection(collection string) error {
+	di.mutex.Lock()
+	defer di.mutex.Unlock()
+	batch := new(leveldb.Batch)
+	err := di.each(collection, func(key []byte, e *DedupEntry) {
+		batch.Delete(key)
+		batch.Delete(dedupFidKey(e.Fid))
+	})
+	if err != nil {
+		return err
+	}
+	return di.db.Write(batch, nil)
+}
+
+// Savings counts the stored files and references of the collection
+func (di *DedupIndex) Savings(collection string) (s DedupSavings, err error) {
+	s.Collection = collection
+	err = di.each(collection, func(key []byte, e *DedupEntry) {
+		s.Files++
+		s.References += e.Refs
+		if e.Refs > 1 {
+			s.SavedBytes += (e.Refs - 1) * e.Size
+		}
+	})
+	return
+}
+
+func (di *DedupIndex) each(collection string, fn func(key []byte, e *DedupEntry)) error {
+	iter := di.db.NewIterator(util.BytesPrefix(dedupHashKey(collection, "")), nil)
+	defer iter.Release()
+	for iter.Next() {
+		e := &DedupEntry{}
+		if err := json.Unmarshal(iter.Value(), e); err != nil {
+			return err
+		}
+		key := make([]byte, len(iter.Key()))
+		copy(key, iter.Key())
+		fn(key, e)
+	}
+	return iter.Error()
+}
+
+func (di *DedupIndex) Close() {
+	di.db.Close()
+}
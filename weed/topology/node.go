@@ -17,7 +17,8 @@ type Node interface {
 	Id() NodeId
 	String() string
 	FreeSpace() int
-	ReserveOneVolume(r int) (*DataNode, error)
+	FreeTierSpace(tier string) int
+	ReserveOneVolume(r int, tier string) (*DataNode, error)
 	UpAdjustMaxVolumeCountDelta(maxVolumeCountDelta int)
 	UpAdjustVolumeCountDelta(volumeCountDelta int)
 	UpAdjustActiveVolumeCountDelta(activeVolumeCountDelta int)
@@ -153,6 +154,18 @@ func (n *NodeImpl) FreeSpace() int {
 	return n.maxVolumeCount - n.volumeCount - n.plannedVolumeCount
 }
 
+// FreeTierSpace is the free slots on the disks of the tier, or on all disks if the tier is empty
+func (n *NodeImpl) FreeTierSpace(tier string) int {
+	if tier == "" {
+		return n.FreeSpace()
+	}
+	freeSpace := 0
+	for _, node := range n.Children() {
+		freeSpace += node.FreeTierSpace(tier)
+	}
+	return freeSpace
+}
+
 func (n *NodeImpl) SetParent(node Node) {
 	n.mutex.Lock()
 	defer n.mutex.Unlock()
@@ -207,11 +220,11 @@ func (n *NodeImpl) GetValue() interface{} {
 	defer n.mutex.RUnlock()
 	return n.value
 }
-func (n *NodeImpl) ReserveOneVolume(r int) (assignedNode *DataNode, err error) {
+func (n *NodeImpl) ReserveOneVolume(r int, tier string) (assignedNode *DataNode, err error) {
 	n.mutex.RLock()
 	defer n.mutex.RUnlock()
 	for _, node := range n.children {
-		freeSpace := node.FreeSpace()
+		freeSpace := node.FreeTierSpace(tier)
 		// fmt.Println("r =", r, ", node =", node, ", freeSpace =", freeSpace)
 		if freeSpace <= 0 {
 			continue
@@ -219,11 +232,11 @@ func (n *NodeImpl) ReserveOneVolume(r int) (assignedNode *DataNode, err error) {
 		if r >= freeSpace {
 			r -= freeSpace
 		} else {
-			if node.IsDataNode() && node.FreeSpace() > 0 {
+			if node.IsDataNode() && node.FreeTierSpace(tier) > 0 {
 				// fmt.Println("assigned to node =", node, ", freeSpace =", node.FreeSpace())
 				return node.(*DataNode), nil
 			}
-			assignedNode, err = node.ReserveOneVolume(r)
+			assignedNode, err = node.ReserveOneVolume(r, tier)
 			if err != nil {
 				return
 			}
@@ -2,6 +2,7 @@ package topology
 
 import (
 	"bytes"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -17,16 +18,29 @@ import (
 
 func ReplicatedWrite(masterNode string, s *storage.Store,
 	volumeId storage.VolumeId, needle *storage.Needle,
-	r *http.Request) (size uint32, errorStatus string) {
-	//check JWT
-	jwt := security.GetJwt(r)
+	r *http.Request, jwt security.EncodedJwt) (size uint32, errorStatus string) {
+	return ReplicatedWriteStream(masterNode, s, volumeId, needle, nil, r, jwt)
+}
+
+// ReplicatedWriteStream writes the needle with its data read from the stream, or with
+// needle.Data if the stream is nil. The other replicas are sent the data stored locally,
+// with the jwt of the file id signed by this volume server, not the token of the client.
+func ReplicatedWriteStream(masterNode string, s *storage.Store,
+	volumeId storage.VolumeId, needle *storage.Needle, data io.Reader,
+	r *http.Request, jwt security.EncodedJwt) (size uint32, errorStatus string) {
 	defer func() {
 		if errorStatus == "" {
 			return
 		}
-		ReplicatedDelete(masterNode, s, volumeId, needle, r)
+		ReplicatedDelete(masterNode, s, volumeId, needle, r, jwt)
 	}()
-	ret, err := s.Write(volumeId, needle)
+	var ret uint32
+	var err error
+	if data != nil {
+		ret, err = s.WriteStream(volumeId, needle, data)
+	} else {
+		ret, err = s.Write(volumeId, needle)
+	}
 	if err != nil {
 		errorStatus = "Failed to write to local disk (" + err.Error() + ")"
 	} else if ret <= 0 {
@@ -47,8 +61,17 @@ func ReplicatedWrite(masterNode string, s *storage.Store,
 
 			u := util.MkUrl(location.Url, r.URL.Path, args)
 			glog.V(4).Infoln("write replication to", u)
+			var content io.Reader = bytes.NewReader(needle.Data)
+			if needle.Data == nil && data != nil {
+				// the streamed data is only on the disk
+				var re error
+				if content, re = s.ReadVolumeNeedleData(volumeId, needle); re != nil {
+					glog.V(0).Infof("read %s to replicate: %v", r.URL.Path, re)
+					return false
+				}
+			}
 			_, err := operation.Upload(u,
-				string(needle.Name), bytes.NewReader(needle.Data), needle.IsGzipped(), string(needle.Mime),
+				string(needle.Name), content, needle.IsGzipped(), string(needle.Mime),
 				jwt)
 			if err != nil {
 				glog.V(0).Infof("write replication to %s err, %v", u, err)
@@ -67,10 +90,7 @@ func ReplicatedWrite(masterNode string, s *storage.Store,
 
 func ReplicatedDelete(masterNode string, store *storage.Store,
 	volumeId storage.VolumeId, n *storage.Needle,
-	r *http.Request) (ret uint32) {
-
-	//check JWT
-	jwt := security.GetJwt(r)
+	r *http.Request, jwt security.EncodedJwt) (ret uint32) {
 
 	ret, err := store.Delete(volumeId, n)
 	if err != nil {
@@ -80,7 +100,7 @@ func ReplicatedDelete(masterNode string, store *storage.Store,
 	//send to other replica locations
 	if r.FormValue("type") != "replicate" {
 		if !distributedOperation(masterNode, store, volumeId, func(location operation.Location) bool {
-			return nil == util.Delete("http://"+location.Url+r.URL.Path+"?type=replicate", jwt)
+			return nil == util.Delete(util.NormalizeUrl(location.Url+r.URL.Path+"?type=replicate"), jwt)
 		}) {
 			ret = 0
 		}
@@ -2,14 +2,17 @@ package topology
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/chrislusf/raft"
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/sequence"
 	"github.com/chrislusf/seaweedfs/weed/storage"
 	"github.com/chrislusf/seaweedfs/weed/util"
@@ -28,6 +31,28 @@ type Topology struct {
 	configuration      *Configuration
 	raftServer         raft.Server
 
+	collectionSettingsFile string
+
+	AccessKeys *security.AccessKeys
+
+	dedup     *DedupIndex
+	dedupLock sync.Mutex
+
+	ecShardMap     map[storage.VolumeId]*EcShardLocations
+	ecShardMapLock sync.RWMutex
+
+	corruptionReports map[string]*CorruptionReport
+	corruptionLock    sync.RWMutex
+
+	tierPolicy  *TierPolicy
+	readSamples map[string]readSample
+	tierLock    sync.Mutex
+
+	usages       map[string]*CollectionUsage
+	usageHistory map[string][]CollectionUsage
+	usageSampled time.Time
+	usageLock    sync.RWMutex
+
 	chanDeadDataNodes      chan *DataNode
 	chanRecoveredDataNodes chan *DataNode
 	chanFullVolumes        chan storage.VolumeInfo
@@ -35,14 +60,24 @@ type Topology struct {
 
 func NewTopology(id string, confFile string, cs *storage.CollectionSettings, seq sequence.Sequencer, volumeSizeLimit uint64, pulse int) (*Topology, error) {
 	t := &Topology{}
+	var err error
 	t.id = NodeId(id)
 	t.nodeType = "Topology"
 	t.NodeImpl.value = t
 	t.children = make(map[NodeId]Node)
 	t.collectionMap = util.NewConcurrentMap()
+	t.ecShardMap = make(map[storage.VolumeId]*EcShardLocations)
+	t.corruptionReports = make(map[string]*CorruptionReport)
+	t.readSamples = make(map[string]readSample)
+	t.usages = make(map[string]*CollectionUsage)
+	t.usageHistory = make(map[string][]CollectionUsage)
 	t.pulse = int64(pulse)
 	t.volumeSizeLimit = volumeSizeLimit
 	t.CollectionSettings = cs
+	t.AccessKeys = security.NewAccessKeys()
+	if t.dedup, err = NewDedupIndex(""); err != nil {
+		return nil, err
+	}
 	t.ReGenJoinKey()
 
 	t.Sequence = seq
@@ -51,7 +86,7 @@ func NewTopology(id string, confFile string, cs *storage.CollectionSettings, seq
 	t.chanRecoveredDataNodes = make(chan *DataNode)
 	t.chanFullVolumes = make(chan storage.VolumeInfo)
 
-	err := t.loadConfiguration(confFile)
+	err = t.loadConfiguration(confFile)
 
 	return t, err
 }
@@ -148,6 +183,23 @@ func (t *Topology) NextVolumeId() storage.VolumeId {
 	return next
 }
 
+// ReserveFileIds reserves the file ids through the raft log, for the batch sequencer
+func (t *Topology) ReserveFileIds(min, count uint64) (uint64, error) {
+	raftServer := t.GetRaftServer()
+	if raftServer == nil {
+		return 0, errors.New("raft server is not ready")
+	}
+	ret, err := raftServer.Do(NewFileIdReservationCommand(min, count))
+	if err != nil {
+		return 0, err
+	}
+	start, ok := ret.(uint64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected file id reservation result %v", ret)
+	}
+	return start, nil
+}
+
 func (t *Topology) HasWritableVolume(option *VolumeGrowOption) bool {
 	vl := t.GetVolumeLayout(option.Collection, option.Ttl)
 	return vl.GetActiveVolumeCount(option) > 0
@@ -159,6 +211,9 @@ func (t *Topology) PickForWrite(count uint64, option *VolumeGrowOption) (string,
 		return "", 0, nil, errors.New("No writable volumes available!")
 	}
 	fileId, count := t.Sequence.NextFileId(count)
+	if count == 0 {
+		return "", 0, nil, errors.New("No file id available!")
+	}
 	return storage.NewFileId(*vid, fileId, rand.Uint32()).String(), count, dataNodes.Head(), nil
 }
 
@@ -228,6 +283,11 @@ func (t *Topology) ProcessJoinMessageV2(joinMsgV2 *weedpb.JoinMessageV2) {
 	dn = rack.GetOrCreateDataNode(joinMsgV2.Ip,
 		int(joinMsgV2.Port), joinMsgV2.PublicUrl,
 		int(joinMsgV2.MaxVolumeCount))
+	tiers := make(map[string]int)
+	for _, m := range joinMsgV2.DiskTiers {
+		tiers[m.Tier] += int(m.MaxVolumeCount)
+	}
+	dn.SetTiers(tiers)
 	var volumeInfos []*storage.VolumeInfo
 	for _, v := range joinMsgV2.Volumes {
 		if vi, err := storage.NewVolumeInfo(v); err == nil {
@@ -245,6 +305,19 @@ func (t *Topology) ProcessJoinMessageV2(joinMsgV2 *weedpb.JoinMessageV2) {
 		t.UnRegisterVolumeLayout(v, dn)
 	}
 
+	var ecInfos []*storage.EcVolumeInfo
+	for _, m := range joinMsgV2.EcShards {
+		ei := storage.NewEcVolumeInfo(m)
+		dn.UpAdjustMaxVolumeId(ei.Id)
+		ecInfos = append(ecInfos, ei)
+	}
+	newShards, deletedShards := dn.UpdateEcShards(ecInfos)
+	for _, ei := range newShards {
+		t.RegisterEcShards(ei, dn)
+	}
+	for _, ei := range deletedShards {
+		t.UnRegisterEcShards(ei, dn)
+	}
 }
 
 func (t *Topology) GetOrCreateDataCenter(dcName string) *DataCenter {
@@ -0,0 +1,71 @@
+package topology
+
+import (
+	"errors"
+
+	"github.com/chrislusf/raft"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
+)
+
+// AccessKeyCommand sets or deletes an access key on every master
+type AccessKeyCommand struct {
+	Key    *security.AccessKey `json:"key"`
+	Delete bool                `json:"delete,omitempty"`
+}
+
+func NewAccessKeyCommand(key *security.AccessKey, isDelete bool) *AccessKeyCommand {
+	return &AccessKeyCommand{
+		Key:    key,
+		Delete: isDelete,
+	}
+}
+
+func (c *AccessKeyCommand) CommandName() string {
+	return "AccessKey"
+}
+
+func (c *AccessKeyCommand) Apply(server raft.Server) (interface{}, error) {
+	topo := server.Context().(*Topology)
+	if c.Key == nil || c.Key.Id == "" {
+		return nil, errors.New("invalid access key")
+	}
+	var err error
+	if c.Delete {
+		err = topo.AccessKeys.Delete(c.Key.Id)
+	} else {
+		err = topo.AccessKeys.Set(c.Key)
+	}
+	if err != nil {
+		// the key is changed in memory, only saving it failed
+		glog.V(0).Infof("save access key %s: %v", c.Key.Id, err)
+	}
+
+	glog.V(0).Infof("access key %s: %v, deleted: %v", c.Key.Id, c.Key.Scope, c.Delete)
+
+	return nil, nil
+}
+
+func (t *Topology) SetAccessKey(key *security.AccessKey) error {
+	if key.Id == "" || key.Secret == "" {
+		return errors.New("access key id and secret are required")
+	}
+	// only the hash of the secret goes through the raft log
+	return t.doAccessKeyCommand(NewAccessKeyCommand(key.Hashed(), false))
+}
+
+func (t *Topology) DeleteAccessKey(id string) error {
+	if _, ok := t.AccessKeys.Get(id); !ok {
+		return errors.New("access key " + id + " does not exist")
+	}
+	return t.doAccessKeyCommand(NewAccessKeyCommand(&security.AccessKey{Id: id}, true))
+}
+
+func (t *Topology) doAccessKeyCommand(c *AccessKeyCommand) error {
+	raftServer := t.GetRaftServer()
+	if raftServer == nil {
+		return errors.New("raft server is not ready")
+	}
+	_, err := raftServer.Do(c)
+	return err
+}
@@ -0,0 +1,203 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+var (
+	isBalancerRunning = false
+)
+
+const BalanceTaskTimeout = time.Hour
+
+type BalanceTask struct {
+	Vid        storage.VolumeId
+	Collection string
+	SrcDN      *DataNode
+	DstDN      *DataNode
+}
+
+// Run moves the volume with the balance task on the destination,
+// the volume is readonly during the move, but always readable.
+func (t *BalanceTask) Run(topo *Topology) error {
+	locationList := topo.Lookup(t.Collection, t.Vid)
+	if locationList == nil || !locationList.ContainsDataNode(t.SrcDN) {
+		return fmt.Errorf("volume %v is not found on %s", t.Vid, t.SrcDN.Url())
+	}
+	if locationList.ContainsDataNode(t.DstDN) {
+		return fmt.Errorf("volume %v already exists on %s", t.Vid, t.DstDN.Url())
+	}
+	wasReadonly := false
+	if v := t.SrcDN.GetVolume(t.Vid); v != nil {
+		wasReadonly = v.ReadOnly
+	}
+	locationList = locationList.Duplicate()
+	if !SetVolumeReadonly(locationList, t.Vid.String(), true) {
+		if !wasReadonly {
+			SetVolumeReadonly(locationList, t.Vid.String(), false)
+		}
+		return fmt.Errorf("set volume readonly failed, vid=%v", t.Vid)
+	}
+	tc, e := storage.NewTaskCli(t.DstDN.Url(), storage.TaskBalance, storage.TaskParams{
+		"volume":     t.Vid.String(),
+		"source":     t.SrcDN.Url(),
+		"collection": t.Collection,
+	})
+	if e == nil {
+		if e = tc.WaitAndQueryResult(BalanceTaskTimeout); e != nil {
+			tc.Clean()
+		} else {
+			e = tc.Commit()
+		}
+	}
+	if e == nil {
+		locationList.Remove(t.SrcDN)
+		locationList.Set(t.DstDN)
+	}
+	if !wasReadonly {
+		SetVolumeReadonly(locationList, t.Vid.String(), false)
+	}
+	return e
+}
+
+func (t *BalanceTask) WorkingDataNodes() []*DataNode {
+	return []*DataNode{
+		t.SrcDN,
+		t.DstDN,
+	}
+}
+
+func (t *BalanceTask) Finish() {
+	t.DstDN.UpAdjustPlannedVolumeCountDelta(-1)
+}
+
+func (t *BalanceTask) String() string {
+	return fmt.Sprintf("<Balance> vid: %v, src: %s, dst: %s", t.Vid, t.SrcDN.Url(), t.DstDN.Url())
+}
+
+// balanceNode is the planning state of a data node, volumes moved in the plan are
+// taken into account, so the plan converges without waiting for the heartbeats.
+type balanceNode struct {
+	dn          *DataNode
+	volumeCount int
+	maxCount    int
+	volumes     []*storage.VolumeInfo
+}
+
+func (n *balanceNode) ratio(delta int) float64 {
+	return float64(n.volumeCount+delta) / float64(n.maxCount)
+}
+
+type balanceNodes []*balanceNode
+
+func (s balanceNodes) Len() int           { return len(s) }
+func (s balanceNodes) Less(i, j int) bool { return s[i].ratio(0) > s[j].ratio(0) }
+func (s balanceNodes) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+/*
+planBalanceTasks moves volumes from the fullest data nodes to the emptiest ones, one volume at a time,
+as long as the source would still be at least as full as the destination after the move.
+A volume is only moved if the replica placement of its collection is still satisfied after the move.
+*/
+func planBalanceTasks(t *Topology) (tasks []*BalanceTask) {
+	var nodes balanceNodes
+	t.WalkDataNode(func(dn *DataNode) error {
+		if dn.IsDead() || dn.GetMaxVolumeCount() <= 0 {
+			return nil
+		}
+		nodes = append(nodes, &balanceNode{
+			dn:          dn,
+			volumeCount: dn.GetVolumeCount() + dn.GetPlannedVolumeCount(),
+			maxCount:    dn.GetMaxVolumeCount(),
+			volumes:     dn.Volumes(),
+		})
+		return nil
+	})
+	if len(nodes) < 2 {
+		return
+	}
+	moved := make(map[storage.VolumeId]bool)
+	for {
+		sort.Sort(nodes)
+		task := planOneBalanceTask(t, nodes, moved)
+		if task == nil {
+			break
+		}
+		task.DstDN.UpAdjustPlannedVolumeCountDelta(1)
+		moved[task.Vid] = true
+		tasks = append(tasks, task)
+		glog.V(0).Infof("add balance task, vid: %v, src: %s, dst: %s", task.Vid, task.SrcDN.Url(), task.DstDN.Url())
+	}
+	return
+}
+
+// planOneBalanceTask expects the nodes are sorted from the fullest to the emptiest
+func planOneBalanceTask(t *Topology, nodes balanceNodes, moved map[storage.VolumeId]bool) *BalanceTask {
+	for i := 0; i < len(nodes); i++ {
+		src := nodes[i]
+		for j := len(nodes) - 1; j > i; j-- {
+			dst := nodes[j]
+			if dst.volumeCount >= dst.maxCount || src.ratio(-1) < dst.ratio(1) {
+				continue
+			}
+			for k, v := range src.volumes {
+				if moved[v.Id] || !canMoveVolume(t, v, src.dn, dst.dn) {
+					continue
+				}
+				src.volumes = append(src.volumes[:k], src.volumes[k+1:]...)
+				dst.volumes = append(dst.volumes, v)
+				src.volumeCount--
+				dst.volumeCount++
+				return &BalanceTask{
+					Vid:        v.Id,
+					Collection: v.Collection,
+					SrcDN:      src.dn,
+					DstDN:      dst.dn,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func canMoveVolume(t *Topology, v *storage.VolumeInfo, src, dst *DataNode) bool {
+	locationList := t.Lookup(v.Collection, v.Id)
+	if locationList == nil || locationList.ContainsDataNode(dst) {
+		return false
+	}
+	rp := t.CollectionSettings.GetReplicaPlacement(v.Collection)
+	if locationList.CalcReplicaPlacement().Compare(rp) < 0 {
+		// under replicated volumes are left to the replicate checker
+		return false
+	}
+	newList := locationList.Duplicate()
+	newList.Remove(src)
+	newList.Set(dst)
+	return newList.CalcReplicaPlacement().Compare(rp) >= 0
+}
+
+func (topo *Topology) Balance() {
+	isBalancerRunning = true
+	defer func() {
+		isBalancerRunning = false
+	}()
+	glog.V(1).Infoln("Start balancer on demand")
+	var tasks []clusterTask
+	for _, t := range planBalanceTasks(topo) {
+		tasks = append(tasks, t)
+	}
+	runClusterTasks(topo, tasks)
+	glog.V(0).Infoln("finish balance.")
+}
+
+func (topo *Topology) StartBalance() {
+	if isBalancerRunning {
+		return
+	}
+	go topo.Balance()
+}
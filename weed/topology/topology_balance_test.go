@@ -0,0 +1,80 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/sequence"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func setupBalanceTopology(t *testing.T, replication string, racks map[string]map[string][]int) (*Topology, map[string]*DataNode) {
+	topo, err := NewTopology("weedfs", "/etc/weedfs/weedfs.conf",
+		storage.NewCollectionSettings(replication, "0.3"),
+		sequence.NewMemorySequencer(), 32*1024, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := NewDataCenter("dc1")
+	topo.LinkChildNode(dc)
+	dns := make(map[string]*DataNode)
+	port := 8080
+	for rackName, servers := range racks {
+		rack := NewRack(rackName)
+		dc.LinkChildNode(rack)
+		for serverName, vids := range servers {
+			dn := NewDataNode(serverName)
+			dn.Ip, dn.Port = "127.0.0.1", port
+			port++
+			rack.LinkChildNode(dn)
+			dn.UpAdjustMaxVolumeCountDelta(10)
+			for _, vid := range vids {
+				vi := &storage.VolumeInfo{Id: storage.VolumeId(vid), Size: 100, Version: storage.CurrentVersion}
+				dn.AddOrUpdateVolume(vi)
+				topo.RegisterVolumeLayout(vi, dn)
+			}
+			dns[serverName] = dn
+		}
+	}
+	return topo, dns
+}
+
+func TestPlanBalanceTasks(t *testing.T) {
+	topo, dns := setupBalanceTopology(t, "000", map[string]map[string][]int{
+		"rack1": {"server1": {1, 2, 3, 4, 5, 6}, "server2": {}},
+		"rack2": {"server3": {}},
+	})
+	tasks := planBalanceTasks(topo)
+	if len(tasks) != 4 {
+		t.Fatalf("expect 4 balance tasks, got %v", tasks)
+	}
+	moved := make(map[*DataNode]int)
+	for _, task := range tasks {
+		if task.SrcDN != dns["server1"] {
+			t.Errorf("unexpected source %s", task)
+		}
+		moved[task.DstDN]++
+	}
+	if moved[dns["server2"]] != 2 || moved[dns["server3"]] != 2 {
+		t.Errorf("volumes are not evenly moved: %v", tasks)
+	}
+	if dns["server2"].GetPlannedVolumeCount() != 2 {
+		t.Errorf("planned volume count is not reserved on the destination")
+	}
+}
+
+func TestPlanBalanceTasksKeepReplicaPlacement(t *testing.T) {
+	// every volume has one copy on each rack, and must keep it
+	topo, dns := setupBalanceTopology(t, "010", map[string]map[string][]int{
+		"rack1": {"server1": {1, 2, 3, 4}},
+		"rack2": {"server2": {1, 2, 3, 4}, "server3": {}},
+	})
+	tasks := planBalanceTasks(topo)
+	if len(tasks) != 2 {
+		t.Fatalf("expect 2 balance tasks, got %v", tasks)
+	}
+	for _, task := range tasks {
+		if task.SrcDN != dns["server2"] || task.DstDN != dns["server3"] {
+			t.Errorf("volume is moved out of its rack: %s", task)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package topology
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/chrislusf/raft"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/weedpb"
+)
+
+// CollectionSettingCommand sets or deletes the settings of a collection on every master
+type CollectionSettingCommand struct {
+	Setting *weedpb.CollectionSetting `json:"setting"`
+	Delete  bool                      `json:"delete,omitempty"`
+}
+
+func NewCollectionSettingCommand(setting *weedpb.CollectionSetting, isDelete bool) *CollectionSettingCommand {
+	return &CollectionSettingCommand{
+		Setting: setting,
+		Delete:  isDelete,
+	}
+}
+
+func (c *CollectionSettingCommand) CommandName() string {
+	return "CollectionSetting"
+}
+
+func (c *CollectionSettingCommand) Apply(server raft.Server) (interface{}, error) {
+	topo := server.Context().(*Topology)
+	if err := topo.applyCollectionSetting(c.Setting, c.Delete); err != nil {
+		return nil, err
+	}
+
+	glog.V(0).Infof("collection %s settings: %v, deleted: %v", c.Setting.Collection, c.Setting, c.Delete)
+
+	return nil, nil
+}
+
+// SetCollectionSetting replaces the settings of the collection through the raft log
+func (t *Topology) SetCollectionSetting(setting *weedpb.CollectionSetting) error {
+	if setting.Collection == "" {
+		return errors.New("collection is required, the defaults are set by the master flags")
+	}
+	if err := storage.ValidateCollectionSetting(setting); err != nil {
+		return err
+	}
+	return t.doCollectionSettingCommand(NewCollectionSettingCommand(setting, false))
+}
+
+// DeleteCollectionSetting makes the collection fall back to the default settings
+func (t *Topology) DeleteCollectionSetting(collection string) error {
+	if collection == "" {
+		return errors.New("collection is required, the defaults can not be deleted")
+	}
+	return t.doCollectionSettingCommand(NewCollectionSettingCommand(&weedpb.CollectionSetting{Collection: collection}, true))
+}
+
+func (t *Topology) doCollectionSettingCommand(c *CollectionSettingCommand) error {
+	raftServer := t.GetRaftServer()
+	if raftServer == nil {
+		return errors.New("raft server is not ready")
+	}
+	_, err := raftServer.Do(c)
+	return err
+}
+
+func (t *Topology) applyCollectionSetting(setting *weedpb.CollectionSetting, isDelete bool) error {
+	if setting == nil || setting.Collection == "" {
+		return errors.New("invalid collection setting")
+	}
+	if isDelete {
+		t.CollectionSettings.Delete(setting.Collection)
+	} else if err := t.CollectionSettings.Update(setting); err != nil {
+		return err
+	}
+	if c, ok := t.collectionMap.Get(setting.Collection); ok {
+		c.(*Collection).SetReplicaPlacement(t.CollectionSettings.GetReplicaPlacement(setting.Collection))
+	}
+	if err := t.saveCollectionSettings(); err != nil {
+		glog.V(0).Infof("save collection settings to %s: %v", t.collectionSettingsFile, err)
+	}
+	// the volume servers sync the settings on the next heartbeat
+	t.ReGenJoinKey()
+	return nil
+}
+
+// LoadCollectionSettings loads the collection settings saved in the file,
+// which keeps them in case the raft log is cleared.
+func (t *Topology) LoadCollectionSettings(file string) error {
+	t.collectionSettingsFile = file
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var settings []*weedpb.CollectionSetting
+	if err = json.Unmarshal(content, &settings); err != nil {
+		return fmt.Errorf("invalid collection settings file %s: %v", file, err)
+	}
+	for _, setting := range settings {
+		if setting.Collection == "" {
+			continue
+		}
+		if err = t.CollectionSettings.Update(setting); err != nil {
+			return fmt.Errorf("invalid collection %s settings in %s: %v", setting.Collection, file, err)
+		}
+	}
+	glog.V(0).Infoln("loaded settings of", len(settings), "collections from", file)
+	return nil
+}
+
+func (t *Topology) saveCollectionSettings() error {
+	if t.collectionSettingsFile == "" {
+		return nil
+	}
+	var settings []*weedpb.CollectionSetting
+	for _, collection := range t.CollectionSettings.Collections() {
+		if setting, ok := t.CollectionSettings.PbMessage(collection); ok {
+			settings = append(settings, setting)
+		}
+	}
+	content, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.collectionSettingsFile + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.collectionSettingsFile)
+}
+
+// CollectionVolumeCount counts the logical volumes in the collection
+func (t *Topology) CollectionVolumeCount(collection string) int {
+	if c, ok := t.collectionMap.Get(collection); ok {
+		return c.(*Collection).VolumeCount()
+	}
+	return 0
+}
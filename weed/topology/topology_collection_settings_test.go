@@ -0,0 +1,84 @@
+package topology
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/weedpb"
+)
+
+func TestApplyCollectionSetting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "collection_settings")
+
+	topo, dns := setupBalanceTopology(t, "000", map[string]map[string][]int{
+		"rack1": {"server1": {}},
+	})
+	for _, vid := range []int{1, 2} {
+		vi := &storage.VolumeInfo{Id: storage.VolumeId(vid), Collection: "pics", Size: 100, Version: storage.CurrentVersion}
+		dns["server1"].AddOrUpdateVolume(vi)
+		topo.RegisterVolumeLayout(vi, dns["server1"])
+	}
+	if err = topo.LoadCollectionSettings(file); err != nil {
+		t.Fatal(err)
+	}
+	vl := topo.GetVolumeLayout("pics", nil)
+	if count := vl.GetActiveVolumeCount(&VolumeGrowOption{}); count != 2 {
+		t.Fatalf("expect 2 writable volumes, got %d", count)
+	}
+
+	joinKey := topo.GetJoinKey()
+	setting := &weedpb.CollectionSetting{Collection: "pics", ReplicaPlacement: "001", Ttl: "3d", MaxVolumeCount: 2}
+	if err = topo.applyCollectionSetting(setting, false); err != nil {
+		t.Fatal(err)
+	}
+	if topo.GetJoinKey() == joinKey {
+		t.Errorf("join key should change, so the volume servers sync the settings")
+	}
+	if count := vl.GetActiveVolumeCount(&VolumeGrowOption{}); count != 0 {
+		t.Errorf("volumes with one copy should not be writable with 001, got %d", count)
+	}
+	rp, _ := storage.NewReplicaPlacementFromString("001")
+	_, err = NewDefaultVolumeGrowth().GrowByCountAndType(1, &VolumeGrowOption{Collection: "pics", ReplicaPlacement: rp}, topo)
+	if err == nil || !strings.Contains(err.Error(), "max volume count") {
+		t.Errorf("expect max volume count error, got %v", err)
+	}
+
+	// restarted with a cleared raft log
+	other, _ := setupBalanceTopology(t, "000", nil)
+	if err = other.LoadCollectionSettings(file); err != nil {
+		t.Fatal(err)
+	}
+	cs := other.CollectionSettings
+	if cs.GetMaxVolumeCount("pics") != 2 || cs.GetTtl("pics").String() != "3d" || cs.GetReplicaPlacement("pics").String() != "001" {
+		t.Errorf("settings are not loaded: %v", cs.ToPbMessage())
+	}
+	if cs.GetTier("pics") != "" || cs.GetGarbageThreshold("pics") != "0.3" {
+		t.Errorf("unset settings should fall back to the defaults")
+	}
+
+	if err = topo.applyCollectionSetting(&weedpb.CollectionSetting{Collection: "pics"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if count := vl.GetActiveVolumeCount(&VolumeGrowOption{}); count != 2 {
+		t.Errorf("expect 2 writable volumes with the default replication, got %d", count)
+	}
+	other, _ = setupBalanceTopology(t, "000", nil)
+	if err = other.LoadCollectionSettings(file); err != nil {
+		t.Fatal(err)
+	}
+	if collections := other.CollectionSettings.Collections(); len(collections) != 0 {
+		t.Errorf("expect no collection settings, got %v", collections)
+	}
+	if err = topo.SetCollectionSetting(&weedpb.CollectionSetting{Collection: "pics", Ttl: "3x3"}); err == nil {
+		t.Errorf("expect invalid ttl error")
+	}
+}
@@ -0,0 +1,139 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/chrislusf/raft"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// DedupCommand sets the entry of a content hash on every master.
+// It carries the whole entry, so replaying the log gives the same index.
+type DedupCommand struct {
+	Collection     string      `json:"collection"`
+	Hash           string      `json:"hash,omitempty"`
+	Entry          *DedupEntry `json:"entry,omitempty"`
+	DropCollection bool        `json:"dropCollection,omitempty"`
+}
+
+func NewDedupCommand(collection, hash string, entry *DedupEntry) *DedupCommand {
+	return &DedupCommand{
+		Collection: collection,
+		Hash:       hash,
+		Entry:      entry,
+	}
+}
+
+func (c *DedupCommand) CommandName() string {
+	return "Dedup"
+}
+
+func (c *DedupCommand) Apply(server raft.Server) (interface{}, error) {
+	topo := server.Context().(*Topology)
+	if err := topo.applyDedup(c); err != nil {
+		return nil, err
+	}
+
+	glog.V(3).Infof("dedup %s %s: %+v, dropped: %v", c.Collection, c.Hash, c.Entry, c.DropCollection)
+
+	return nil, nil
+}
+
+func (t *Topology) applyDedup(c *DedupCommand) error {
+	if c.DropCollection {
+		return t.dedup.DeleteCollection(c.Collection)
+	}
+	if c.Hash == "" {
+		return errors.New("invalid dedup command")
+	}
+	return t.dedup.Put(c.Collection, c.Hash, c.Entry)
+}
+
+// LoadDedupIndex opens the index saved in the directory,
+// which keeps the references in case the raft log is cleared.
+func (t *Topology) LoadDedupIndex(dir string) error {
+	di, err := NewDedupIndex(dir)
+	if err != nil {
+		return fmt.Errorf("open dedup index %s: %v", dir, err)
+	}
+	t.dedupLock.Lock()
+	defer t.dedupLock.Unlock()
+	t.dedup.Close()
+	t.dedup = di
+	return nil
+}
+
+// AcquireDedup adds a reference to the file stored with the content hash.
+// If the hash is new, the file fid is registered and found is false,
+// otherwise the existing file is returned and fid should not be written.
+func (t *Topology) AcquireDedup(collection, hash string, size uint64, fid string) (existing string, found bool, err error) {
+	if hash == "" || fid == "" {
+		return "", false, errors.New("hash and fid are required")
+	}
+	t.dedupLock.Lock()
+	defer t.dedupLock.Unlock()
+	e, err := t.dedup.Get(collection, hash)
+	if err != nil {
+		return "", false, err
+	}
+	if e != nil && e.Size == size && e.Fid != fid {
+		e.Refs++
+		if err = t.doDedupCommand(NewDedupCommand(collection, hash, e)); err != nil {
+			return "", false, err
+		}
+		return e.Fid, true, nil
+	}
+	if e != nil {
+		// same hash with another size, or the file itself, keep the first one
+		return fid, false, nil
+	}
+	err = t.doDedupCommand(NewDedupCommand(collection, hash, &DedupEntry{Fid: fid, Size: size, Refs: 1}))
+	return fid, false, err
+}
+
+// ReleaseDedup removes a reference to the file, and returns true
+// if the file should be deleted, which is not indexed or has no more references.
+func (t *Topology) ReleaseDedup(fid string) (bool, error) {
+	t.dedupLock.Lock()
+	defer t.dedupLock.Unlock()
+	collection, hash, e, err := t.dedup.GetByFid(fid)
+	if err != nil {
+		return false, err
+	}
+	if e == nil {
+		return true, nil
+	}
+	e.Refs--
+	if err = t.doDedupCommand(NewDedupCommand(collection, hash, e)); err != nil {
+		return false, err
+	}
+	return e.Refs == 0, nil
+}
+
+// DedupEntry returns the collection and entry of the file, a nil entry if not indexed
+func (t *Topology) DedupEntry(fid string) (string, *DedupEntry, error) {
+	collection, _, e, err := t.dedup.GetByFid(fid)
+	return collection, e, err
+}
+
+// DedupSavings counts the files stored once for the collection
+func (t *Topology) DedupSavings(collection string) (DedupSavings, error) {
+	return t.dedup.Savings(collection)
+}
+
+// DropDedupCollection forgets the content hashes of a deleted collection
+func (t *Topology) DropDedupCollection(collection string) error {
+	t.dedupLock.Lock()
+	defer t.dedupLock.Unlock()
+	return t.doDedupCommand(&DedupCommand{Collection: collection, DropCollection: true})
+}
+
+func (t *Topology) doDedupCommand(c *DedupCommand) error {
+	raftServer := t.GetRaftServer()
+	if raftServer == nil {
+		return errors.New("raft server is not ready")
+	}
+	_, err := raftServer.Do(c)
+	return err
+}
@@ -0,0 +1,72 @@
+package topology
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDedupIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	topo, _ := setupBalanceTopology(t, "000", nil)
+	if err = topo.LoadDedupIndex(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*DedupCommand{
+		NewDedupCommand("pics", "h1", &DedupEntry{Fid: "1,01", Size: 100, Refs: 3}),
+		NewDedupCommand("pics", "h2", &DedupEntry{Fid: "1,02", Size: 10, Refs: 1}),
+		NewDedupCommand("logs", "h1", &DedupEntry{Fid: "2,01", Size: 100, Refs: 2}),
+	} {
+		if err = topo.applyDedup(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	savings, err := topo.DedupSavings("pics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if savings.Files != 2 || savings.References != 4 || savings.SavedBytes != 200 {
+		t.Errorf("unexpected savings of pics: %+v", savings)
+	}
+	collection, e, err := topo.DedupEntry("2,01")
+	if err != nil || collection != "logs" || e == nil || e.Refs != 2 {
+		t.Errorf("unexpected entry of 2,01: %s %+v %v", collection, e, err)
+	}
+
+	// the last reference is gone
+	if err = topo.applyDedup(NewDedupCommand("pics", "h2", &DedupEntry{Fid: "1,02", Size: 10})); err != nil {
+		t.Fatal(err)
+	}
+	if _, e, _ = topo.DedupEntry("1,02"); e != nil {
+		t.Errorf("entry without references should be deleted: %+v", e)
+	}
+
+	// restarted with a cleared raft log
+	topo.dedup.Close()
+	other, _ := setupBalanceTopology(t, "000", nil)
+	if err = other.LoadDedupIndex(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer other.dedup.Close()
+	if _, e, _ = other.DedupEntry("1,01"); e == nil || e.Refs != 3 || e.Size != 100 {
+		t.Errorf("entry of 1,01 is not reloaded: %+v", e)
+	}
+
+	if err = other.applyDedup(&DedupCommand{Collection: "pics", DropCollection: true}); err != nil {
+		t.Fatal(err)
+	}
+	if savings, _ = other.DedupSavings("pics"); savings.Files != 0 {
+		t.Errorf("entries of dropped collection are left: %+v", savings)
+	}
+	if _, e, _ = other.DedupEntry("1,01"); e != nil {
+		t.Errorf("fid of dropped collection is left: %+v", e)
+	}
+	if savings, _ = other.DedupSavings("logs"); savings.Files != 1 || savings.SavedBytes != 100 {
+		t.Errorf("other collection should be kept: %+v", savings)
+	}
+}
@@ -0,0 +1,107 @@
+package topology
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+type EcShardLocations struct {
+	Collection string
+	Locations  [storage.EcTotalShards][]*DataNode
+}
+
+func (loc *EcShardLocations) AddShard(shardId int, dn *DataNode) bool {
+	for _, n := range loc.Locations[shardId] {
+		if n.Id() == dn.Id() {
+			return false
+		}
+	}
+	loc.Locations[shardId] = append(loc.Locations[shardId], dn)
+	return true
+}
+
+func (loc *EcShardLocations) DeleteShard(shardId int, dn *DataNode) bool {
+	for i, n := range loc.Locations[shardId] {
+		if n.Id() == dn.Id() {
+			loc.Locations[shardId] = append(loc.Locations[shardId][:i], loc.Locations[shardId][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (loc *EcShardLocations) ShardCount() (count int) {
+	for _, l := range loc.Locations {
+		if len(l) > 0 {
+			count++
+		}
+	}
+	return
+}
+
+// DataNodes lists the distinct data nodes holding any shard
+func (loc *EcShardLocations) DataNodes() (dns []*DataNode) {
+	seen := make(map[*DataNode]bool)
+	for _, l := range loc.Locations {
+		for _, dn := range l {
+			if !seen[dn] {
+				seen[dn] = true
+				dns = append(dns, dn)
+			}
+		}
+	}
+	return
+}
+
+func (t *Topology) RegisterEcShards(ei *storage.EcVolumeInfo, dn *DataNode) {
+	t.ecShardMapLock.Lock()
+	defer t.ecShardMapLock.Unlock()
+	loc, ok := t.ecShardMap[ei.Id]
+	if !ok {
+		loc = &EcShardLocations{Collection: ei.Collection}
+		t.ecShardMap[ei.Id] = loc
+	}
+	for _, shardId := range ei.ShardBits.ShardIds() {
+		loc.AddShard(shardId, dn)
+	}
+}
+
+func (t *Topology) UnRegisterEcShards(ei *storage.EcVolumeInfo, dn *DataNode) {
+	glog.Infof("removing ec shards info:%+v", ei)
+	t.ecShardMapLock.Lock()
+	defer t.ecShardMapLock.Unlock()
+	loc, ok := t.ecShardMap[ei.Id]
+	if !ok {
+		return
+	}
+	for _, shardId := range ei.ShardBits.ShardIds() {
+		loc.DeleteShard(shardId, dn)
+	}
+	if loc.ShardCount() == 0 {
+		delete(t.ecShardMap, ei.Id)
+	}
+}
+
+// LookupEcShards returns a copy of the shard locations of an ec volume
+func (t *Topology) LookupEcShards(vid storage.VolumeId) (*EcShardLocations, bool) {
+	t.ecShardMapLock.RLock()
+	defer t.ecShardMapLock.RUnlock()
+	loc, ok := t.ecShardMap[vid]
+	if !ok {
+		return nil, false
+	}
+	ret := &EcShardLocations{Collection: loc.Collection}
+	for i, l := range loc.Locations {
+		ret.Locations[i] = append([]*DataNode(nil), l...)
+	}
+	return ret, true
+}
+
+func (t *Topology) ListEcVolumes() (vids []storage.VolumeId) {
+	t.ecShardMapLock.RLock()
+	defer t.ecShardMapLock.RUnlock()
+	for vid := range t.ecShardMap {
+		vids = append(vids, vid)
+	}
+	return
+}
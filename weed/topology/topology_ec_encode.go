@@ -0,0 +1,168 @@
+package topology
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const EcTaskTimeout = 3 * time.Hour
+
+/*
+EcEncodeVolume converts a sealed volume into erasure coded shards:
+1. set all replicas readonly
+2. generate all the shards on one replica, which drops its volume on commit
+3. spread the shards to other data nodes, preferring different racks
+4. delete the shards moved away from the source, and the other replicas if all the shards are spread
+*/
+func (t *Topology) EcEncodeVolume(vid storage.VolumeId, collection string) error {
+	locationList := t.Lookup(collection, vid)
+	if locationList == nil || locationList.Length() == 0 {
+		return fmt.Errorf("volume %v is not found", vid)
+	}
+	if _, ok := t.LookupEcShards(vid); ok {
+		return fmt.Errorf("volume %v is already erasure coded", vid)
+	}
+	src := locationList.Head()
+	vi := src.GetVolume(vid)
+	if vi == nil {
+		return fmt.Errorf("volume %v is not found on %s", vid, src.Url())
+	}
+	if !vi.ReadOnly && vi.Size < t.volumeSizeLimit {
+		return fmt.Errorf("volume %v is not full or readonly yet", vid)
+	}
+	collection = vi.Collection
+	if !SetVolumeReadonly(locationList, vid.String(), true) {
+		return fmt.Errorf("set volume readonly failed, vid=%v", vid)
+	}
+
+	tc, e := storage.NewTaskCli(src.Url(), storage.TaskEcEncode, storage.TaskParams{
+		"volume": vid.String(),
+	})
+	if e != nil {
+		return e
+	}
+	if e = tc.WaitAndQueryResult(EcTaskTimeout); e != nil {
+		tc.Clean()
+		return e
+	}
+	if e = tc.Commit(); e != nil {
+		return e
+	}
+	glog.V(0).Infof("volume %v is erasure coded on %s", vid, src.Url())
+
+	failedCopies := 0
+	for dn, shardIds := range t.planEcShards(src) {
+		if e := copyEcShards(vid, collection, src, dn, shardIds); e != nil {
+			glog.V(0).Infof("copy ec volume %v shards %v to %s error: %v", vid, shardIds, dn.Url(), e)
+			failedCopies++
+			continue
+		}
+		if _, e := util.RemoteApiCall(src.Url(), "/admin/ec/delete", url.Values{
+			"volume": {vid.String()},
+			"shards": {joinShardIds(shardIds)},
+		}); e != nil {
+			glog.V(0).Infof("delete ec volume %v shards %v on %s error: %v", vid, shardIds, src.Url(), e)
+		}
+	}
+
+	// the shards not copied are still on the source, but keep the full replicas until they are spread
+	if failedCopies > 0 {
+		return fmt.Errorf("%d shard copies of ec volume %v failed, the other replicas are kept", failedCopies, vid)
+	}
+	for _, dn := range locationList.AllDataNode() {
+		if dn == src {
+			continue
+		}
+		if _, e := util.RemoteApiCall(dn.Url(), "/admin/delete_volume", url.Values{"volume": {vid.String()}}); e != nil {
+			glog.V(0).Infof("delete replica of volume %v on %s error: %v", vid, dn.Url(), e)
+		}
+	}
+	return nil
+}
+
+// planEcShards assigns the shards to the alive data nodes in turn, racks are interleaved,
+// the shards assigned to the source data node are not listed
+func (t *Topology) planEcShards(src *DataNode) map[*DataNode][]int {
+	var racks [][]*DataNode
+	srcRackIndex := 0
+	for _, c := range t.Children() {
+		for _, r := range c.(*DataCenter).Children() {
+			var dns []*DataNode
+			for _, n := range r.(*Rack).Children() {
+				dn := n.(*DataNode)
+				if dn.IsDead() {
+					continue
+				}
+				if dn == src {
+					srcRackIndex = len(racks)
+					dns = append([]*DataNode{dn}, dns...)
+				} else {
+					dns = append(dns, dn)
+				}
+			}
+			if len(dns) > 0 {
+				racks = append(racks, dns)
+			}
+		}
+	}
+	if len(racks) == 0 {
+		return nil
+	}
+	// start from the source, so it keeps the first shard
+	racks = append(racks[srcRackIndex:], racks[:srcRackIndex]...)
+	maxRackSize := 0
+	for _, dns := range racks {
+		if len(dns) > maxRackSize {
+			maxRackSize = len(dns)
+		}
+	}
+	// the i-th node of every rack, until there is a node for every shard
+	var nodes []*DataNode
+	for i := 0; i < maxRackSize && len(nodes) < storage.EcTotalShards; i++ {
+		for _, dns := range racks {
+			if i < len(dns) && len(nodes) < storage.EcTotalShards {
+				nodes = append(nodes, dns[i])
+			}
+		}
+	}
+	plan := make(map[*DataNode][]int)
+	for shardId := 0; shardId < storage.EcTotalShards; shardId++ {
+		dn := nodes[shardId%len(nodes)]
+		if dn != src {
+			plan[dn] = append(plan[dn], shardId)
+		}
+	}
+	return plan
+}
+
+func copyEcShards(vid storage.VolumeId, collection string, src, dst *DataNode, shardIds []int) error {
+	tc, e := storage.NewTaskCli(dst.Url(), storage.TaskEcCopy, storage.TaskParams{
+		"volume":     vid.String(),
+		"collection": collection,
+		"source":     src.Url(),
+		"shards":     joinShardIds(shardIds),
+	})
+	if e != nil {
+		return e
+	}
+	if e = tc.WaitAndQueryResult(EcTaskTimeout); e != nil {
+		tc.Clean()
+		return e
+	}
+	return tc.Commit()
+}
+
+func joinShardIds(shardIds []int) string {
+	var ids []string
+	for _, id := range shardIds {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,47 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func TestPlanEcShards(t *testing.T) {
+	// the source is alone in its rack, the other rack has more nodes than the first one
+	topo, dns := setupBalanceTopology(t, "000", map[string]map[string][]int{
+		"rack1": {"server1": {1}},
+		"rack2": {"server2": {}, "server3": {}, "server4": {}, "server5": {}, "server6": {}},
+	})
+	plan := topo.planEcShards(dns["server1"])
+	if len(plan) != 5 {
+		t.Fatalf("expect the shards on the 5 other nodes, got %v", plan)
+	}
+	assigned := 0
+	for dn, shardIds := range plan {
+		if dn == dns["server1"] || len(shardIds) < 2 || len(shardIds) > 3 {
+			t.Errorf("unexpected shards %v on %s", shardIds, dn.Id())
+		}
+		assigned += len(shardIds)
+	}
+	// the source keeps every 6th shard
+	if kept := (storage.EcTotalShards + 5) / 6; assigned != storage.EcTotalShards-kept {
+		t.Errorf("expect %d shards assigned, got %d", storage.EcTotalShards-kept, assigned)
+	}
+
+	// no more nodes than the shards are used
+	servers := make(map[string][]int)
+	for i := 0; i < storage.EcTotalShards+4; i++ {
+		servers[string(rune('a'+i))] = nil
+	}
+	topo, _ = setupBalanceTopology(t, "000", map[string]map[string][]int{"rack1": servers})
+	dn := topo.Children()[0].Children()[0].Children()[0].(*DataNode)
+	plan = topo.planEcShards(dn)
+	for _, shardIds := range plan {
+		if len(shardIds) != 1 {
+			t.Errorf("expect one shard per node, got %v", plan)
+		}
+	}
+	if len(plan) != storage.EcTotalShards-1 {
+		t.Errorf("expect %d other nodes, got %d", storage.EcTotalShards-1, len(plan))
+	}
+}
@@ -14,6 +14,7 @@ func (t *Topology) StartRefreshWritableVolumes() {
 			if t.IsLeader() {
 				freshThreshHold := time.Now().Unix() - 3*t.pulse //3 times of sleep interval
 				t.CollectDeadNodeAndFullVolumes(freshThreshHold, t.volumeSizeLimit)
+				t.RefreshCollectionUsage(time.Now())
 			}
 			time.Sleep(time.Duration(float32(t.pulse*1e3)*(1+rand.Float32())) * time.Millisecond)
 		}
@@ -70,6 +71,9 @@ func (t *Topology) UnRegisterDataNode(dn *DataNode) {
 		vl := t.GetVolumeLayout(v.Collection, v.Ttl)
 		vl.SetVolumeUnavailable(dn, v.Id)
 	}
+	for _, ei := range dn.EcShards() {
+		t.UnRegisterEcShards(ei, dn)
+	}
 	dn.UpAdjustVolumeCountDelta(-dn.GetVolumeCount())
 	dn.UpAdjustActiveVolumeCountDelta(-dn.GetActiveVolumeCount())
 	dn.UpAdjustMaxVolumeCountDelta(-dn.GetMaxVolumeCount())
@@ -82,4 +86,7 @@ func (t *Topology) RegisterRecoveredDataNode(dn *DataNode) {
 			vl.SetVolumeAvailable(dn, v.Id)
 		}
 	}
+	for _, ei := range dn.EcShards() {
+		t.RegisterEcShards(ei, dn)
+	}
 }
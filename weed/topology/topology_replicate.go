@@ -57,6 +57,10 @@ func (t *ReplicateTask) WorkingDataNodes() []*DataNode {
 	}
 }
 
+func (t *ReplicateTask) Finish() {
+	t.DstDN.UpAdjustPlannedVolumeCountDelta(-1)
+}
+
 func (t *ReplicateTask) String() string {
 	return fmt.Sprintf("<Replicate> vid: %v, src: %s, dst: %s", t.Vid, t.SrcDN.Url(), t.DstDN.Url())
 }
@@ -65,7 +69,7 @@ func planReplicateTasks(t *Topology) (tasks []*ReplicateTask) {
 	for i1 := range t.collectionMap.IterItems() {
 		c := i1.Value.(*Collection)
 		glog.V(0).Infoln("checking replicate on collection:", c.Name)
-		growOption := &VolumeGrowOption{ReplicaPlacement: c.rp}
+		growOption := &VolumeGrowOption{ReplicaPlacement: c.ReplicaPlacement()}
 		for i2 := range c.storageType2VolumeLayout.IterItems() {
 			if i2.Value == nil {
 				continue
@@ -101,45 +105,50 @@ func planReplicateTasks(t *Topology) (tasks []*ReplicateTask) {
 	return
 }
 
-func (topo *Topology) CheckReplicate() {
-	isReplicateCheckerRunning = true
-	defer func() {
-		isReplicateCheckerRunning = false
-	}()
-	glog.V(1).Infoln("Start replicate checker on demand")
+// clusterTask is a task which runs across data nodes, driven by the master
+type clusterTask interface {
+	Run(topo *Topology) error
+	WorkingDataNodes() []*DataNode
+	Finish()
+	String() string
+}
+
+// runClusterTasks runs the tasks concurrently, but only one task will run on a data node at a time
+func runClusterTasks(topo *Topology, tasks []clusterTask) {
 	busyDataNodes := make(map[*DataNode]int)
 	taskCount := 0
 	taskQueue := list.New()
-	for _, t := range planReplicateTasks(topo) {
+	for _, t := range tasks {
 		taskQueue.PushBack(t)
 		taskCount++
 	}
-	taskChan := make(chan *ReplicateTask)
+	taskChan := make(chan clusterTask)
 	for taskCount > 0 {
 	TaskQueueLoop:
-		for e := taskQueue.Front(); e != nil; e = e.Next() {
-			task := e.Value.(*ReplicateTask)
+		for e := taskQueue.Front(); e != nil; {
+			task := e.Value.(clusterTask)
 			//only one task will run on the data node
 			dns := task.WorkingDataNodes()
 			for _, dn := range dns {
 				if busyDataNodes[dn] > 0 {
+					e = e.Next()
 					continue TaskQueueLoop
 				}
 			}
 			for _, dn := range dns {
 				busyDataNodes[dn]++
 			}
-			go func(t *ReplicateTask) {
+			go func(t clusterTask) {
 				if e := t.Run(topo); e != nil {
-					glog.V(0).Infof("ReplicateTask run error, vid: %v, dst: %s. %v", t.Vid, t.DstDN.Url(), e)
+					glog.V(0).Infof("%s run error: %v", t.String(), e)
 				} else {
-					glog.V(2).Infof("ReplicateTask finished, vid: %v, dst: %s", t.Vid, t.DstDN.Url())
-
+					glog.V(2).Infof("%s finished", t.String())
 				}
 				taskChan <- t
 			}(task)
+			next := e.Next()
 			taskQueue.Remove(e)
-
+			e = next
 		}
 
 		finishedTask := <-taskChan
@@ -149,8 +158,21 @@ func (topo *Topology) CheckReplicate() {
 			}
 		}
 		taskCount--
-		finishedTask.DstDN.UpAdjustPlannedVolumeCountDelta(-1)
+		finishedTask.Finish()
+	}
+}
+
+func (topo *Topology) CheckReplicate() {
+	isReplicateCheckerRunning = true
+	defer func() {
+		isReplicateCheckerRunning = false
+	}()
+	glog.V(1).Infoln("Start replicate checker on demand")
+	var tasks []clusterTask
+	for _, t := range planReplicateTasks(topo) {
+		tasks = append(tasks, t)
 	}
+	runClusterTasks(topo, tasks)
 	glog.V(0).Infoln("finish replicate check.")
 }
 
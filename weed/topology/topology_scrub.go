@@ -0,0 +1,168 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+var (
+	isScrubberRunning = false
+)
+
+const ScrubTaskTimeout = 24 * time.Hour
+
+// ScrubTask verifies the needle checksums of one volume replica, the
+// corrupted needles are repaired with the copies on the other replicas.
+type ScrubTask struct {
+	Vid        storage.VolumeId
+	Collection string
+	DN         *DataNode
+	Replicas   []*DataNode
+	RateMB     int
+}
+
+func (t *ScrubTask) Run(topo *Topology) error {
+	var replicas []string
+	for _, dn := range t.Replicas {
+		replicas = append(replicas, dn.Url())
+	}
+	tc, e := storage.NewTaskCli(t.DN.Url(), storage.TaskScrub, storage.TaskParams{
+		"volume":     t.Vid.String(),
+		"collection": t.Collection,
+		"replicas":   strings.Join(replicas, ","),
+		"rateMB":     strconv.Itoa(t.RateMB),
+	})
+	if e != nil {
+		return e
+	}
+	if e = tc.WaitAndQueryResult(ScrubTaskTimeout); e != nil {
+		tc.Clean()
+		return e
+	}
+	return tc.Commit()
+}
+
+func (t *ScrubTask) WorkingDataNodes() []*DataNode {
+	return []*DataNode{t.DN}
+}
+
+func (t *ScrubTask) Finish() {
+}
+
+func (t *ScrubTask) String() string {
+	return fmt.Sprintf("<Scrub> vid: %v, dn: %s", t.Vid, t.DN.Url())
+}
+
+// planScrubTasks plans a task for each replica of the volumes, in all collections if collection is empty
+func planScrubTasks(t *Topology, collection string, rateMB int) (tasks []*ScrubTask) {
+	for i1 := range t.collectionMap.IterItems() {
+		c := i1.Value.(*Collection)
+		if collection != "" && c.Name != collection {
+			continue
+		}
+		for i2 := range c.storageType2VolumeLayout.IterItems() {
+			if i2.Value == nil {
+				continue
+			}
+			volumeLayout := i2.Value.(*VolumeLayout)
+			for _, vid := range volumeLayout.ListVolumeId() {
+				locationList := volumeLayout.Lookup(vid)
+				if locationList == nil {
+					continue
+				}
+				dns := locationList.AllDataNode()
+				for i, dn := range dns {
+					var replicas []*DataNode
+					replicas = append(replicas, dns[:i]...)
+					replicas = append(replicas, dns[i+1:]...)
+					tasks = append(tasks, &ScrubTask{
+						Vid:        vid,
+						Collection: c.Name,
+						DN:         dn,
+						Replicas:   replicas,
+						RateMB:     rateMB,
+					})
+				}
+			}
+		}
+	}
+	return
+}
+
+func (topo *Topology) Scrub(collection string, rateMB int) {
+	isScrubberRunning = true
+	defer func() {
+		isScrubberRunning = false
+	}()
+	glog.V(1).Infoln("Start scrubbing on demand")
+	var tasks []clusterTask
+	for _, t := range planScrubTasks(topo, collection, rateMB) {
+		tasks = append(tasks, t)
+	}
+	runClusterTasks(topo, tasks)
+	glog.V(0).Infoln("finish scrubbing.")
+}
+
+func (topo *Topology) StartScrub(collection string, rateMB int) {
+	if isScrubberRunning {
+		return
+	}
+	go topo.Scrub(collection, rateMB)
+}
+
+func (topo *Topology) IsScrubbing() bool {
+	return isScrubberRunning
+}
+
+// CorruptionReport is the result of the last scrub of a volume replica with corrupted needles
+type CorruptionReport struct {
+	Volume     storage.VolumeId `json:"volume"`
+	Collection string           `json:"collection,omitempty"`
+	Url        string           `json:"url"`
+	Checked    int              `json:"checked"`
+	Corrupted  []string         `json:"corrupted"`
+	Repaired   []string         `json:"repaired,omitempty"`
+	Time       time.Time        `json:"time"`
+}
+
+// ReportCorruption keeps the report if any needle is corrupted, or forgets the
+// earlier report of the volume replica
+func (topo *Topology) ReportCorruption(r *CorruptionReport) {
+	key := r.Url + "/" + r.Volume.String()
+	topo.corruptionLock.Lock()
+	defer topo.corruptionLock.Unlock()
+	if len(r.Corrupted) == 0 {
+		delete(topo.corruptionReports, key)
+		return
+	}
+	glog.V(0).Infof("volume %v on %s has %d corrupted needles, %d repaired", r.Volume, r.Url, len(r.Corrupted), len(r.Repaired))
+	topo.corruptionReports[key] = r
+}
+
+type corruptionReportList []*CorruptionReport
+
+func (s corruptionReportList) Len() int      { return len(s) }
+func (s corruptionReportList) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s corruptionReportList) Less(i, j int) bool {
+	if s[i].Volume != s[j].Volume {
+		return s[i].Volume < s[j].Volume
+	}
+	return s[i].Url < s[j].Url
+}
+
+func (topo *Topology) CorruptionReports() []*CorruptionReport {
+	topo.corruptionLock.RLock()
+	reports := make(corruptionReportList, 0, len(topo.corruptionReports))
+	for _, r := range topo.corruptionReports {
+		reports = append(reports, r)
+	}
+	topo.corruptionLock.RUnlock()
+	sort.Sort(reports)
+	return reports
+}
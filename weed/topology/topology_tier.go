@@ -0,0 +1,238 @@
+package topology
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+var (
+	isTierMoverRunning = false
+)
+
+const TierTaskTimeout = time.Hour
+
+// TierPolicy decides which volumes are moved from the hot tier to the cold tier.
+// A volume is moved when it is read only or full enough, has not been written
+// for MinAge, and is read no more than MaxReadsPerHour.
+type TierPolicy struct {
+	Hot             string
+	Cold            string
+	MinAge          time.Duration
+	MinFullness     float64 // of the volume size limit, 0 to 1
+	MaxReadsPerHour float64
+	Interval        time.Duration // 0 to only move on demand
+}
+
+func (p *TierPolicy) String() string {
+	return fmt.Sprintf("hot:%s, cold:%s, minAge:%v, minFullness:%v, maxReadsPerHour:%v, interval:%v",
+		p.Hot, p.Cold, p.MinAge, p.MinFullness, p.MaxReadsPerHour, p.Interval)
+}
+
+// TierTask moves a volume replica to the cold tier, on the same data node if it has
+// a free slot there, otherwise on another one. The volume is always readable.
+type TierTask struct {
+	Vid        storage.VolumeId
+	Collection string
+	Tier       string
+	SrcDN      *DataNode
+	DstDN      *DataNode
+}
+
+func (t *TierTask) Run(topo *Topology) error {
+	locationList := topo.Lookup(t.Collection, t.Vid)
+	if locationList == nil || !locationList.ContainsDataNode(t.SrcDN) {
+		return fmt.Errorf("volume %v is not found on %s", t.Vid, t.SrcDN.Url())
+	}
+	if t.DstDN != t.SrcDN && locationList.ContainsDataNode(t.DstDN) {
+		return fmt.Errorf("volume %v already exists on %s", t.Vid, t.DstDN.Url())
+	}
+	wasReadonly := false
+	if v := t.SrcDN.GetVolume(t.Vid); v != nil {
+		wasReadonly = v.ReadOnly
+	}
+	locationList = locationList.Duplicate()
+	if !SetVolumeReadonly(locationList, t.Vid.String(), true) {
+		if !wasReadonly {
+			SetVolumeReadonly(locationList, t.Vid.String(), false)
+		}
+		return fmt.Errorf("set volume readonly failed, vid=%v", t.Vid)
+	}
+	tc, e := storage.NewTaskCli(t.DstDN.Url(), storage.TaskTier, storage.TaskParams{
+		"volume":     t.Vid.String(),
+		"source":     t.SrcDN.Url(),
+		"collection": t.Collection,
+		"tier":       t.Tier,
+	})
+	if e == nil {
+		if e = tc.WaitAndQueryResult(TierTaskTimeout); e != nil {
+			tc.Clean()
+		} else {
+			e = tc.Commit()
+		}
+	}
+	if e == nil && t.DstDN != t.SrcDN {
+		locationList.Remove(t.SrcDN)
+		locationList.Set(t.DstDN)
+	}
+	if !wasReadonly {
+		SetVolumeReadonly(locationList, t.Vid.String(), false)
+	}
+	return e
+}
+
+func (t *TierTask) WorkingDataNodes() []*DataNode {
+	if t.DstDN == t.SrcDN {
+		return []*DataNode{t.SrcDN}
+	}
+	return []*DataNode{
+		t.SrcDN,
+		t.DstDN,
+	}
+}
+
+func (t *TierTask) Finish() {
+	if t.DstDN != t.SrcDN {
+		t.DstDN.UpAdjustPlannedVolumeCountDelta(-1)
+	}
+}
+
+func (t *TierTask) String() string {
+	return fmt.Sprintf("<Tier> vid: %v, tier: %s, src: %s, dst: %s", t.Vid, t.Tier, t.SrcDN.Url(), t.DstDN.Url())
+}
+
+type readSample struct {
+	count uint64
+	time  time.Time
+}
+
+// readRate is the reads per hour of the volume replica since the last sample,
+// it is unknown for the first sample or after the volume is reloaded.
+func (t *Topology) readRate(dn *DataNode, v *storage.VolumeInfo, now time.Time) (perHour float64, ok bool) {
+	key := dn.Url() + "/" + v.Id.String()
+	t.tierLock.Lock()
+	defer t.tierLock.Unlock()
+	last, found := t.readSamples[key]
+	t.readSamples[key] = readSample{count: v.ReadCount, time: now}
+	if !found || v.ReadCount < last.count || !now.After(last.time) {
+		return 0, false
+	}
+	return float64(v.ReadCount-last.count) / now.Sub(last.time).Hours(), true
+}
+
+func (t *Topology) isColdCandidate(p *TierPolicy, dn *DataNode, v *storage.VolumeInfo, now time.Time) bool {
+	readsPerHour, ok := t.readRate(dn, v, now)
+	if v.Tier != p.Hot {
+		return false
+	}
+	full := t.volumeSizeLimit > 0 && float64(v.Size) >= p.MinFullness*float64(t.volumeSizeLimit)
+	if !v.ReadOnly && !full {
+		return false
+	}
+	if now.Sub(time.Unix(int64(v.LastModified), 0)) < p.MinAge {
+		return false
+	}
+	return ok && readsPerHour <= p.MaxReadsPerHour
+}
+
+// planTierTasks moves the cold volumes to a free slot of the cold tier, the same data node is
+// preferred, and another data node is only used if the replica placement is still satisfied.
+func planTierTasks(t *Topology, p *TierPolicy, now time.Time) (tasks []*TierTask) {
+	var nodes []*DataNode
+	t.WalkDataNode(func(dn *DataNode) error {
+		if !dn.IsDead() {
+			nodes = append(nodes, dn)
+		}
+		return nil
+	})
+	planned := make(map[*DataNode]int) // cold slots taken in the plan
+	freeColdSpace := func(dn *DataNode) int {
+		return dn.FreeTierSpace(p.Cold) - planned[dn]
+	}
+	moved := make(map[storage.VolumeId]bool)
+	for _, dn := range nodes {
+		for _, v := range dn.Volumes() {
+			if !t.isColdCandidate(p, dn, v, now) || moved[v.Id] {
+				continue
+			}
+			task := &TierTask{Vid: v.Id, Collection: v.Collection, Tier: p.Cold, SrcDN: dn}
+			if freeColdSpace(dn) > 0 {
+				task.DstDN = dn
+			} else {
+				for _, other := range nodes {
+					if other != dn && freeColdSpace(other) > 0 && canMoveVolume(t, v, dn, other) {
+						task.DstDN = other
+						break
+					}
+				}
+			}
+			if task.DstDN == nil {
+				glog.V(1).Infof("no free slot on tier %s for volume %v on %s", p.Cold, v.Id, dn.Url())
+				continue
+			}
+			planned[task.DstDN]++
+			if task.DstDN != dn {
+				task.DstDN.UpAdjustPlannedVolumeCountDelta(1)
+				// the other replicas are moved in the next run, with the new locations
+				moved[v.Id] = true
+			}
+			tasks = append(tasks, task)
+			glog.V(0).Infof("add tier task, vid: %v, tier: %s, src: %s, dst: %s", task.Vid, task.Tier, task.SrcDN.Url(), task.DstDN.Url())
+		}
+	}
+	return
+}
+
+func (topo *Topology) SetTierPolicy(p *TierPolicy) {
+	topo.tierLock.Lock()
+	defer topo.tierLock.Unlock()
+	topo.tierPolicy = p
+}
+
+func (topo *Topology) GetTierPolicy() *TierPolicy {
+	topo.tierLock.Lock()
+	defer topo.tierLock.Unlock()
+	return topo.tierPolicy
+}
+
+func (topo *Topology) MoveToColdTier() {
+	isTierMoverRunning = true
+	defer func() {
+		isTierMoverRunning = false
+	}()
+	p := topo.GetTierPolicy()
+	if p == nil {
+		return
+	}
+	glog.V(1).Infoln("Start moving volumes to the cold tier with", p)
+	var tasks []clusterTask
+	for _, t := range planTierTasks(topo, p, time.Now()) {
+		tasks = append(tasks, t)
+	}
+	runClusterTasks(topo, tasks)
+	glog.V(0).Infoln("finish moving volumes to tier", p.Cold)
+}
+
+func (topo *Topology) StartMoveToColdTier() {
+	if isTierMoverRunning {
+		return
+	}
+	go topo.MoveToColdTier()
+}
+
+// StartTierPolicy applies the tier policy periodically on the leader
+func (topo *Topology) StartTierPolicy(p *TierPolicy) {
+	topo.SetTierPolicy(p)
+	if p.Interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(p.Interval) {
+			if topo.IsLeader() {
+				topo.StartMoveToColdTier()
+			}
+		}
+	}()
+}
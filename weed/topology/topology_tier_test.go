@@ -0,0 +1,71 @@
+package topology
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func TestPlanTierTasks(t *testing.T) {
+	topo, dns := setupBalanceTopology(t, "000", map[string]map[string][]int{
+		"rack1": {"server1": {1, 2, 3, 4}, "server2": {}},
+	})
+	dns["server1"].SetTiers(map[string]int{"hot": 5, "cold": 1})
+	dns["server2"].SetTiers(map[string]int{"cold": 5})
+	now := time.Now()
+	old := uint64(now.Add(-30 * 24 * time.Hour).Unix())
+	for _, vi := range dns["server1"].Volumes() {
+		vi.Tier, vi.ReadOnly, vi.LastModified = "hot", true, old
+	}
+	dns["server1"].GetVolume(4).LastModified = uint64(now.Unix())
+	if free := dns["server1"].FreeTierSpace("hot"); free != 1 {
+		t.Errorf("expect 1 free hot slot, got %d", free)
+	}
+	if free := topo.FreeTierSpace("cold"); free != 6 {
+		t.Errorf("expect 6 free cold slots, got %d", free)
+	}
+
+	p := &TierPolicy{Hot: "hot", Cold: "cold", MinAge: 24 * time.Hour, MaxReadsPerHour: 10}
+	if tasks := planTierTasks(topo, p, now); len(tasks) != 0 {
+		t.Fatalf("the read rate is unknown before the second run, got %v", tasks)
+	}
+	dns["server1"].GetVolume(3).ReadCount = 1000
+	tasks := planTierTasks(topo, p, now.Add(time.Hour))
+	if len(tasks) != 2 {
+		t.Fatalf("expect 2 tier tasks, got %v", tasks)
+	}
+	dst := make(map[storage.VolumeId]*DataNode)
+	for _, task := range tasks {
+		if task.SrcDN != dns["server1"] || task.Tier != "cold" {
+			t.Errorf("unexpected task %s", task)
+		}
+		dst[task.Vid] = task.DstDN
+	}
+	if dst[1] == dst[2] || dst[1] == nil || dst[2] == nil {
+		t.Errorf("expect one volume moved on the same node and one to the other: %v", tasks)
+	}
+	if dns["server2"].GetPlannedVolumeCount() != 1 || dns["server1"].GetPlannedVolumeCount() != 0 {
+		t.Errorf("planned volume count is only reserved on the other node")
+	}
+}
+
+func TestPickForWriteOnTier(t *testing.T) {
+	topo, dns := setupBalanceTopology(t, "000", map[string]map[string][]int{
+		"rack1": {"server1": {1, 2}},
+	})
+	dns["server1"].GetVolume(2).Tier = "cold"
+	vl := topo.GetVolumeLayout("", nil)
+	for i := 0; i < 10; i++ {
+		vid, _, _, err := vl.PickForWrite(1, &VolumeGrowOption{Tier: "cold"})
+		if err != nil || *vid != 2 {
+			t.Fatalf("expect volume 2 on the cold tier, got %v %v", vid, err)
+		}
+	}
+	if _, _, _, err := vl.PickForWrite(1, &VolumeGrowOption{Tier: "warm"}); err == nil {
+		t.Errorf("expect no writable volume on the warm tier")
+	}
+	if count := vl.GetActiveVolumeCount(&VolumeGrowOption{Tier: "cold"}); count != 1 {
+		t.Errorf("expect 1 active volume on the cold tier, got %d", count)
+	}
+}
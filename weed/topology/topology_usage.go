@@ -0,0 +1,155 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+const (
+	usageSampleInterval = time.Minute
+	usageHistoryLength  = 24 * 60
+)
+
+// CollectionUsage is the space used by a collection, aggregated from the heartbeats.
+// Bytes and FileCount count the live content once, whatever the replication is,
+// while PhysicalBytes counts every replica, including the deleted content not vacuumed yet.
+type CollectionUsage struct {
+	Collection    string    `json:"collection"`
+	Bytes         uint64    `json:"bytes"`
+	PhysicalBytes uint64    `json:"physicalBytes"`
+	FileCount     uint64    `json:"fileCount"`
+	VolumeCount   int       `json:"volumeCount"`
+	Time          time.Time `json:"time"`
+}
+
+func (u *CollectionUsage) String() string {
+	return fmt.Sprintf("%s bytes:%d files:%d volumes:%d", u.Collection, u.Bytes, u.FileCount, u.VolumeCount)
+}
+
+// CollectionQuota is the limits of a collection, 0 for no limit
+type CollectionQuota struct {
+	MaxBytes       uint64 `json:"maxBytes"`
+	MaxFileCount   uint64 `json:"maxFileCount"`
+	MaxVolumeCount int    `json:"maxVolumeCount"`
+}
+
+func (t *Topology) CollectionQuota(collection string) CollectionQuota {
+	return CollectionQuota{
+		MaxBytes:       t.CollectionSettings.GetMaxBytes(collection),
+		MaxFileCount:   t.CollectionSettings.GetMaxFileCount(collection),
+		MaxVolumeCount: t.CollectionSettings.GetMaxVolumeCount(collection),
+	}
+}
+
+// collectCollectionUsage sums the largest replica of each volume
+func collectCollectionUsage(t *Topology, now time.Time) map[string]*CollectionUsage {
+	largest := make(map[storage.VolumeId]*storage.VolumeInfo)
+	usages := make(map[string]*CollectionUsage)
+	t.WalkDataNode(func(dn *DataNode) error {
+		for _, v := range dn.Volumes() {
+			u, ok := usages[v.Collection]
+			if !ok {
+				u = &CollectionUsage{Collection: v.Collection, Time: now}
+				usages[v.Collection] = u
+			}
+			u.PhysicalBytes += v.Size
+			if l, found := largest[v.Id]; !found || l.Size < v.Size {
+				largest[v.Id] = v
+			}
+		}
+		return nil
+	})
+	for _, v := range largest {
+		u := usages[v.Collection]
+		u.VolumeCount++
+		if v.Size > v.DeletedByteCount {
+			u.Bytes += v.Size - v.DeletedByteCount
+		}
+		if v.FileCount > v.DeleteCount {
+			u.FileCount += uint64(v.FileCount - v.DeleteCount)
+		}
+	}
+	return usages
+}
+
+// RefreshCollectionUsage updates the usage of all collections,
+// and keeps a sample every minute for a day.
+func (t *Topology) RefreshCollectionUsage(now time.Time) {
+	usages := collectCollectionUsage(t, now)
+	t.usageLock.Lock()
+	defer t.usageLock.Unlock()
+	t.usages = usages
+	if now.Sub(t.usageSampled) < usageSampleInterval {
+		return
+	}
+	t.usageSampled = now
+	for collection, u := range usages {
+		history := append(t.usageHistory[collection], *u)
+		if len(history) > usageHistoryLength {
+			history = history[len(history)-usageHistoryLength:]
+		}
+		t.usageHistory[collection] = history
+	}
+	for collection, history := range t.usageHistory {
+		if _, ok := usages[collection]; ok {
+			continue
+		}
+		// the collection has no volumes left
+		if last := history[len(history)-1]; last.VolumeCount > 0 {
+			t.usageHistory[collection] = append(history, CollectionUsage{Collection: collection, Time: now})
+		} else if now.Sub(last.Time) > usageHistoryLength*usageSampleInterval {
+			delete(t.usageHistory, collection)
+		}
+	}
+}
+
+func (t *Topology) CollectionUsage(collection string) CollectionUsage {
+	t.usageLock.RLock()
+	defer t.usageLock.RUnlock()
+	if u, ok := t.usages[collection]; ok {
+		return *u
+	}
+	return CollectionUsage{Collection: collection, Time: t.usageSampled}
+}
+
+func (t *Topology) CollectionUsageHistory(collection string, since time.Time) (history []CollectionUsage) {
+	t.usageLock.RLock()
+	defer t.usageLock.RUnlock()
+	for _, u := range t.usageHistory[collection] {
+		if !u.Time.Before(since) {
+			history = append(history, u)
+		}
+	}
+	return
+}
+
+// UsageCollections lists the collections with volumes
+func (t *Topology) UsageCollections() (collections []string) {
+	t.usageLock.RLock()
+	defer t.usageLock.RUnlock()
+	for collection := range t.usages {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	return
+}
+
+// CheckCollectionQuota returns an error if the collection uses up its bytes or file count quota.
+// The volume count quota only stops growing new volumes.
+func (t *Topology) CheckCollectionQuota(collection string) error {
+	quota := t.CollectionQuota(collection)
+	if quota.MaxBytes == 0 && quota.MaxFileCount == 0 {
+		return nil
+	}
+	u := t.CollectionUsage(collection)
+	if quota.MaxBytes > 0 && u.Bytes >= quota.MaxBytes {
+		return fmt.Errorf("collection %s is over quota: %d bytes used, limit %d bytes", collection, u.Bytes, quota.MaxBytes)
+	}
+	if quota.MaxFileCount > 0 && u.FileCount >= quota.MaxFileCount {
+		return fmt.Errorf("collection %s is over quota: %d files, limit %d files", collection, u.FileCount, quota.MaxFileCount)
+	}
+	return nil
+}
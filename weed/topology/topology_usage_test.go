@@ -0,0 +1,67 @@
+package topology
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/weedpb"
+)
+
+func TestCollectionUsageAndQuota(t *testing.T) {
+	topo, dns := setupBalanceTopology(t, "001", map[string]map[string][]int{
+		"rack1": {"server1": {}, "server2": {}},
+	})
+	for _, dn := range []*DataNode{dns["server1"], dns["server2"]} {
+		dn.AddOrUpdateVolume(&storage.VolumeInfo{Id: 1, Collection: "pics", Size: 1000, DeletedByteCount: 200, FileCount: 10, DeleteCount: 2})
+	}
+	// a replica behind the other one
+	dns["server1"].AddOrUpdateVolume(&storage.VolumeInfo{Id: 2, Collection: "pics", Size: 300, FileCount: 3})
+	dns["server2"].AddOrUpdateVolume(&storage.VolumeInfo{Id: 2, Collection: "pics", Size: 500, FileCount: 5})
+	dns["server2"].AddOrUpdateVolume(&storage.VolumeInfo{Id: 3, Collection: "logs", Size: 100, FileCount: 1})
+
+	now := time.Now()
+	topo.RefreshCollectionUsage(now)
+	u := topo.CollectionUsage("pics")
+	if u.Bytes != 1300 || u.PhysicalBytes != 2800 || u.FileCount != 13 || u.VolumeCount != 2 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+	if collections := topo.UsageCollections(); len(collections) != 2 || collections[0] != "logs" {
+		t.Errorf("unexpected collections %v", collections)
+	}
+
+	if err := topo.CheckCollectionQuota("pics"); err != nil {
+		t.Errorf("no quota is set: %v", err)
+	}
+	topo.applyCollectionSetting(&weedpb.CollectionSetting{Collection: "pics", MaxBytes: 1300}, false)
+	if err := topo.CheckCollectionQuota("pics"); err == nil || !strings.Contains(err.Error(), "over quota") {
+		t.Errorf("expect over bytes quota, got %v", err)
+	}
+	rp, _ := storage.NewReplicaPlacementFromString("001")
+	if _, err := NewDefaultVolumeGrowth().AutomaticGrowByType(&VolumeGrowOption{Collection: "pics", ReplicaPlacement: rp}, topo); err == nil {
+		t.Errorf("volumes should not grow over quota")
+	}
+	topo.applyCollectionSetting(&weedpb.CollectionSetting{Collection: "pics", MaxBytes: 5000, MaxFileCount: 13}, false)
+	if err := topo.CheckCollectionQuota("pics"); err == nil || !strings.Contains(err.Error(), "files") {
+		t.Errorf("expect over file count quota, got %v", err)
+	}
+	if err := topo.CheckCollectionQuota("logs"); err != nil {
+		t.Errorf("quota of other collection is not set: %v", err)
+	}
+
+	// the history keeps a sample every minute
+	topo.RefreshCollectionUsage(now.Add(10 * time.Second))
+	dns["server2"].DeleteVolume(3)
+	topo.RefreshCollectionUsage(now.Add(2 * time.Minute))
+	if history := topo.CollectionUsageHistory("pics", now); len(history) != 2 {
+		t.Errorf("expect 2 samples, got %v", history)
+	}
+	history := topo.CollectionUsageHistory("logs", now)
+	if len(history) != 2 || history[1].VolumeCount != 0 {
+		t.Errorf("expect an empty sample after the volumes are gone, got %v", history)
+	}
+	if history = topo.CollectionUsageHistory("pics", now.Add(time.Minute)); len(history) != 1 {
+		t.Errorf("expect 1 sample since a minute later, got %v", history)
+	}
+}
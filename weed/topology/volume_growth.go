@@ -24,6 +24,7 @@ type VolumeGrowOption struct {
 	DataCenter       string
 	Rack             string
 	DataNode         string
+	Tier             string
 }
 
 type VolumeGrowth struct {
@@ -31,7 +32,28 @@ type VolumeGrowth struct {
 }
 
 func (o *VolumeGrowOption) String() string {
-	return fmt.Sprintf("Collection:%s, ReplicaPlacement:%v, Ttl:%v, DataCenter:%s, Rack:%s, DataNode:%s", o.Collection, o.ReplicaPlacement, o.Ttl, o.DataCenter, o.Rack, o.DataNode)
+	return fmt.Sprintf("Collection:%s, ReplicaPlacement:%v, Ttl:%v, DataCenter:%s, Rack:%s, DataNode:%s, Tier:%s", o.Collection, o.ReplicaPlacement, o.Ttl, o.DataCenter, o.Rack, o.DataNode, o.Tier)
+}
+
+// matchLocation tells whether the volume replica on the data node is in the preferred location
+func (o *VolumeGrowOption) matchLocation(dn *DataNode, vid storage.VolumeId) bool {
+	if o.DataCenter != "" {
+		if dn.GetDataCenter().Id() != NodeId(o.DataCenter) {
+			return false
+		}
+		if o.Rack != "" && dn.GetRack().Id() != NodeId(o.Rack) {
+			return false
+		}
+		if o.DataNode != "" && dn.Id() != NodeId(o.DataNode) {
+			return false
+		}
+	}
+	if o.Tier != "" {
+		if v := dn.GetVolume(vid); v == nil || v.Tier != o.Tier {
+			return false
+		}
+	}
+	return true
 }
 
 func NewDefaultVolumeGrowth() *VolumeGrowth {
@@ -55,6 +77,9 @@ func (vg *VolumeGrowth) findVolumeCount(copyCount int) (count int) {
 }
 
 func (vg *VolumeGrowth) AutomaticGrowByType(option *VolumeGrowOption, topo *Topology) (count int, err error) {
+	if err = topo.CheckCollectionQuota(option.Collection); err != nil {
+		return 0, err
+	}
 	count, err = vg.GrowByCountAndType(vg.findVolumeCount(option.ReplicaPlacement.GetCopyCount()), option, topo)
 	if count > 0 && count%option.ReplicaPlacement.GetCopyCount() == 0 {
 		return count, nil
@@ -65,7 +90,11 @@ func (vg *VolumeGrowth) GrowByCountAndType(targetCount int, option *VolumeGrowOp
 	vg.accessLock.Lock()
 	defer vg.accessLock.Unlock()
 
+	maxVolumeCount := topo.CollectionSettings.GetMaxVolumeCount(option.Collection)
 	for i := 0; i < targetCount; i++ {
+		if maxVolumeCount > 0 && topo.CollectionVolumeCount(option.Collection) >= maxVolumeCount {
+			return counter, fmt.Errorf("collection %s has reached the max volume count %d", option.Collection, maxVolumeCount)
+		}
 		if c, e := vg.findAndGrow(topo, option); e == nil {
 			counter += c
 		} else {
@@ -94,6 +123,7 @@ func (vg *VolumeGrowth) grow(topo *Topology, vid storage.VolumeId, option *Volum
 				Collection: option.Collection,
 				Ttl:        option.Ttl,
 				Version:    storage.CurrentVersion,
+				Tier:       option.Tier,
 			}
 			server.AddOrUpdateVolume(vi)
 			topo.RegisterVolumeLayout(vi, server)
@@ -114,14 +144,14 @@ func filterMainDataCenter(option *VolumeGrowOption, node Node) error {
 	if len(node.Children()) < rp.DiffRackCount+1 {
 		return fmt.Errorf("Only has %d racks, not enough for %d.", len(node.Children()), rp.DiffRackCount+1)
 	}
-	if node.FreeSpace() < rp.DiffRackCount+rp.SameRackCount+1 {
-		return fmt.Errorf("Free:%d < Expected:%d", node.FreeSpace(), rp.DiffRackCount+rp.SameRackCount+1)
+	if node.FreeTierSpace(option.Tier) < rp.DiffRackCount+rp.SameRackCount+1 {
+		return fmt.Errorf("Free:%d < Expected:%d", node.FreeTierSpace(option.Tier), rp.DiffRackCount+rp.SameRackCount+1)
 	}
 	possibleRacksCount := 0
 	for _, rack := range node.Children() {
 		possibleDataNodesCount := 0
 		for _, n := range rack.Children() {
-			if n.FreeSpace() >= 1 {
+			if n.FreeTierSpace(option.Tier) >= 1 {
 				possibleDataNodesCount++
 			}
 		}
@@ -140,8 +170,8 @@ func filterMainRack(option *VolumeGrowOption, node Node) error {
 		return fmt.Errorf("Not matching preferred rack:%s", option.Rack)
 	}
 	rp := option.ReplicaPlacement
-	if node.FreeSpace() < rp.SameRackCount+1 {
-		return fmt.Errorf("Free:%d < Expected:%d", node.FreeSpace(), rp.SameRackCount+1)
+	if node.FreeTierSpace(option.Tier) < rp.SameRackCount+1 {
+		return fmt.Errorf("Free:%d < Expected:%d", node.FreeTierSpace(option.Tier), rp.SameRackCount+1)
 	}
 	if len(node.Children()) < rp.SameRackCount+1 {
 		// a bit faster way to test free racks
@@ -149,7 +179,7 @@ func filterMainRack(option *VolumeGrowOption, node Node) error {
 	}
 	possibleDataNodesCount := 0
 	for _, n := range node.Children() {
-		if n.FreeSpace() >= 1 {
+		if n.FreeTierSpace(option.Tier) >= 1 {
 			possibleDataNodesCount++
 		}
 	}
@@ -159,13 +189,13 @@ func filterMainRack(option *VolumeGrowOption, node Node) error {
 	return nil
 }
 
-func makeExceptNodeFilter(nodes []Node) FilterNodeFn {
+func makeExceptNodeFilter(option *VolumeGrowOption, nodes []Node) FilterNodeFn {
 	m := make(map[NodeId]bool)
 	for _, n := range nodes {
 		m[n.Id()] = true
 	}
 	return func(dn Node) error {
-		if dn.FreeSpace() <= 0 {
+		if dn.FreeTierSpace(option.Tier) <= 0 {
 			return ErrFilterContinue
 		}
 		if _, ok := m[dn.Id()]; ok {
@@ -204,7 +234,7 @@ func FindEmptySlotsForOneVolume(topo *Topology, option *VolumeGrowOption, exists
 
 		if restCount > 0 {
 			restNodes, err = np.PickNodes(restCount,
-				makeExceptNodeFilter(existsNodes), pickNodesFn)
+				makeExceptNodeFilter(option, existsNodes), pickNodesFn)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -250,8 +280,8 @@ func FindEmptySlotsForOneVolume(topo *Topology, option *VolumeGrowOption, exists
 			if option.DataNode != "" && node.IsDataNode() && node.Id() != NodeId(option.DataNode) {
 				return fmt.Errorf("Not matching preferred data node:%s", option.DataNode)
 			}
-			if node.FreeSpace() < 1 {
-				return fmt.Errorf("Free:%d < Expected:%d", node.FreeSpace(), 1)
+			if node.FreeTierSpace(option.Tier) < 1 {
+				return fmt.Errorf("Free:%d < Expected:%d", node.FreeTierSpace(option.Tier), 1)
 			}
 			return nil
 		},
@@ -270,16 +300,16 @@ func FindEmptySlotsForOneVolume(topo *Topology, option *VolumeGrowOption, exists
 		additionServers = append(additionServers, server.(*DataNode))
 	}
 	for _, rack := range otherRacks {
-		r := rand.Intn(rack.FreeSpace())
-		if server, e := rack.ReserveOneVolume(r); e == nil {
+		r := rand.Intn(rack.FreeTierSpace(option.Tier))
+		if server, e := rack.ReserveOneVolume(r, option.Tier); e == nil {
 			additionServers = append(additionServers, server)
 		} else {
 			return additionServers, e
 		}
 	}
 	for _, dc := range otherDataCenters {
-		r := rand.Intn(dc.FreeSpace())
-		if server, e := dc.ReserveOneVolume(r); e == nil {
+		r := rand.Intn(dc.FreeTierSpace(option.Tier))
+		if server, e := dc.ReserveOneVolume(r, option.Tier); e == nil {
 			additionServers = append(additionServers, server)
 		} else {
 			return additionServers, e
@@ -99,6 +99,25 @@ func (vl *VolumeLayout) Lookup(vid storage.VolumeId) *VolumeLocationList {
 	return nil
 }
 
+func (vl *VolumeLayout) SetReplicaPlacement(rp *storage.ReplicaPlacement) {
+	vl.mutex.Lock()
+	defer vl.mutex.Unlock()
+	vl.rp = rp
+	for vid := range vl.vid2location {
+		if vl.isWritable(vid) {
+			vl.addToWritable(vid)
+		} else {
+			vl.removeFromWritable(vid)
+		}
+	}
+}
+
+func (vl *VolumeLayout) VolumeCount() int {
+	vl.mutex.RLock()
+	defer vl.mutex.RUnlock()
+	return len(vl.vid2location)
+}
+
 func (vl *VolumeLayout) ListVolumeServers() (nodes []*DataNode) {
 	vl.mutex.RLock()
 	defer vl.mutex.RUnlock()
@@ -126,7 +145,7 @@ func (vl *VolumeLayout) PickForWrite(count uint64, option *VolumeGrowOption) (*s
 		glog.V(0).Infoln("No more writable volumes!")
 		return nil, 0, nil, errors.New("No more writable volumes!")
 	}
-	if option.DataCenter == "" {
+	if option.DataCenter == "" && option.Tier == "" {
 		vid := vl.writables[rand.Intn(len_writers)]
 		locationList := vl.vid2location[vid]
 		if locationList != nil {
@@ -140,13 +159,7 @@ func (vl *VolumeLayout) PickForWrite(count uint64, option *VolumeGrowOption) (*s
 	for _, v := range vl.writables {
 		volumeLocationList := vl.vid2location[v]
 		for _, dn := range volumeLocationList.AllDataNode() {
-			if dn.GetDataCenter().Id() == NodeId(option.DataCenter) {
-				if option.Rack != "" && dn.GetRack().Id() != NodeId(option.Rack) {
-					continue
-				}
-				if option.DataNode != "" && dn.Id() != NodeId(option.DataNode) {
-					continue
-				}
+			if option.matchLocation(dn, v) {
 				counter++
 				if rand.Intn(counter) < 1 {
 					vid, locationList = v, volumeLocationList
@@ -154,25 +167,22 @@ func (vl *VolumeLayout) PickForWrite(count uint64, option *VolumeGrowOption) (*s
 			}
 		}
 	}
+	if locationList == nil {
+		return nil, 0, nil, fmt.Errorf("No writable volumes for %s", option)
+	}
 	return &vid, count, locationList.Duplicate(), nil
 }
 
 func (vl *VolumeLayout) GetActiveVolumeCount(option *VolumeGrowOption) int {
 	vl.mutex.RLock()
 	defer vl.mutex.RUnlock()
-	if option.DataCenter == "" {
+	if option.DataCenter == "" && option.Tier == "" {
 		return len(vl.writables)
 	}
 	counter := 0
 	for _, v := range vl.writables {
 		for _, dn := range vl.vid2location[v].AllDataNode() {
-			if dn.GetDataCenter().Id() == NodeId(option.DataCenter) {
-				if option.Rack != "" && dn.GetRack().Id() != NodeId(option.Rack) {
-					continue
-				}
-				if option.DataNode != "" && dn.Id() != NodeId(option.DataNode) {
-					continue
-				}
+			if option.matchLocation(dn, v) {
 				counter++
 			}
 		}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 	"os"
 
 	"strconv"
+	"sync"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/security"
@@ -24,6 +26,11 @@ import (
 var (
 	client    *http.Client
 	Transport *http.Transport
+
+	internalJwt     func() security.EncodedJwt
+	internalJwtLock sync.RWMutex
+
+	scheme = "http"
 )
 
 func init() {
@@ -33,9 +40,66 @@ func init() {
 	client = &http.Client{Transport: Transport}
 }
 
+// SetTLS makes the requests between the servers use https, verified by the config.
+// It should be called before any request.
+func SetTLS(config *tls.Config) {
+	Transport.TLSClientConfig = config
+	scheme = "https"
+}
+
+// Scheme is "https" if SetTLS is called, otherwise "http"
+func Scheme() string {
+	return scheme
+}
+
+// SetInternalJwt sets the JWT added to the API calls between the servers,
+// by the helpers below except HttpDo and DownloadUrl. Those may carry the
+// requests of the clients, which have only their own tokens.
+func SetInternalJwt(f func() security.EncodedJwt) {
+	internalJwtLock.Lock()
+	defer internalJwtLock.Unlock()
+	internalJwt = f
+}
+
+// InternalJwt returns the JWT for the API calls between the servers, empty if not set
+func InternalJwt() security.EncodedJwt {
+	internalJwtLock.RLock()
+	f := internalJwt
+	internalJwtLock.RUnlock()
+	if f == nil {
+		return ""
+	}
+	return f()
+}
+
+// InternalDo sends the API call between the servers with the internal JWT, if any
+func InternalDo(req *http.Request) (*http.Response, error) {
+	if jwt := InternalJwt(); jwt != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "BEARER "+string(jwt))
+	}
+	return client.Do(req)
+}
+
+func postForm(url string, values url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return InternalDo(req)
+}
+
+func getInternal(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return InternalDo(req)
+}
+
 func MkUrl(host, path string, args url.Values) string {
 	u := url.URL{
-		Scheme: "http",
+		Scheme: scheme,
 		Host:   host,
 		Path:   path,
 	}
@@ -46,7 +110,12 @@ func MkUrl(host, path string, args url.Values) string {
 }
 
 func PostBytes(url string, body []byte) ([]byte, error) {
-	r, err := client.Post(url, "application/octet-stream", bytes.NewReader(body))
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	r, err := InternalDo(req)
 	if err != nil {
 		return nil, fmt.Errorf("Post to %s: %v", url, err)
 	}
@@ -69,7 +138,7 @@ func PostPbMsg(url string, msg proto.Message, ret proto.Message) error {
 	}
 	req.Header.Set("Content-Type", "application/protobuf")
 	req.Header.Set("Accept", "application/protobuf")
-	resp, err := client.Do(req)
+	resp, err := InternalDo(req)
 	if err != nil {
 		return fmt.Errorf("Post to %s: %v", url, err)
 	}
@@ -89,7 +158,7 @@ func PostPbMsg(url string, msg proto.Message, ret proto.Message) error {
 func PostEx(host, path string, values url.Values) (content []byte, statusCode int, e error) {
 	url := MkUrl(host, path, nil)
 	glog.V(4).Infoln("Post", url+"?"+values.Encode())
-	r, err := client.PostForm(url, values)
+	r, err := postForm(url, values)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -142,7 +211,7 @@ func RemoteApiCall(host, path string, values url.Values) (result map[string]inte
 
 func Get(host, path string, values url.Values) ([]byte, error) {
 	url := MkUrl(host, path, values)
-	r, err := client.Get(url)
+	r, err := getInternal(url)
 	if err != nil {
 		return nil, err
 	}
@@ -188,7 +257,7 @@ func Delete(url string, jwt security.EncodedJwt) error {
 }
 
 func GetBufferStream(url string, values url.Values, allocatedBytes []byte, eachBuffer func([]byte)) error {
-	r, err := client.PostForm(url, values)
+	r, err := postForm(url, values)
 	if err != nil {
 		return err
 	}
@@ -212,7 +281,7 @@ func GetBufferStream(url string, values url.Values, allocatedBytes []byte, eachB
 }
 
 func GetUrlStream(url string, values url.Values, readFn func(io.Reader) error) error {
-	r, err := client.PostForm(url, values)
+	r, err := postForm(url, values)
 	if err != nil {
 		return err
 	}
@@ -244,7 +313,7 @@ func DownloadUrl(fileUrl string) (filename string, rc io.ReadCloser, e error) {
 }
 
 func DownloadToFile(fileUrl, savePath string) (e error) {
-	response, err := client.Get(fileUrl)
+	response, err := getInternal(fileUrl)
 	if err != nil {
 		return err
 	}
@@ -286,5 +355,5 @@ func NormalizeUrl(url string) string {
 	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
 		return url
 	}
-	return "http://" + url
+	return scheme + "://" + url
 }
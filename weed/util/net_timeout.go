@@ -1,6 +1,7 @@
 package util
 
 import (
+	"crypto/tls"
 	"net"
 	"time"
 
@@ -79,3 +80,11 @@ func NewListener(addr string, timeout time.Duration) (net.Listener, error) {
 	}
 	return tl, nil
 }
+
+// NewTLSListener serves https with the config on the listener, or plain http if the config is nil
+func NewTLSListener(l net.Listener, config *tls.Config) net.Listener {
+	if config == nil {
+		return l
+	}
+	return tls.NewListener(l, config)
+}
@@ -42,6 +42,8 @@ var cmdBackup = &Command{
 	
 	The complexity comes when there are multiple addition, deletion and compaction.
 	This tool will handle them correctly and efficiently, avoiding unnecessary data transporation.
+
+	Encrypted needles are copied as they are, the backup needs the same keys to be read.
   `,
 }
 
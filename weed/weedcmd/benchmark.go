@@ -191,7 +191,7 @@ func writeFiles(idChan chan int, fileIdLineChan chan string, s *stat) {
 				if df.enterTime.After(time.Now()) {
 					time.Sleep(df.enterTime.Sub(time.Now()))
 				}
-				if e := util.Delete("http://"+df.fp.Server+"/"+df.fp.Fid,
+				if e := util.Delete(util.NormalizeUrl(df.fp.Server+"/"+df.fp.Fid),
 					security.GenJwt(secret, df.fp.Fid)); e == nil {
 					s.completed++
 				} else {
@@ -0,0 +1,46 @@
+package weedcmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func init() {
+	cmdEcEncode.Run = runEcEncode // break init cycle
+}
+
+var cmdEcEncode = &Command{
+	UsageLine: "ec.encode -master=localhost:9333 -volumeId=234",
+	Short:     "convert a sealed volume into erasure coded shards",
+	Long: `Ask the master to convert a full or readonly volume into 10 data shards and 4 parity shards.
+  The shards are spread across the data nodes, preferring different racks,
+  and all the full replicas of the volume are deleted afterwards.
+  Any 10 of the 14 shards are enough to read the volume.
+
+  `,
+}
+
+var (
+	ecEncodeMaster     = cmdEcEncode.Flag.String("master", "localhost:9333", "SeaweedFS master location")
+	ecEncodeCollection = cmdEcEncode.Flag.String("collection", "", "volume collection name")
+	ecEncodeVolumeId   = cmdEcEncode.Flag.Int("volumeId", -1, "a volume id, the volume should be full or readonly.")
+)
+
+func runEcEncode(cmd *Command, args []string) bool {
+	if *ecEncodeVolumeId == -1 {
+		return false
+	}
+	_, err := util.RemoteApiCall(*ecEncodeMaster, "/vol/ec/encode", url.Values{
+		"volumeId":   {strconv.Itoa(*ecEncodeVolumeId)},
+		"collection": {*ecEncodeCollection},
+	})
+	if err != nil {
+		fmt.Println("Erasure coding volume", *ecEncodeVolumeId, "failed:", err)
+		return true
+	}
+	fmt.Println("Volume", *ecEncodeVolumeId, "is erasure coded.")
+	return true
+}
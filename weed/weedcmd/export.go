@@ -3,7 +3,11 @@ package weedcmd
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -14,6 +18,7 @@ import (
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 const (
@@ -29,6 +34,8 @@ type ExportOptions struct {
 	dir        *string
 	collection *string
 	volumeId   *int
+	keyFile    *string
+	master     *string
 }
 
 var cmdExport = &Command{
@@ -38,6 +45,11 @@ var cmdExport = &Command{
 
 	The format of file name in the tar file can be customized. Default is {{.Mime}}/{{.Id}}:{{.Name}}. Also available is {{.Key}}.
 
+	Encrypted files are exported in plain text with the keys from "-keyFile", the same file as the volume server "-encryption.keyFile".
+
+	At the end, the duplicated files in the volume and the bytes dedup would save are reported.
+	With "-master", the space already saved by the dedup of the collection is reported too.
+
   `,
 }
 
@@ -46,6 +58,8 @@ func init() {
 	export.dir = cmdExport.Flag.String("dir", ".", "input data directory to store volume data files")
 	export.collection = cmdExport.Flag.String("collection", "", "the volume collection name")
 	export.volumeId = cmdExport.Flag.Int("volumeId", -1, "a volume id. The volume .dat and .idx files should already exist in the dir.")
+	export.keyFile = cmdExport.Flag.String("keyFile", "", "file of the encryption keys, to export encrypted files")
+	export.master = cmdExport.Flag.String("master", "", "optional master server, to also report the space saved by dedup of the collection")
 }
 
 var (
@@ -60,6 +74,7 @@ var (
 	newerThan              time.Time
 	newerThanUnix          int64 = -1
 	localLocation, _             = time.LoadLocation("Local")
+	exportDedup                  = newDedupStats()
 )
 
 func runExport(cmd *Command, args []string) bool {
@@ -124,6 +139,7 @@ func runExport(cmd *Command, args []string) bool {
 	}
 
 	var version storage.Version
+	keys := loadKeyProvider(*export.keyFile)
 
 	err = storage.ScanVolumeFile(*export.dir, *export.collection, vid,
 		storage.NeedleMapInMemory,
@@ -140,6 +156,9 @@ func runExport(cmd *Command, args []string) bool {
 						n.LastModified, newerThanUnix)
 					return nil
 				}
+				if err := n.Decrypt(keys, *export.collection); err != nil {
+					return err
+				}
 				return walker(vid, n, version)
 			}
 			if !ok {
@@ -152,9 +171,75 @@ func runExport(cmd *Command, args []string) bool {
 	if err != nil {
 		glog.Fatalf("Export Volume File [ERROR] %s\n", err)
 	}
+	report := os.Stdout
+	if *output == "-" {
+		report = os.Stderr
+	}
+	exportDedup.print(report)
+	if *export.master != "" {
+		printCollectionDedupSavings(report, *export.master, *export.collection)
+	}
 	return true
 }
 
+// dedupStats counts the files with the same content
+type dedupStats struct {
+	files map[string]int
+	sizes map[string]int64
+}
+
+func newDedupStats() *dedupStats {
+	return &dedupStats{
+		files: make(map[string]int),
+		sizes: make(map[string]int64),
+	}
+}
+
+func (d *dedupStats) add(data []byte) {
+	sum := sha256.Sum256(data)
+	hash := string(sum[:])
+	d.files[hash]++
+	d.sizes[hash] = int64(len(data))
+}
+
+// savings returns the files and bytes that would not be stored with dedup
+func (d *dedupStats) savings() (duplicates int, saved int64) {
+	for hash, count := range d.files {
+		duplicates += count - 1
+		saved += int64(count-1) * d.sizes[hash]
+	}
+	return
+}
+
+func (d *dedupStats) print(w io.Writer) {
+	files := 0
+	for _, count := range d.files {
+		files += count
+	}
+	duplicates, saved := d.savings()
+	fmt.Fprintf(w, "%d files, %d unique, %d duplicated files, dedup would save %d bytes\n",
+		files, len(d.files), duplicates, saved)
+}
+
+func printCollectionDedupSavings(w io.Writer, master, collection string) {
+	jsonBlob, err := util.Get(master, "/dedup/status", url.Values{"collection": {collection}})
+	if err != nil {
+		fmt.Fprintf(w, "cannot get dedup status from %s: %v\n", master, err)
+		return
+	}
+	var savings struct {
+		Files      uint64 `json:"files"`
+		References uint64 `json:"references"`
+		SavedBytes uint64 `json:"savedBytes"`
+	}
+	if err = json.Unmarshal(jsonBlob, &savings); err != nil {
+		fmt.Fprintf(w, "invalid dedup status %s: %v\n", string(jsonBlob), err)
+		return
+	}
+	fmt.Fprintf(w, "collection %q: %d files deduplicated with %d references, saved %d bytes\n",
+		collection, savings.Files, savings.References, savings.SavedBytes)
+}
+
 type nameParams struct {
 	Name string
 	Id   uint64
@@ -165,6 +250,7 @@ type nameParams struct {
 
 func walker(vid storage.VolumeId, n *storage.Needle, version storage.Version) (err error) {
 	key := storage.NewFileIdFromNeedle(vid, n).String()
+	exportDedup.add(n.Data)
 	if tarOutputFile != nil {
 		fileNameTemplateBuffer.Reset()
 		if err = fileNameTemplate.Execute(fileNameTemplateBuffer,
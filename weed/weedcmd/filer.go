@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/chrislusf/seaweedfs/weed/weedserver"
@@ -23,12 +24,19 @@ type FilerOptions struct {
 	dir                     *string
 	redirectOnRead          *bool
 	disableDirListing       *bool
+	maxMB                   *int
 	secretKey               *string
 	cassandra_server        *string
 	cassandra_keyspace      *string
 	redis_server            *string
 	redis_password          *string
 	redis_database          *int
+	trashRetention          *time.Duration
+	versioning              *string
+	maxVersions             *int
+	maxVersionAge           *time.Duration
+	uploadTimeout           *time.Duration
+	tls                     TLSOptions
 }
 
 func init() {
@@ -40,12 +48,19 @@ func init() {
 	f.defaultReplicaPlacement = cmdFiler.Flag.String("defaultReplicaPlacement", "000", "default replication type if not specified")
 	f.redirectOnRead = cmdFiler.Flag.Bool("redirectOnRead", false, "whether proxy or redirect to volume server during file GET request")
 	f.disableDirListing = cmdFiler.Flag.Bool("disableDirListing", false, "turn off directory listing")
+	f.maxMB = cmdFiler.Flag.Int("maxMB", 32, "stream the uploads larger than this limit, or of unknown size, in chunks of this size, 0 to disable")
 	f.cassandra_server = cmdFiler.Flag.String("cassandra.server", "", "host[:port] of the cassandra server")
 	f.cassandra_keyspace = cmdFiler.Flag.String("cassandra.keyspace", "seaweed", "keyspace of the cassandra server")
 	f.redis_server = cmdFiler.Flag.String("redis.server", "", "host:port of the redis server, e.g., 127.0.0.1:6379")
 	f.redis_password = cmdFiler.Flag.String("redis.password", "", "password in clear text")
 	f.redis_database = cmdFiler.Flag.Int("redis.database", 0, "the database on the redis server")
 	f.secretKey = cmdFiler.Flag.String("secure.secret", "", "secret to encrypt Json Web Token(JWT)")
+	f.trashRetention = cmdFiler.Flag.Duration("trash.retention", 0, "keep the deleted entries in "+filer.TrashDir+" for the duration, e.g. 72h, 0 to delete them at once")
+	f.versioning = cmdFiler.Flag.String("versioning", "", "comma separated directories whose files keep their previous versions in "+filer.VersionsDir)
+	f.maxVersions = cmdFiler.Flag.Int("versioning.maxVersions", 10, "the number of previous versions kept for each file, 0 for no limit")
+	f.maxVersionAge = cmdFiler.Flag.Duration("versioning.maxAge", 0, "expire the previous versions older than the duration, e.g. 720h, 0 for no limit")
+	f.uploadTimeout = cmdFiler.Flag.Duration("upload.timeout", 24*time.Hour, "abort the resumable uploads left without activity for the duration, 0 to keep them")
+	f.tls.bind(&cmdFiler.Flag, "tls.", false)
 
 }
 
@@ -62,6 +77,16 @@ var cmdFiler = &Command{
 	POST /path/to/
 	//return a json format subdirectory and files listing
 	GET /path/to/
+	//with -versioning, list the previous versions of the file, get or restore one of them
+	GET /path/to/file?versions=true
+	GET /path/to/file?version=20171017-203450.123456789
+	POST /path/to/file?restore=20171017-203450.123456789
+	//upload the file in parts, in any order, then join the parts, or abort the upload
+	POST /path/to/file?uploads
+	PUT /path/to/file?uploadId=<id>&partNumber=1
+	GET /path/to/file?uploadId=<id>
+	POST /path/to/file?uploadId=<id>
+	DELETE /path/to/file?uploadId=<id>
 
   Current <fullpath~fileid> mapping metadata store is local embedded leveldb.
   It should be highly scalable to hundreds of millions of files on a modest machine.
@@ -77,12 +102,16 @@ func runFiler(cmd *Command, args []string) bool {
 		glog.Fatalf("Check Meta Folder (-dir) Writable %s : %s", *f.dir, err)
 	}
 
+	tlsConfig := f.tls.setupServer()
 	r := http.NewServeMux()
 	_, nfs_err := weedserver.NewFilerServer(r, *f.port, *f.master, *f.dir, *f.collection,
-		*f.defaultReplicaPlacement, *f.redirectOnRead, *f.disableDirListing,
+		*f.defaultReplicaPlacement, *f.redirectOnRead, *f.disableDirListing, *f.maxMB,
 		*f.secretKey,
 		*f.cassandra_server, *f.cassandra_keyspace,
 		*f.redis_server, *f.redis_password, *f.redis_database,
+		*f.trashRetention,
+		*f.versioning, *f.maxVersions, *f.maxVersionAge,
+		*f.uploadTimeout,
 	)
 	if nfs_err != nil {
 		glog.Fatalf("Filer startup error: %v", nfs_err)
@@ -95,7 +124,8 @@ func runFiler(cmd *Command, args []string) bool {
 	if e != nil {
 		glog.Fatalf("Filer listener error: %v", e)
 	}
-	if e := http.Serve(filerListener, r); e != nil {
+	filerListener = util.NewTLSListener(filerListener, tlsConfig)
+	if e := http.Serve(filerListener, weedserver.InstrumentServeMux("filer", r)); e != nil {
 		glog.Fatalf("Filer Fail to serve: %v", e)
 	}
 
@@ -1,8 +1,11 @@
 package weedcmd
 
 import (
+	"flag"
+	"hash/fnv"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -11,6 +14,8 @@ import (
 	"net"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/sequence"
+	"github.com/chrislusf/seaweedfs/weed/topology"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/chrislusf/seaweedfs/weed/weedserver"
 	"github.com/gorilla/mux"
@@ -44,10 +49,86 @@ var (
 	garbageThreshold        = cmdMaster.Flag.String("garbageThreshold", "0.3", "threshold to vacuum and reclaim spaces")
 	masterWhiteListOption   = cmdMaster.Flag.String("whiteList", "", "comma separated Ip addresses having write permission. No limit if empty.")
 	masterSecureKey         = cmdMaster.Flag.String("secure.secret", "", "secret to encrypt Json Web Token(JWT)")
+	masterSecureReads       = cmdMaster.Flag.Bool("secure.reads", false, "reads also require a JWT if secure.secret is set")
 
-	masterWhiteList []string
+	masterWhiteList        []string
+	masterTierOptions      TierPolicyOptions
+	masterSequencerOptions SequencerOptions
+	masterTLSOptions       TLSOptions
 )
 
+type SequencerOptions struct {
+	kind   *string
+	step   *uint64
+	nodeId *int
+}
+
+type TierPolicyOptions struct {
+	hot             *string
+	cold            *string
+	minAge          *time.Duration
+	minFullness     *float64
+	maxReadsPerHour *float64
+	interval        *time.Duration
+}
+
+func init() {
+	masterTierOptions.bind(&cmdMaster.Flag, "tier.")
+	masterSequencerOptions.bind(&cmdMaster.Flag, "sequencer")
+	masterTLSOptions.bind(&cmdMaster.Flag, "tls.", true)
+}
+
+func (o *SequencerOptions) bind(fs *flag.FlagSet, name string) {
+	o.kind = fs.String(name, "raft", "[raft|snowflake|memory] how to issue the file ids: reserved in batches through raft, time based without coordination, or in memory just for testing")
+	o.step = fs.Uint64(name+".step", 10000, "how many file ids are reserved at a time by the raft sequencer")
+	o.nodeId = fs.Int(name+".nodeId", -1, "unique id of the master for the snowflake sequencer, 0~1023, derived from the master address if negative")
+}
+
+// sequencer creates the file id sequencer, the raft sequencer keeps its state in the meta folder
+func (o *SequencerOptions) sequencer(metaFolder, masterAddress string) sequence.Sequencer {
+	switch *o.kind {
+	case "memory":
+		return sequence.NewMemorySequencer()
+	case "snowflake":
+		nodeId := *o.nodeId
+		if nodeId < 0 {
+			h := fnv.New32a()
+			h.Write([]byte(masterAddress))
+			nodeId = int(h.Sum32() % (sequence.SnowflakeMaxNode + 1))
+		}
+		glog.V(0).Infoln("snowflake sequencer with node id", nodeId)
+		return sequence.NewSnowflakeSequencer(nodeId)
+	case "raft":
+		seq, err := sequence.NewBatchSequencer(*o.step, filepath.Join(metaFolder, "sequence"))
+		if err != nil {
+			glog.Fatalf("create sequencer: %v", err)
+		}
+		return seq
+	}
+	glog.Fatalf("unknown sequencer %s", *o.kind)
+	return nil
+}
+
+func (o *TierPolicyOptions) bind(fs *flag.FlagSet, prefix string) {
+	o.hot = fs.String(prefix+"hot", "hot", "the tier of the new volumes, as tagged in the volume server -dir")
+	o.cold = fs.String(prefix+"cold", "cold", "the tier to move the cold volumes to")
+	o.minAge = fs.Duration(prefix+"minAge", 7*24*time.Hour, "only move the volumes not written for this long")
+	o.minFullness = fs.Float64(prefix+"minFullness", 0.9, "move the full volumes even if they are writable, as a ratio of -volumeSizeLimitMB")
+	o.maxReadsPerHour = fs.Float64(prefix+"maxReadsPerHour", 10, "only move the volumes read less often")
+	o.interval = fs.Duration(prefix+"interval", time.Hour, "how often to move the cold volumes, 0 to only move on demand")
+}
+
+func (o *TierPolicyOptions) policy() *topology.TierPolicy {
+	return &topology.TierPolicy{
+		Hot:             *o.hot,
+		Cold:            *o.cold,
+		MinAge:          *o.minAge,
+		MinFullness:     *o.minFullness,
+		MaxReadsPerHour: *o.maxReadsPerHour,
+		Interval:        *o.interval,
+	}
+}
+
 func runMaster(cmd *Command, args []string) bool {
 	if *mMaxCpu < 1 {
 		*mMaxCpu = runtime.NumCPU()
@@ -60,11 +141,17 @@ func runMaster(cmd *Command, args []string) bool {
 		masterWhiteList = strings.Split(*masterWhiteListOption, ",")
 	}
 
+	tlsConfig := masterTLSOptions.setupServer()
+
 	r := mux.NewRouter()
 	ms := weedserver.NewMasterServer(r, *mport, *metaFolder,
 		*volumeSizeLimitMB, *mpulse, *confFile, *defaultReplicaPlacement, *garbageThreshold,
 		masterWhiteList, *masterSecureKey,
+		masterSequencerOptions.sequencer(*metaFolder, net.JoinHostPort(*masterIp, strconv.Itoa(*mport))),
 	)
+	ms.SetReadSecured(*masterSecureReads)
+	ms.SetClientCertRequired(masterTLSOptions.clientCertRequired())
+	ms.Topo.StartTierPolicy(masterTierOptions.policy())
 
 	listeningAddress := net.JoinHostPort(*masterBindIp, strconv.Itoa(*mport))
 
@@ -74,6 +161,7 @@ func runMaster(cmd *Command, args []string) bool {
 	if e != nil {
 		glog.Fatalf("Master startup error: %v", e)
 	}
+	listener = util.NewTLSListener(listener, tlsConfig)
 
 	go func() {
 		time.Sleep(100 * time.Millisecond)
@@ -86,7 +174,7 @@ func runMaster(cmd *Command, args []string) bool {
 		ms.SetRaftServer(raftServer)
 	}()
 
-	if e := http.Serve(listener, r); e != nil {
+	if e := http.Serve(listener, weedserver.InstrumentRouter("master", r)); e != nil {
 		glog.Fatalf("Fail to serve: %v", e)
 	}
 	return true
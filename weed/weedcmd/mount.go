@@ -1,8 +1,17 @@
 package weedcmd
 
+import (
+	"os"
+)
+
 type MountOptions struct {
-	filer *string
-	dir   *string
+	filer            *string
+	dir              *string
+	collection       *string
+	replication      *string
+	ttl              *string
+	chunkSizeLimitMB *int
+	tempDir          *string
 }
 
 var (
@@ -14,6 +23,11 @@ func init() {
 	cmdMount.IsDebug = cmdMount.Flag.Bool("debug", false, "verbose debug information")
 	mountOptions.filer = cmdMount.Flag.String("filer", "localhost:8888", "weed filer location")
 	mountOptions.dir = cmdMount.Flag.String("dir", ".", "mount weed filer to this directory")
+	mountOptions.collection = cmdMount.Flag.String("collection", "", "collection to create the files")
+	mountOptions.replication = cmdMount.Flag.String("replication", "", "replication to create the files")
+	mountOptions.ttl = cmdMount.Flag.String("ttl", "", "time to live of the created files, e.g.: 1m, 1h, 1d, 1M, 1y")
+	mountOptions.chunkSizeLimitMB = cmdMount.Flag.Int("chunkSizeLimitMB", 16, "files larger than this limit are written in chunks")
+	mountOptions.tempDir = cmdMount.Flag.String("tempDir", os.TempDir(), "directory to buffer the written files until they are closed")
 }
 
 var cmdMount = &Command{
@@ -29,6 +43,10 @@ var cmdMount = &Command{
   This uses bazil.org/fuse, whichenables writing FUSE file systems on
   Linux, and OS X.
 
+  The mount is writable. Written files are buffered in "-tempDir", and
+  uploaded to the volume servers when closed or synced. Files larger than
+  "-chunkSizeLimitMB" are uploaded in chunks, with a chunk manifest.
+
   On OS X, it requires OSXFUSE (http://osxfuse.github.com/).
 
   `,
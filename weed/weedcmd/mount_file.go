@@ -0,0 +1,492 @@
+// +build linux darwin
+
+package weedcmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"golang.org/x/net/context"
+)
+
+/*
+File is read from the volume servers with range requests, or with the chunk
+manifest for large files. Writes go to a local buffer, which is a full copy of
+the content, and the buffer is uploaded and committed to the filer when the
+file is flushed, synced or closed.
+*/
+type File struct {
+	wfs     *WFS
+	mutex   sync.Mutex
+	Path    string
+	entry   *filer.FileEntry // the entry committed to the filer
+	buffer  *os.File         // local copy of the content while being written
+	dirty   bool             // the buffer or the attributes are not committed
+	handles int
+	removed bool
+}
+
+func (file *File) setPath(fullPath string) {
+	file.mutex.Lock()
+	file.Path = fullPath
+	file.entry.Name = filepath.Base(fullPath)
+	file.mutex.Unlock()
+}
+
+// refresh takes the entry from the filer, unless local changes are pending
+func (file *File) refresh(entry *filer.FileEntry) {
+	file.mutex.Lock()
+	if file.buffer == nil && !file.dirty {
+		file.entry = entry
+	}
+	file.mutex.Unlock()
+}
+
+func (file *File) markRemoved() {
+	file.mutex.Lock()
+	file.removed = true
+	file.mutex.Unlock()
+}
+
+func (file *File) Attr(context context.Context, attr *fuse.Attr) error {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	file.attr(attr)
+	return nil
+}
+
+func (file *File) attr(attr *fuse.Attr) {
+	attr.Inode = fileInode(file.Path, file.entry)
+	attr.Size = file.entry.Size
+	if file.buffer != nil {
+		if fi, err := file.buffer.Stat(); err == nil {
+			attr.Size = uint64(fi.Size())
+		}
+	}
+	attr.Mtime = file.entry.Mtime
+	attr.Crtime = file.entry.Crtime
+	attr.Mode = file.entry.Mode
+	if attr.Mode == 0 {
+		attr.Mode = filer.DefaultFileMode
+	}
+	attr.Uid = file.entry.Uid
+	attr.Gid = file.entry.Gid
+}
+
+func (file *File) Forget() {
+	file.mutex.Lock()
+	fullPath := file.Path
+	file.mutex.Unlock()
+	file.wfs.forget(fullPath, file)
+}
+
+func (file *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	return file.open(req.Flags&fuse.OpenTruncate != 0)
+}
+
+func (file *File) open(truncate bool) (*FileHandle, error) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	if truncate {
+		if err := file.truncate(0); err != nil {
+			return nil, err
+		}
+	}
+	file.handles++
+	return &FileHandle{file: file}, nil
+}
+
+func (file *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	if req.Valid.Size() {
+		if err := file.truncate(int64(req.Size)); err != nil {
+			return err
+		}
+	}
+	if req.Valid.Mode() {
+		file.entry.Mode = req.Mode
+		file.dirty = true
+	}
+	if req.Valid.Uid() {
+		file.entry.Uid = req.Uid
+		file.dirty = true
+	}
+	if req.Valid.Gid() {
+		file.entry.Gid = req.Gid
+		file.dirty = true
+	}
+	if req.Valid.Mtime() {
+		file.entry.Mtime = req.Mtime
+		file.dirty = true
+	}
+	// without open handles nobody else would commit the change
+	if file.handles == 0 {
+		if err := file.commit(); err != nil {
+			return err
+		}
+		file.closeBuffer()
+	}
+	file.attr(&resp.Attr)
+	return nil
+}
+
+func (file *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	return file.commit()
+}
+
+// truncate resizes the local buffer, the content is not downloaded if the file is emptied
+func (file *File) truncate(size int64) error {
+	if err := file.loadBuffer(size == 0); err != nil {
+		return err
+	}
+	if err := file.buffer.Truncate(size); err != nil {
+		return err
+	}
+	file.dirty = true
+	return nil
+}
+
+// loadBuffer copies the committed content to a local file, for writing
+func (file *File) loadBuffer(empty bool) error {
+	if file.buffer != nil {
+		return nil
+	}
+	buffer, err := ioutil.TempFile(*mountOptions.tempDir, "weed-mount-")
+	if err != nil {
+		return err
+	}
+	os.Remove(buffer.Name())
+	if !empty && file.entry.Size > 0 {
+		content, err := newRemoteContent(file.wfs, file.entry)
+		if err == nil {
+			_, err = content.WriteTo(buffer)
+			content.Close()
+		}
+		if err != nil {
+			buffer.Close()
+			glog.V(0).Infof("load %s: %v", file.Path, err)
+			return fuse.EIO
+		}
+	}
+	file.buffer = buffer
+	return nil
+}
+
+func (file *File) closeBuffer() {
+	if file.buffer != nil {
+		file.buffer.Close()
+		file.buffer = nil
+	}
+}
+
+// commit uploads the buffer if any, and saves the entry to the filer
+func (file *File) commit() error {
+	if !file.dirty || file.removed {
+		return nil
+	}
+	entry := *file.entry
+	if file.buffer != nil {
+		fi, err := file.buffer.Stat()
+		if err != nil {
+			return err
+		}
+		if entry.Id, err = file.upload(fi.Size()); err != nil {
+			glog.V(0).Infof("upload %s: %v", file.Path, err)
+			return fuse.EIO
+		}
+		entry.Chunks = nil
+		entry.Size = uint64(fi.Size())
+		entry.Mime = mime.TypeByExtension(filepath.Ext(file.Path))
+		entry.Mtime = time.Now()
+	}
+	if err := filer.CreateFileEntry(file.wfs.filer, filepath.Dir(file.Path), &entry); err != nil {
+		glog.V(0).Infof("commit %s: %v", file.Path, err)
+		if file.buffer != nil && entry.Id != "" {
+			operation.DeleteFile(file.wfs.master, string(entry.Id), entry.Collection, "")
+		}
+		return fuse.EIO
+	}
+	file.entry = &entry
+	file.dirty = false
+	return nil
+}
+
+// upload writes the buffer to a new file id, in chunks if it is larger than the chunk size limit
+func (file *File) upload(size int64) (filer.FileId, error) {
+	if size == 0 {
+		return "", nil
+	}
+	ret, err := operation.Assign(file.wfs.master, 1, file.entry.Replication, file.entry.Collection, file.entry.Ttl)
+	if err != nil {
+		return "", err
+	}
+	fp := operation.FilePart{
+		Reader:      io.NewSectionReader(file.buffer, 0, size),
+		FileName:    file.entry.Name,
+		FileSize:    size,
+		MimeType:    mime.TypeByExtension(filepath.Ext(file.Path)),
+		Replication: file.entry.Replication,
+		Collection:  file.entry.Collection,
+		Ttl:         file.entry.Ttl,
+		Server:      ret.Url,
+		Fid:         ret.Fid,
+	}
+	if _, err = fp.Upload(*mountOptions.chunkSizeLimitMB, file.wfs.master, ""); err != nil {
+		return "", err
+	}
+	return filer.FileId(ret.Fid), nil
+}
+
+type FileHandle struct {
+	file    *File
+	content *remoteContent
+}
+
+func (fh *FileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	file := fh.file
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	buf := make([]byte, req.Size)
+	var n int
+	var err error
+	if file.buffer != nil {
+		n, err = file.buffer.ReadAt(buf, req.Offset)
+	} else {
+		if fh.content == nil || fh.content.entry != file.entry {
+			fh.closeContent()
+			if fh.content, err = newRemoteContent(file.wfs, file.entry); err != nil {
+				glog.V(0).Infof("read %s: %v", file.Path, err)
+				return fuse.EIO
+			}
+		}
+		n, err = fh.content.ReadAt(buf, req.Offset)
+	}
+	if err != nil && err != io.EOF {
+		glog.V(0).Infof("read %s at %d: %v", file.Path, req.Offset, err)
+		return fuse.EIO
+	}
+	resp.Data = buf[:n]
+	return nil
+}
+
+func (fh *FileHandle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	file := fh.file
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	if err := file.loadBuffer(false); err != nil {
+		return err
+	}
+	n, err := file.buffer.WriteAt(req.Data, req.Offset)
+	if err != nil {
+		glog.V(0).Infof("write %s at %d: %v", file.Path, req.Offset, err)
+		return fuse.EIO
+	}
+	file.dirty = true
+	resp.Size = n
+	return nil
+}
+
+func (fh *FileHandle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	fh.file.mutex.Lock()
+	defer fh.file.mutex.Unlock()
+	return fh.file.commit()
+}
+
+func (fh *FileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	file := fh.file
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	fh.closeContent()
+	err := file.commit()
+	file.handles--
+	if file.handles == 0 {
+		file.closeBuffer()
+	}
+	return err
+}
+
+func (fh *FileHandle) closeContent() {
+	if fh.content != nil {
+		fh.content.Close()
+		fh.content = nil
+	}
+}
+
+// remoteContent reads the committed content of a file from the volume servers
+type remoteContent struct {
+	wfs      *WFS
+	entry    *filer.FileEntry
+	fileUrl  string                   // for the content in one needle
+	manifest *operation.ChunkManifest // for chunked content
+	reader   *storage.ChunkedFileReader
+	offset   int64 // position of the reader
+}
+
+func newRemoteContent(wfs *WFS, entry *filer.FileEntry) (*remoteContent, error) {
+	c := &remoteContent{wfs: wfs, entry: entry}
+	if len(entry.Chunks) > 0 {
+		c.manifest = &operation.ChunkManifest{Size: int64(entry.Size)}
+		for _, chunk := range entry.Chunks {
+			c.manifest.Chunks = append(c.manifest.Chunks, &operation.ChunkInfo{
+				Fid:    chunk.Fid,
+				Offset: chunk.Offset,
+				Size:   int64(chunk.Size),
+			})
+		}
+		return c, nil
+	}
+	if entry.Id == "" {
+		return c, nil
+	}
+	fileUrl, err := operation.LookupFileId(wfs.master, string(entry.Id), entry.Collection, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpRequest("HEAD", fileUrl)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-File-Store") != "chunked" {
+		c.fileUrl = fileUrl
+		return c, nil
+	}
+	// the raw manifest instead of the content
+	if resp, err = httpRequest("GET", fileUrl+"?cm=false"); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if c.manifest, err = operation.LoadChunkManifest(data, false); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *remoteContent) ReadAt(p []byte, off int64) (int, error) {
+	if c.manifest != nil {
+		return c.readChunked(p, off)
+	}
+	if c.fileUrl == "" || off >= int64(c.entry.Size) {
+		return 0, io.EOF
+	}
+	req, err := http.NewRequest("GET", c.fileUrl, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, err := util.HttpDo(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the whole content is returned for small files
+		if _, err = io.CopyN(ioutil.Discard, resp.Body, off); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("read %s: %s", c.fileUrl, resp.Status)
+	}
+	return readFull(resp.Body, p)
+}
+
+// readChunked keeps streaming from the chunked file reader for sequential reads
+func (c *remoteContent) readChunked(p []byte, off int64) (int, error) {
+	if off >= c.manifest.Size {
+		return 0, io.EOF
+	}
+	if c.reader == nil || c.offset != off {
+		c.Close()
+		c.reader = &storage.ChunkedFileReader{
+			Manifest:   c.manifest,
+			Master:     c.wfs.master,
+			Collection: c.entry.Collection,
+		}
+		if _, err := c.reader.Seek(off, 0); err != nil {
+			return 0, err
+		}
+		c.offset = off
+	}
+	n, err := readFull(c.reader, p)
+	c.offset += int64(n)
+	return n, err
+}
+
+func readFull(r io.Reader, p []byte) (int, error) {
+	n, err := io.ReadFull(r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteTo copies the whole content
+func (c *remoteContent) WriteTo(w io.Writer) (int64, error) {
+	if c.manifest != nil {
+		reader := &storage.ChunkedFileReader{
+			Manifest:   c.manifest,
+			Master:     c.wfs.master,
+			Collection: c.entry.Collection,
+		}
+		defer reader.Close()
+		return reader.WriteTo(w)
+	}
+	if c.fileUrl == "" {
+		return 0, nil
+	}
+	resp, err := httpRequest("GET", c.fileUrl)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// httpRequest fails unless the response is 200 OK
+func httpRequest(method, fileUrl string) (*http.Response, error) {
+	req, err := http.NewRequest(method, fileUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := util.HttpDo(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s", method, fileUrl, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *remoteContent) Close() error {
+	if c.reader == nil {
+		return nil
+	}
+	err := c.reader.Close()
+	c.reader = nil
+	return err
+}
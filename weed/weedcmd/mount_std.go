@@ -4,7 +4,14 @@ package weedcmd
 
 import (
 	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
@@ -22,6 +29,17 @@ func runMount(cmd *Command, args []string) bool {
 		return false
 	}
 
+	status, err := filer.FilerStatus(*mountOptions.filer)
+	if err != nil {
+		glog.Fatalf("Can not get the master from filer %s: %v", *mountOptions.filer, err)
+		return false
+	}
+	wfs := &WFS{
+		filer:  *mountOptions.filer,
+		master: status.Master,
+		nodes:  make(map[string]wfsNode),
+	}
+
 	c, err := fuse.Mount(*mountOptions.dir)
 	if err != nil {
 		glog.Fatal(err)
@@ -33,7 +51,7 @@ func runMount(cmd *Command, args []string) bool {
 		c.Close()
 	})
 
-	err = fs.Serve(c, WFS{})
+	err = fs.Serve(c, wfs)
 	if err != nil {
 		fuse.Unmount(*mountOptions.dir)
 	}
@@ -47,60 +65,250 @@ func runMount(cmd *Command, args []string) bool {
 	return true
 }
 
-type File struct {
-	FileId filer.FileId
-	Name   string
+// WFS keeps the name space on the filer, and reads and writes the file content
+// on the volume servers directly.
+type WFS struct {
+	filer  string
+	master string
+
+	// the nodes known to the kernel by full path, so that the open files keep
+	// their state across lookups, and renames can update their paths
+	nodes      map[string]wfsNode
+	nodesMutex sync.Mutex
 }
 
-func (File) Attr(context context.Context, attr *fuse.Attr) error {
-	return nil
+type wfsNode interface {
+	fs.Node
+	setPath(fullPath string)
+}
+
+func (wfs *WFS) Root() (fs.Node, error) {
+	return &Dir{wfs: wfs, Path: "/"}, nil
+}
+
+func (wfs *WFS) dirNode(fullPath string) *Dir {
+	wfs.nodesMutex.Lock()
+	defer wfs.nodesMutex.Unlock()
+	if d, ok := wfs.nodes[fullPath].(*Dir); ok {
+		return d
+	}
+	d := &Dir{wfs: wfs, Path: fullPath}
+	wfs.nodes[fullPath] = d
+	return d
+}
+
+func (wfs *WFS) fileNode(fullPath string, entry *filer.FileEntry) *File {
+	wfs.nodesMutex.Lock()
+	defer wfs.nodesMutex.Unlock()
+	if f, ok := wfs.nodes[fullPath].(*File); ok {
+		f.refresh(entry)
+		return f
+	}
+	f := &File{wfs: wfs, Path: fullPath, entry: entry}
+	wfs.nodes[fullPath] = f
+	return f
+}
+
+func (wfs *WFS) forget(fullPath string, node wfsNode) {
+	wfs.nodesMutex.Lock()
+	defer wfs.nodesMutex.Unlock()
+	if wfs.nodes[fullPath] == node {
+		delete(wfs.nodes, fullPath)
+	}
 }
-func (File) ReadAll(ctx context.Context) ([]byte, error) {
-	return []byte("hello, world\n"), nil
+
+// removed drops the node, the content of a file still open is not committed any more
+func (wfs *WFS) removed(fullPath string) {
+	wfs.nodesMutex.Lock()
+	f, ok := wfs.nodes[fullPath].(*File)
+	delete(wfs.nodes, fullPath)
+	wfs.nodesMutex.Unlock()
+	if ok {
+		f.markRemoved()
+	}
+}
+
+// renamed moves the node and all the nodes under it to the new path
+func (wfs *WFS) renamed(oldPath, newPath string) {
+	wfs.removed(newPath)
+	wfs.nodesMutex.Lock()
+	defer wfs.nodesMutex.Unlock()
+	moved := make(map[string]wfsNode)
+	for p, node := range wfs.nodes {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			delete(wfs.nodes, p)
+			moved[newPath+p[len(oldPath):]] = node
+		}
+	}
+	for p, node := range moved {
+		node.setPath(p)
+		wfs.nodes[p] = node
+	}
 }
 
 type Dir struct {
-	Path string
-	Id   uint64
+	wfs   *WFS
+	mutex sync.Mutex
+	Path  string
 }
 
-func (dir Dir) Attr(context context.Context, attr *fuse.Attr) error {
+func (dir *Dir) path() string {
+	dir.mutex.Lock()
+	defer dir.mutex.Unlock()
+	return dir.Path
+}
+
+func (dir *Dir) setPath(fullPath string) {
+	dir.mutex.Lock()
+	dir.Path = fullPath
+	dir.mutex.Unlock()
+}
+
+func (dir *Dir) Attr(context context.Context, attr *fuse.Attr) error {
+	attr.Mode = os.ModeDir | 0755
 	return nil
 }
 
-func (dir Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
-	files_result, e := filer.ListFiles(*mountOptions.filer, dir.Path, name)
-	if e != nil {
-		return nil, fuse.ENOENT
+func (dir *Dir) Forget() {
+	dir.wfs.forget(dir.path(), dir)
+}
+
+func (dir *Dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	dirPath := dir.path()
+	if files_result, e := filer.ListFiles(dir.wfs.filer, dirPath, name); e == nil && len(files_result.Files) > 0 {
+		return dir.wfs.fileNode(filepath.Join(dirPath, name), &files_result.Files[0]), nil
 	}
-	if len(files_result.Files) > 0 {
-		return File{files_result.Files[0].Id, files_result.Files[0].Name}, nil
+	if dir.hasSubDirectory(dirPath, name) {
+		return dir.wfs.dirNode(filepath.Join(dirPath, name)), nil
 	}
-	return nil, fmt.Errorf("File Not Found for %s", name)
+	return nil, fuse.ENOENT
 }
 
-type WFS struct{}
-
-func (WFS) Root() (fs.Node, error) {
-	return Dir{}, nil
+func (dir *Dir) hasSubDirectory(dirPath, name string) bool {
+	dirs, e := filer.ListDirectories(dir.wfs.filer, dirPath)
+	if e != nil {
+		return false
+	}
+	for _, d := range dirs.Directories {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
-func (dir *Dir) ReadDir(ctx context.Context) ([]fuse.Dirent, error) {
+func (dir *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	dirPath := dir.path()
 	var ret []fuse.Dirent
-	if dirs, e := filer.ListDirectories(*mountOptions.filer, dir.Path); e == nil {
+	if dirs, e := filer.ListDirectories(dir.wfs.filer, dirPath); e == nil {
 		for _, d := range dirs.Directories {
 			dirId := uint64(d.Id)
 			ret = append(ret, fuse.Dirent{Inode: dirId, Name: d.Name, Type: fuse.DT_Dir})
 		}
 	}
-	if files, e := filer.ListFiles(*mountOptions.filer, dir.Path, ""); e == nil {
+	lastFileName := ""
+	for {
+		files, e := filer.ListFilesAfter(dir.wfs.filer, dirPath, lastFileName, 1000)
+		if e != nil {
+			return ret, e
+		}
+		if len(files.Files) == 0 {
+			break
+		}
 		for _, f := range files.Files {
-			if fileId, e := storage.ParseFileId(string(f.Id)); e == nil {
-				fileInode := uint64(fileId.VolumeId)<<48 + fileId.Key
-				ret = append(ret, fuse.Dirent{Inode: fileInode, Name: f.Name, Type: fuse.DT_File})
-			}
-
+			ret = append(ret, fuse.Dirent{Inode: fileInode(filepath.Join(dirPath, f.Name), &f), Name: f.Name, Type: fuse.DT_File})
 		}
+		lastFileName = files.Files[len(files.Files)-1].Name
 	}
 	return ret, nil
 }
+
+// fileInode derives the inode from the file id, or from the path for empty files
+func fileInode(fullPath string, entry *filer.FileEntry) uint64 {
+	if fileId, e := storage.ParseFileId(string(entry.Id)); e == nil {
+		return uint64(fileId.VolumeId)<<48 + fileId.Key
+	}
+	h := fnv.New64a()
+	h.Write([]byte(fullPath))
+	return h.Sum64()
+}
+
+func (dir *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	dirPath := dir.path()
+	now := time.Now()
+	entry := &filer.FileEntry{
+		Name: req.Name,
+		Attributes: filer.Attributes{
+			Mtime:       now,
+			Crtime:      now,
+			Mode:        req.Mode &^ req.Umask,
+			Uid:         req.Uid,
+			Gid:         req.Gid,
+			Collection:  *mountOptions.collection,
+			Replication: *mountOptions.replication,
+			Ttl:         *mountOptions.ttl,
+		},
+	}
+	if err := filer.CreateFileEntry(dir.wfs.filer, dirPath, entry); err != nil {
+		glog.V(0).Infof("create %s in %s: %v", req.Name, dirPath, err)
+		return nil, nil, err
+	}
+	file := dir.wfs.fileNode(filepath.Join(dirPath, req.Name), entry)
+	handle, err := file.open(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, handle, nil
+}
+
+func (dir *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	fullPath := filepath.Join(dir.path(), req.Name)
+	if err := filer.CreateDirectory(dir.wfs.filer, fullPath); err != nil {
+		glog.V(0).Infof("mkdir %s: %v", fullPath, err)
+		return nil, err
+	}
+	return dir.wfs.dirNode(fullPath), nil
+}
+
+func (dir *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	dirPath := dir.path()
+	fullPath := filepath.Join(dirPath, req.Name)
+	if req.Dir {
+		if err := filer.DeleteDirectory(dir.wfs.filer, fullPath); err != nil {
+			glog.V(1).Infof("rmdir %s: %v", fullPath, err)
+			return fuse.Errno(syscall.ENOTEMPTY)
+		}
+		dir.wfs.removed(fullPath)
+		return nil
+	}
+	if err := filer.DeleteFileEntry(dir.wfs.filer, dirPath, req.Name); err != nil {
+		glog.V(0).Infof("delete %s: %v", fullPath, err)
+		return err
+	}
+	dir.wfs.removed(fullPath)
+	return nil
+}
+
+func (dir *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	newParent, ok := newDir.(*Dir)
+	if !ok {
+		return fuse.EIO
+	}
+	dirPath, newDirPath := dir.path(), newParent.path()
+	from, to := filepath.Join(dirPath, req.OldName), filepath.Join(newDirPath, req.NewName)
+	if newParent.hasSubDirectory(newDirPath, req.NewName) {
+		// the filer moves under an existing directory, while rename replaces an empty one
+		if !dir.hasSubDirectory(dirPath, req.OldName) {
+			return fuse.Errno(syscall.EISDIR)
+		}
+		if err := filer.DeleteDirectory(dir.wfs.filer, to); err != nil {
+			return fuse.Errno(syscall.ENOTEMPTY)
+		}
+	}
+	if err := filer.Move(dir.wfs.filer, from, to); err != nil {
+		glog.V(0).Infof("rename %s to %s: %v", from, to, err)
+		return err
+	}
+	dir.wfs.renamed(from, to)
+	return nil
+}
@@ -0,0 +1,399 @@
+package weedcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+var (
+	rep ReplicateOptions
+)
+
+type ReplicateOptions struct {
+	source      *string
+	target      *string
+	collections *string
+	dir         *string
+	interval    *time.Duration
+	timeout     *time.Duration
+	port        *int
+	tls         TLSOptions
+}
+
+func init() {
+	cmdReplicate.Run = runReplicate // break init cycle
+	rep.source = cmdReplicate.Flag.String("source", "localhost:9333", "master of the cluster to copy from")
+	rep.target = cmdReplicate.Flag.String("target", "", "master of the cluster to copy to")
+	rep.collections = cmdReplicate.Flag.String("collections", "", "comma separated collections to mirror, empty for the default collection")
+	rep.dir = cmdReplicate.Flag.String("dir", ".", "directory to keep the checkpoints")
+	rep.interval = cmdReplicate.Flag.Duration("interval", 30*time.Second, "wait time between the rounds over all volumes")
+	rep.timeout = cmdReplicate.Flag.Duration("timeout", time.Hour, "maximum time to synchronize one volume")
+	rep.port = cmdReplicate.Flag.Int("port", 0, "http port to report the lag per volume on /status, 0 to disable")
+	rep.tls.bindClient(&cmdReplicate.Flag, "tls.")
+}
+
+var cmdReplicate = &Command{
+	UsageLine: "replicate -source=localhost:9333 -target=other:9333 -collections=pics,logs",
+	Short:     "continuously mirror collections to another cluster",
+	Long: `Continuously mirror the volumes of the collections from a source cluster to a target cluster.
+
+	The volumes are copied incrementally like "weed backup", but into the volume servers
+	of the target cluster, keeping the same volume ids, so the file ids are valid in both clusters.
+	A volume missing on the target is created on the volume server with the most free slots.
+	The mirrored volumes are read-only on the target, the target master does not assign writes into them.
+
+	The target cluster should not grow volumes of its own in the mirrored collections,
+	and its volume servers should be in the white list of the source volume servers.
+
+	The synchronized position of each volume is kept in the "-dir" folder,
+	the unchanged volumes are skipped, also after a restart.
+	The lag per volume is logged, and served as json on "/status" with "-port".
+
+  `,
+}
+
+func runReplicate(cmd *Command, args []string) bool {
+	if *rep.target == "" {
+		return false
+	}
+	rep.tls.setupClient()
+	r, err := newReplicator(*rep.source, *rep.target, strings.Split(*rep.collections, ","),
+		filepath.Join(*rep.dir, "replicate.checkpoints"))
+	if err != nil {
+		glog.Fatalf("replicate: %v", err)
+	}
+	r.timeout = *rep.timeout
+	if *rep.port > 0 {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/status", r.statusHandler)
+		go func() {
+			if err := http.ListenAndServe(":"+strconv.Itoa(*rep.port), mux); err != nil {
+				glog.Fatalf("replicate status server: %v", err)
+			}
+		}()
+	}
+	for {
+		r.round()
+		time.Sleep(*rep.interval)
+	}
+}
+
+// replicateCheckpoint is the source position a volume is synchronized to
+type replicateCheckpoint struct {
+	Collection      string    `json:"collection"`
+	TailOffset      uint64    `json:"tailOffset"`
+	CompactRevision uint16    `json:"compactRevision"`
+	Targets         []string  `json:"targets"`
+	SyncedAt        time.Time `json:"syncedAt"`
+}
+
+// replicateStatus is the lag of a volume, as of the last round
+type replicateStatus struct {
+	Volume           storage.VolumeId `json:"volume"`
+	Collection       string           `json:"collection"`
+	Source           string           `json:"source"`
+	Targets          []string         `json:"targets"`
+	SourceTailOffset uint64           `json:"sourceTailOffset"`
+	SyncedTailOffset uint64           `json:"syncedTailOffset"`
+	LagBytes         uint64           `json:"lagBytes"`
+	LagSeconds       int64            `json:"lagSeconds"`
+	SyncedAt         time.Time        `json:"syncedAt,omitempty"`
+	Error            string           `json:"error,omitempty"`
+}
+
+type replicator struct {
+	source, target string
+	collections    map[string]bool
+	checkpointFile string
+	timeout        time.Duration
+	started        time.Time
+
+	mutex       sync.Mutex
+	checkpoints map[storage.VolumeId]*replicateCheckpoint
+	statuses    map[storage.VolumeId]*replicateStatus
+}
+
+func newReplicator(source, target string, collections []string, checkpointFile string) (*replicator, error) {
+	r := &replicator{
+		source:         source,
+		target:         target,
+		collections:    make(map[string]bool),
+		checkpointFile: checkpointFile,
+		timeout:        time.Hour,
+		started:        time.Now(),
+		checkpoints:    make(map[storage.VolumeId]*replicateCheckpoint),
+		statuses:       make(map[storage.VolumeId]*replicateStatus),
+	}
+	for _, c := range collections {
+		r.collections[strings.TrimSpace(c)] = true
+	}
+	content, err := ioutil.ReadFile(checkpointFile)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &r.checkpoints); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %v", checkpointFile, err)
+	}
+	glog.V(0).Infof("resume %d volumes from %s", len(r.checkpoints), checkpointFile)
+	return r, nil
+}
+
+// round synchronizes all the volumes of the collections once
+func (r *replicator) round() {
+	volumes, err := r.sourceVolumes()
+	if err != nil {
+		glog.V(0).Infof("list volumes of %s: %v", r.source, err)
+		return
+	}
+	vids := make([]int, 0, len(volumes))
+	for vid := range volumes {
+		vids = append(vids, int(vid))
+	}
+	sort.Ints(vids)
+	for _, id := range vids {
+		vid := storage.VolumeId(id)
+		status := r.syncVolume(vid, volumes[vid])
+		r.mutex.Lock()
+		r.statuses[vid] = status
+		r.mutex.Unlock()
+		if status.Error != "" {
+			glog.V(0).Infof("replicate volume %d: %s", vid, status.Error)
+		} else {
+			glog.V(1).Infof("replicate volume %d: lag %d bytes, %d seconds", vid, status.LagBytes, status.LagSeconds)
+		}
+	}
+}
+
+// replicateSource is a volume in the source cluster
+type replicateSource struct {
+	collection string
+	servers    []string
+}
+
+// sourceVolumes lists the servers of each volume in the collections
+func (r *replicator) sourceVolumes() (map[storage.VolumeId]*replicateSource, error) {
+	var status struct {
+		Volumes struct {
+			DataCenters map[string]map[string]map[string][]storage.VolumeInfo
+		}
+	}
+	if err := getJson(r.source, "/vol/status", nil, &status); err != nil {
+		return nil, err
+	}
+	volumes := make(map[storage.VolumeId]*replicateSource)
+	for _, racks := range status.Volumes.DataCenters {
+		for _, nodes := range racks {
+			for node, vis := range nodes {
+				for _, vi := range vis {
+					if !r.collections[vi.Collection] {
+						continue
+					}
+					if volumes[vi.Id] == nil {
+						volumes[vi.Id] = &replicateSource{collection: vi.Collection}
+					}
+					volumes[vi.Id].servers = append(volumes[vi.Id].servers, node)
+				}
+			}
+		}
+	}
+	return volumes, nil
+}
+
+func (r *replicator) syncVolume(vid storage.VolumeId, source *replicateSource) *replicateStatus {
+	status := &replicateStatus{Volume: vid, Collection: source.collection}
+	var sourceStatus *operation.SyncVolumeResponse
+	var err error
+	for _, server := range source.servers {
+		status.Source = server
+		if sourceStatus, err = operation.GetVolumeSyncStatus(status.Source, vid.String()); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.SourceTailOffset = sourceStatus.TailOffset
+
+	r.mutex.Lock()
+	checkpoint := r.checkpoints[vid]
+	unchanged := checkpoint != nil && checkpoint.CompactRevision == sourceStatus.CompactRevision &&
+		checkpoint.TailOffset == sourceStatus.TailOffset
+	if unchanged {
+		checkpoint.SyncedAt = time.Now()
+	}
+	r.mutex.Unlock()
+	if unchanged {
+		r.fillLag(status, checkpoint)
+		return status
+	}
+
+	targets, err := r.targetServers(vid, status.Collection, checkpoint)
+	if err == nil {
+		status.Targets = targets
+		for _, target := range targets {
+			if err = r.mirror(target, vid, status.Collection, sourceStatus.Ttl, status.Source); err != nil {
+				err = fmt.Errorf("mirror on %s: %v", target, err)
+				break
+			}
+		}
+	}
+	if err != nil {
+		status.Error = err.Error()
+		r.fillLag(status, checkpoint)
+		return status
+	}
+	checkpoint = &replicateCheckpoint{
+		Collection:      status.Collection,
+		TailOffset:      sourceStatus.TailOffset,
+		CompactRevision: sourceStatus.CompactRevision,
+		Targets:         targets,
+		SyncedAt:        time.Now(),
+	}
+	r.mutex.Lock()
+	r.checkpoints[vid] = checkpoint
+	err = r.saveCheckpoints()
+	r.mutex.Unlock()
+	if err != nil {
+		status.Error = fmt.Sprintf("save checkpoints: %v", err)
+	}
+	r.fillLag(status, checkpoint)
+	return status
+}
+
+// fillLag counts the bytes and time the target is behind the source if the
+// volume is not synchronized, since the last time the volume was in sync
+func (r *replicator) fillLag(status *replicateStatus, checkpoint *replicateCheckpoint) {
+	syncedAt := r.started
+	if checkpoint != nil {
+		status.SyncedTailOffset = checkpoint.TailOffset
+		status.SyncedAt = checkpoint.SyncedAt
+		syncedAt = checkpoint.SyncedAt
+		if status.Targets == nil {
+			status.Targets = checkpoint.Targets
+		}
+	}
+	if status.Error == "" {
+		return
+	}
+	if status.SourceTailOffset > status.SyncedTailOffset {
+		status.LagBytes = status.SourceTailOffset - status.SyncedTailOffset
+	}
+	status.LagSeconds = int64(time.Since(syncedAt).Seconds())
+}
+
+// targetServers finds the copies of the volume in the target cluster,
+// or picks the volume server with the most free slots for a new copy
+func (r *replicator) targetServers(vid storage.VolumeId, collection string, checkpoint *replicateCheckpoint) ([]string, error) {
+	jsonBlob, err := util.Post(r.target, "/dir/lookup", url.Values{"volumeId": {vid.String()}, "collection": {collection}})
+	if err != nil {
+		return nil, err
+	}
+	var lookup operation.LookupResult
+	if err = json.Unmarshal(jsonBlob, &lookup); err != nil {
+		return nil, fmt.Errorf("lookup volume %d on %s: %v", vid, r.target, err)
+	}
+	if len(lookup.Locations) > 0 {
+		var targets []string
+		for _, l := range lookup.Locations {
+			targets = append(targets, l.Url)
+		}
+		return targets, nil
+	}
+	if checkpoint != nil && len(checkpoint.Targets) > 0 {
+		// created but not reported to the master yet
+		return checkpoint.Targets, nil
+	}
+	var status shellTopology
+	if err = getJson(r.target, "/dir/status", nil, &status); err != nil {
+		return nil, err
+	}
+	var picked shellDataNode
+	for _, dc := range status.Topology.DataCenters {
+		for _, rack := range dc.Racks {
+			for _, dn := range rack.DataNodes {
+				if dn.Free > picked.Free {
+					picked = dn
+				}
+			}
+		}
+	}
+	if picked.Url == "" {
+		return nil, errors.New("no free volume slot in the target cluster")
+	}
+	return []string{picked.Url}, nil
+}
+
+// mirror runs the mirror task on the target volume server, which pulls the changes from the source
+func (r *replicator) mirror(target string, vid storage.VolumeId, collection, ttl, source string) error {
+	params := storage.TaskParams{
+		"volume":     vid.String(),
+		"collection": collection,
+		"ttl":        ttl,
+		"source":     source,
+	}
+	tc, err := storage.NewTaskCli(target, storage.TaskMirror, params)
+	if err != nil && err.Error() == storage.ErrTaskExists.Error() {
+		// left by an interrupted round
+		(&storage.TaskCli{TID: storage.TaskMirror + "-" + vid.String(), DataNode: target}).Clean()
+		tc, err = storage.NewTaskCli(target, storage.TaskMirror, params)
+	}
+	if err != nil {
+		return err
+	}
+	defer tc.Clean()
+	if err = tc.WaitAndQueryResult(r.timeout); err != nil {
+		return err
+	}
+	return tc.Commit()
+}
+
+func (r *replicator) saveCheckpoints() error {
+	content, err := json.MarshalIndent(r.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.checkpointFile + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.checkpointFile)
+}
+
+func (r *replicator) statusHandler(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	vids := make([]int, 0, len(r.statuses))
+	for vid := range r.statuses {
+		vids = append(vids, int(vid))
+	}
+	sort.Ints(vids)
+	statuses := make([]*replicateStatus, 0, len(vids))
+	for _, vid := range vids {
+		statuses = append(statuses, r.statuses[storage.VolumeId(vid)])
+	}
+	r.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Source":  r.source,
+		"Target":  r.target,
+		"Volumes": statuses,
+	})
+}
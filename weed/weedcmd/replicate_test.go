@@ -0,0 +1,97 @@
+package weedcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplicator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replicate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tailOffset := 800
+	sourceVolume := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Ttl":"","TailOffset":%d,"CompactRevision":1}`, tailOffset)
+	}))
+	defer sourceVolume.Close()
+	sourceVolumeUrl := strings.TrimPrefix(sourceVolume.URL, "http://")
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Volumes":{"DataCenters":{"dc1":{"rack1":{%q:[
+			{"Id":3,"Collection":"pics"},{"Id":4,"Collection":"logs"}]}}}}}`, sourceVolumeUrl)
+	}))
+	defer source.Close()
+
+	var tasks []string
+	failTask := false
+	targetVolume := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/admin/task/new":
+			tasks = append(tasks, r.Form.Get("task")+" "+r.Form.Get("volume")+" "+r.Form.Get("source"))
+			w.Write([]byte(`{"tid":"mirror-3"}`))
+		case "/admin/task/query":
+			if failTask {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error":"source is gone"}`))
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer targetVolume.Close()
+	targetVolumeUrl := strings.TrimPrefix(targetVolume.URL, "http://")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dir/lookup":
+			w.Write([]byte(`{"error":"volume id 3 not found"}`))
+		case "/dir/status":
+			fmt.Fprintf(w, `{"Topology":{"DataCenters":[{"Id":"dc1","Racks":[{"Id":"rack1","DataNodes":[
+				{"Url":"full:8080","Free":0},{"Url":%q,"Free":3}]}]}]}}`, targetVolumeUrl)
+		}
+	}))
+	defer target.Close()
+
+	checkpointFile := filepath.Join(dir, "replicate.checkpoints")
+	newTestReplicator := func() *replicator {
+		r, err := newReplicator(strings.TrimPrefix(source.URL, "http://"), strings.TrimPrefix(target.URL, "http://"),
+			[]string{"pics"}, checkpointFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	r := newTestReplicator()
+	r.round()
+	if len(tasks) != 1 || tasks[0] != "mirror 3 "+sourceVolumeUrl {
+		t.Fatalf("expect one mirror task of volume 3 from the source, got %v", tasks)
+	}
+	status := r.statuses[3]
+	if status == nil || status.Error != "" || status.LagBytes != 0 || status.Targets[0] != targetVolumeUrl {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// resumed with the checkpoints, the unchanged volume is skipped
+	r = newTestReplicator()
+	r.round()
+	if len(tasks) != 1 {
+		t.Errorf("unchanged volume should not be synchronized again: %v", tasks)
+	}
+
+	tailOffset, failTask = 1000, true
+	r.round()
+	status = r.statuses[3]
+	if len(tasks) != 2 || status.Error == "" || status.LagBytes != 200 || status.SyncedTailOffset != 800 {
+		t.Errorf("expect lag of the failed synchronization, got %+v", status)
+	}
+}
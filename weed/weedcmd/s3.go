@@ -0,0 +1,100 @@
+package weedcmd
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/weedserver"
+)
+
+var (
+	s3 S3Options
+)
+
+type S3Options struct {
+	filer                   *string
+	master                  *string
+	port                    *int
+	bucketsDir              *string
+	defaultReplicaPlacement *string
+	secretKey               *string
+	tls                     TLSOptions
+}
+
+func init() {
+	cmdS3.Run = runS3 // break init cycle
+	s3.filer = cmdS3.Flag.String("filer", "localhost:8888", "filer server location")
+	s3.master = cmdS3.Flag.String("master", "localhost:9333", "master server location, to verify the signatures of the access keys")
+	s3.port = cmdS3.Flag.Int("port", 8333, "s3 server http listen port")
+	s3.bucketsDir = cmdS3.Flag.String("buckets.dir", "/buckets", "the filer directory holding all buckets")
+	s3.defaultReplicaPlacement = cmdS3.Flag.String("defaultReplicaPlacement", "", "default replication type, the one of the filer if empty")
+	s3.secretKey = cmdS3.Flag.String("secure.secret", "", "secret to encrypt Json Web Token(JWT)")
+	s3.tls.bind(&cmdS3.Flag, "tls.", false)
+}
+
+var cmdS3 = &Command{
+	UsageLine: "s3 -port=8333 -filer=<ip:port>",
+	Short:     "start a s3 compatible gateway that points to a filer server",
+	Long: `start a gateway which speaks a subset of the S3 REST API, with path style requests.
+
+	//list, create and delete buckets
+	GET /
+	PUT /bucket
+	DELETE /bucket
+	//list objects, with prefix, delimiter and max-keys, both version 1 and 2
+	GET /bucket?list-type=2&prefix=a/&delimiter=/
+	//put, get with Range, head and delete objects
+	PUT /bucket/path/to/object
+	GET /bucket/path/to/object
+	//multipart upload
+	POST /bucket/path/to/object?uploads
+	PUT /bucket/path/to/object?uploadId=xxx&partNumber=1
+	POST /bucket/path/to/object?uploadId=xxx
+	DELETE /bucket/path/to/object?uploadId=xxx
+
+  Buckets are directories under -buckets.dir of the filer, which needs the directories
+  of its embedded store. The objects of a bucket are files in the collection with the
+  same name as the bucket, so the mount, the trash and the versioning of the filer see
+  them as any other file. The large objects are split into chunks by the filer, as its
+  -maxMB sets, and the unfinished multipart uploads expire as its -upload.timeout sets.
+
+  With -secure.secret, the same as the one of the master, the requests are signed
+  with AWS Signature Version 4, in the header or presigned, by an access key of the
+  master whose scope allows the action on the collection of the bucket. The access
+  key id and secret are the ones of key.set in "weed shell". The requests can also
+  carry a JWT of the scope, e.g. from /key/token of the master, as
+  "Authorization: BEARER <jwt>" or the "jwt" query parameter. The chunked uploads
+  signed by chunk are not supported. Without a secret, the requests are not
+  authenticated.
+
+  `,
+}
+
+func runS3(cmd *Command, args []string) bool {
+
+	tlsConfig := s3.tls.setupServer()
+
+	r := http.NewServeMux()
+	if _, err := weedserver.NewS3Server(r, *s3.filer, *s3.master, *s3.bucketsDir,
+		*s3.defaultReplicaPlacement, *s3.secretKey,
+	); err != nil {
+		glog.Fatalf("S3 startup error: %v", err)
+	}
+	glog.V(0).Infoln("Start Seaweed S3 Gateway", util.VERSION, "at port", strconv.Itoa(*s3.port))
+	s3Listener, e := util.NewListener(
+		":"+strconv.Itoa(*s3.port),
+		time.Duration(10)*time.Second,
+	)
+	if e != nil {
+		glog.Fatalf("S3 listener error: %v", e)
+	}
+	s3Listener = util.NewTLSListener(s3Listener, tlsConfig)
+	if e := http.Serve(s3Listener, r); e != nil {
+		glog.Fatalf("S3 Fail to serve: %v", e)
+	}
+
+	return true
+}
@@ -12,6 +12,7 @@ import (
 
 	"net"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/storage"
 	"github.com/chrislusf/seaweedfs/weed/util"
@@ -24,8 +25,11 @@ type ServerOptions struct {
 }
 
 var (
-	serverOptions ServerOptions
-	filerOptions  FilerOptions
+	serverOptions          ServerOptions
+	filerOptions           FilerOptions
+	serverTierOptions      TierPolicyOptions
+	serverSequencerOptions SequencerOptions
+	serverTLSOptions       TLSOptions
 )
 
 func init() {
@@ -59,6 +63,7 @@ var (
 	serverWhiteListOption         = cmdServer.Flag.String("whiteList", "", "comma separated Ip addresses having write permission. No limit if empty.")
 	serverPeers                   = cmdServer.Flag.String("master.peers", "", "other master nodes in comma separated ip:masterPort list")
 	serverSecureKey               = cmdServer.Flag.String("secure.secret", "", "secret to encrypt Json Web Token(JWT)")
+	serverSecureReads             = cmdServer.Flag.Bool("secure.reads", false, "reads also require a JWT if secure.secret is set")
 	serverGarbageThreshold        = cmdServer.Flag.String("garbageThreshold", "0.3", "threshold to vacuum and reclaim spaces")
 	masterPort                    = cmdServer.Flag.Int("master.port", 9333, "master server http listen port")
 	masterMetaFolder              = cmdServer.Flag.String("master.dir", "", "data directory to store meta data, default to same as -dir specified")
@@ -67,13 +72,15 @@ var (
 	masterDefaultReplicaPlacement = cmdServer.Flag.String("master.defaultReplicaPlacement", "000", "Default replication type if not specified.")
 	volumePort                    = cmdServer.Flag.Int("volume.port", 8080, "volume server http listen port")
 	volumePublicPort              = cmdServer.Flag.Int("volume.port.public", 0, "volume server public port")
-	volumeDataFolders             = cmdServer.Flag.String("dir", os.TempDir(), "directories to store data files. dir[,dir]...")
+	volumeDataFolders             = cmdServer.Flag.String("dir", os.TempDir(), "directories to store data files, optionally tagged with a tier. dir[:tier][,dir[:tier]]...")
 	volumeMaxDataVolumeCounts     = cmdServer.Flag.String("volume.max", "7", "maximum numbers of volumes, count[,count]...")
 	volumePulse                   = cmdServer.Flag.Int("pulseSeconds", 5, "number of seconds between heartbeats")
 	volumeIndexType               = cmdServer.Flag.String("volume.index", "memory", "Choose [memory|leveldb|boltdb] mode for memory~performance balance.")
 	volumeFixJpgOrientation       = cmdServer.Flag.Bool("volume.images.fix.orientation", true, "Adjust jpg orientation when uploading.")
+	volumeImageCacheMB            = cmdServer.Flag.Int("volume.images.cacheMB", 64, "megabytes of transformed images cached in memory, 0 to disable")
 	volumeReadRedirect            = cmdServer.Flag.Bool("volume.read.redirect", true, "Redirect moved or non-local volumes.")
 	volumeReadRemoteNeedle        = cmdServer.Flag.Bool("volume.read.remote.needle", false, "Read remote needle when have non-local volumes.")
+	volumeEncryptionKeyFile       = cmdServer.Flag.String("volume.encryption.keyFile", "", "file of the keys to encrypt the collections, one \"collection:keyId:hexKey\" per line")
 	volumeServerPublicUrl         = cmdServer.Flag.String("volume.publicUrl", "", "publicly accessible address")
 	isStartingFiler               = cmdServer.Flag.Bool("filer", false, "whether to start filer")
 
@@ -82,6 +89,9 @@ var (
 
 func init() {
 	serverOptions.cpuprofile = cmdServer.Flag.String("cpuprofile", "", "cpu profile output file")
+	serverTierOptions.bind(&cmdServer.Flag, "master.tier.")
+	serverSequencerOptions.bind(&cmdServer.Flag, "master.sequencer")
+	serverTLSOptions.bind(&cmdServer.Flag, "tls.", true)
 	filerOptions.master = cmdServer.Flag.String("filer.master", "", "default to current master server")
 	filerOptions.collection = cmdServer.Flag.String("filer.collection", "", "all data will be stored in this collection")
 	filerOptions.port = cmdServer.Flag.Int("filer.port", 8888, "filer server http listen port")
@@ -89,11 +99,17 @@ func init() {
 	filerOptions.defaultReplicaPlacement = cmdServer.Flag.String("filer.defaultReplicaPlacement", "", "Default replication type if not specified during runtime.")
 	filerOptions.redirectOnRead = cmdServer.Flag.Bool("filer.redirectOnRead", false, "whether proxy or redirect to volume server during file GET request")
 	filerOptions.disableDirListing = cmdServer.Flag.Bool("filer.disableDirListing", false, "turn off directory listing")
+	filerOptions.maxMB = cmdServer.Flag.Int("filer.maxMB", 32, "stream the uploads larger than this limit, or of unknown size, in chunks of this size, 0 to disable")
 	filerOptions.cassandra_server = cmdServer.Flag.String("filer.cassandra.server", "", "host[:port] of the cassandra server")
 	filerOptions.cassandra_keyspace = cmdServer.Flag.String("filer.cassandra.keyspace", "seaweed", "keyspace of the cassandra server")
 	filerOptions.redis_server = cmdServer.Flag.String("filer.redis.server", "", "host:port of the redis server, e.g., 127.0.0.1:6379")
 	filerOptions.redis_password = cmdServer.Flag.String("filer.redis.password", "", "redis password in clear text")
 	filerOptions.redis_database = cmdServer.Flag.Int("filer.redis.database", 0, "the database on the redis server")
+	filerOptions.trashRetention = cmdServer.Flag.Duration("filer.trash.retention", 0, "keep the deleted entries in "+filer.TrashDir+" for the duration, e.g. 72h, 0 to delete them at once")
+	filerOptions.versioning = cmdServer.Flag.String("filer.versioning", "", "comma separated directories whose files keep their previous versions in "+filer.VersionsDir)
+	filerOptions.maxVersions = cmdServer.Flag.Int("filer.versioning.maxVersions", 10, "the number of previous versions kept for each file, 0 for no limit")
+	filerOptions.maxVersionAge = cmdServer.Flag.Duration("filer.versioning.maxAge", 0, "expire the previous versions older than the duration, e.g. 720h, 0 for no limit")
+	filerOptions.uploadTimeout = cmdServer.Flag.Duration("filer.upload.timeout", 24*time.Hour, "abort the resumable uploads left without activity for the duration, 0 to keep them")
 }
 
 func runServer(cmd *Command, args []string) bool {
@@ -140,13 +156,14 @@ func runServer(cmd *Command, args []string) bool {
 		glog.Fatalf("%d directories by -dir, but only %d max is set by -max", len(folders), len(maxCounts))
 	}
 	for _, folder := range folders {
+		folder, _ = storage.ParseDiskTier(folder)
 		if err := util.TestFolderWritable(folder); err != nil {
 			glog.Fatalf("Check Data Folder(-dir) Writable %s : %s", folder, err)
 		}
 	}
 
 	if *masterMetaFolder == "" {
-		*masterMetaFolder = folders[0]
+		*masterMetaFolder, _ = storage.ParseDiskTier(folders[0])
 	}
 	if *isStartingFiler {
 		if *filerOptions.dir == "" {
@@ -164,16 +181,20 @@ func runServer(cmd *Command, args []string) bool {
 	if *serverWhiteListOption != "" {
 		serverWhiteList = strings.Split(*serverWhiteListOption, ",")
 	}
+	tlsConfig := serverTLSOptions.setupServer()
 
 	if *isStartingFiler {
 		go func() {
 			r := http.NewServeMux()
 			_, nfs_err := weedserver.NewFilerServer(r, *filerOptions.port, *filerOptions.master, *filerOptions.dir, *filerOptions.collection,
 				*filerOptions.defaultReplicaPlacement,
-				*filerOptions.redirectOnRead, *filerOptions.disableDirListing,
+				*filerOptions.redirectOnRead, *filerOptions.disableDirListing, *filerOptions.maxMB,
 				*filerOptions.secretKey,
 				*filerOptions.cassandra_server, *filerOptions.cassandra_keyspace,
 				*filerOptions.redis_server, *filerOptions.redis_password, *filerOptions.redis_database,
+				*filerOptions.trashRetention,
+				*filerOptions.versioning, *filerOptions.maxVersions, *filerOptions.maxVersionAge,
+				*filerOptions.uploadTimeout,
 			)
 			if nfs_err != nil {
 				glog.Fatalf("Filer startup error: %v", nfs_err)
@@ -186,7 +207,8 @@ func runServer(cmd *Command, args []string) bool {
 			if e != nil {
 				glog.Fatalf("Filer listener error: %v", e)
 			}
-			if e := http.Serve(filerListener, r); e != nil {
+			filerListener = util.NewTLSListener(filerListener, tlsConfig)
+			if e := http.Serve(filerListener, weedserver.InstrumentServeMux("filer", r)); e != nil {
 				glog.Fatalf("Filer Fail to serve: %v", e)
 			}
 		}()
@@ -203,13 +225,18 @@ func runServer(cmd *Command, args []string) bool {
 		ms := weedserver.NewMasterServer(r, *masterPort, *masterMetaFolder,
 			*masterVolumeSizeLimitMB, *volumePulse, *masterConfFile, *masterDefaultReplicaPlacement, *serverGarbageThreshold,
 			serverWhiteList, *serverSecureKey,
+			serverSequencerOptions.sequencer(*masterMetaFolder, net.JoinHostPort(*serverIp, strconv.Itoa(*masterPort))),
 		)
+		ms.SetReadSecured(*serverSecureReads)
+		ms.SetClientCertRequired(serverTLSOptions.clientCertRequired())
+		ms.Topo.StartTierPolicy(serverTierOptions.policy())
 
 		glog.V(0).Infoln("Start Seaweed Master", util.VERSION, "at", net.JoinHostPort(*serverIp, strconv.Itoa(*masterPort)))
 		masterListener, e := util.NewListener(net.JoinHostPort(*serverBindIp, strconv.Itoa(*masterPort)), time.Duration(*serverTimeout)*time.Second)
 		if e != nil {
 			glog.Fatalf("Master startup error: %v", e)
 		}
+		masterListener = util.NewTLSListener(masterListener, tlsConfig)
 
 		go func() {
 			raftWaitForMaster.Wait()
@@ -225,7 +252,7 @@ func runServer(cmd *Command, args []string) bool {
 		}()
 
 		raftWaitForMaster.Done()
-		if e := http.Serve(masterListener, r); e != nil {
+		if e := http.Serve(masterListener, weedserver.InstrumentRouter("master", r)); e != nil {
 			glog.Fatalf("Master Fail to serve:%s", e.Error())
 		}
 	}()
@@ -256,8 +283,10 @@ func runServer(cmd *Command, args []string) bool {
 		folders, maxCounts,
 		volumeNeedleMapKind,
 		net.JoinHostPort(*serverIp, strconv.Itoa(*masterPort)), *volumePulse, *serverDataCenter, *serverRack,
-		serverWhiteList, *volumeFixJpgOrientation, *volumeReadRedirect, *volumeReadRemoteNeedle,
+		serverWhiteList, *serverSecureKey, *volumeFixJpgOrientation, *volumeImageCacheMB, *volumeReadRedirect, *volumeReadRemoteNeedle,
+		loadKeyProvider(*volumeEncryptionKeyFile),
 	)
+	volumeServer.SetClientCertRequired(serverTLSOptions.clientCertRequired())
 
 	glog.V(0).Infoln("Start Seaweed volume server", util.VERSION, "at", net.JoinHostPort(*serverIp, strconv.Itoa(*volumePort)))
 	volumeListener, eListen := util.NewListener(
@@ -267,6 +296,7 @@ func runServer(cmd *Command, args []string) bool {
 	if eListen != nil {
 		glog.Fatalf("Volume server listener error: %v", eListen)
 	}
+	volumeListener = util.NewTLSListener(volumeListener, tlsConfig)
 	if isSeperatedPublicPort {
 		publicListeningAddress := net.JoinHostPort(*serverIp, strconv.Itoa(*volumePublicPort))
 		glog.V(0).Infoln("Start Seaweed volume server", util.VERSION, "public at", publicListeningAddress)
@@ -274,8 +304,9 @@ func runServer(cmd *Command, args []string) bool {
 		if e != nil {
 			glog.Fatalf("Volume server listener error:%v", e)
 		}
+		publicListener = util.NewTLSListener(publicListener, tlsConfig)
 		go func() {
-			if e := http.Serve(publicListener, publicVolumeMux); e != nil {
+			if e := http.Serve(publicListener, weedserver.InstrumentServeMux("volume", publicVolumeMux)); e != nil {
 				glog.Fatalf("Volume server fail to serve public: %v", e)
 			}
 		}()
@@ -286,7 +317,7 @@ func runServer(cmd *Command, args []string) bool {
 		pprof.StopCPUProfile()
 	})
 
-	if e := http.Serve(volumeListener, volumeMux); e != nil {
+	if e := http.Serve(volumeListener, weedserver.InstrumentServeMux("volume", volumeMux)); e != nil {
 		glog.Fatalf("Volume server fail to serve:%v", e)
 	}
 
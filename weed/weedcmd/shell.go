@@ -3,57 +3,174 @@ package weedcmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+var (
+	shellMaster      = cmdShell.Flag.String("master", "localhost:9333", "SeaweedFS master location")
+	shellFiler       = cmdShell.Flag.String("filer", "localhost:8888", "filer location, for the fs.* commands")
+	shellRunCommands = cmdShell.Flag.String("c", "", "run the commands separated by ';' and exit, instead of the interactive mode")
+	shellHistory     = cmdShell.Flag.String("history", defaultShellHistoryFile(), "file to keep the command history, empty to disable")
+	shellTLSOptions  TLSOptions
+	shellJwt         = cmdShell.Flag.String("jwt", os.Getenv("WEED_JWT"), "JWT sent with the requests if the cluster is secured, default to $WEED_JWT")
 )
 
 func init() {
 	cmdShell.Run = runShell // break init cycle
+	shellTLSOptions.bindClient(&cmdShell.Flag, "tls.")
 }
 
 var cmdShell = &Command{
-	UsageLine: "shell",
-	Short:     "run interactive commands, now just echo",
-	Long: `run interactive commands.
+	UsageLine: "shell -master=localhost:9333 -filer=localhost:8888",
+	Short:     "run interactive commands to administer the cluster",
+	Long: `run interactive commands to administer the cluster.
+
+  Type "help" in the shell to list all the commands, and "help <command>" for the details.
+  "history" lists the previous commands, "!!" runs the last one, and "!n" runs the n-th one.
+
+  Run the commands non-interactively for scripting:
+    weed shell -c "volume.list; node.list"
 
   `,
 }
 
+func defaultShellHistoryFile() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".weed_shell_history")
+}
+
 func runShell(command *Command, args []string) bool {
+	env := &shellEnv{
+		master: *shellMaster,
+		filer:  *shellFiler,
+		out:    os.Stdout,
+	}
+	shellTLSOptions.setupClient()
+	if *shellJwt != "" {
+		jwt := security.EncodedJwt(*shellJwt)
+		util.SetInternalJwt(func() security.EncodedJwt { return jwt })
+	}
+	if *shellRunCommands != "" {
+		ok := true
+		for _, line := range strings.Split(*shellRunCommands, ";") {
+			if err := env.execute(line); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				ok = false
+			}
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return true
+	}
+
+	h := loadShellHistory(*shellHistory)
 	r := bufio.NewReader(os.Stdin)
-	o := bufio.NewWriter(os.Stdout)
-	e := bufio.NewWriter(os.Stderr)
-	prompt := func() {
-		var err error
-		if _, err = o.WriteString("> "); err != nil {
-			glog.V(0).Infoln("error writing to stdout:", err)
+	for {
+		fmt.Fprint(env.out, "> ")
+		line, err := r.ReadString('\n')
+		if err != nil && line == "" {
+			if err != io.EOF {
+				glog.V(0).Infoln("error reading from stdin:", err)
+			}
+			fmt.Fprintln(env.out)
+			return true
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line, err = h.expand(line); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		h.add(line)
+		switch strings.Fields(line)[0] {
+		case "exit", "quit":
+			return true
+		case "history":
+			h.print(env.out)
+			continue
 		}
-		if err = o.Flush(); err != nil {
-			glog.V(0).Infoln("error flushing stdout:", err)
+		if err = env.execute(line); err != nil {
+			fmt.Fprintln(os.Stderr, err)
 		}
 	}
-	readLine := func() string {
-		ret, err := r.ReadString('\n')
-		if err != nil {
-			fmt.Fprint(e, err)
-			os.Exit(1)
+}
+
+// shellHistoryList keeps the commands in memory, and appends them to the history file if any
+type shellHistoryList struct {
+	fileName string
+	lines    []string
+}
+
+func loadShellHistory(fileName string) *shellHistoryList {
+	h := &shellHistoryList{fileName: fileName}
+	if fileName == "" {
+		return h
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			h.lines = append(h.lines, line)
 		}
-		return ret
 	}
-	execCmd := func(cmd string) int {
-		if cmd != "" {
-			if _, err := o.WriteString(cmd); err != nil {
-				glog.V(0).Infoln("error writing to stdout:", err)
-			}
+	return h
+}
+
+// expand replaces "!!" with the last command, and "!n" with the n-th command
+func (h *shellHistoryList) expand(line string) (string, error) {
+	if !strings.HasPrefix(line, "!") {
+		return line, nil
+	}
+	if line == "!!" {
+		if len(h.lines) == 0 {
+			return "", fmt.Errorf("no command in history")
 		}
-		return 0
+		return h.lines[len(h.lines)-1], nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n <= 0 || n > len(h.lines) {
+		return "", fmt.Errorf("%s: event not found", line)
 	}
+	return h.lines[n-1], nil
+}
 
-	cmd := ""
-	for {
-		prompt()
-		cmd = readLine()
-		execCmd(cmd)
+func (h *shellHistoryList) add(line string) {
+	if len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if h.fileName == "" {
+		return
+	}
+	f, err := os.OpenFile(h.fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		glog.V(1).Infoln("open history file", h.fileName, "error:", err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func (h *shellHistoryList) print(w io.Writer) {
+	for i, line := range h.lines {
+		fmt.Fprintf(w, "%5d  %s\n", i+1, line)
 	}
 }
@@ -0,0 +1,788 @@
+package weedcmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+type shellEnv struct {
+	master string
+	filer  string
+	out    io.Writer
+}
+
+type shellCommand struct {
+	name  string
+	usage string
+	help  string
+	do    func(env *shellEnv, args []string) error
+}
+
+var shellCommands = []*shellCommand{
+	{"volume.list", "volume.list [-collection=<name>]", "list all volumes and their locations", shellVolumeList},
+	{"volume.grow", "volume.grow -count=<n> [-collection=<name>] [-replication=<xyz>] [-ttl=<ttl>] [-dataCenter=<dc>] [-tier=<tier>]", "grow new writable volumes", shellVolumeGrow},
+	{"volume.vacuum", "volume.vacuum [-garbageThreshold=<ratio>]", "compact the volumes with too much deleted content", shellVolumeVacuum},
+	{"volume.check_replicate", "volume.check_replicate", "start to replicate the under replicated volumes in background", shellVolumeCheckReplicate},
+	{"volume.tier", "volume.tier", "move the cold volumes to the cold tier in background, by the master tier policy", shellVolumeTier},
+	{"volume.scrub", "volume.scrub [-collection=<name>] [-rateMB=<n>]", "verify the needle checksums and repair the corrupted needles from the replicas in background", shellVolumeScrub},
+	{"volume.corrupted", "volume.corrupted", "list the corrupted needles found by the last scrub", shellVolumeCorrupted},
+	{"volume.trash", "volume.trash -volumeId=<id> [-collection=<name>]", "list the deleted files of the volume which can be undeleted", shellVolumeTrash},
+	{"volume.undelete", "volume.undelete <fid> [-collection=<name>]", "undelete a file kept in the trash of its volume", shellVolumeUndelete},
+	{"volume.snapshot", "volume.snapshot <name> [-collection=<name>] [-volumeId=<id>]", "snapshot the volume, or all volumes of the collection, retained until released", shellVolumeSnapshot},
+	{"volume.snapshot.list", "volume.snapshot.list [-collection=<name>] [-volumeId=<id>]", "list the volume snapshots", shellVolumeSnapshotList},
+	{"volume.snapshot.release", "volume.snapshot.release <name> [-collection=<name>] [-volumeId=<id>]", "release the snapshot, so vacuum can remove its deleted files", shellVolumeSnapshotRelease},
+	{"volume.restore", "volume.restore <name> [-collection=<name>] [-volumeId=<id>]", "rebuild the volume, or all volumes of the collection, as of the snapshot", shellVolumeRestore},
+	{"collection.list", "collection.list", "list all collections and their volume layouts", shellCollectionList},
+	{"collection.delete", "collection.delete <name>", "delete a collection and all its volumes", shellCollectionDelete},
+	{"collection.settings", "collection.settings [<name>]", "list the collection settings, or the effective settings of a collection", shellCollectionSettings},
+	{"collection.set", "collection.set <name> [-replication=<xyz>] [-garbageThreshold=<ratio>] [-ttl=<ttl>] [-maxVolumeCount=<n>] [-maxBytes=<n>] [-maxFileCount=<n>] [-tier=<tier>] [-dedup=<bool>] [-trashRetention=<ttl>]", "change the collection settings, an empty value falls back to the default", shellCollectionSet},
+	{"collection.reset", "collection.reset <name>", "delete the collection settings, so the defaults are used", shellCollectionReset},
+	{"collection.usage", "collection.usage [<name>]", "show the space used by the collections and their quotas", shellCollectionUsage},
+	{"key.list", "key.list", "list the access keys and their scopes", shellKeyList},
+	{"key.set", "key.set <id> -collections=<a,b|*> -actions=<read,write,delete,admin|*> [-secret=<secret>]", "create or replace an access key, the secret is generated if not given", shellKeySet},
+	{"key.delete", "key.delete <id>", "delete an access key", shellKeyDelete},
+	{"node.list", "node.list", "list all data nodes", shellNodeList},
+	{"task.list", "task.list", "list the tasks running on the data nodes", shellTaskList},
+	{"fs.ls", "fs.ls [<dir>]", "list a filer directory", shellFsLs},
+	{"fs.cat", "fs.cat <file>", "print the content of a filer file", shellFsCat},
+	{"fs.mv", "fs.mv <from> <to>", "move a filer file or directory", shellFsMv},
+}
+
+func (env *shellEnv) execute(line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+	if args[0] == "help" {
+		env.help(args[1:])
+		return nil
+	}
+	for _, c := range shellCommands {
+		if c.name == args[0] {
+			return c.do(env, args[1:])
+		}
+	}
+	return fmt.Errorf("unknown command: %s, type \"help\" to list all commands", args[0])
+}
+
+func (env *shellEnv) help(args []string) {
+	for _, c := range shellCommands {
+		if len(args) == 0 {
+			fmt.Fprintf(env.out, "  %-24s %s\n", c.name, c.help)
+		} else if c.name == args[0] {
+			fmt.Fprintf(env.out, "%s\n  %s\n", c.help, c.usage)
+		}
+	}
+}
+
+func newShellFlagSet(env *shellEnv, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.out)
+	return fs
+}
+
+func newShellTable(env *shellEnv, columns ...string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(env.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	return tw
+}
+
+func writeShellRow(tw *tabwriter.Writer, values ...interface{}) {
+	for i, v := range values {
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, v)
+	}
+	fmt.Fprintln(tw)
+}
+
+// getJson gets the json from the server, and fails on the error field if any
+func getJson(server, path string, values url.Values, ret interface{}) error {
+	jsonBlob, err := util.Get(server, path, values)
+	if err != nil {
+		return err
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(jsonBlob, &e) == nil && e.Error != "" {
+		return fmt.Errorf("%s: %s", path, e.Error)
+	}
+	return json.Unmarshal(jsonBlob, ret)
+}
+
+// postForm posts to the server, and fails on non 200 status or the error field if any
+func postForm(server, path string, values url.Values) ([]byte, error) {
+	content, code, err := util.PostEx(server, path, values)
+	if err != nil {
+		return nil, err
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(content, &e) == nil && e.Error != "" {
+		return nil, fmt.Errorf("%s: %s", path, e.Error)
+	}
+	if code != 200 {
+		return nil, fmt.Errorf("%s: status %d", path, code)
+	}
+	return content, nil
+}
+
+type shellDataNode struct {
+	Url       string
+	PublicUrl string
+	Volumes   int
+	Max       int
+	Free      int
+}
+
+type shellTopology struct {
+	Topology struct {
+		Max         int
+		Free        int
+		DataCenters []struct {
+			Id    string
+			Racks []struct {
+				Id        string
+				DataNodes []shellDataNode
+			}
+		}
+		Layouts []struct {
+			Collection  string `json:"collection"`
+			Replication string `json:"replication"`
+			Ttl         string `json:"ttl"`
+			Writables   []int  `json:"writables"`
+		} `json:"layouts"`
+	}
+}
+
+type shellVolume struct {
+	storage.VolumeInfo
+	dc, rack, node string
+}
+
+type shellVolumes []shellVolume
+
+func (s shellVolumes) Len() int      { return len(s) }
+func (s shellVolumes) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s shellVolumes) Less(i, j int) bool {
+	if s[i].Id != s[j].Id {
+		return s[i].Id < s[j].Id
+	}
+	return s[i].node < s[j].node
+}
+
+func shellVolumeList(env *shellEnv, args []string) error {
+	fs := newShellFlagSet(env, "volume.list")
+	collection := fs.String("collection", "", "only list the volumes in the collection")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var status struct {
+		Volumes struct {
+			DataCenters map[string]map[string]map[string][]storage.VolumeInfo
+		}
+	}
+	if err := getJson(env.master, "/vol/status", nil, &status); err != nil {
+		return err
+	}
+	var volumes shellVolumes
+	for dc, racks := range status.Volumes.DataCenters {
+		for rack, nodes := range racks {
+			for node, vis := range nodes {
+				for _, vi := range vis {
+					if *collection != "" && vi.Collection != *collection {
+						continue
+					}
+					volumes = append(volumes, shellVolume{vi, dc, rack, node})
+				}
+			}
+		}
+	}
+	sort.Sort(volumes)
+	tw := newShellTable(env, "ID", "COLLECTION", "SIZE", "FILES", "DELETED", "READONLY", "TIER", "NODE", "RACK", "DC")
+	for _, v := range volumes {
+		writeShellRow(tw, v.Id, v.Collection, v.Size, v.FileCount, v.DeleteCount, v.ReadOnly, v.Tier, v.node, v.rack, v.dc)
+	}
+	return tw.Flush()
+}
+
+func shellVolumeGrow(env *shellEnv, args []string) error {
+	fs := newShellFlagSet(env, "volume.grow")
+	count := fs.Int("count", 1, "how many volumes to grow")
+	collection := fs.String("collection", "", "collection name")
+	replication := fs.String("replication", "", "replication type, the master default if empty")
+	ttl := fs.String("ttl", "", "time to live, e.g. 3d")
+	dataCenter := fs.String("dataCenter", "", "preferred data center")
+	tier := fs.String("tier", "", "disk tier of the new volumes, any tier if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var ret struct {
+		Count int `json:"count"`
+	}
+	content, err := postForm(env.master, "/vol/grow", url.Values{
+		"count":       {strconv.Itoa(*count)},
+		"collection":  {*collection},
+		"replication": {*replication},
+		"ttl":         {*ttl},
+		"dataCenter":  {*dataCenter},
+		"tier":        {*tier},
+	})
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(content, &ret); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "grew %d volumes\n", ret.Count)
+	return nil
+}
+
+func shellVolumeVacuum(env *shellEnv, args []string) error {
+	fs := newShellFlagSet(env, "volume.vacuum")
+	garbageThreshold := fs.String("garbageThreshold", "", "compact the volumes with more garbage than the ratio, the master default if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	values := url.Values{}
+	if *garbageThreshold != "" {
+		values.Set("garbageThreshold", *garbageThreshold)
+	}
+	if _, err := postForm(env.master, "/vol/vacuum", values); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, "vacuum finished")
+	return nil
+}
+
+func shellVolumeCheckReplicate(env *shellEnv, args []string) error {
+	if _, err := postForm(env.master, "/vol/check_replicate", nil); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, "replicate checker is running")
+	return nil
+}
+
+func shellVolumeTier(env *shellEnv, args []string) error {
+	if _, err := postForm(env.master, "/vol/tier", nil); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, "moving volumes to the cold tier")
+	return nil
+}
+
+func shellVolumeScrub(env *shellEnv, args []string) error {
+	fs := newShellFlagSet(env, "volume.scrub")
+	collection := fs.String("collection", "", "only scrub the volumes in the collection")
+	rateMB := fs.Int("rateMB", 8, "maximum MB per second read on each volume server, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	values := url.Values{
+		"collection": {*collection},
+		"rateMB":     {strconv.Itoa(*rateMB)},
+	}
+	if _, err := postForm(env.master, "/vol/scrub", values); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, "scrubbing is running, check the results with volume.corrupted")
+	return nil
+}
+
+func shellVolumeCorrupted(env *shellEnv, args []string) error {
+	var status struct {
+		Running bool
+		Reports []struct {
+			Volume    int       `json:"volume"`
+			Url       string    `json:"url"`
+			Checked   int       `json:"checked"`
+			Corrupted []string  `json:"corrupted"`
+			Repaired  []string  `json:"repaired"`
+			Time      time.Time `json:"time"`
+		}
+	}
+	if err := getJson(env.master, "/vol/scrub/status", nil, &status); err != nil {
+		return err
+	}
+	if status.Running {
+		fmt.Fprintln(env.out, "scrubbing is running")
+	}
+	tw := newShellTable(env, "ID", "NODE", "CHECKED", "CORRUPTED", "REPAIRED", "TIME")
+	for _, r := range status.Reports {
+		writeShellRow(tw, r.Volume, r.Url, r.Checked, strings.Join(r.Corrupted, ","), len(r.Repaired), r.Time.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func shellVolumeTrash(env *shellEnv, args []string) error {
+	fs := newShellFlagSet(env, "volume.trash")
+	collection := fs.String("collection", "", "the collection of the volume")
+	volumeId := fs.Int("volumeId", 0, "the volume id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *volumeId <= 0 {
+		return fmt.Errorf("usage: volume.trash -volumeId=<id> [-collection=<name>]")
+	}
+	var ret struct {
+		TrashRetention string `json:"trashRetention"`
+		Files          []struct {
+			Fid       string `json:"fid"`
+			Size      uint32 `json:"size"`
+			DeletedAt int64  `json:"deletedAt"`
+		} `json:"files"`
+	}
+	values := url.Values{"collection": {*collection}, "volume": {strconv.Itoa(*volumeId)}}
+	if err := getJson(env.master, "/vol/trash", values, &ret); err != nil {
+		return err
+	}
+	tw := newShellTable(env, "FID", "SIZE", "DELETED")
+	for _, f := range ret.Files {
+		writeShellRow(tw, f.Fid, f.Size, time.Unix(f.DeletedAt, 0).Format(time.RFC3339))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "trash retention %s\n", ret.TrashRetention)
+	return nil
+}
+
+func shellVolumeUndelete(env *shellEnv, args []string) error {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: volume.undelete <fid> [-collection=<name>]")
+	}
+	fs := newShellFlagSet(env, "volume.undelete")
+	collection := fs.String("collection", "", "the collection of the file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	values := url.Values{"collection": {*collection}, "fid": {args[0]}}
+	if _, err := postForm(env.master, "/vol/undelete", values); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "%s is undeleted\n", args[0])
+	return nil
+}
+
+// parseShellSnapshotArgs parses "<name> [-collection=<name>] [-volumeId=<id>]",
+// or the flags only if the name is not required.
+func parseShellSnapshotArgs(env *shellEnv, command string, args []string, withName bool) (url.Values, error) {
+	values := url.Values{}
+	if withName {
+		if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+			return nil, fmt.Errorf("usage: %s <name> [-collection=<name>] [-volumeId=<id>]", command)
+		}
+		values.Set("name", args[0])
+		args = args[1:]
+	}
+	fs := newShellFlagSet(env, command)
+	collection := fs.String("collection", "", "the collection of the volumes")
+	volumeId := fs.Int("volumeId", 0, "only the volume, instead of all volumes of the collection")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	values.Set("collection", *collection)
+	if *volumeId > 0 {
+		values.Set("volume", strconv.Itoa(*volumeId))
+	}
+	return values, nil
+}
+
+func shellVolumeSnapshot(env *shellEnv, args []string) error {
+	values, err := parseShellSnapshotArgs(env, "volume.snapshot", args, true)
+	if err != nil {
+		return err
+	}
+	if _, err = postForm(env.master, "/vol/snapshot/create", values); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "snapshot %s is created\n", values.Get("name"))
+	return nil
+}
+
+func shellVolumeSnapshotList(env *shellEnv, args []string) error {
+	values, err := parseShellSnapshotArgs(env, "volume.snapshot.list", args, false)
+	if err != nil {
+		return err
+	}
+	var ret map[string]struct {
+		Volumes []struct {
+			Volume     string `json:"volume"`
+			Collection string `json:"collection"`
+			Snapshots  []struct {
+				Name            string
+				DatSize         int64
+				CompactRevision uint16
+				FileCount       int
+				Created         time.Time
+			} `json:"snapshots"`
+		} `json:"volumes"`
+	}
+	if err = getJson(env.master, "/vol/snapshot/list", values, &ret); err != nil {
+		return err
+	}
+	var nodes []string
+	for node := range ret {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	tw := newShellTable(env, "NODE", "ID", "COLLECTION", "SNAPSHOT", "REVISION", "SIZE", "FILES", "CREATED")
+	for _, node := range nodes {
+		for _, v := range ret[node].Volumes {
+			for _, s := range v.Snapshots {
+				writeShellRow(tw, node, v.Volume, v.Collection, s.Name, s.CompactRevision, s.DatSize, s.FileCount, s.Created.Format(time.RFC3339))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func shellVolumeSnapshotRelease(env *shellEnv, args []string) error {
+	values, err := parseShellSnapshotArgs(env, "volume.snapshot.release", args, true)
+	if err != nil {
+		return err
+	}
+	if _, err = postForm(env.master, "/vol/snapshot/release", values); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "snapshot %s is released\n", values.Get("name"))
+	return nil
+}
+
+func shellVolumeRestore(env *shellEnv, args []string) error {
+	values, err := parseShellSnapshotArgs(env, "volume.restore", args, true)
+	if err != nil {
+		return err
+	}
+	if _, err = postForm(env.master, "/vol/snapshot/restore", values); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "restored to snapshot %s\n", values.Get("name"))
+	return nil
+}
+
+func shellCollectionList(env *shellEnv, args []string) error {
+	var status shellTopology
+	if err := getJson(env.master, "/dir/status", nil, &status); err != nil {
+		return err
+	}
+	tw := newShellTable(env, "COLLECTION", "REPLICATION", "TTL", "WRITABLES")
+	for _, l := range status.Topology.Layouts {
+		writeShellRow(tw, l.Collection, l.Replication, l.Ttl, len(l.Writables))
+	}
+	return tw.Flush()
+}
+
+func shellCollectionDelete(env *shellEnv, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: collection.delete <name>")
+	}
+	if _, err := postForm(env.master, "/col/delete", url.Values{"collection": {args[0]}}); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "collection %s is deleted\n", args[0])
+	return nil
+}
+
+type shellCollectionSetting struct {
+	Collection             string `json:"collection"`
+	ReplicaPlacement       string `json:"replica_placement"`
+	VacuumGarbageThreshold string `json:"vacuum_garbage_threshold"`
+	Ttl                    string `json:"ttl"`
+	MaxVolumeCount         int    `json:"max_volume_count"`
+	Tier                   string `json:"tier"`
+	MaxBytes               uint64 `json:"max_bytes"`
+	MaxFileCount           uint64 `json:"max_file_count"`
+	Dedup                  bool   `json:"dedup"`
+	TrashRetention         string `json:"trash_retention"`
+}
+
+func writeShellCollectionSettings(env *shellEnv, settings ...shellCollectionSetting) error {
+	tw := newShellTable(env, "COLLECTION", "REPLICATION", "GARBAGE", "TTL", "MAXVOLUMES", "MAXBYTES", "MAXFILES", "TIER", "DEDUP", "TRASH")
+	for _, s := range settings {
+		writeShellRow(tw, s.Collection, s.ReplicaPlacement, s.VacuumGarbageThreshold, s.Ttl, s.MaxVolumeCount, s.MaxBytes, s.MaxFileCount, s.Tier, s.Dedup, s.TrashRetention)
+	}
+	return tw.Flush()
+}
+
+func shellCollectionSettings(env *shellEnv, args []string) error {
+	if len(args) > 0 {
+		var ret struct {
+			Effective   shellCollectionSetting
+			VolumeCount int
+		}
+		if err := getJson(env.master, "/col/settings", url.Values{"collection": {args[0]}}, &ret); err != nil {
+			return err
+		}
+		if err := writeShellCollectionSettings(env, ret.Effective); err != nil {
+			return err
+		}
+		fmt.Fprintf(env.out, "%d volumes\n", ret.VolumeCount)
+		return nil
+	}
+	var ret struct {
+		Defaults    shellCollectionSetting
+		Collections []shellCollectionSetting
+	}
+	if err := getJson(env.master, "/col/settings", nil, &ret); err != nil {
+		return err
+	}
+	ret.Defaults.Collection = "(default)"
+	return writeShellCollectionSettings(env, append([]shellCollectionSetting{ret.Defaults}, ret.Collections...)...)
+}
+
+func shellCollectionSet(env *shellEnv, args []string) error {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: collection.set <name> [-replication=<xyz>] ...")
+	}
+	fs := newShellFlagSet(env, "collection.set")
+	fs.String("replication", "", "replication type of the new volumes")
+	fs.String("garbageThreshold", "", "vacuum the volumes with more garbage than the ratio")
+	fs.String("ttl", "", "default time to live of the new volumes, e.g. 3d")
+	fs.String("maxVolumeCount", "", "maximum number of volumes in the collection, 0 for no limit")
+	fs.String("tier", "", "disk tier of the new volumes")
+	fs.String("maxBytes", "", "maximum live bytes in the collection, 0 for no limit")
+	fs.String("maxFileCount", "", "maximum live files in the collection, 0 for no limit")
+	fs.String("dedup", "", "store the same content in the collection only once, true or false")
+	fs.String("trashRetention", "", "keep the deleted files recoverable by vacuum for the period, e.g. 7d")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	values := url.Values{"collection": {args[0]}}
+	fs.Visit(func(f *flag.Flag) {
+		values.Set(f.Name, f.Value.String())
+	})
+	content, err := postForm(env.master, "/col/settings/set", values)
+	if err != nil {
+		return err
+	}
+	var ret struct {
+		Effective shellCollectionSetting
+	}
+	if err = json.Unmarshal(content, &ret); err != nil {
+		return err
+	}
+	return writeShellCollectionSettings(env, ret.Effective)
+}
+
+func shellCollectionReset(env *shellEnv, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: collection.reset <name>")
+	}
+	if _, err := postForm(env.master, "/col/settings/delete", url.Values{"collection": {args[0]}}); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "collection %s uses the default settings\n", args[0])
+	return nil
+}
+
+func shellCollectionUsage(env *shellEnv, args []string) error {
+	values := url.Values{}
+	if len(args) > 0 {
+		values.Set("collection", args[0])
+	}
+	var ret struct {
+		Collections []struct {
+			Collection  string `json:"collection"`
+			Bytes       uint64 `json:"bytes"`
+			FileCount   uint64 `json:"fileCount"`
+			VolumeCount int    `json:"volumeCount"`
+			Quota       struct {
+				MaxBytes       uint64 `json:"maxBytes"`
+				MaxFileCount   uint64 `json:"maxFileCount"`
+				MaxVolumeCount int    `json:"maxVolumeCount"`
+			} `json:"quota"`
+		}
+	}
+	if err := getJson(env.master, "/col/usage", values, &ret); err != nil {
+		return err
+	}
+	tw := newShellTable(env, "COLLECTION", "BYTES", "MAXBYTES", "FILES", "MAXFILES", "VOLUMES", "MAXVOLUMES")
+	for _, u := range ret.Collections {
+		writeShellRow(tw, u.Collection, u.Bytes, u.Quota.MaxBytes, u.FileCount, u.Quota.MaxFileCount, u.VolumeCount, u.Quota.MaxVolumeCount)
+	}
+	return tw.Flush()
+}
+
+type shellAccessKey struct {
+	Id          string   `json:"id"`
+	Secret      string   `json:"secret"`
+	Collections []string `json:"collections"`
+	Actions     []string `json:"actions"`
+}
+
+func shellKeyList(env *shellEnv, args []string) error {
+	var ret struct {
+		Keys []shellAccessKey
+	}
+	if err := getJson(env.master, "/key/list", nil, &ret); err != nil {
+		return err
+	}
+	tw := newShellTable(env, "ID", "COLLECTIONS", "ACTIONS")
+	for _, k := range ret.Keys {
+		writeShellRow(tw, k.Id, strings.Join(k.Collections, ","), strings.Join(k.Actions, ","))
+	}
+	return tw.Flush()
+}
+
+func shellKeySet(env *shellEnv, args []string) error {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: key.set <id> -collections=<a,b|*> -actions=<read,write,delete,admin|*>")
+	}
+	fs := newShellFlagSet(env, "key.set")
+	collections := fs.String("collections", "", "comma separated collections, * for all collections")
+	actions := fs.String("actions", "", "comma separated actions of read, write, delete and admin, * for all actions")
+	secret := fs.String("secret", "", "secret of the key, generated if empty")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	content, err := postForm(env.master, "/key/set", url.Values{
+		"id":          {args[0]},
+		"collections": {*collections},
+		"actions":     {*actions},
+		"secret":      {*secret},
+	})
+	if err != nil {
+		return err
+	}
+	var k shellAccessKey
+	if err = json.Unmarshal(content, &k); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "key %s secret %s collections %s actions %s\n",
+		k.Id, k.Secret, strings.Join(k.Collections, ","), strings.Join(k.Actions, ","))
+	return nil
+}
+
+func shellKeyDelete(env *shellEnv, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: key.delete <id>")
+	}
+	if _, err := postForm(env.master, "/key/delete", url.Values{"id": {args[0]}}); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.out, "key %s deleted\n", args[0])
+	return nil
+}
+
+func listShellDataNodes(env *shellEnv, fn func(dc, rack string, dn shellDataNode)) error {
+	var status shellTopology
+	if err := getJson(env.master, "/dir/status", nil, &status); err != nil {
+		return err
+	}
+	for _, dc := range status.Topology.DataCenters {
+		for _, rack := range dc.Racks {
+			for _, dn := range rack.DataNodes {
+				fn(dc.Id, rack.Id, dn)
+			}
+		}
+	}
+	return nil
+}
+
+func shellNodeList(env *shellEnv, args []string) error {
+	tw := newShellTable(env, "DC", "RACK", "NODE", "PUBLIC", "VOLUMES", "MAX", "FREE")
+	if err := listShellDataNodes(env, func(dc, rack string, dn shellDataNode) {
+		writeShellRow(tw, dc, rack, dn.Url, dn.PublicUrl, dn.Volumes, dn.Max, dn.Free)
+	}); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func shellTaskList(env *shellEnv, args []string) error {
+	var nodes []string
+	if err := listShellDataNodes(env, func(dc, rack string, dn shellDataNode) {
+		nodes = append(nodes, dn.Url)
+	}); err != nil {
+		return err
+	}
+	tw := newShellTable(env, "NODE", "TASK", "ELAPSED", "STATUS")
+	for _, node := range nodes {
+		var ret struct {
+			Tasks []storage.TaskStatus `json:"tasks"`
+		}
+		if err := getJson(node, "/admin/task/all", nil, &ret); err != nil {
+			writeShellRow(tw, node, "-", "-", err)
+			continue
+		}
+		for _, t := range ret.Tasks {
+			status := "running"
+			if t.Error != "" {
+				status = "failed: " + t.Error
+			} else if t.Finished {
+				status = "finished"
+			}
+			writeShellRow(tw, node, t.Id, time.Since(t.StartTime)/time.Second*time.Second, status)
+		}
+	}
+	return tw.Flush()
+}
+
+func shellFsLs(env *shellEnv, args []string) error {
+	dir := "/"
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	tw := newShellTable(env, "NAME", "FID")
+	lastFileName := ""
+	for {
+		var ret struct {
+			Subdirectories []filer.DirectoryEntry
+			Files          []filer.FileEntry
+		}
+		if err := getJson(env.filer, dir, url.Values{"lastFileName": {lastFileName}, "limit": {"1000"}}, &ret); err != nil {
+			return err
+		}
+		for _, d := range ret.Subdirectories {
+			writeShellRow(tw, d.Name+"/", "")
+		}
+		for _, f := range ret.Files {
+			writeShellRow(tw, f.Name, f.Id)
+		}
+		if len(ret.Files) < 1000 {
+			break
+		}
+		lastFileName = ret.Files[len(ret.Files)-1].Name
+	}
+	return tw.Flush()
+}
+
+func shellFsCat(env *shellEnv, args []string) error {
+	if len(args) != 1 || strings.HasSuffix(args[0], "/") {
+		return fmt.Errorf("usage: fs.cat <file>")
+	}
+	_, rc, err := util.DownloadUrl(util.MkUrl(env.filer, args[0], nil))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(env.out, rc)
+	return err
+}
+
+func shellFsMv(env *shellEnv, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: fs.mv <from> <to>")
+	}
+	_, err := postForm(env.filer, "/admin/mv", url.Values{"from": {args[0]}, "to": {args[1]}})
+	return err
+}
@@ -0,0 +1,110 @@
+package weedcmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestShellCommands(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vol/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Volumes":{"DataCenters":{"dc1":{"rack1":{"127.0.0.1:8080":[
+			{"Id":2,"Size":100,"Collection":"pics","FileCount":3},
+			{"Id":1,"Size":200,"FileCount":5,"ReadOnly":true}]}}}}}`))
+	})
+	mux.HandleFunc("/dir/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Topology":{"DataCenters":[{"Id":"dc1","Racks":[{"Id":"rack1","DataNodes":[
+			{"Url":"127.0.0.1:8080","PublicUrl":"127.0.0.1:8080","Volumes":2,"Max":7,"Free":5}]}]}],
+			"layouts":[{"collection":"pics","replication":"000","ttl":"","writables":[2]}]}}`))
+	})
+	mux.HandleFunc("/col/delete", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"collection nope does not exist"}`))
+	})
+	mux.HandleFunc("/col/settings", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Defaults":{"replica_placement":"000","vacuum_garbage_threshold":"0.3"},
+			"Collections":[{"collection":"logs","ttl":"7d","max_volume_count":5}]}`))
+	})
+	mux.HandleFunc("/col/usage", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Collections":[{"collection":"logs","bytes":1300,"fileCount":13,"volumeCount":2,
+			"quota":{"maxBytes":5000,"maxFileCount":0,"maxVolumeCount":4}}]}`))
+	})
+	mux.HandleFunc("/key/list", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Keys":[{"id":"uploader","collections":["pics","logs"],"actions":["read","write"]}]}`))
+	})
+	var setForm url.Values
+	mux.HandleFunc("/col/settings/set", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		setForm = r.PostForm
+		w.Write([]byte(`{"Effective":{"collection":"logs","replica_placement":"000","ttl":"3d","tier":"cold"}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	env := &shellEnv{master: strings.TrimPrefix(server.URL, "http://"), out: out}
+	cases := []struct {
+		line     string
+		expected []string
+	}{
+		{"volume.list", []string{"ID", "1   ", "true", "2   pics"}},
+		{"volume.list -collection=pics", []string{"2   pics"}},
+		{"node.list", []string{"dc1  rack1  127.0.0.1:8080", "7    5"}},
+		{"collection.list", []string{"pics        000"}},
+		{"collection.settings", []string{"(default)   000", "logs", "7d   5"}},
+		{"collection.usage", []string{"logs        1300   5000      13"}},
+		{"collection.set logs -ttl=3d -tier=cold", []string{"logs        000", "cold"}},
+		{"key.list", []string{"uploader  pics,logs    read,write"}},
+		{"help", []string{"volume.grow", "fs.mv"}},
+	}
+	for _, c := range cases {
+		out.Reset()
+		if err := env.execute(c.line); err != nil {
+			t.Fatalf("%s: %v", c.line, err)
+		}
+		for _, s := range c.expected {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("%s: expect %q in output:\n%s", c.line, s, out.String())
+			}
+		}
+	}
+	if setForm.Get("collection") != "logs" || setForm.Get("ttl") != "3d" || len(setForm) != 3 {
+		t.Errorf("only the given settings should be changed, got %v", setForm)
+	}
+	out.Reset()
+	env.execute("volume.list -collection=pics")
+	if strings.Contains(out.String(), "true") {
+		t.Errorf("volume in other collection is listed:\n%s", out.String())
+	}
+	if err := env.execute("collection.delete nope"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("expect collection.delete error, got %v", err)
+	}
+	if err := env.execute("volume.unknown"); err == nil {
+		t.Errorf("expect unknown command error")
+	}
+}
+
+func TestShellHistory(t *testing.T) {
+	h := &shellHistoryList{}
+	if _, err := h.expand("!!"); err == nil {
+		t.Errorf("expect error on empty history")
+	}
+	h.add("node.list")
+	h.add("volume.list")
+	h.add("volume.list")
+	if len(h.lines) != 2 {
+		t.Errorf("duplicated command should be added once: %v", h.lines)
+	}
+	for line, expected := range map[string]string{"!!": "volume.list", "!1": "node.list", "fs.ls": "fs.ls"} {
+		if got, err := h.expand(line); err != nil || got != expected {
+			t.Errorf("expand %s: got %s %v, expected %s", line, got, err, expected)
+		}
+	}
+	if _, err := h.expand("!3"); err == nil {
+		t.Errorf("expect error on missing event")
+	}
+}
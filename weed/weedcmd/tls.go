@@ -0,0 +1,78 @@
+package weedcmd
+
+import (
+	"crypto/tls"
+	"flag"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// TLSOptions makes the servers serve https, and talk to each other over https
+type TLSOptions struct {
+	cert       *string
+	key        *string
+	ca         *string
+	clientCert *bool
+}
+
+// bind adds the flags of a server, withClientCert for the servers guarding the admin calls
+func (o *TLSOptions) bind(fs *flag.FlagSet, prefix string, withClientCert bool) {
+	o.cert = fs.String(prefix+"cert", "", "certificate file in PEM to serve https, also presented as the client certificate to the other servers")
+	o.key = fs.String(prefix+"key", "", "private key file in PEM of the certificate")
+	o.ca = fs.String(prefix+"ca", "", "CA certificate file in PEM to verify the other servers and the client certificates, default to the system CAs")
+	if withClientCert {
+		o.clientCert = fs.Bool(prefix+"clientCert", false, "require a client certificate signed by the CA for the admin calls and writes, instead of the whiteList")
+	}
+}
+
+// bindClient adds the flags of a client command
+func (o *TLSOptions) bindClient(fs *flag.FlagSet, prefix string) {
+	o.cert = fs.String(prefix+"cert", "", "client certificate file in PEM, if the servers require one")
+	o.key = fs.String(prefix+"key", "", "private key file in PEM of the client certificate")
+	o.ca = fs.String(prefix+"ca", "", "CA certificate file in PEM to talk to the servers over https, default to plain http")
+}
+
+func (o *TLSOptions) enabled() bool {
+	return *o.cert != "" || *o.ca != ""
+}
+
+// setupClient makes the requests to the servers use https if enabled
+func (o *TLSOptions) setupClient() {
+	if !o.enabled() {
+		return
+	}
+	config, err := security.NewClientTLSConfig(*o.cert, *o.key, *o.ca)
+	if err != nil {
+		glog.Fatalf("TLS client config: %v", err)
+	}
+	util.SetTLS(config)
+}
+
+// setupServer makes the requests to the other servers use https,
+// and returns the config to serve https, nil if TLS is not enabled.
+func (o *TLSOptions) setupServer() *tls.Config {
+	if !o.enabled() {
+		if o.clientCertRequired() {
+			glog.Fatalf("client certificates are only checked over https, set the cert, key and ca flags")
+		}
+		return nil
+	}
+	if *o.cert == "" || *o.key == "" {
+		glog.Fatalf("both the certificate and its key are required to serve https")
+	}
+	if o.clientCertRequired() && *o.ca == "" {
+		glog.Fatalf("the CA is required to verify the client certificates")
+	}
+	o.setupClient()
+	config, err := security.NewServerTLSConfig(*o.cert, *o.key, *o.ca)
+	if err != nil {
+		glog.Fatalf("TLS server config: %v", err)
+	}
+	return config
+}
+
+func (o *TLSOptions) clientCertRequired() bool {
+	return o.clientCert != nil && *o.clientCert
+}
@@ -23,6 +23,7 @@ type UploadOptions struct {
 	ttl         *string
 	maxMB       *int
 	secretKey   *string
+	tls         TLSOptions
 }
 
 func init() {
@@ -36,6 +37,7 @@ func init() {
 	upload.ttl = cmdUpload.Flag.String("ttl", "", "time to live, e.g.: 1m, 1h, 1d, 1M, 1y")
 	upload.maxMB = cmdUpload.Flag.Int("maxMB", 0, "split files larger than the limit")
 	upload.secretKey = cmdUpload.Flag.String("secure.secret", "", "secret to encrypt Json Web Token(JWT)")
+	upload.tls.bindClient(&cmdUpload.Flag, "tls.")
 }
 
 var cmdUpload = &Command{
@@ -63,6 +65,7 @@ var cmdUpload = &Command{
 
 func runUpload(cmd *Command, args []string) bool {
 	secret := security.Secret(*upload.secretKey)
+	upload.tls.setupClient()
 	if len(cmdUpload.Flag.Args()) == 0 {
 		if *upload.dir == "" {
 			return false
@@ -35,10 +35,14 @@ type VolumeServerOptions struct {
 	dataCenter            *string
 	rack                  *string
 	whiteList             []string
+	secretKey             *string
 	indexType             *string
 	fixJpgOrientation     *bool
+	imageCacheMB          *int
 	readRedirect          *bool
 	readRemoteNeedle      *bool
+	encryptionKeyFile     *string
+	tls                   TLSOptions
 }
 
 func init() {
@@ -56,8 +60,12 @@ func init() {
 	v.rack = cmdVolume.Flag.String("rack", "", "current volume server's rack name")
 	v.indexType = cmdVolume.Flag.String("index", "memory", "Choose [memory|leveldb|boltdb] mode for memory~performance balance.")
 	v.fixJpgOrientation = cmdVolume.Flag.Bool("images.fix.orientation", true, "Adjust jpg orientation when uploading.")
+	v.imageCacheMB = cmdVolume.Flag.Int("images.cacheMB", 64, "megabytes of transformed images cached in memory, 0 to disable")
 	v.readRedirect = cmdVolume.Flag.Bool("read.redirect", true, "Redirect moved or non-local volumes.")
 	v.readRemoteNeedle = cmdVolume.Flag.Bool("read.remote.needle", false, "Read remote needle when have non-local volumes.")
+	v.encryptionKeyFile = cmdVolume.Flag.String("encryption.keyFile", "", "file of the keys to encrypt the collections, one \"collection:keyId:hexKey\" per line")
+	v.secretKey = cmdVolume.Flag.String("secure.secret", "", "secret to encrypt Json Web Token(JWT), the same as the master's")
+	v.tls.bind(&cmdVolume.Flag, "tls.", true)
 
 }
 
@@ -70,11 +78,23 @@ var cmdVolume = &Command{
 }
 
 var (
-	volumeFolders         = cmdVolume.Flag.String("dir", os.TempDir(), "directories to store data files. dir[,dir]...")
+	volumeFolders         = cmdVolume.Flag.String("dir", os.TempDir(), "directories to store data files, optionally tagged with a tier. dir[:tier][,dir[:tier]]...")
 	maxVolumeCounts       = cmdVolume.Flag.String("max", "7", "maximum numbers of volumes, count[,count]...")
 	volumeWhiteListOption = cmdVolume.Flag.String("whiteList", "", "comma separated Ip addresses having write permission. No limit if empty.")
 )
 
+// loadKeyProvider reads the encryption keys, nil if there is no key file
+func loadKeyProvider(keyFile string) storage.KeyProvider {
+	if keyFile == "" {
+		return nil
+	}
+	keys, err := storage.NewFileKeyProvider(keyFile)
+	if err != nil {
+		glog.Fatalf("load encryption keys: %v", err)
+	}
+	return keys
+}
+
 func runVolume(cmd *Command, args []string) bool {
 	if *v.maxCpu < 1 {
 		*v.maxCpu = runtime.NumCPU()
@@ -95,6 +115,7 @@ func runVolume(cmd *Command, args []string) bool {
 		glog.Fatalf("%d directories by -dir, but only %d max is set by -max", len(v.folders), len(v.folderMaxLimits))
 	}
 	for _, folder := range v.folders {
+		folder, _ = storage.ParseDiskTier(folder)
 		if err := util.TestFolderWritable(folder); err != nil {
 			glog.Fatalf("Check Data Folder(-dir) Writable %s : %s", folder, err)
 		}
@@ -130,14 +151,17 @@ func runVolume(cmd *Command, args []string) bool {
 	case "boltdb":
 		volumeNeedleMapKind = storage.NeedleMapBoltDb
 	}
+	tlsConfig := v.tls.setupServer()
 	volumeServer := weedserver.NewVolumeServer(volumeMux, publicVolumeMux,
 		*v.ip, *v.port, *v.publicUrl,
 		v.folders, v.folderMaxLimits,
 		volumeNeedleMapKind,
 		*v.master, *v.pulseSeconds, *v.dataCenter, *v.rack,
-		v.whiteList,
-		*v.fixJpgOrientation, *v.readRedirect, *v.readRemoteNeedle,
+		v.whiteList, *v.secretKey,
+		*v.fixJpgOrientation, *v.imageCacheMB, *v.readRedirect, *v.readRemoteNeedle,
+		loadKeyProvider(*v.encryptionKeyFile),
 	)
+	volumeServer.SetClientCertRequired(v.tls.clientCertRequired())
 
 	listeningAddress := net.JoinHostPort(*v.bindIp, strconv.Itoa(*v.port))
 	glog.V(0).Infoln("Start Seaweed volume server", util.VERSION, "at", listeningAddress)
@@ -145,6 +169,7 @@ func runVolume(cmd *Command, args []string) bool {
 	if e != nil {
 		glog.Fatalf("Volume server listener error:%v", e)
 	}
+	listener = util.NewTLSListener(listener, tlsConfig)
 	if isSeperatedPublicPort {
 		publicListeningAddress := net.JoinHostPort(*v.bindIp, strconv.Itoa(*v.publicPort))
 		glog.V(0).Infoln("Start Seaweed volume server", util.VERSION, "public at", publicListeningAddress)
@@ -152,8 +177,9 @@ func runVolume(cmd *Command, args []string) bool {
 		if e != nil {
 			glog.Fatalf("Volume server listener error:%v", e)
 		}
+		publicListener = util.NewTLSListener(publicListener, tlsConfig)
 		go func() {
-			if e := http.Serve(publicListener, publicVolumeMux); e != nil {
+			if e := http.Serve(publicListener, weedserver.InstrumentServeMux("volume", publicVolumeMux)); e != nil {
 				glog.Fatalf("Volume server fail to serve public: %v", e)
 			}
 		}()
@@ -163,7 +189,7 @@ func runVolume(cmd *Command, args []string) bool {
 		volumeServer.Shutdown()
 	})
 
-	if e := http.Serve(listener, volumeMux); e != nil {
+	if e := http.Serve(listener, weedserver.InstrumentServeMux("volume", volumeMux)); e != nil {
 		glog.Fatalf("Volume server fail to serve: %v", e)
 	}
 	return true
@@ -22,13 +22,16 @@ var server *string
 var commands = []*Command{
 	cmdBenchmark,
 	cmdBackup,
+	cmdReplicate,
 	cmdCompact,
+	cmdEcEncode,
 	cmdFix,
 	cmdServer,
 	cmdMaster,
 	cmdFiler,
 	cmdUpload,
 	cmdDownload,
+	cmdS3,
 	cmdShell,
 	cmdVersion,
 	cmdVolume,
@@ -12,8 +12,10 @@ It has these top-level messages:
 	VolumeInformationMessage
 	JoinMessage
 	JoinMessageV2
+	EcShardInformationMessage
 	CollectionSetting
 	JoinResponse
+	DiskTierMessage
 */
 package weedpb
 
@@ -41,6 +43,9 @@ type VolumeInformationMessage struct {
 	ReplicaPlacement uint32 `protobuf:"varint,8,opt,name=replica_placement,json=replicaPlacement" json:"replica_placement,omitempty"`
 	Version          uint32 `protobuf:"varint,9,opt,name=version" json:"version,omitempty"`
 	Ttl              uint32 `protobuf:"varint,10,opt,name=ttl" json:"ttl,omitempty"`
+	Tier             string `protobuf:"bytes,11,opt,name=tier" json:"tier,omitempty"`
+	LastModified     uint64 `protobuf:"varint,12,opt,name=last_modified,json=lastModified" json:"last_modified,omitempty"`
+	ReadCount        uint64 `protobuf:"varint,13,opt,name=read_count,json=readCount" json:"read_count,omitempty"`
 }
 
 func (m *VolumeInformationMessage) Reset()                    { *m = VolumeInformationMessage{} }
@@ -75,15 +80,17 @@ func (m *JoinMessage) GetVolumes() []*VolumeInformationMessage {
 }
 
 type JoinMessageV2 struct {
-	JoinKey        string                      `protobuf:"bytes,1,opt,name=join_key,json=joinKey" json:"join_key,omitempty"`
-	Ip             string                      `protobuf:"bytes,2,opt,name=ip" json:"ip,omitempty"`
-	Port           uint32                      `protobuf:"varint,3,opt,name=port" json:"port,omitempty"`
-	PublicUrl      string                      `protobuf:"bytes,4,opt,name=public_url,json=publicUrl" json:"public_url,omitempty"`
-	MaxVolumeCount uint32                      `protobuf:"varint,5,opt,name=max_volume_count,json=maxVolumeCount" json:"max_volume_count,omitempty"`
-	MaxFileKey     uint64                      `protobuf:"varint,6,opt,name=max_file_key,json=maxFileKey" json:"max_file_key,omitempty"`
-	DataCenter     string                      `protobuf:"bytes,7,opt,name=data_center,json=dataCenter" json:"data_center,omitempty"`
-	Rack           string                      `protobuf:"bytes,8,opt,name=rack" json:"rack,omitempty"`
-	Volumes        []*VolumeInformationMessage `protobuf:"bytes,9,rep,name=volumes" json:"volumes,omitempty"`
+	JoinKey        string                       `protobuf:"bytes,1,opt,name=join_key,json=joinKey" json:"join_key,omitempty"`
+	Ip             string                       `protobuf:"bytes,2,opt,name=ip" json:"ip,omitempty"`
+	Port           uint32                       `protobuf:"varint,3,opt,name=port" json:"port,omitempty"`
+	PublicUrl      string                       `protobuf:"bytes,4,opt,name=public_url,json=publicUrl" json:"public_url,omitempty"`
+	MaxVolumeCount uint32                       `protobuf:"varint,5,opt,name=max_volume_count,json=maxVolumeCount" json:"max_volume_count,omitempty"`
+	MaxFileKey     uint64                       `protobuf:"varint,6,opt,name=max_file_key,json=maxFileKey" json:"max_file_key,omitempty"`
+	DataCenter     string                       `protobuf:"bytes,7,opt,name=data_center,json=dataCenter" json:"data_center,omitempty"`
+	Rack           string                       `protobuf:"bytes,8,opt,name=rack" json:"rack,omitempty"`
+	Volumes        []*VolumeInformationMessage  `protobuf:"bytes,9,rep,name=volumes" json:"volumes,omitempty"`
+	EcShards       []*EcShardInformationMessage `protobuf:"bytes,10,rep,name=ec_shards,json=ecShards" json:"ec_shards,omitempty"`
+	DiskTiers      []*DiskTierMessage           `protobuf:"bytes,11,rep,name=disk_tiers,json=diskTiers" json:"disk_tiers,omitempty"`
 }
 
 func (m *JoinMessageV2) Reset()                    { *m = JoinMessageV2{} }
@@ -98,16 +105,48 @@ func (m *JoinMessageV2) GetVolumes() []*VolumeInformationMessage {
 	return nil
 }
 
+func (m *JoinMessageV2) GetEcShards() []*EcShardInformationMessage {
+	if m != nil {
+		return m.EcShards
+	}
+	return nil
+}
+
+func (m *JoinMessageV2) GetDiskTiers() []*DiskTierMessage {
+	if m != nil {
+		return m.DiskTiers
+	}
+	return nil
+}
+
+type EcShardInformationMessage struct {
+	Id          uint32 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Collection  string `protobuf:"bytes,2,opt,name=collection" json:"collection,omitempty"`
+	EcIndexBits uint32 `protobuf:"varint,3,opt,name=ec_index_bits,json=ecIndexBits" json:"ec_index_bits,omitempty"`
+}
+
+func (m *EcShardInformationMessage) Reset()                    { *m = EcShardInformationMessage{} }
+func (m *EcShardInformationMessage) String() string            { return proto.CompactTextString(m) }
+func (*EcShardInformationMessage) ProtoMessage()               {}
+func (*EcShardInformationMessage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
 type CollectionSetting struct {
 	Collection             string `protobuf:"bytes,1,opt,name=collection" json:"collection,omitempty"`
 	ReplicaPlacement       string `protobuf:"bytes,2,opt,name=replica_placement,json=replicaPlacement" json:"replica_placement,omitempty"`
 	VacuumGarbageThreshold string `protobuf:"bytes,3,opt,name=vacuum_garbage_threshold,json=vacuumGarbageThreshold" json:"vacuum_garbage_threshold,omitempty"`
+	Ttl                    string `protobuf:"bytes,4,opt,name=ttl" json:"ttl,omitempty"`
+	MaxVolumeCount         uint32 `protobuf:"varint,5,opt,name=max_volume_count,json=maxVolumeCount" json:"max_volume_count,omitempty"`
+	Tier                   string `protobuf:"bytes,6,opt,name=tier" json:"tier,omitempty"`
+	MaxBytes               uint64 `protobuf:"varint,7,opt,name=max_bytes,json=maxBytes" json:"max_bytes,omitempty"`
+	MaxFileCount           uint64 `protobuf:"varint,8,opt,name=max_file_count,json=maxFileCount" json:"max_file_count,omitempty"`
+	Dedup                  bool   `protobuf:"varint,9,opt,name=dedup" json:"dedup,omitempty"`
+	TrashRetention         string `protobuf:"bytes,10,opt,name=trash_retention,json=trashRetention" json:"trash_retention,omitempty"`
 }
 
 func (m *CollectionSetting) Reset()                    { *m = CollectionSetting{} }
 func (m *CollectionSetting) String() string            { return proto.CompactTextString(m) }
 func (*CollectionSetting) ProtoMessage()               {}
-func (*CollectionSetting) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*CollectionSetting) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 type JoinResponse struct {
 	Error              string               `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
@@ -116,12 +155,13 @@ type JoinResponse struct {
 	VolumeSizeLimit    uint64               `protobuf:"varint,4,opt,name=volume_size_limit,json=volumeSizeLimit" json:"volume_size_limit,omitempty"`
 	CollectionSettings []*CollectionSetting `protobuf:"bytes,5,rep,name=collection_settings,json=collectionSettings" json:"collection_settings,omitempty"`
 	SecretKey          string               `protobuf:"bytes,6,opt,name=secret_key,json=secretKey" json:"secret_key,omitempty"`
+	SecureReads        bool                 `protobuf:"varint,7,opt,name=secure_reads,json=secureReads" json:"secure_reads,omitempty"`
 }
 
 func (m *JoinResponse) Reset()                    { *m = JoinResponse{} }
 func (m *JoinResponse) String() string            { return proto.CompactTextString(m) }
 func (*JoinResponse) ProtoMessage()               {}
-func (*JoinResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*JoinResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *JoinResponse) GetCollectionSettings() []*CollectionSetting {
 	if m != nil {
@@ -130,54 +170,82 @@ func (m *JoinResponse) GetCollectionSettings() []*CollectionSetting {
 	return nil
 }
 
+type DiskTierMessage struct {
+	Tier           string `protobuf:"bytes,1,opt,name=tier" json:"tier,omitempty"`
+	MaxVolumeCount uint32 `protobuf:"varint,2,opt,name=max_volume_count,json=maxVolumeCount" json:"max_volume_count,omitempty"`
+}
+
+func (m *DiskTierMessage) Reset()                    { *m = DiskTierMessage{} }
+func (m *DiskTierMessage) String() string            { return proto.CompactTextString(m) }
+func (*DiskTierMessage) ProtoMessage()               {}
+func (*DiskTierMessage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
 func init() {
 	proto.RegisterType((*VolumeInformationMessage)(nil), "weedpb.VolumeInformationMessage")
 	proto.RegisterType((*JoinMessage)(nil), "weedpb.JoinMessage")
 	proto.RegisterType((*JoinMessageV2)(nil), "weedpb.JoinMessageV2")
+	proto.RegisterType((*EcShardInformationMessage)(nil), "weedpb.EcShardInformationMessage")
 	proto.RegisterType((*CollectionSetting)(nil), "weedpb.CollectionSetting")
 	proto.RegisterType((*JoinResponse)(nil), "weedpb.JoinResponse")
+	proto.RegisterType((*DiskTierMessage)(nil), "weedpb.DiskTierMessage")
 }
 
 var fileDescriptor0 = []byte{
-	// 627 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe4, 0x94, 0xe1, 0x4e, 0xd4, 0x40,
-	0x10, 0xc7, 0xd3, 0x02, 0x77, 0xd7, 0x39, 0x0e, 0x8f, 0x95, 0x68, 0x89, 0x51, 0xf1, 0x3e, 0x11,
-	0x35, 0x98, 0xe0, 0x17, 0xe3, 0x47, 0x48, 0x34, 0xa0, 0x46, 0x52, 0x94, 0xaf, 0x9b, 0x5e, 0x3b,
-	0xc0, 0xca, 0xb6, 0xdb, 0xec, 0xee, 0xa1, 0xe7, 0xa3, 0xf8, 0x08, 0xbe, 0x87, 0x89, 0x6f, 0xe3,
-	0x2b, 0xb8, 0x3b, 0x7b, 0x3d, 0x81, 0xd3, 0x27, 0xf0, 0xdb, 0xce, 0x7f, 0xa6, 0xdd, 0x99, 0xdf,
-	0xcc, 0x2c, 0x6c, 0x98, 0xa9, 0xb1, 0x58, 0xf1, 0x0a, 0x8d, 0xc9, 0xcf, 0x70, 0xa7, 0xd1, 0xca,
-	0x2a, 0xd6, 0xf9, 0x8c, 0x58, 0x36, 0xe3, 0xd1, 0xcf, 0x18, 0xd2, 0x13, 0x25, 0x27, 0x15, 0x1e,
-	0xd4, 0xa7, 0x4a, 0x57, 0xb9, 0x15, 0xaa, 0x7e, 0x17, 0x42, 0xd9, 0x1a, 0xc4, 0xa2, 0x4c, 0xa3,
-	0xad, 0x68, 0x7b, 0x90, 0xb9, 0x13, 0x63, 0xb0, 0x6c, 0xc4, 0x57, 0x4c, 0x63, 0xa7, 0x2c, 0x67,
-	0x74, 0x66, 0x0f, 0x00, 0x0a, 0x25, 0x25, 0x16, 0xfe, 0xc3, 0x74, 0xc9, 0x79, 0x92, 0xec, 0x8a,
-	0xc2, 0xee, 0x03, 0x9c, 0x0a, 0x89, 0xbc, 0x50, 0x93, 0xda, 0xa6, 0xcb, 0xf4, 0x65, 0xe2, 0x95,
-	0x7d, 0x2f, 0xb0, 0x47, 0xb0, 0x5a, 0xa2, 0x44, 0xdb, 0x06, 0xac, 0x50, 0x40, 0x3f, 0x68, 0x21,
-	0xe4, 0x29, 0xb0, 0x60, 0x96, 0x7c, 0x3c, 0x9d, 0x07, 0x76, 0x28, 0x70, 0x38, 0xf3, 0xec, 0x4d,
-	0xdb, 0xe8, 0x7b, 0x90, 0x68, 0xcc, 0x4b, 0xae, 0x6a, 0x39, 0x4d, 0xbb, 0x2e, 0xa8, 0x97, 0xf5,
-	0xbc, 0xf0, 0xde, 0xd9, 0xec, 0x19, 0xac, 0x6b, 0x6c, 0xa4, 0x28, 0x72, 0xde, 0xc8, 0xbc, 0xc0,
-	0x0a, 0xdd, 0x9f, 0x7a, 0xbe, 0xbe, 0xbd, 0x38, 0x8d, 0xb2, 0xe1, 0xcc, 0x79, 0xd4, 0xfa, 0x58,
-	0x0a, 0xdd, 0x4b, 0xd4, 0xc6, 0x97, 0x96, 0x10, 0x86, 0xd6, 0x64, 0x43, 0x58, 0xb2, 0x56, 0xa6,
-	0x40, 0xaa, 0x3f, 0x8e, 0x7e, 0xc4, 0xd0, 0x3f, 0x54, 0x62, 0x4e, 0xef, 0x2e, 0x74, 0x85, 0xe1,
-	0xa2, 0x16, 0x96, 0x10, 0xf6, 0xb2, 0x8e, 0x30, 0x07, 0xce, 0x22, 0xac, 0x0d, 0x41, 0x4c, 0x1c,
-	0xd6, 0xc6, 0x63, 0x6d, 0x94, 0xb6, 0x04, 0x6f, 0x90, 0xd1, 0xd9, 0x63, 0x6b, 0x26, 0x63, 0x97,
-	0x0c, 0x9f, 0x68, 0x49, 0xd8, 0x92, 0x2c, 0x09, 0xca, 0x47, 0x2d, 0xd9, 0x36, 0x0c, 0xab, 0xfc,
-	0x0b, 0xbf, 0xa4, 0xce, 0x5d, 0x41, 0x37, 0xc8, 0xd6, 0x9c, 0x1e, 0x1a, 0x1a, 0x78, 0x6c, 0xc1,
-	0xaa, 0x8f, 0xa4, 0x1e, 0x5c, 0xe0, 0x74, 0xc6, 0x0d, 0x9c, 0xf6, 0xca, 0x49, 0x6f, 0x70, 0xca,
-	0x1e, 0x42, 0xbf, 0xcc, 0x6d, 0xce, 0x0b, 0x57, 0x30, 0x6a, 0x62, 0xe6, 0x5a, 0xe8, 0xa5, 0x7d,
-	0x52, 0x7c, 0x7e, 0x3a, 0x2f, 0x2e, 0x08, 0x54, 0x92, 0xd1, 0x99, 0xbd, 0x74, 0x60, 0xe8, 0x16,
-	0xe3, 0xc0, 0x2c, 0x6d, 0xf7, 0x77, 0xb7, 0x76, 0xc2, 0x44, 0xed, 0xfc, 0x6b, 0x9a, 0xb2, 0xf6,
-	0x03, 0x5f, 0x5b, 0x5e, 0x56, 0xa2, 0xe6, 0x54, 0x75, 0x20, 0x98, 0x90, 0x72, 0xe4, 0x84, 0xd1,
-	0xf7, 0x18, 0x06, 0x57, 0x38, 0x9e, 0xec, 0xb2, 0x4d, 0xe8, 0x7d, 0x72, 0x02, 0xe5, 0x1f, 0x51,
-	0x12, 0x5d, 0x6f, 0xfb, 0xe4, 0xff, 0x73, 0x96, 0xa3, 0x6f, 0x11, 0xac, 0xef, 0xcf, 0xb7, 0xed,
-	0x18, 0xad, 0x15, 0xf5, 0xd9, 0x8d, 0xa5, 0x8c, 0x16, 0x96, 0xf2, 0xc9, 0xdf, 0xf6, 0x20, 0x40,
-	0x5c, 0xdc, 0x81, 0x17, 0x90, 0x5e, 0xe6, 0xc5, 0x64, 0x52, 0xf1, 0xb3, 0x5c, 0x8f, 0xdd, 0xed,
-	0xdc, 0x9e, 0x6b, 0x34, 0xe7, 0x4a, 0x96, 0xb3, 0x7d, 0xbf, 0x13, 0xfc, 0xaf, 0x83, 0xfb, 0x43,
-	0xeb, 0x1d, 0xfd, 0x8a, 0x60, 0xd5, 0x77, 0x32, 0x43, 0xd3, 0xa8, 0xda, 0x20, 0xdb, 0x80, 0x15,
-	0xd4, 0x5a, 0xe9, 0x59, 0x4a, 0xc1, 0xb8, 0xd6, 0xde, 0xf8, 0x7a, 0x7b, 0xdd, 0x0e, 0x91, 0xcb,
-	0xf5, 0x38, 0x5c, 0xd5, 0xf1, 0xe6, 0x41, 0xc3, 0x1e, 0xc3, 0xfa, 0xac, 0x61, 0xfe, 0x15, 0xe2,
-	0x52, 0x54, 0xa2, 0x7d, 0x5d, 0x6e, 0x05, 0xc7, 0xb1, 0xd3, 0xdf, 0x7a, 0x99, 0x1d, 0xc2, 0xed,
-	0x3f, 0xb5, 0x73, 0x13, 0x18, 0x19, 0xd7, 0x63, 0xcf, 0x7a, 0xb3, 0x65, 0xbd, 0x40, 0x31, 0x63,
-	0xc5, 0x4d, 0x89, 0x66, 0xd7, 0x60, 0xa1, 0xd1, 0xce, 0x07, 0xc0, 0xcd, 0x52, 0x50, 0x5c, 0xbe,
-	0xe3, 0x0e, 0xbd, 0xae, 0xcf, 0x7f, 0x07, 0x00, 0x00, 0xff, 0xff, 0x13, 0x29, 0xcf, 0xb8, 0x75,
-	0x05, 0x00, 0x00,
+	// 881 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe4, 0x55, 0xdb, 0x6e, 0x23, 0x45,
+	0x13, 0xd6, 0x8c, 0x13, 0x7b, 0xa6, 0x6c, 0xe7, 0xd0, 0xff, 0xea, 0xcf, 0x44, 0x2b, 0xc0, 0x31,
+	0x48, 0x58, 0x80, 0x82, 0xb4, 0x48, 0x08, 0x71, 0xc1, 0x45, 0xc2, 0x41, 0x59, 0x58, 0xed, 0xaa,
+	0xb3, 0xec, 0x6d, 0xab, 0x3d, 0x53, 0x49, 0x9a, 0xcc, 0x4c, 0x8f, 0xba, 0xdb, 0xc1, 0xde, 0xc7,
+	0xe0, 0x09, 0x10, 0xef, 0xc2, 0xdb, 0xf0, 0x10, 0xa8, 0xab, 0x67, 0x8c, 0x13, 0x67, 0x2f, 0xb8,
+	0xe6, 0xae, 0xfa, 0xab, 0xc3, 0x54, 0xd7, 0x57, 0xfd, 0x0d, 0x3c, 0xb1, 0x2b, 0xeb, 0xb0, 0x12,
+	0x15, 0x5a, 0x2b, 0xaf, 0xf1, 0xb4, 0x31, 0xda, 0x69, 0xd6, 0xff, 0x15, 0xb1, 0x68, 0xe6, 0xd3,
+	0xdf, 0x7b, 0x90, 0xbd, 0xd1, 0xe5, 0xa2, 0xc2, 0x8b, 0xfa, 0x4a, 0x9b, 0x4a, 0x3a, 0xa5, 0xeb,
+	0x17, 0x21, 0x94, 0xed, 0x41, 0xac, 0x8a, 0x2c, 0x9a, 0x44, 0xb3, 0x31, 0x8f, 0x55, 0xc1, 0x18,
+	0xec, 0x58, 0xf5, 0x16, 0xb3, 0x78, 0x12, 0xcd, 0x76, 0x38, 0xd9, 0xec, 0x7d, 0x80, 0x5c, 0x97,
+	0x25, 0xe6, 0x3e, 0x31, 0xeb, 0x4d, 0xa2, 0x59, 0xca, 0x37, 0x10, 0xf6, 0x1e, 0xc0, 0x95, 0x2a,
+	0x51, 0xe4, 0x7a, 0x51, 0xbb, 0x6c, 0x87, 0x32, 0x53, 0x8f, 0x9c, 0x7b, 0x80, 0x9d, 0xc0, 0xa8,
+	0xc0, 0x12, 0x5d, 0x17, 0xb0, 0x4b, 0x01, 0xc3, 0x80, 0x85, 0x90, 0xcf, 0x80, 0x85, 0x63, 0x21,
+	0xe6, 0xab, 0x75, 0x60, 0x9f, 0x02, 0x0f, 0x5a, 0xcf, 0xd9, 0xaa, 0x8b, 0x7e, 0x0a, 0xa9, 0x41,
+	0x59, 0x08, 0x5d, 0x97, 0xab, 0x6c, 0x30, 0x89, 0x66, 0x09, 0x4f, 0x3c, 0xf0, 0xb2, 0x2e, 0x57,
+	0xec, 0x73, 0x38, 0x34, 0xd8, 0x94, 0x2a, 0x97, 0xa2, 0x29, 0x65, 0x8e, 0x15, 0xd6, 0x2e, 0x4b,
+	0xfc, 0xfd, 0xce, 0xe2, 0x2c, 0xe2, 0x07, 0xad, 0xf3, 0x55, 0xe7, 0x63, 0x19, 0x0c, 0xee, 0xd0,
+	0x58, 0x7f, 0xb5, 0x94, 0xc6, 0xd0, 0x1d, 0xd9, 0x01, 0xf4, 0x9c, 0x2b, 0x33, 0x20, 0xd4, 0x9b,
+	0x7e, 0x3a, 0x4e, 0xa1, 0xc9, 0x86, 0x34, 0x03, 0xb2, 0xd9, 0x87, 0x30, 0x2e, 0xa5, 0x75, 0xa2,
+	0xd2, 0x85, 0xba, 0x52, 0x58, 0x64, 0x23, 0x6a, 0x7b, 0xe4, 0xc1, 0x17, 0x2d, 0xe6, 0x47, 0x44,
+	0x2d, 0x87, 0x8b, 0x8d, 0xc3, 0x88, 0x3c, 0x42, 0x37, 0x9a, 0xfe, 0x19, 0xc3, 0xf0, 0xb9, 0x56,
+	0x6b, 0x56, 0x8e, 0x60, 0xa0, 0xac, 0x50, 0xb5, 0x72, 0x44, 0x4d, 0xc2, 0xfb, 0xca, 0x5e, 0xd4,
+	0xca, 0x11, 0x5d, 0x0d, 0x91, 0x93, 0xf2, 0x58, 0x35, 0xbe, 0xa1, 0x46, 0x1b, 0x47, 0xa4, 0x8c,
+	0x39, 0xd9, 0xfe, 0x5b, 0xcd, 0x62, 0x5e, 0xaa, 0x5c, 0x2c, 0x4c, 0x49, 0x74, 0xa4, 0x3c, 0x0d,
+	0xc8, 0xcf, 0xa6, 0x64, 0x33, 0x38, 0xa8, 0xe4, 0x52, 0xdc, 0xd1, 0x46, 0x6c, 0x50, 0x32, 0xe6,
+	0x7b, 0x95, 0x5c, 0x86, 0x45, 0x09, 0x73, 0x9e, 0xc0, 0xc8, 0x47, 0x12, 0xb7, 0xb7, 0xb8, 0x6a,
+	0xf9, 0x80, 0x4a, 0x2e, 0xbf, 0x57, 0x25, 0xfe, 0x88, 0x2b, 0xf6, 0x01, 0x0c, 0x0b, 0xe9, 0xa4,
+	0xc8, 0xb1, 0x76, 0x68, 0x88, 0x8b, 0x94, 0x83, 0x87, 0xce, 0x09, 0xf1, 0xfd, 0x19, 0x99, 0xdf,
+	0x12, 0x01, 0x29, 0x27, 0x9b, 0x7d, 0x0d, 0x83, 0xf0, 0x71, 0x9b, 0xa5, 0x93, 0xde, 0x6c, 0xf8,
+	0x6c, 0x72, 0x1a, 0x36, 0xf5, 0xf4, 0x5d, 0x5b, 0xca, 0xbb, 0x04, 0x7f, 0x37, 0x59, 0x54, 0xaa,
+	0x16, 0x74, 0xeb, 0xc0, 0x4c, 0x4a, 0xc8, 0x2b, 0x6d, 0xdc, 0xf4, 0x8f, 0x1e, 0x8c, 0x37, 0xe6,
+	0xf8, 0xe6, 0x19, 0x3b, 0x86, 0xe4, 0x17, 0xad, 0x6a, 0xea, 0x3f, 0xa2, 0x26, 0x06, 0xfe, 0xec,
+	0x9b, 0xff, 0xaf, 0xcf, 0xf2, 0x1b, 0x48, 0x31, 0x17, 0xf6, 0x46, 0x9a, 0xc2, 0x66, 0x40, 0xd9,
+	0x27, 0x5d, 0xf6, 0x77, 0xf9, 0xa5, 0xc7, 0x1f, 0x49, 0x4f, 0x30, 0xb8, 0x2c, 0xfb, 0x12, 0xa0,
+	0x50, 0xf6, 0x56, 0xf8, 0x57, 0x60, 0xb3, 0x21, 0x15, 0x38, 0xea, 0x0a, 0x7c, 0xab, 0xec, 0xed,
+	0x6b, 0x85, 0xa6, 0x4b, 0x4b, 0x8b, 0x16, 0xb0, 0x53, 0x0d, 0xc7, 0xef, 0x2c, 0xbf, 0xa5, 0x47,
+	0xf7, 0xb5, 0x27, 0xde, 0xd2, 0x9e, 0x29, 0x8c, 0x31, 0x17, 0xaa, 0x2e, 0x70, 0x29, 0xe6, 0xca,
+	0xd9, 0x96, 0xbd, 0x21, 0xe6, 0x17, 0x1e, 0x3b, 0x53, 0xce, 0x4e, 0xff, 0x8a, 0xe1, 0xf0, 0x7c,
+	0x9d, 0x72, 0x89, 0xce, 0xa9, 0xfa, 0xfa, 0x41, 0xe5, 0x68, 0xab, 0xf2, 0xa7, 0x8f, 0x09, 0x49,
+	0x68, 0x60, 0x5b, 0x44, 0xbe, 0x82, 0xec, 0x4e, 0xe6, 0x8b, 0x45, 0x25, 0xae, 0xa5, 0x99, 0xcb,
+	0x6b, 0x14, 0xee, 0xc6, 0xa0, 0xbd, 0xd1, 0x65, 0xd1, 0x0a, 0xe6, 0xff, 0x83, 0xff, 0x87, 0xe0,
+	0x7e, 0xdd, 0x79, 0x3b, 0x91, 0x09, 0xab, 0xe5, 0xcd, 0x7f, 0xb1, 0x54, 0x9d, 0x1c, 0xf5, 0x37,
+	0xe4, 0xe8, 0x29, 0xa4, 0x3e, 0xdb, 0xcb, 0xa8, 0xa5, 0x25, 0xda, 0xe1, 0x49, 0x25, 0x97, 0x5e,
+	0x3d, 0x2d, 0xfb, 0x08, 0xf6, 0xd6, 0x5b, 0x18, 0x0a, 0x27, 0x41, 0xac, 0xda, 0x3d, 0x0c, 0x65,
+	0x9f, 0xc0, 0x6e, 0x81, 0xc5, 0xa2, 0x21, 0x3d, 0x4c, 0x78, 0x38, 0xb0, 0x8f, 0x61, 0xdf, 0x19,
+	0x69, 0x6f, 0x84, 0x41, 0x87, 0x35, 0x0d, 0x0d, 0xe8, 0xbb, 0x7b, 0x04, 0xf3, 0x0e, 0x9d, 0xfe,
+	0x16, 0xc3, 0xc8, 0x3f, 0x42, 0x8e, 0xb6, 0xd1, 0xb5, 0x45, 0x5f, 0x0f, 0x8d, 0xd1, 0xa6, 0x1d,
+	0x72, 0x38, 0xdc, 0x7b, 0x99, 0xf1, 0xfd, 0x97, 0x79, 0x04, 0x64, 0x0a, 0xd5, 0xb4, 0xc3, 0xeb,
+	0xfb, 0xe3, 0x45, 0xc3, 0x3e, 0x81, 0xc3, 0x76, 0x2c, 0xfe, 0xc7, 0x24, 0x4a, 0x55, 0xa9, 0xee,
+	0x87, 0xb3, 0x1f, 0x1c, 0x97, 0xea, 0x2d, 0xfe, 0xe4, 0x61, 0xf6, 0x1c, 0xfe, 0xf7, 0x0f, 0x9b,
+	0xc2, 0x06, 0xd6, 0x6d, 0xb6, 0x4b, 0x7b, 0x7a, 0xdc, 0xed, 0xe9, 0xd6, 0x5e, 0x70, 0x96, 0x3f,
+	0x84, 0x48, 0x76, 0x2c, 0xe6, 0x06, 0xdd, 0xfa, 0xed, 0xa6, 0x3c, 0x0d, 0x88, 0xef, 0xf7, 0x04,
+	0x46, 0x16, 0xf3, 0x85, 0x41, 0xe1, 0x25, 0xdd, 0xb6, 0xff, 0xa4, 0x61, 0xc0, 0xb8, 0x87, 0xa6,
+	0x2f, 0x61, 0xff, 0xc1, 0x93, 0x58, 0xb3, 0x17, 0x6d, 0xb0, 0xf7, 0x18, 0xf7, 0xf1, 0x63, 0xdc,
+	0xcf, 0xfb, 0xf4, 0x93, 0xff, 0xe2, 0xef, 0x01, 0x00, 0x97, 0x6a, 0xf4, 0xf8, 0xfc, 0x07, 0x00,
+	0x00,
 }
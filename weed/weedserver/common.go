@@ -115,7 +115,7 @@ func submitForClientHandler(w http.ResponseWriter, r *http.Request, masterUrl st
 		return
 	}
 
-	url := "http://" + assignResult.Url + "/" + assignResult.Fid
+	url := util.NormalizeUrl(assignResult.Url + "/" + assignResult.Fid)
 	if lastModified != 0 {
 		url = url + "?ts=" + strconv.FormatUint(lastModified, 10)
 	}
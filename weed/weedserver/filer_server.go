@@ -1,8 +1,13 @@
 package weedserver
 
 import (
+	"hash/fnv"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/filer/cassandra_store"
@@ -11,6 +16,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/filer/redis_store"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 type FilerServer struct {
@@ -22,13 +28,22 @@ type FilerServer struct {
 	disableDirListing  bool
 	secret             security.Secret
 	filer              filer.Filer
+	trash              *filer.TrashFiler
+	versioning         *filer.Versioning
+	uploads            *filer.UploadSessions
+	maxMB              int
+	// pathLocks serialize the changes of the same path, see lockPaths
+	pathLocks [64]sync.Mutex
 }
 
 func NewFilerServer(r *http.ServeMux, port int, master string, dir string, collection string,
-	replication string, redirectOnRead bool, disableDirListing bool,
+	replication string, redirectOnRead bool, disableDirListing bool, maxMB int,
 	secret string,
 	cassandra_server string, cassandra_keyspace string,
 	redis_server string, redis_password string, redis_database int,
+	trashRetention time.Duration,
+	versioning string, maxVersions int, maxVersionAge time.Duration,
+	uploadTimeout time.Duration,
 ) (fs *FilerServer, err error) {
 	fs = &FilerServer{
 		master:             master,
@@ -36,32 +51,103 @@ func NewFilerServer(r *http.ServeMux, port int, master string, dir string, colle
 		defaultReplication: replication,
 		redirectOnRead:     redirectOnRead,
 		disableDirListing:  disableDirListing,
+		maxMB:              maxMB,
 		port:               ":" + strconv.Itoa(port),
+		secret:             security.Secret(secret),
+	}
+	if secret != "" {
+		// only for the calls to the master, the reads and writes have tokens of their file ids
+		util.SetInternalJwt(security.NewGuard(nil, secret).InternalJwt)
 	}
 
+	if trashRetention > 0 && (cassandra_server != "" || redis_server != "") {
+		glog.Fatalf("The trash needs the directories of the embedded filer")
+	}
+	if versioning != "" && (cassandra_server != "" || redis_server != "") {
+		glog.Fatalf("The versioning needs the directories of the embedded filer")
+	}
 	if cassandra_server != "" {
 		cassandra_store, err := cassandra_store.NewCassandraStore(cassandra_keyspace, cassandra_server)
 		if err != nil {
 			glog.Fatalf("Can not connect to cassandra server %s with keyspace %s: %v", cassandra_server, cassandra_keyspace, err)
 		}
-		fs.filer = flat_namespace.NewFlatNamespaceFiler(master, cassandra_store)
+		fs.filer = flat_namespace.NewFlatNamespaceFiler(master, cassandra_store, secret)
 	} else if redis_server != "" {
 		redis_store := redis_store.NewRedisStore(redis_server, redis_password, redis_database)
-		fs.filer = flat_namespace.NewFlatNamespaceFiler(master, redis_store)
+		fs.filer = flat_namespace.NewFlatNamespaceFiler(master, redis_store, secret)
 	} else {
-		if fs.filer, err = embedded_filer.NewFilerEmbedded(master, dir); err != nil {
+		if fs.filer, err = embedded_filer.NewFilerEmbedded(master, dir, secret); err != nil {
 			glog.Fatalf("Can not start filer in dir %s : %v", dir, err)
 			return
 		}
 
 		r.HandleFunc("/admin/mv", fs.moveHandler)
+
+		if versioning != "" {
+			// the expired versions are removed for good, not moved into the trash
+			fs.versioning = filer.NewVersioning(fs.filer, versioning, maxVersions, maxVersionAge)
+		}
+
+		// the parts of the uploads are deleted for good when they are aborted
+		fs.uploads = filer.NewUploadSessions(fs.filer)
+		if uploadTimeout > 0 {
+			go fs.expireUploads(uploadTimeout)
+		}
+
+		if trashRetention > 0 {
+			fs.trash = filer.NewTrashFiler(fs.filer)
+			fs.filer = fs.trash
+			go fs.purgeTrash(trashRetention)
+		}
 	}
 
+	r.HandleFunc("/__api__", fs.apiHandler)
+	r.HandleFunc("/metrics", metricsHandler())
 	r.HandleFunc("/", fs.filerHandler)
 
 	return fs, nil
 }
 
+// isTrashed tells the deleted entry is moved into the trash, with its content kept
+func (fs *FilerServer) isTrashed(fullPath string) bool {
+	return fs.trash != nil && !filer.InTrash(fullPath)
+}
+
+func (fs *FilerServer) purgeTrash(retention time.Duration) {
+	for range time.Tick(time.Minute) {
+		if err := fs.trash.Purge(time.Now().Add(-retention)); err != nil {
+			glog.V(0).Infof("purge the trash: %v", err)
+		}
+	}
+}
+
 func (fs *FilerServer) jwt(fileId string) security.EncodedJwt {
 	return security.GenJwt(fs.secret, fileId)
 }
+
+// lockPaths serializes the changes of the entries at the paths, so the entry replaced or deleted
+// by a request is seen by that request only, and its content is deleted exactly once.
+// It returns the function to unlock them.
+func (fs *FilerServer) lockPaths(paths ...string) (unlock func()) {
+	var slots []int
+	for _, p := range paths {
+		h := fnv.New32a()
+		h.Write([]byte(filepath.Clean(p)))
+		slots = append(slots, int(h.Sum32()%uint32(len(fs.pathLocks))))
+	}
+	// always in the same order, and each slot once, so two paths do not deadlock
+	sort.Ints(slots)
+	var locked []int
+	for i, slot := range slots {
+		if i > 0 && slot == slots[i-1] {
+			continue
+		}
+		fs.pathLocks[slot].Lock()
+		locked = append(locked, slot)
+	}
+	return func() {
+		for _, slot := range locked {
+			fs.pathLocks[slot].Unlock()
+		}
+	}
+}
@@ -1,24 +1,36 @@
 package weedserver
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/storage"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
+// maxDedupUploadMB limits the uploads hashed for the dedup when the filer does not stream the large uploads
+const maxDedupUploadMB = 1024
+
 func (fs *FilerServer) filerHandler(w http.ResponseWriter, r *http.Request) {
+	if isUploadRequest(r) {
+		fs.uploadHandler(w, r)
+		return
+	}
 	switch r.Method {
 	case "GET":
 		fs.GetOrHeadHandler(w, r, true)
@@ -70,14 +82,25 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request,
 		fs.listDirectoryHandler(w, r)
 		return
 	}
+	query := r.URL.Query()
+	if query.Get("versions") == "true" {
+		fs.listVersionsHandler(w, r)
+		return
+	}
 
 	fileId, err := fs.filer.FindFile(r.URL.Path)
+	if version := query.Get("version"); version != "" {
+		if fileId, err = fs.findVersion(r.URL.Path, version); err != nil {
+			glog.V(3).Infoln("Version not found", r.URL.Path, err.Error())
+			writeJsonError(w, r, http.StatusNotFound, err)
+			return
+		}
+	}
 	if err == leveldb.ErrNotFound {
 		glog.V(3).Infoln("Not found in db", r.URL.Path)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	query := r.URL.Query()
 	collection := query.Get("collection")
 	if collection == "" {
 		collection = fs.collection
@@ -120,15 +143,22 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request,
 	io.Copy(w, resp.Body)
 }
 
-type analogueReader struct {
-	*bytes.Buffer
+// spooledBody is a request body saved into a temp file, which is removed once closed
+type spooledBody struct {
+	*os.File
 }
 
-// So that it implements the io.ReadCloser interface
-func (m analogueReader) Close() error { return nil }
+func (b spooledBody) Close() error {
+	b.File.Close()
+	return os.Remove(b.Name())
+}
 
 func (fs *FilerServer) PostHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
+	if version := query.Get("restore"); version != "" {
+		fs.restoreVersionHandler(w, r, version)
+		return
+	}
 	replication := query.Get("replication")
 	if replication == "" {
 		replication = fs.defaultReplication
@@ -137,89 +167,55 @@ func (fs *FilerServer) PostHandler(w http.ResponseWriter, r *http.Request) {
 	if collection == "" {
 		collection = fs.collection
 	}
+	if fs.isStreamed(r) {
+		fs.streamUploadHandler(w, r, replication, collection)
+		return
+	}
 
-	var fileId string
-	var err error
-	var urlLocation string
-	if r.Method == "PUT" {
-		buf, _ := ioutil.ReadAll(r.Body)
-		r.Body = analogueReader{bytes.NewBuffer(buf)}
-		fileName, _, _, _, _, _, _, pe := storage.ParseUpload(r)
-		if pe != nil {
-			glog.V(0).Infoln("failing to parse post body", pe.Error())
-			writeJsonError(w, r, http.StatusInternalServerError, pe)
-			return
-		}
-		//reconstruct http request body for following new request to volume server
-		r.Body = analogueReader{bytes.NewBuffer(buf)}
-
-		path := r.URL.Path
-		if strings.HasSuffix(path, "/") {
-			if fileName != "" {
-				path += fileName
-			}
-		}
-
-		if fileId, err = fs.filer.FindFile(path); err != nil && err != leveldb.ErrNotFound {
-			glog.V(0).Infoln("failing to find path in filer store", path, err.Error())
+	// every upload is written into a new file, PUT deletes the content of the replaced file once saved
+	assignResult, ae := operation.Assign(fs.master, 1, replication, collection, query.Get("ttl"))
+	if ae != nil {
+		glog.V(0).Infoln("failing to assign a file id", ae.Error())
+		writeJsonError(w, r, http.StatusInternalServerError, ae)
+		return
+	}
+	fileId := assignResult.Fid
+	urlLocation := util.NormalizeUrl(assignResult.Url + "/" + assignResult.Fid)
+	var resp_body []byte
+	// the assigned fid registered for the content, forgotten if the upload fails
+	var registered bool
+	if assignResult.Dedup {
+		var err error
+		fileId, resp_body, registered, err = fs.acquireDedup(w, r, collection, fileId)
+		// removes the spooled body, if not sent to the volume server
+		defer r.Body.Close()
+		if err != nil {
+			glog.V(0).Infoln("failing to dedup", r.RequestURI, err.Error())
 			writeJsonError(w, r, http.StatusInternalServerError, err)
 			return
-		} else if fileId != "" && err == nil {
-			var le error
-			urlLocation, le = operation.LookupFileId(fs.master, fileId, collection, false)
-			if le != nil {
-				glog.V(1).Infoln("operation LookupFileId %s failed, err is %s", fileId, le.Error())
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
-		}
-	} else {
-		assignResult, ae := operation.Assign(fs.master, 1, replication, collection, query.Get("ttl"))
-		if ae != nil {
-			glog.V(0).Infoln("failing to assign a file id", ae.Error())
-			writeJsonError(w, r, http.StatusInternalServerError, ae)
-			return
 		}
-		fileId = assignResult.Fid
-		urlLocation = "http://" + assignResult.Url + "/" + assignResult.Fid
 	}
 
-	u, _ := url.Parse(urlLocation)
-	glog.V(4).Infoln("post to", u)
-	request := &http.Request{
-		Method:        r.Method,
-		URL:           u,
-		Proto:         r.Proto,
-		ProtoMajor:    r.ProtoMajor,
-		ProtoMinor:    r.ProtoMinor,
-		Header:        r.Header,
-		Body:          r.Body,
-		Host:          r.Host,
-		ContentLength: r.ContentLength,
-	}
-	resp, do_err := util.HttpDo(request)
-	if do_err != nil {
-		glog.V(0).Infoln("failing to connect to volume server", r.RequestURI, do_err.Error())
-		writeJsonError(w, r, http.StatusInternalServerError, do_err)
-		return
-	}
-	defer resp.Body.Close()
-	resp_body, ra_err := ioutil.ReadAll(resp.Body)
-	if ra_err != nil {
-		glog.V(0).Infoln("failing to upload to volume server", r.RequestURI, ra_err.Error())
-		writeJsonError(w, r, http.StatusInternalServerError, ra_err)
-		return
+	if resp_body == nil {
+		var status int
+		var err error
+		if resp_body, status, err = proxyToVolumeServer(r, urlLocation, fs.jwt(fileId)); err != nil {
+			fs.releaseDedup(registered, fileId)
+			writeJsonError(w, r, status, err)
+			return
+		}
 	}
-	glog.V(4).Infoln("post result", string(resp_body))
 	var ret operation.UploadResult
 	unmarshal_err := json.Unmarshal(resp_body, &ret)
 	if unmarshal_err != nil {
 		glog.V(0).Infoln("failing to read upload resonse", r.RequestURI, string(resp_body))
+		fs.releaseDedup(registered, fileId)
 		writeJsonError(w, r, http.StatusInternalServerError, unmarshal_err)
 		return
 	}
 	if ret.Error != "" {
 		glog.V(0).Infoln("failing to post to volume server", r.RequestURI, ret.Error)
+		fs.releaseDedup(registered, fileId)
 		writeJsonError(w, r, http.StatusInternalServerError, errors.New(ret.Error))
 		return
 	}
@@ -235,15 +231,152 @@ func (fs *FilerServer) PostHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	fs.saveFileEntry(w, r, path, fileId, r.Method == "PUT", collection, replication, ret.Mime, uint64(ret.Size), ret)
+}
+
+// saveFileEntry maps the path to the uploaded file, and replies with the upload result and the file id.
+// The attributes are from the upload, the mime type is guessed from the path if it is not known.
+// The content of the replaced file is deleted if deleteReplaced, unless it is kept as a version.
+func (fs *FilerServer) saveFileEntry(w http.ResponseWriter, r *http.Request, path, fileId string, deleteReplaced bool,
+	collection, replication, mimeType string, size uint64, ret operation.UploadResult) {
+	query := r.URL.Query()
 	glog.V(4).Infoln("saving", path, "=>", fileId)
-	if db_err := fs.filer.CreateFile(path, fileId); db_err != nil {
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	}
+	entry := &filer.FileEntry{
+		Name: filepath.Base(path),
+		Id:   filer.FileId(fileId),
+		Attributes: filer.Attributes{
+			Size:  size,
+			Mtime: time.Now(),
+			Mode:  filer.DefaultFileMode,
+			Mime:  mimeType,
+		},
+	}
+	entry.Collection = collection
+	entry.Replication = replication
+	entry.Ttl = query.Get("ttl")
+	if mode, e := strconv.ParseUint(query.Get("mode"), 8, 32); e == nil {
+		entry.Mode = os.FileMode(mode)
+	}
+	if uid, e := strconv.ParseUint(query.Get("uid"), 10, 32); e == nil {
+		entry.Uid = uint32(uid)
+	}
+	if gid, e := strconv.ParseUint(query.Get("gid"), 10, 32); e == nil {
+		entry.Gid = uint32(gid)
+	}
+	oldEntry, db_err := fs.createFileEntry(path, entry, deleteReplaced)
+	if db_err != nil {
 		operation.DeleteFile(fs.master, fileId, collection, fs.jwt(fileId)) //clean up
 		glog.V(0).Infof("failing to write %s to filer server : %v", path, db_err)
 		writeJsonError(w, r, http.StatusInternalServerError, db_err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	w.Write(resp_body)
+	if oldEntry != nil && oldEntry.Id == entry.Id {
+		// the content is deduplicated into the file it replaces, which holds a reference already
+		fs.releaseDedup(true, fileId)
+	}
+	ret.Fid = fileId
+	writeJsonQuiet(w, r, http.StatusCreated, ret)
+}
+
+// acquireDedup references the file already stored with the content of the upload,
+// and returns its fid with the upload result, or the assigned fid with a nil result
+// if the content is new and should be written. The assigned fid is then registered
+// for the content, and should be released if the content can not be written.
+// The body is hashed while it is spooled into a temp file, which the request reads
+// from afterwards, and is limited to maxMB, or maxDedupUploadMB if not streaming.
+func (fs *FilerServer) acquireDedup(w http.ResponseWriter, r *http.Request, collection, fileId string) (fid string, resp_body []byte, registered bool, err error) {
+	limit := int64(fs.maxMB) << 20
+	if limit <= 0 {
+		limit = maxDedupUploadMB << 20
+	}
+	spool, err := ioutil.TempFile("", "weed-dedup-")
+	if err != nil {
+		return fileId, nil, false, err
+	}
+	body := http.MaxBytesReader(w, r.Body, limit)
+	// the content is hashed from a copy of the request, reading the body through the spool
+	parsed := *r
+	parsed.Body = ioutil.NopCloser(io.TeeReader(body, spool))
+	fileName, data, mimeType, _, _, _, isChunkedFile, pe := storage.ParseUploadStream(&parsed)
+	var hash string
+	var size int64
+	if pe == nil && !isChunkedFile {
+		hash, size, pe = operation.HashContent(data)
+	}
+	// keep the rest of the body after the file part
+	if _, err = io.Copy(spool, body); err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooledBody{spool}.Close()
+		return fileId, nil, false, err
+	}
+	r.Body = spooledBody{spool}
+	if pe != nil || isChunkedFile {
+		return fileId, nil, false, pe
+	}
+	dedup, err := operation.DedupAcquire(fs.master, collection, hash, size, fileId, fs.jwt(fileId))
+	if err != nil {
+		return fileId, nil, false, err
+	}
+	if !dedup.Found {
+		return fileId, nil, true, nil
+	}
+	glog.V(4).Infoln("dedup", fileId, "=>", dedup.Fid)
+	resp_body, err = json.Marshal(operation.UploadResult{Name: fileName, Size: uint32(size), Mime: mimeType})
+	return dedup.Fid, resp_body, false, err
+}
+
+// releaseDedup forgets the reference acquired for the failed upload, if registered.
+// Once the file is written, the reference is released by deleting the file instead.
+func (fs *FilerServer) releaseDedup(registered bool, fileId string) {
+	if !registered {
+		return
+	}
+	if _, err := operation.DedupRelease(fs.master, []string{fileId}, fs.jwt(fileId)); err != nil {
+		glog.V(0).Infof("release the dedup reference of %s: %v", fileId, err)
+	}
+}
+
+// proxyToVolumeServer sends the upload to the volume server, with the token of the file id
+// if the filer has a secret, and returns the response with the status to report on errors.
+func proxyToVolumeServer(r *http.Request, urlLocation string, jwt security.EncodedJwt) ([]byte, int, error) {
+	u, _ := url.Parse(urlLocation)
+	glog.V(4).Infoln("post to", u)
+	header := make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	if jwt != "" {
+		header.Set("Authorization", "BEARER "+string(jwt))
+	}
+	request := &http.Request{
+		Method:        r.Method,
+		URL:           u,
+		Proto:         r.Proto,
+		ProtoMajor:    r.ProtoMajor,
+		ProtoMinor:    r.ProtoMinor,
+		Header:        header,
+		Body:          r.Body,
+		Host:          r.Host,
+		ContentLength: r.ContentLength,
+	}
+	resp, do_err := util.HttpDo(request)
+	if do_err != nil {
+		glog.V(0).Infoln("failing to connect to volume server", r.RequestURI, do_err.Error())
+		return nil, http.StatusInternalServerError, do_err
+	}
+	defer resp.Body.Close()
+	resp_body, ra_err := ioutil.ReadAll(resp.Body)
+	if ra_err != nil {
+		glog.V(0).Infoln("failing to upload to volume server", r.RequestURI, ra_err.Error())
+		return nil, http.StatusInternalServerError, ra_err
+	}
+	glog.V(4).Infoln("post result", string(resp_body))
+	return resp_body, http.StatusOK, nil
 }
 
 // curl -X DELETE http://localhost:8888/path/to
@@ -255,10 +388,23 @@ func (fs *FilerServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 		isRecursive := r.FormValue("recursive") == "true"
 		err = fs.filer.DeleteDirectory(r.URL.Path, isRecursive)
 	} else {
+		defer fs.lockPaths(r.URL.Path)()
+		entry, _ := fs.filer.FindFileEntry(r.URL.Path)
+		if fs.isTrashed(r.URL.Path) {
+			// the content is kept with the entry in the trash
+			entry = nil
+		}
 		fid, err = fs.filer.DeleteFile(r.URL.Path)
 		if err == nil && fid != "" {
 			err = operation.DeleteFile(fs.master, fid, r.FormValue("collection"), fs.jwt(fid))
 		}
+		if err == nil && entry != nil {
+			for _, chunk := range entry.Chunks {
+				if e := operation.DeleteFile(fs.master, chunk.Fid, entry.Collection, fs.jwt(chunk.Fid)); e != nil {
+					glog.V(0).Infof("deleting chunk %s of %s: %v", chunk.Fid, r.URL.Path, e)
+				}
+			}
+		}
 	}
 	if err == nil {
 		writeJsonQuiet(w, r, http.StatusAccepted, map[string]string{"error": ""})
@@ -19,7 +19,7 @@ Wildcard is not supported.
 func (fs *FilerServer) moveHandler(w http.ResponseWriter, r *http.Request) {
 	from := r.FormValue("from")
 	to := r.FormValue("to")
-	err := fs.filer.Move(from, to)
+	err := fs.move(from, to)
 	if err != nil {
 		glog.V(4).Infoln("moving", from, "->", to, err.Error())
 		writeJsonError(w, r, http.StatusInternalServerError, err)
@@ -0,0 +1,161 @@
+package weedserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+)
+
+// apiHandler serves the filer.ApiRequest posted in the "request" form value,
+// it is used by "weed mount" to list and change the name space.
+func (fs *FilerServer) apiHandler(w http.ResponseWriter, r *http.Request) {
+	var request filer.ApiRequest
+	if err := json.Unmarshal([]byte(r.FormValue("request")), &request); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	glog.V(4).Infof("filer api %+v", request)
+	fullPath := filepath.Join(request.Directory, request.FileName)
+	var err error
+	switch request.Command {
+	case "listFiles":
+		var files []filer.FileEntry
+		if request.FileName != "" {
+			var entry *filer.FileEntry
+			if entry, err = fs.filer.FindFileEntry(fullPath); err == nil {
+				entry.Name = request.FileName
+				files = append(files, *entry)
+			}
+		} else {
+			limit := request.Limit
+			if limit <= 0 {
+				limit = 1000
+			}
+			files, err = fs.filer.ListFiles(request.Directory, request.LastFileName, limit)
+		}
+		if err == nil {
+			writeJsonQuiet(w, r, http.StatusOK, filer.ListFilesResult{Files: files})
+			return
+		}
+	case "listDirectories":
+		var dirs []filer.DirectoryEntry
+		if dirs, err = fs.filer.ListDirectories(request.Directory); err == nil {
+			writeJsonQuiet(w, r, http.StatusOK, filer.ListDirectoriesResult{Directories: dirs})
+			return
+		}
+	case "createFile":
+		if request.Entry == nil {
+			err = fmt.Errorf("missing entry for %s", fullPath)
+			break
+		}
+		_, err = fs.createFileEntry(fullPath, request.Entry, true)
+	case "deleteFile":
+		err = fs.deleteFileEntry(fullPath)
+	case "mkdir":
+		err = fs.filer.CreateDirectory(request.Directory)
+	case "rmdir":
+		err = fs.filer.DeleteDirectory(request.Directory, false)
+	case "move":
+		err = fs.move(request.From, request.To)
+	case "status":
+		writeJsonQuiet(w, r, http.StatusOK, filer.FilerStatusResult{Master: fs.master})
+		return
+	default:
+		err = fmt.Errorf("unknown command %q", request.Command)
+	}
+	if err != nil {
+		glog.V(2).Infof("filer api %s %s: %v", request.Command, fullPath, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, filer.ApiResult{})
+}
+
+// createFileEntry saves the entry, and returns the entry it replaces. Under the versioned directories,
+// the replaced entry is kept as a version if its content changes, otherwise the content only referenced
+// by the replaced entry is deleted if deleteReplaced. Without a creation time, the one of the replaced
+// entry is kept.
+func (fs *FilerServer) createFileEntry(fullPath string, entry *filer.FileEntry, deleteReplaced bool) (*filer.FileEntry, error) {
+	defer fs.lockPaths(fullPath)()
+	entry.Name = filepath.Base(fullPath)
+	oldEntry, _ := fs.filer.FindFileEntry(fullPath)
+	if entry.Crtime.IsZero() {
+		entry.Crtime = entry.Mtime
+		if oldEntry != nil {
+			entry.Crtime = oldEntry.Crtime
+		}
+	}
+	if err := fs.filer.CreateFileEntry(fullPath, entry); err != nil {
+		return nil, err
+	}
+	if oldEntry == nil {
+		return nil, nil
+	}
+	kept := make(map[string]bool)
+	for _, fid := range entry.Fids() {
+		kept[fid] = true
+	}
+	var fids []string
+	for _, fid := range oldEntry.Fids() {
+		if !kept[fid] {
+			fids = append(fids, fid)
+		}
+	}
+	if len(fids) > 0 && fs.isVersioned(fullPath) {
+		fs.saveVersion(fullPath, oldEntry)
+	} else if deleteReplaced {
+		fs.deleteFileContent(fullPath, fids)
+	}
+	return oldEntry, nil
+}
+
+// deleteFileEntry deletes the entry with its content, unless the entry is moved into the trash
+func (fs *FilerServer) deleteFileEntry(fullPath string) error {
+	defer fs.lockPaths(fullPath)()
+	entry, err := fs.filer.FindFileEntry(fullPath)
+	if err != nil {
+		return err
+	}
+	if _, err = fs.filer.DeleteFile(fullPath); err == nil && !fs.isTrashed(fullPath) {
+		fs.deleteFileContent(fullPath, entry.Fids())
+	}
+	return err
+}
+
+// move renames the file or directory, the content of a replaced file is not referenced any more,
+// unless kept as a version
+func (fs *FilerServer) move(from, to string) error {
+	defer fs.lockPaths(from, to)()
+	var replaced *filer.FileEntry
+	if filepath.Clean(from) != filepath.Clean(to) {
+		replaced, _ = fs.filer.FindFileEntry(to)
+	}
+	if err := fs.filer.Move(from, to); err != nil || replaced == nil {
+		return err
+	}
+	if fs.isVersioned(to) {
+		fs.saveVersion(to, replaced)
+	} else {
+		fs.deleteFileContent(to, replaced.Fids())
+	}
+	return nil
+}
+
+func (fs *FilerServer) deleteFileContent(fullPath string, fids []string) {
+	if len(fids) == 0 {
+		return
+	}
+	ret, err := operation.DeleteFiles(fs.master, fids)
+	if err != nil {
+		glog.V(0).Infof("deleting content %v of %s: %v", fids, fullPath, err)
+		return
+	}
+	for _, e := range ret.Errors {
+		glog.V(0).Infof("deleting content of %s: %s", fullPath, e)
+	}
+}
@@ -0,0 +1,127 @@
+package weedserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/filer/embedded_filer"
+)
+
+func TestFilerApi(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := embedded_filer.NewFilerEmbedded("127.0.0.1:1", dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: "127.0.0.1:1", filer: f}
+	ts := httptest.NewServer(http.HandlerFunc(fs.apiHandler))
+	defer ts.Close()
+	server := strings.TrimPrefix(ts.URL, "http://")
+
+	if status, err := filer.FilerStatus(server); err != nil || status.Master != "127.0.0.1:1" {
+		t.Fatalf("status: %+v %v", status, err)
+	}
+	if err = filer.CreateDirectory(server, "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"x.txt", "y.txt", "z.txt"} {
+		entry := &filer.FileEntry{Name: name, Attributes: filer.Attributes{Size: 3, Mode: 0600, Uid: 7}}
+		if err = filer.CreateFileEntry(server, "/a", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filer.ListFiles(server, "/a", "y.txt")
+	if err != nil || len(files.Files) != 1 {
+		t.Fatalf("list y.txt: %+v %v", files, err)
+	}
+	if e := files.Files[0]; e.Name != "y.txt" || e.Size != 3 || e.Mode != 0600 || e.Uid != 7 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if _, err = filer.ListFiles(server, "/a", "none.txt"); err == nil {
+		t.Errorf("expected error listing a missing file")
+	}
+	if files, err = filer.ListFilesAfter(server, "/a", "x.txt", 1); err != nil || len(files.Files) != 1 || files.Files[0].Name != "y.txt" {
+		t.Errorf("list after x.txt: %+v %v", files, err)
+	}
+
+	if err = filer.Move(server, "/a/y.txt", "/a/b/w.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if files, err = filer.ListFiles(server, "/a/b", "w.txt"); err != nil || files.Files[0].Uid != 7 {
+		t.Errorf("moved file: %+v %v", files, err)
+	}
+	if err = filer.DeleteDirectory(server, "/a/b"); err == nil {
+		t.Errorf("expected error deleting a non-empty directory")
+	}
+	if err = filer.DeleteFileEntry(server, "/a/b", "w.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err = filer.DeleteDirectory(server, "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := filer.ListDirectories(server, "/a")
+	if err != nil || len(dirs.Directories) != 0 {
+		t.Errorf("list directories: %+v %v", dirs, err)
+	}
+	if files, err = filer.ListFilesAfter(server, "/a", "", 10); err != nil || len(files.Files) != 2 {
+		t.Errorf("list files: %+v %v", files, err)
+	}
+}
+
+func TestFilerApiVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := embedded_filer.NewFilerEmbedded("127.0.0.1:1", dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: "127.0.0.1:1", filer: f, versioning: filer.NewVersioning(f, "/v", 0, 0)}
+	ts := httptest.NewServer(http.HandlerFunc(fs.apiHandler))
+	defer ts.Close()
+	server := strings.TrimPrefix(ts.URL, "http://")
+
+	create := func(name, fid string, mode os.FileMode) {
+		entry := &filer.FileEntry{Name: name, Id: filer.FileId(fid), Attributes: filer.Attributes{Size: 3, Mode: mode}}
+		if err := filer.CreateFileEntry(server, "/v", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versionIds := func() (fids []string) {
+		versions, err := fs.versioning.ListVersions("/v/a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, version := range versions {
+			fids = append(fids, string(version.Id))
+		}
+		return
+	}
+
+	create("a.txt", "3,01637037d6", 0644)
+	// only the attributes change, the content is the same
+	create("a.txt", "3,01637037d6", 0600)
+	if fids := versionIds(); len(fids) != 0 {
+		t.Errorf("unexpected versions %v", fids)
+	}
+	create("a.txt", "3,02637037d6", 0600)
+	create("b.txt", "3,03637037d6", 0600)
+	if err = filer.Move(server, "/v/b.txt", "/v/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if fids := versionIds(); len(fids) != 2 || fids[0] != "3,02637037d6" || fids[1] != "3,01637037d6" {
+		t.Errorf("the replaced entries should be kept as versions, got %v", fids)
+	}
+}
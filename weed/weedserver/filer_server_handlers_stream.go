@@ -0,0 +1,111 @@
+package weedserver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// isStreamed tells whether the upload is too large, or of unknown size, to be buffered
+func (fs *FilerServer) isStreamed(r *http.Request) bool {
+	return fs.maxMB > 0 && (r.ContentLength < 0 || r.ContentLength > int64(fs.maxMB)*1024*1024)
+}
+
+// streamUploadHandler writes the upload to the volume servers as it is received. The content is
+// split into chunks of maxMB, which are referenced by a chunk manifest, so the memory used does
+// not depend on the file size. The streamed uploads are not deduplicated.
+func (fs *FilerServer) streamUploadHandler(w http.ResponseWriter, r *http.Request, replication, collection string) {
+	fileName, header, data, err := storage.ReadUploadPart(r)
+	if err != nil {
+		glog.V(0).Infoln("failing to parse post body", err.Error())
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	path := r.URL.Path
+	if strings.HasSuffix(path, "/") {
+		if fileName == "" {
+			glog.V(0).Infoln("Can not to write to folder", path, "without a file name!")
+			writeJsonError(w, r, http.StatusInternalServerError,
+				errors.New("Can not to write to folder "+path+" without a file name"))
+			return
+		}
+		path += fileName
+	}
+	if fileName == "" {
+		fileName = filepath.Base(path)
+	}
+	mimeType := header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName)))
+	}
+
+	fileId, size, err := fs.uploadChunked(data, fileName, mimeType, replication, collection, r.URL.Query().Get("ttl"))
+	if err != nil {
+		glog.V(0).Infoln("failing to stream", r.RequestURI, err.Error())
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	fs.saveFileEntry(w, r, path, fileId, r.Method == "PUT", collection, replication, mimeType, uint64(size),
+		operation.UploadResult{Name: fileName, Size: uint32(size), Mime: mimeType})
+}
+
+// uploadChunked uploads the content in chunks of maxMB, and returns the fid of their chunk manifest
+func (fs *FilerServer) uploadChunked(data io.Reader, fileName, mimeType, replication, collection, ttl string) (string, int64, error) {
+	chunkSize := int64(fs.maxMB) * 1024 * 1024
+	reader := bufio.NewReader(data)
+	cm := &operation.ChunkManifest{
+		Name: fileName,
+		Mime: mimeType,
+	}
+	var fids []string
+	for i := 1; ; i++ {
+		if _, err := reader.Peek(1); err == io.EOF {
+			break
+		} else if err != nil {
+			fs.deleteFileContent(fileName, fids)
+			return "", 0, err
+		}
+		assignResult, err := operation.Assign(fs.master, 1, replication, collection, ttl)
+		if err != nil {
+			fs.deleteFileContent(fileName, fids)
+			return "", 0, err
+		}
+		fid := assignResult.Fid
+		ret, err := operation.Upload(util.NormalizeUrl(assignResult.Url+"/"+fid),
+			fileName+"-"+strconv.Itoa(i), io.LimitReader(reader, chunkSize), false,
+			"application/octet-stream", fs.jwt(fid))
+		if err != nil {
+			fs.deleteFileContent(fileName, append(fids, fid))
+			return "", 0, err
+		}
+		fids = append(fids, fid)
+		cm.Chunks = append(cm.Chunks, &operation.ChunkInfo{
+			Fid:    fid,
+			Offset: cm.Size,
+			Size:   int64(ret.Size),
+		})
+		cm.Size += int64(ret.Size)
+	}
+	assignResult, err := operation.Assign(fs.master, 1, replication, collection, ttl)
+	if err != nil {
+		fs.deleteFileContent(fileName, fids)
+		return "", 0, err
+	}
+	if err = operation.UploadNestedChunkManifest(util.NormalizeUrl(assignResult.Url+"/"+assignResult.Fid), cm, fs.jwt(assignResult.Fid),
+		fs.master, replication, collection, ttl, fs.secret); err != nil {
+		fs.deleteFileContent(fileName, fids)
+		return "", 0, err
+	}
+	glog.V(4).Infof("streamed %s in %d chunks of %d bytes", fileName, len(cm.Chunks), cm.Size)
+	return assignResult.Fid, cm.Size, nil
+}
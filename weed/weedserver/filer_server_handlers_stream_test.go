@@ -0,0 +1,115 @@
+package weedserver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer/embedded_filer"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func TestFilerStreamUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the server is both the master and the volume server
+	var lock sync.Mutex
+	stored := make(map[string][]byte)
+	manifests := make(map[string]bool)
+	fileKey := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.URL.Path == "/dir/assign":
+			fileKey++
+			fmt.Fprintf(w, `{"fid":"3,%02x637037d6","url":"%s","count":1}`, fileKey, r.Host)
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/3,"):
+			r.ParseForm()
+			fileName, data, _, _, _, _, isChunkedFile, err := storage.ParseUpload(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stored[r.URL.Path[1:]], manifests[r.URL.Path[1:]] = data, isChunkedFile
+			fmt.Fprintf(w, `{"name":"%s","size":%d}`, fileName, len(data))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	master := strings.TrimPrefix(server.URL, "http://")
+	f, err := embedded_filer.NewFilerEmbedded(master, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: master, filer: f, maxMB: 1}
+	ts := httptest.NewServer(http.HandlerFunc(fs.filerHandler))
+	defer ts.Close()
+
+	// the body is piped, so its size is not known in advance
+	content := bytes.Repeat([]byte("0123456789abcdef"), 160*1024)
+	body, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="file"; filename="big.bin"`)
+		h.Set("Content-Type", "application/x-sample")
+		part, _ := mw.CreatePart(h)
+		part.Write(content)
+		mw.Close()
+		pw.Close()
+	}()
+	resp, err := http.Post(ts.URL+"/a/", mw.FormDataContentType(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload status %d", resp.StatusCode)
+	}
+
+	entry, err := f.FindFileEntry("/a/big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if !manifests[string(entry.Id)] || entry.Size != uint64(len(content)) {
+		t.Fatalf("expect a chunk manifest of %d bytes: %+v", len(content), entry)
+	}
+	cm, err := operation.LoadChunkManifest(stored[string(entry.Id)], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Mime != "application/x-sample" {
+		t.Errorf("unexpected mime type %s", entry.Mime)
+	}
+	if len(cm.Chunks) != 3 || cm.Size != int64(len(content)) || cm.Name != "big.bin" || cm.Mime != entry.Mime {
+		t.Fatalf("unexpected manifest %+v", cm)
+	}
+	var joined []byte
+	for _, c := range cm.Chunks {
+		if c.Offset != int64(len(joined)) || c.Size != int64(len(stored[c.Fid])) {
+			t.Errorf("unexpected chunk %+v", c)
+		}
+		joined = append(joined, stored[c.Fid]...)
+	}
+	if !bytes.Equal(joined, content) {
+		t.Errorf("chunks do not add up to the uploaded content")
+	}
+}
@@ -0,0 +1,189 @@
+package weedserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer/embedded_filer"
+)
+
+func TestFilerDedupRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the server is both the master and the volume server, which fails the writes
+	var lock sync.Mutex
+	var acquired, released []string
+	writeError := "write failed"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/dir/assign":
+			fmt.Fprintf(w, `{"fid":"7,%02x637037d6","url":"%s","count":1,"dedup":true}`, len(acquired)+1, r.Host)
+		case r.URL.Path == "/dedup/acquire":
+			acquired = append(acquired, r.FormValue("fid"))
+			w.Write([]byte(`{}`))
+		case r.URL.Path == "/dedup/release":
+			released = append(released, r.Form["fid"]...)
+			w.Write([]byte(`{"delete":["` + strings.Join(r.Form["fid"], `","`) + `"]}`))
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/7,"):
+			if writeError != "" {
+				fmt.Fprintf(w, `{"error":"%s"}`, writeError)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	master := strings.TrimPrefix(server.URL, "http://")
+	f, err := embedded_filer.NewFilerEmbedded(master, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: master, filer: f}
+	ts := httptest.NewServer(http.HandlerFunc(fs.filerHandler))
+	defer ts.Close()
+
+	upload := func() {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("file", "b.txt")
+		part.Write([]byte("some content"))
+		mw.Close()
+		resp, err := http.Post(ts.URL+"/a/b.txt", mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("the failed upload got %s", resp.Status)
+		}
+	}
+	// the volume server replies with an error, then with an unexpected response
+	upload()
+	writeError = ""
+	upload()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(acquired) != 2 || !reflect.DeepEqual(released, acquired) {
+		t.Errorf("the registered fids %v should be released, released %v", acquired, released)
+	}
+	if _, err := f.FindFile("/a/b.txt"); err == nil {
+		t.Errorf("the failed upload should not be saved")
+	}
+}
+
+func TestFilerDedupSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the spooled bodies go into the temp dir of the test
+	tmpDir := filepath.Join(dir, "tmp")
+	os.Mkdir(tmpDir, 0755)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmpDir)
+
+	var lock sync.Mutex
+	var hashes []string
+	stored := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.URL.Path == "/dir/assign":
+			fmt.Fprintf(w, `{"fid":"7,%02x637037d6","url":"%s","count":1,"dedup":true}`, len(stored)+1, r.Host)
+		case r.URL.Path == "/dedup/acquire":
+			r.ParseForm()
+			for _, hash := range hashes {
+				if hash == r.FormValue("hash") {
+					w.Write([]byte(`{"fid":"7,01637037d6","found":true}`))
+					return
+				}
+			}
+			hashes = append(hashes, r.FormValue("hash"))
+			w.Write([]byte(`{}`))
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/7,"):
+			_, part, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f, _ := part.Open()
+			content, _ := ioutil.ReadAll(f)
+			stored[r.URL.Path[1:]] = content
+			fmt.Fprintf(w, `{"name":"%s","size":%d}`, part.Filename, len(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	master := strings.TrimPrefix(server.URL, "http://")
+	f, err := embedded_filer.NewFilerEmbedded(master, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: master, filer: f}
+	ts := httptest.NewServer(http.HandlerFunc(fs.filerHandler))
+	defer ts.Close()
+
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	upload := func(path, mimeType string) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="file"; filename="`+filepath.Base(path)+`"`)
+		h.Set("Content-Type", mimeType)
+		part, _ := mw.CreatePart(h)
+		part.Write(content)
+		mw.Close()
+		resp, err := http.Post(ts.URL+path, mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("upload of %s got %s", path, resp.Status)
+		}
+	}
+	upload("/a/b.bin", "application/octet-stream")
+	// the same content is not written again, and keeps the mime type of its upload
+	upload("/a/c.dat", "application/x-sample")
+	if entry, err := f.FindFileEntry("/a/c.dat"); err != nil || entry.Id != "7,01637037d6" || entry.Mime != "application/x-sample" {
+		t.Errorf("unexpected deduplicated entry %+v %v", entry, err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if sum := sha256.Sum256(content); len(hashes) != 1 || hashes[0] != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected hashes %v", hashes)
+	}
+	if len(stored) != 1 || !bytes.Equal(stored["7,01637037d6"], content) {
+		t.Errorf("the volume server got %d bytes instead of %d", len(stored["7,01637037d6"]), len(content))
+	}
+	if files, _ := ioutil.ReadDir(tmpDir); len(files) != 0 {
+		t.Errorf("the spooled body %s is not removed", files[0].Name())
+	}
+}
@@ -0,0 +1,278 @@
+package weedserver
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// isUploadRequest tells whether the request is a step of a resumable upload:
+//
+//	POST   /path/to/file?uploads                      starts the upload, and returns its uploadId
+//	PUT    /path/to/file?uploadId=xxx&partNumber=N    writes the request body as part N
+//	GET    /path/to/file?uploadId=xxx                 lists the uploaded parts
+//	POST   /path/to/file?uploadId=xxx                 joins the parts into the file, or only the parts
+//	                                                  listed in the form value "parts", e.g. parts=1,2,5
+//	DELETE /path/to/file?uploadId=xxx                 aborts the upload
+func isUploadRequest(r *http.Request) bool {
+	query := r.URL.Query()
+	_, initiate := query["uploads"]
+	return initiate || query.Get("uploadId") != ""
+}
+
+func (fs *FilerServer) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	if fs.uploads == nil {
+		writeJsonError(w, r, http.StatusNotImplemented, errors.New("The resumable uploads need the directories of the embedded filer"))
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/") {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("The uploaded file needs a file name"))
+		return
+	}
+	uploadId := r.URL.Query().Get("uploadId")
+	switch {
+	case r.Method == "POST" && uploadId == "":
+		fs.initiateUploadHandler(w, r)
+	case r.Method == "PUT":
+		fs.uploadPartHandler(w, r, uploadId)
+	case r.Method == "GET":
+		fs.listPartsHandler(w, r, uploadId)
+	case r.Method == "POST":
+		fs.completeUploadHandler(w, r, uploadId)
+	case r.Method == "DELETE":
+		fs.abortUploadHandler(w, r, uploadId)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (fs *FilerServer) initiateUploadHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	replication := query.Get("replication")
+	if replication == "" {
+		replication = fs.defaultReplication
+	}
+	collection := query.Get("collection")
+	if collection == "" {
+		collection = fs.collection
+	}
+	uploadId, err := fs.uploads.Create(collection, replication, query.Get("ttl"), time.Now())
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	glog.V(2).Infof("upload %s of %s is started", uploadId, r.URL.Path)
+	writeJsonQuiet(w, r, http.StatusOK, map[string]string{"uploadId": uploadId})
+}
+
+// uploadPartHandler streams the request body into a new file of the volume servers.
+// The parts can be uploaded in any order and in parallel, and a part uploaded again replaces
+// the previous one.
+func (fs *FilerServer) uploadPartHandler(w http.ResponseWriter, r *http.Request, uploadId string) {
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > filer.MaxPartNumber {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("The part number should be between 1 and %d", filer.MaxPartNumber))
+		return
+	}
+	session, err := fs.uploads.Session(uploadId)
+	if err != nil {
+		writeJsonError(w, r, http.StatusNotFound, err)
+		return
+	}
+	assignResult, err := operation.Assign(fs.master, 1, session.Replication, session.Collection, session.Ttl)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	fid := assignResult.Fid
+	ret, err := operation.Upload(util.NormalizeUrl(assignResult.Url+"/"+fid),
+		filepath.Base(r.URL.Path)+"-"+strconv.Itoa(partNumber), r.Body, false,
+		"application/octet-stream", fs.jwt(fid))
+	if err != nil {
+		fs.deleteFileContent(r.URL.Path, []string{fid})
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// the part uploaded twice at the same time is replaced once
+	unlock := fs.lockPaths(filer.UploadsDir + "/" + uploadId + "/" + strconv.Itoa(partNumber))
+	replaced, err := fs.uploads.PutPart(uploadId, partNumber, fid, uint64(ret.Size), time.Now())
+	unlock()
+	if err != nil {
+		fs.deleteFileContent(r.URL.Path, []string{fid})
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if replaced != "" {
+		fs.deleteFileContent(r.URL.Path, []string{replaced})
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"partNumber": partNumber,
+		"size":       ret.Size,
+		"fid":        fid,
+	})
+}
+
+func (fs *FilerServer) listPartsHandler(w http.ResponseWriter, r *http.Request, uploadId string) {
+	session, err := fs.uploads.Session(uploadId)
+	if err != nil {
+		writeJsonError(w, r, http.StatusNotFound, err)
+		return
+	}
+	parts, err := fs.uploads.Parts(uploadId)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"uploadId":   uploadId,
+		"collection": session.Collection,
+		"parts":      parts,
+	})
+}
+
+// completeUploadHandler writes the chunk manifest of the parts, in the order of their part numbers,
+// and saves it as the file. The parts not listed in "parts", if given, are deleted.
+func (fs *FilerServer) completeUploadHandler(w http.ResponseWriter, r *http.Request, uploadId string) {
+	session, err := fs.uploads.Session(uploadId)
+	if err != nil {
+		writeJsonError(w, r, http.StatusNotFound, err)
+		return
+	}
+	parts, err := fs.uploads.Parts(uploadId)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	var unused []string
+	if list := r.FormValue("parts"); list != "" {
+		if parts, unused, err = selectParts(parts, list); err != nil {
+			writeJsonError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if len(parts) == 0 {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("upload %s has no part", uploadId))
+		return
+	}
+	path := r.URL.Path
+	fileName := filepath.Base(path)
+	cm := &operation.ChunkManifest{
+		Name: fileName,
+		Mime: mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))),
+	}
+	for _, part := range parts {
+		cm.Chunks = append(cm.Chunks, &operation.ChunkInfo{
+			Fid:    string(part.Id),
+			Offset: cm.Size,
+			Size:   int64(part.Size),
+		})
+		cm.Size += int64(part.Size)
+	}
+	assignResult, err := operation.Assign(fs.master, 1, session.Replication, session.Collection, session.Ttl)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	fileId := assignResult.Fid
+	if err = operation.UploadNestedChunkManifest(util.NormalizeUrl(assignResult.Url+"/"+fileId), cm, fs.jwt(fileId),
+		fs.master, session.Replication, session.Collection, session.Ttl, fs.secret); err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	// the parts are the chunks of the file now, only the session and the unused parts are removed
+	if err = fs.uploads.Remove(uploadId); err != nil {
+		glog.V(0).Infof("removing upload %s: %v", uploadId, err)
+	}
+	fs.deleteFileContent(path, unused)
+	glog.V(2).Infof("upload %s of %s is completed with %d parts of %d bytes", uploadId, path, len(parts), cm.Size)
+
+	// the file expires with its parts
+	query := r.URL.Query()
+	query.Set("ttl", session.Ttl)
+	r.URL.RawQuery = query.Encode()
+	fs.saveFileEntry(w, r, path, fileId, true, session.Collection, session.Replication, cm.Mime, uint64(cm.Size),
+		operation.UploadResult{Name: fileName, Size: uint32(cm.Size), Mime: cm.Mime})
+}
+
+// selectParts picks the parts listed by their numbers in ascending order, e.g. "1,2,5",
+// and returns the file ids of the other parts
+func selectParts(parts []filer.FileEntry, list string) (selected []filer.FileEntry, unused []string, err error) {
+	byNumber := make(map[string]filer.FileEntry, len(parts))
+	for _, part := range parts {
+		byNumber[part.Name] = part
+	}
+	last := 0
+	for _, s := range strings.Split(list, ",") {
+		partNumber, e := strconv.Atoi(strings.TrimSpace(s))
+		if e != nil || partNumber <= last {
+			return nil, nil, fmt.Errorf("The parts %q should be part numbers in ascending order", list)
+		}
+		last = partNumber
+		part, found := byNumber[strconv.Itoa(partNumber)]
+		if !found {
+			return nil, nil, fmt.Errorf("part %d is not uploaded", partNumber)
+		}
+		selected = append(selected, part)
+		delete(byNumber, part.Name)
+	}
+	for _, part := range parts {
+		if _, found := byNumber[part.Name]; found {
+			unused = append(unused, string(part.Id))
+		}
+	}
+	return selected, unused, nil
+}
+
+func (fs *FilerServer) abortUploadHandler(w http.ResponseWriter, r *http.Request, uploadId string) {
+	if _, err := fs.uploads.Session(uploadId); err != nil {
+		writeJsonError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err := fs.abortUpload(uploadId); err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	glog.V(2).Infof("upload %s of %s is aborted", uploadId, r.URL.Path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// abortUpload deletes the uploaded parts and the session
+func (fs *FilerServer) abortUpload(uploadId string) error {
+	parts, err := fs.uploads.Parts(uploadId)
+	if err != nil {
+		return err
+	}
+	var fids []string
+	for _, part := range parts {
+		fids = append(fids, string(part.Id))
+	}
+	fs.deleteFileContent(filer.UploadsDir+"/"+uploadId, fids)
+	return fs.uploads.Remove(uploadId)
+}
+
+// expireUploads aborts the uploads abandoned for longer than the timeout
+func (fs *FilerServer) expireUploads(timeout time.Duration) {
+	for range time.Tick(time.Minute) {
+		expired, err := fs.uploads.Expired(time.Now().Add(-timeout))
+		if err != nil {
+			glog.V(0).Infof("listing the uploads: %v", err)
+			continue
+		}
+		for _, uploadId := range expired {
+			glog.V(1).Infof("upload %s is abandoned", uploadId)
+			if err = fs.abortUpload(uploadId); err != nil {
+				glog.V(0).Infof("aborting upload %s: %v", uploadId, err)
+			}
+		}
+	}
+}
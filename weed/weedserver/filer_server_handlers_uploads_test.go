@@ -0,0 +1,195 @@
+package weedserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/filer/embedded_filer"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func TestFilerResumableUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the server is both the master and the volume server
+	var lock sync.Mutex
+	stored := make(map[string][]byte)
+	fileKey := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/dir/assign":
+			fileKey++
+			fmt.Fprintf(w, `{"fid":"6,%02x637037d6","url":"%s","count":1}`, fileKey, r.Host)
+		case r.URL.Path == "/dedup/release":
+			w.Write([]byte(`{"delete":["` + strings.Join(r.Form["fid"], `","`) + `"]}`))
+		case r.URL.Path == "/vol/lookup":
+			w.Write([]byte(`{"6":{"volumeId":"6","locations":[{"url":"` + r.Host + `"}]}}`))
+		case r.URL.Path == "/delete":
+			for _, fid := range r.Form["fid"] {
+				delete(stored, fid)
+			}
+			w.Write([]byte(`[]`))
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/6,"):
+			fileName, data, _, _, _, _, _, err := storage.ParseUpload(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stored[r.URL.Path[1:]] = data
+			fmt.Fprintf(w, `{"name":"%s","size":%d}`, fileName, len(data))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	master := strings.TrimPrefix(server.URL, "http://")
+	f, err := embedded_filer.NewFilerEmbedded(master, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: master, filer: f, uploads: filer.NewUploadSessions(f)}
+	ts := httptest.NewServer(http.HandlerFunc(fs.filerHandler))
+	defer ts.Close()
+
+	do := func(method, url string, body []byte, expected int, ret interface{}) {
+		req, _ := http.NewRequest(method, ts.URL+url, bytes.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != expected {
+			b, _ := ioutil.ReadAll(resp.Body)
+			t.Fatalf("%s %s: status %d instead of %d: %s", method, url, resp.StatusCode, expected, b)
+		}
+		if ret != nil {
+			if err = json.NewDecoder(resp.Body).Decode(ret); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	initiate := func() string {
+		var ret struct{ UploadId string }
+		do("POST", "/a/big.bin?uploads", nil, http.StatusOK, &ret)
+		return ret.UploadId
+	}
+
+	// the parts are uploaded in parallel and out of order, and the first part twice
+	parts := [][]byte{
+		bytes.Repeat([]byte("first "), 1000),
+		bytes.Repeat([]byte("second "), 1000),
+		[]byte("third"),
+	}
+	uploadId := initiate()
+	var wg sync.WaitGroup
+	for i := len(parts); i > 0; i-- {
+		wg.Add(1)
+		go func(partNumber int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/a/big.bin?uploadId=%s&partNumber=%d", ts.URL, uploadId, partNumber),
+				strings.NewReader("lost"))
+			if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+				t.Errorf("uploading part %d: %v %v", partNumber, resp, err)
+			} else {
+				resp.Body.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, part := range parts {
+		do("PUT", fmt.Sprintf("/a/big.bin?uploadId=%s&partNumber=%d", uploadId, i+1), part, http.StatusOK, nil)
+	}
+	var listed struct {
+		Parts []filer.FileEntry
+	}
+	do("GET", "/a/big.bin?uploadId="+uploadId, nil, http.StatusOK, &listed)
+	if len(listed.Parts) != len(parts) {
+		t.Fatalf("unexpected parts %+v", listed.Parts)
+	}
+	for i, part := range listed.Parts {
+		if part.Name != fmt.Sprint(i+1) || part.Size != uint64(len(parts[i])) {
+			t.Errorf("unexpected part %+v", part)
+		}
+	}
+	lock.Lock()
+	if len(stored) != len(parts) {
+		t.Errorf("the replaced parts are not deleted: %d files are stored", len(stored))
+	}
+	lock.Unlock()
+
+	do("POST", "/a/big.bin?uploadId="+uploadId, nil, http.StatusCreated, nil)
+	entry, err := f.FindFileEntry("/a/big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	cm, err := operation.LoadChunkManifest(stored[string(entry.Id)], false)
+	lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Join(parts, nil)
+	if len(cm.Chunks) != len(parts) || cm.Size != int64(len(content)) || entry.Size != uint64(len(content)) {
+		t.Fatalf("unexpected manifest %+v of %+v", cm, entry)
+	}
+	// the mime type is guessed from the name of the file
+	if expected := mime.TypeByExtension(".bin"); entry.Mime != expected || cm.Mime != expected {
+		t.Errorf("the mime type of %+v and of its manifest is not %s", entry, expected)
+	}
+	var joined []byte
+	for _, c := range cm.Chunks {
+		if c.Offset != int64(len(joined)) {
+			t.Errorf("unexpected chunk %+v", c)
+		}
+		joined = append(joined, stored[c.Fid]...)
+	}
+	if !bytes.Equal(joined, content) {
+		t.Errorf("parts do not add up to the uploaded content")
+	}
+	do("GET", "/a/big.bin?uploadId="+uploadId, nil, http.StatusNotFound, nil)
+
+	// the parts of the aborted and of the abandoned uploads are deleted
+	lock.Lock()
+	count := len(stored)
+	lock.Unlock()
+	uploadId = initiate()
+	do("PUT", "/a/big.bin?uploadId="+uploadId+"&partNumber=1", parts[0], http.StatusOK, nil)
+	do("DELETE", "/a/big.bin?uploadId="+uploadId, nil, http.StatusNoContent, nil)
+	do("PUT", "/a/big.bin?uploadId="+uploadId+"&partNumber=2", parts[1], http.StatusNotFound, nil)
+	uploadId = initiate()
+	do("PUT", "/a/big.bin?uploadId="+uploadId+"&partNumber=1", parts[0], http.StatusOK, nil)
+	if expired, _ := fs.uploads.Expired(time.Now().Add(-time.Hour)); len(expired) != 0 {
+		t.Errorf("active uploads %v are expired", expired)
+	}
+	expired, err := fs.uploads.Expired(time.Now().Add(time.Hour))
+	if err != nil || len(expired) != 1 || expired[0] != uploadId {
+		t.Fatalf("unexpected expired uploads %v: %v", expired, err)
+	}
+	if err = fs.abortUpload(uploadId); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(stored) != count {
+		t.Errorf("%d parts are left", len(stored)-count)
+	}
+}
@@ -0,0 +1,103 @@
+package weedserver
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+)
+
+// isVersioned tells the replaced entries of the file are kept as versions
+func (fs *FilerServer) isVersioned(fullPath string) bool {
+	return fs.versioning != nil && fs.versioning.IsVersioned(fullPath)
+}
+
+// findVersion finds the file id of a previous version of the file
+func (fs *FilerServer) findVersion(fullPath string, version string) (string, error) {
+	if fs.versioning == nil {
+		return "", errors.New("versioning is not enabled")
+	}
+	entry, err := fs.versioning.FindVersion(fullPath, version)
+	if err != nil {
+		return "", err
+	}
+	return string(entry.Id), nil
+}
+
+// curl http://localhost:8888/path/to/file?versions=true
+func (fs *FilerServer) listVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if !fs.isVersioned(r.URL.Path) {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("versioning is not enabled for "+r.URL.Path))
+		return
+	}
+	versions, err := fs.versioning.ListVersions(r.URL.Path)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"path":     r.URL.Path,
+		"versions": versions,
+	})
+}
+
+// curl -X POST http://localhost:8888/path/to/file?restore=20171017-203450.123456789
+func (fs *FilerServer) restoreVersionHandler(w http.ResponseWriter, r *http.Request, version string) {
+	if !fs.isVersioned(r.URL.Path) {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("versioning is not enabled for "+r.URL.Path))
+		return
+	}
+	expired, err := fs.versioning.Restore(r.URL.Path, version, time.Now())
+	if err != nil {
+		glog.V(0).Infof("restoring version %s of %s: %v", version, r.URL.Path, err)
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	fs.deleteVersions(r.URL.Path, expired)
+	glog.V(1).Infof("restored version %s of %s", version, r.URL.Path)
+	writeJsonQuiet(w, r, http.StatusOK, map[string]string{"error": ""})
+}
+
+// saveVersion keeps the replaced entry of the file, and deletes the expired versions
+func (fs *FilerServer) saveVersion(fullPath string, entry *filer.FileEntry) {
+	expired, err := fs.versioning.SaveVersion(fullPath, entry, time.Now())
+	if err != nil {
+		glog.V(0).Infof("saving the previous version of %s: %v", fullPath, err)
+	}
+	fs.deleteVersions(fullPath, expired)
+}
+
+// deleteVersions deletes the content of the expired versions, except the chunks still referenced
+// by the file or its other versions, since the entries written by the mount share their chunks
+func (fs *FilerServer) deleteVersions(fullPath string, expired []filer.FileEntry) {
+	if len(expired) == 0 {
+		return
+	}
+	versions, err := fs.versioning.ListVersions(fullPath)
+	if err != nil {
+		glog.V(0).Infof("listing the versions of %s to delete the expired ones: %v", fullPath, err)
+		return
+	}
+	if entry, err := fs.filer.FindFileEntry(fullPath); err == nil {
+		versions = append(versions, *entry)
+	}
+	kept := make(map[string]bool)
+	for _, version := range versions {
+		for _, fid := range version.Fids() {
+			kept[fid] = true
+		}
+	}
+	for _, version := range expired {
+		for _, fid := range version.Fids() {
+			if kept[fid] {
+				continue
+			}
+			if err := operation.DeleteFile(fs.master, fid, version.Collection, fs.jwt(fid)); err != nil {
+				glog.V(0).Infof("deleting %s of version %s of %s: %v", fid, version.Name, fullPath, err)
+			}
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"path/filepath"
 	"sync"
 
 	"net/http/pprof"
@@ -43,42 +44,90 @@ func NewMasterServer(r *mux.Router, port int, metaFolder string,
 	garbageThreshold string,
 	whiteList []string,
 	secureKey string,
+	seq sequence.Sequencer,
 ) *MasterServer {
 	ms := &MasterServer{
 		port:                    port,
+		metaFolder:              metaFolder,
 		volumeSizeLimitMB:       volumeSizeLimitMB,
 		pulseSeconds:            pulseSeconds,
 		defaultReplicaPlacement: defaultReplicaPlacement,
 		garbageThreshold:        garbageThreshold,
 	}
 	ms.bounedLeaderChan = make(chan int, 16)
-	seq := sequence.NewMemorySequencer()
 	cs := storage.NewCollectionSettings(defaultReplicaPlacement, garbageThreshold)
 	var e error
 	if ms.Topo, e = topology.NewTopology("topo", confFile, cs,
 		seq, uint64(volumeSizeLimitMB)*1024*1024, pulseSeconds); e != nil {
 		glog.Fatalf("cannot create topology:%s", e)
 	}
+	if metaFolder != "" {
+		if e = ms.Topo.LoadCollectionSettings(filepath.Join(metaFolder, "collection_settings")); e != nil {
+			glog.Fatalf("cannot load collection settings:%s", e)
+		}
+		if e = ms.Topo.AccessKeys.Load(filepath.Join(metaFolder, "access_keys")); e != nil {
+			glog.Fatalf("cannot load access keys:%s", e)
+		}
+		if e = ms.Topo.LoadDedupIndex(filepath.Join(metaFolder, "dedup")); e != nil {
+			glog.Fatalf("cannot load dedup index:%s", e)
+		}
+	}
+	if bs, ok := seq.(*sequence.BatchSequencer); ok {
+		bs.SetReserver(ms.Topo.ReserveFileIds)
+	}
 	ms.vg = topology.NewDefaultVolumeGrowth()
 	glog.V(0).Infoln("Volume Size Limit is", volumeSizeLimitMB, "MB")
 
 	ms.guard = security.NewGuard(whiteList, secureKey)
+	ms.guard.SetKeyChecker(func(id string) bool {
+		_, ok := ms.Topo.AccessKeys.Get(id)
+		return ok
+	})
+	if secureKey != "" {
+		util.SetInternalJwt(ms.guard.InternalJwt)
+	}
 
 	r.HandleFunc("/", ms.uiStatusHandler)
 	r.HandleFunc("/ui/index.html", ms.uiStatusHandler)
-	r.HandleFunc("/dir/assign", ms.proxyToLeader(ms.guard.WhiteList(ms.dirAssignHandler)))
+	r.HandleFunc("/dir/assign", ms.proxyToLeader(ms.guard.Secure(security.ActionWrite, ms.dirAssignHandler)))
 	r.HandleFunc("/dir/lookup", ms.proxyToLeader(ms.guard.WhiteList(ms.dirLookupHandler)))
+	r.HandleFunc("/dir/lookup_ec", ms.proxyToLeader(ms.guard.WhiteList(ms.dirLookupEcHandler)))
 	r.HandleFunc("/dir/join", ms.proxyToLeader(ms.guard.WhiteList(ms.dirJoinHandler)))
 	r.HandleFunc("/dir/join2", ms.proxyToLeader(ms.guard.WhiteList(ms.dirJoin2Handler)))
 	r.HandleFunc("/dir/status", ms.proxyToLeader(ms.guard.WhiteList(ms.dirStatusHandler)))
-	r.HandleFunc("/col/delete", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionDeleteHandler)))
+	r.HandleFunc("/col/delete", ms.proxyToLeader(ms.guard.Admin(ms.collectionDeleteHandler)))
+	r.HandleFunc("/col/settings", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionSettingsHandler)))
+	r.HandleFunc("/col/settings/set", ms.proxyToLeader(ms.guard.Admin(ms.collectionSettingsSetHandler)))
+	r.HandleFunc("/col/settings/delete", ms.proxyToLeader(ms.guard.Admin(ms.collectionSettingsDeleteHandler)))
+	r.HandleFunc("/col/usage", ms.proxyToLeader(ms.guard.WhiteList(ms.collectionUsageHandler)))
 	r.HandleFunc("/vol/lookup", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeLookupHandler)))
-	r.HandleFunc("/vol/grow", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeGrowHandler)))
+	r.HandleFunc("/vol/grow", ms.proxyToLeader(ms.guard.Admin(ms.volumeGrowHandler)))
 	r.HandleFunc("/vol/status", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeStatusHandler)))
-	r.HandleFunc("/vol/vacuum", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeVacuumHandler)))
-	r.HandleFunc("/vol/check_replicate", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeCheckReplicateHandler)))
-	r.HandleFunc("/submit", ms.guard.WhiteList(ms.submitFromMasterServerHandler))
-	r.HandleFunc("/delete", ms.guard.WhiteList(ms.deleteFromMasterServerHandler))
+	r.HandleFunc("/vol/vacuum", ms.proxyToLeader(ms.guard.AdminAll(ms.volumeVacuumHandler)))
+	r.HandleFunc("/vol/check_replicate", ms.proxyToLeader(ms.guard.AdminAll(ms.volumeCheckReplicateHandler)))
+	r.HandleFunc("/vol/balance", ms.proxyToLeader(ms.guard.AdminAll(ms.volumeBalanceHandler)))
+	r.HandleFunc("/vol/tier", ms.proxyToLeader(ms.guard.AdminAll(ms.volumeTierHandler)))
+	r.HandleFunc("/vol/scrub", ms.proxyToLeader(ms.guard.Admin(ms.volumeScrubHandler)))
+	r.HandleFunc("/vol/scrub/report", ms.proxyToLeader(ms.guard.AdminAll(ms.volumeScrubReportHandler)))
+	r.HandleFunc("/vol/scrub/status", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeScrubStatusHandler)))
+	r.HandleFunc("/vol/trash", ms.proxyToLeader(ms.guard.Admin(ms.volumeTrashHandler)))
+	r.HandleFunc("/vol/undelete", ms.proxyToLeader(ms.guard.Admin(ms.volumeUndeleteHandler)))
+	r.HandleFunc("/vol/snapshot/create", ms.proxyToLeader(ms.guard.Admin(ms.volumeSnapshotCreateHandler)))
+	r.HandleFunc("/vol/snapshot/list", ms.proxyToLeader(ms.guard.WhiteList(ms.volumeSnapshotListHandler)))
+	r.HandleFunc("/vol/snapshot/release", ms.proxyToLeader(ms.guard.Admin(ms.volumeSnapshotReleaseHandler)))
+	r.HandleFunc("/vol/snapshot/restore", ms.proxyToLeader(ms.guard.Admin(ms.volumeSnapshotRestoreHandler)))
+	r.HandleFunc("/vol/ec/encode", ms.proxyToLeader(ms.guard.AdminAll(ms.volumeEcEncodeHandler)))
+	r.HandleFunc("/key/list", ms.proxyToLeader(ms.guard.AdminAll(ms.keyListHandler)))
+	r.HandleFunc("/key/set", ms.proxyToLeader(ms.guard.AdminAll(ms.keySetHandler)))
+	r.HandleFunc("/key/delete", ms.proxyToLeader(ms.guard.AdminAll(ms.keyDeleteHandler)))
+	r.HandleFunc("/key/token", ms.proxyToLeader(ms.keyTokenHandler))
+	r.HandleFunc("/key/signingKey", ms.proxyToLeader(ms.guard.AdminAll(ms.keySigningKeyHandler)))
+	r.HandleFunc("/dedup/acquire", ms.proxyToLeader(ms.dedupAcquireHandler))
+	r.HandleFunc("/dedup/release", ms.proxyToLeader(ms.dedupReleaseHandler))
+	r.HandleFunc("/dedup/status", ms.proxyToLeader(ms.guard.WhiteList(ms.dedupStatusHandler)))
+	r.HandleFunc("/submit", ms.guard.Secure(security.ActionWrite, ms.submitFromMasterServerHandler))
+	r.HandleFunc("/delete", ms.guard.AdminAll(ms.deleteFromMasterServerHandler))
+	r.HandleFunc("/metrics", ms.guard.WhiteList(metricsHandler(ms.metricsCollectors()...)))
 	r.HandleFunc("/{fileId}", ms.proxyToLeader(ms.redirectHandler))
 	r.HandleFunc("/stats/counter", ms.guard.WhiteList(statsCounterHandler))
 	r.HandleFunc("/stats/memory", ms.guard.WhiteList(statsMemoryHandler))
@@ -93,6 +142,18 @@ func NewMasterServer(r *mux.Router, port int, metaFolder string,
 	return ms
 }
 
+// SetReadSecured makes the volume servers require a JWT for reads if the secret key is set
+func (ms *MasterServer) SetReadSecured(b bool) {
+	ms.guard.SetReadSecured(b)
+	ms.Topo.ReGenJoinKey()
+}
+
+// SetClientCertRequired makes the admin calls and writes pass with a verified client certificate,
+// and fail without one unless in the white list or with a JWT.
+func (ms *MasterServer) SetClientCertRequired(b bool) {
+	ms.guard.SetClientCertRequired(b)
+}
+
 func (ms *MasterServer) SetRaftServer(raftServer *RaftServer) {
 	ms.Topo.SetRaftServer(raftServer.raftServer)
 	ms.Topo.GetRaftServer().AddEventListener(raft.LeaderChangeEventType, func(e raft.Event) {
@@ -116,10 +177,10 @@ func (ms *MasterServer) proxyToLeader(f func(w http.ResponseWriter, r *http.Requ
 		} else if ms.Topo.GetRaftServer() != nil && ms.Topo.GetRaftServer().Leader() != "" {
 			ms.bounedLeaderChan <- 1
 			defer func() { <-ms.bounedLeaderChan }()
-			targetUrl, err := url.Parse("http://" + ms.Topo.GetRaftServer().Leader())
+			targetUrl, err := url.Parse(util.NormalizeUrl(ms.Topo.GetRaftServer().Leader()))
 			if err != nil {
 				writeJsonError(w, r, http.StatusInternalServerError,
-					fmt.Errorf("Leader URL http://%s Parse Error: %v", ms.Topo.GetRaftServer().Leader(), err))
+					fmt.Errorf("Leader URL %s Parse Error: %v", util.NormalizeUrl(ms.Topo.GetRaftServer().Leader()), err))
 				return
 			}
 			glog.V(4).Infoln("proxying to leader", ms.Topo.GetRaftServer().Leader())
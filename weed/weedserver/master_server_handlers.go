@@ -30,6 +30,13 @@ func (ms *MasterServer) lookupVolumeId(vids []string, collection string) (volume
 					ret = append(ret, operation.Location{Url: dn.Url(), PublicUrl: dn.PublicUrl})
 				}
 				volumeLocations[vid] = operation.LookupResult{VolumeId: vid, Locations: ret}
+			} else if ecLocations, ok := ms.Topo.LookupEcShards(volumeId); ok {
+				// any data node holding a shard can serve the reads of an ec volume
+				var ret operation.Locations
+				for _, dn := range ecLocations.DataNodes() {
+					ret = append(ret, operation.Location{Url: dn.Url(), PublicUrl: dn.PublicUrl})
+				}
+				volumeLocations[vid] = operation.LookupResult{VolumeId: vid, Locations: ret}
 			} else {
 				volumeLocations[vid] = operation.LookupResult{VolumeId: vid, Error: "volumeId not found."}
 			}
@@ -79,6 +86,10 @@ func (ms *MasterServer) dirAssignHandler(w http.ResponseWriter, r *http.Request)
 		writeJsonQuiet(w, r, http.StatusNotAcceptable, operation.AssignResult{Error: err.Error()})
 		return
 	}
+	if err = ms.Topo.CheckCollectionQuota(option.Collection); err != nil {
+		writeJsonQuiet(w, r, http.StatusForbidden, operation.AssignResult{Error: err.Error()})
+		return
+	}
 
 	if !ms.Topo.HasWritableVolume(option) {
 		if ms.Topo.FreeSpace() <= 0 {
@@ -97,7 +108,8 @@ func (ms *MasterServer) dirAssignHandler(w http.ResponseWriter, r *http.Request)
 	}
 	fid, count, dn, err := ms.Topo.PickForWrite(requestedCount, option)
 	if err == nil {
-		writeJsonQuiet(w, r, http.StatusOK, operation.AssignResult{Fid: fid, Url: dn.Url(), PublicUrl: dn.PublicUrl, Count: count})
+		writeJsonQuiet(w, r, http.StatusOK, operation.AssignResult{Fid: fid, Url: dn.Url(), PublicUrl: dn.PublicUrl, Count: count,
+			Dedup: ms.Topo.CollectionSettings.GetDedup(option.Collection)})
 	} else {
 		writeJsonQuiet(w, r, http.StatusNotAcceptable, operation.AssignResult{Error: err.Error()})
 	}
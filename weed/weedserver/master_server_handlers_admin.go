@@ -10,6 +10,7 @@ import (
 
 	"net/url"
 	"sync"
+	"time"
 
 	"net"
 
@@ -35,6 +36,177 @@ func (ms *MasterServer) collectionDeleteHandler(w http.ResponseWriter, r *http.R
 		}
 	}
 	ms.Topo.DeleteCollection(r.FormValue("collection"))
+	if err := ms.Topo.DropDedupCollection(r.FormValue("collection")); err != nil {
+		glog.V(0).Infof("drop dedup index of collection %s: %v", r.FormValue("collection"), err)
+	}
+}
+
+func (ms *MasterServer) collectionSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	cs := ms.Topo.CollectionSettings
+	if collection := r.FormValue("collection"); collection != "" {
+		setting, ok := cs.PbMessage(collection)
+		if !ok {
+			setting = &weedpb.CollectionSetting{Collection: collection}
+		}
+		writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+			"Setting":     setting,
+			"Effective":   effectiveCollectionSetting(cs, collection),
+			"VolumeCount": ms.Topo.CollectionVolumeCount(collection),
+		})
+		return
+	}
+	defaults, _ := cs.PbMessage("")
+	settings := []*weedpb.CollectionSetting{}
+	for _, collection := range cs.Collections() {
+		if setting, ok := cs.PbMessage(collection); ok {
+			settings = append(settings, setting)
+		}
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"Defaults":    defaults,
+		"Collections": settings,
+	})
+}
+
+// collectionSettingsSetHandler changes the settings given in the form,
+// an empty value makes the setting fall back to the default.
+func (ms *MasterServer) collectionSettingsSetHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	collection := r.FormValue("collection")
+	setting, ok := ms.Topo.CollectionSettings.PbMessage(collection)
+	if !ok {
+		setting = &weedpb.CollectionSetting{Collection: collection}
+	}
+	if _, ok = r.Form["replication"]; ok {
+		setting.ReplicaPlacement = r.FormValue("replication")
+	}
+	if _, ok = r.Form["garbageThreshold"]; ok {
+		setting.VacuumGarbageThreshold = r.FormValue("garbageThreshold")
+		if setting.VacuumGarbageThreshold != "" {
+			if _, err := strconv.ParseFloat(setting.VacuumGarbageThreshold, 32); err != nil {
+				writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid garbageThreshold %s", setting.VacuumGarbageThreshold))
+				return
+			}
+		}
+	}
+	if _, ok = r.Form["ttl"]; ok {
+		setting.Ttl = r.FormValue("ttl")
+	}
+	if _, ok = r.Form["maxVolumeCount"]; ok {
+		setting.MaxVolumeCount = 0
+		if s := r.FormValue("maxVolumeCount"); s != "" {
+			count, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid maxVolumeCount %s", s))
+				return
+			}
+			setting.MaxVolumeCount = uint32(count)
+		}
+	}
+	if _, ok = r.Form["tier"]; ok {
+		setting.Tier = r.FormValue("tier")
+	}
+	if _, ok = r.Form["trashRetention"]; ok {
+		setting.TrashRetention = r.FormValue("trashRetention")
+	}
+	if _, ok = r.Form["dedup"]; ok {
+		setting.Dedup = false
+		if s := r.FormValue("dedup"); s != "" {
+			dedup, err := strconv.ParseBool(s)
+			if err != nil {
+				writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid dedup %s", s))
+				return
+			}
+			setting.Dedup = dedup
+		}
+	}
+	for _, limit := range []struct {
+		name  string
+		value *uint64
+	}{{"maxBytes", &setting.MaxBytes}, {"maxFileCount", &setting.MaxFileCount}} {
+		if _, ok = r.Form[limit.name]; !ok {
+			continue
+		}
+		*limit.value = 0
+		if s := r.FormValue(limit.name); s != "" {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid %s %s", limit.name, s))
+				return
+			}
+			*limit.value = n
+		}
+	}
+	if err := ms.Topo.SetCollectionSetting(setting); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"Setting":   setting,
+		"Effective": effectiveCollectionSetting(ms.Topo.CollectionSettings, collection),
+	})
+}
+
+func (ms *MasterServer) collectionSettingsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	collection := r.FormValue("collection")
+	if err := ms.Topo.DeleteCollectionSetting(collection); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"Effective": effectiveCollectionSetting(ms.Topo.CollectionSettings, collection),
+	})
+}
+
+// effectiveCollectionSetting fills the settings of the collection with the defaults
+func effectiveCollectionSetting(cs *storage.CollectionSettings, collection string) *weedpb.CollectionSetting {
+	setting := &weedpb.CollectionSetting{
+		Collection:             collection,
+		VacuumGarbageThreshold: cs.GetGarbageThreshold(collection),
+		Ttl:                    cs.GetTtl(collection).String(),
+		MaxVolumeCount:         uint32(cs.GetMaxVolumeCount(collection)),
+		Tier:                   cs.GetTier(collection),
+		MaxBytes:               cs.GetMaxBytes(collection),
+		MaxFileCount:           cs.GetMaxFileCount(collection),
+		Dedup:                  cs.GetDedup(collection),
+		TrashRetention:         cs.GetTrashRetention(collection).String(),
+	}
+	if rp := cs.GetReplicaPlacement(collection); rp != nil {
+		setting.ReplicaPlacement = rp.String()
+	}
+	return setting
+}
+
+// collectionUsageHandler reports the usage and quota of the collections,
+// with the usage samples in the last hour, or since the "since" duration ago.
+func (ms *MasterServer) collectionUsageHandler(w http.ResponseWriter, r *http.Request) {
+	since := time.Hour
+	if s := r.FormValue("since"); s != "" {
+		var err error
+		if since, err = time.ParseDuration(s); err != nil {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid since %s", s))
+			return
+		}
+	}
+	collections := ms.Topo.UsageCollections()
+	if _, ok := r.Form["collection"]; ok {
+		collections = []string{r.FormValue("collection")}
+	}
+	type collectionUsage struct {
+		topology.CollectionUsage
+		Quota   topology.CollectionQuota   `json:"quota"`
+		History []topology.CollectionUsage `json:"history,omitempty"`
+	}
+	start := time.Now().Add(-since)
+	usages := []collectionUsage{}
+	for _, collection := range collections {
+		usages = append(usages, collectionUsage{
+			CollectionUsage: ms.Topo.CollectionUsage(collection),
+			Quota:           ms.Topo.CollectionQuota(collection),
+			History:         ms.Topo.CollectionUsageHistory(collection, start),
+		})
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"Collections": usages})
 }
 
 // deprecated
@@ -69,12 +241,10 @@ func (ms *MasterServer) dirJoinHandler(w http.ResponseWriter, r *http.Request) {
 	ms.Topo.ProcessJoinMessage(joinMessage)
 	type JoinResult struct {
 		VolumeSizeLimit uint64 `json:"VolumeSizeLimit,omitempty"`
-		SecretKey       string `json:"secretKey,omitempty"`
 		Error           string `json:"error,omitempty"`
 	}
 	writeJsonQuiet(w, r, http.StatusOK, JoinResult{
 		VolumeSizeLimit: uint64(ms.volumeSizeLimitMB) * 1024 * 1024,
-		SecretKey:       string(ms.guard.GetSecretKey()),
 	})
 }
 
@@ -106,7 +276,7 @@ func (ms *MasterServer) dirJoin2Handler(w http.ResponseWriter, r *http.Request)
 	if joinMsgV2.JoinKey != joinResp.JoinKey {
 		joinResp.JoinIp = joinMsgV2.Ip
 		joinResp.VolumeSizeLimit = ms.Topo.GetVolumeSizeLimit()
-		joinResp.SecretKey = string(ms.guard.GetSecretKey())
+		joinResp.SecureReads = ms.guard.IsReadSecured()
 		joinResp.CollectionSettings = ms.Topo.CollectionSettings.ToPbMessage()
 	}
 	writeObjResponse(w, r, http.StatusOK, joinResp)
@@ -134,6 +304,61 @@ func (ms *MasterServer) volumeCheckReplicateHandler(w http.ResponseWriter, r *ht
 	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"status": "running"})
 }
 
+func (ms *MasterServer) volumeBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	ms.Topo.StartBalance()
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"status": "running"})
+}
+
+func (ms *MasterServer) volumeTierHandler(w http.ResponseWriter, r *http.Request) {
+	p := ms.Topo.GetTierPolicy()
+	if p == nil {
+		writeJsonError(w, r, http.StatusNotImplemented, errors.New("no tier policy is configured"))
+		return
+	}
+	ms.Topo.StartMoveToColdTier()
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"status": "running", "policy": p})
+}
+
+func (ms *MasterServer) volumeScrubHandler(w http.ResponseWriter, r *http.Request) {
+	rateMB := 8
+	if s := r.FormValue("rateMB"); s != "" {
+		var err error
+		if rateMB, err = strconv.Atoi(s); err != nil || rateMB < 0 {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid rateMB %s", s))
+			return
+		}
+	}
+	ms.Topo.StartScrub(r.FormValue("collection"), rateMB)
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"status": "running"})
+}
+
+func (ms *MasterServer) volumeScrubReportHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	vid, err := storage.NewVolumeId(r.FormValue("volume"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	checked, _ := strconv.Atoi(r.FormValue("checked"))
+	ms.Topo.ReportCorruption(&topology.CorruptionReport{
+		Volume:     vid,
+		Collection: r.FormValue("collection"),
+		Url:        r.FormValue("url"),
+		Checked:    checked,
+		Corrupted:  r.Form["corrupted"],
+		Repaired:   r.Form["repaired"],
+		Time:       time.Now(),
+	})
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{})
+}
+
+func (ms *MasterServer) volumeScrubStatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"Running": ms.Topo.IsScrubbing(),
+		"Reports": ms.Topo.CorruptionReports(),
+	})
+}
+
 func (ms *MasterServer) volumeGrowHandler(w http.ResponseWriter, r *http.Request) {
 	count := 0
 	option, err := ms.getVolumeGrowOption(r)
@@ -220,9 +445,15 @@ func (ms *MasterServer) HasWritableVolume(option *topology.VolumeGrowOption) boo
 }
 
 func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGrowOption, error) {
+	collection := r.FormValue("collection")
+	cs := ms.Topo.CollectionSettings
 	replicationString := r.FormValue("replication")
 	if replicationString == "" {
-		replicationString = ms.defaultReplicaPlacement
+		if rp := cs.GetReplicaPlacement(collection); rp != nil {
+			replicationString = rp.String()
+		} else {
+			replicationString = ms.defaultReplicaPlacement
+		}
 	}
 	replicaPlacement, err := storage.NewReplicaPlacementFromString(replicationString)
 	if err != nil {
@@ -232,13 +463,21 @@ func (ms *MasterServer) getVolumeGrowOption(r *http.Request) (*topology.VolumeGr
 	if err != nil {
 		return nil, err
 	}
+	if r.FormValue("ttl") == "" && cs.GetTtl(collection) != nil {
+		ttl = cs.GetTtl(collection)
+	}
+	tier := r.FormValue("tier")
+	if tier == "" {
+		tier = cs.GetTier(collection)
+	}
 	volumeGrowOption := &topology.VolumeGrowOption{
-		Collection:       r.FormValue("collection"),
+		Collection:       collection,
 		ReplicaPlacement: replicaPlacement,
 		Ttl:              ttl,
 		DataCenter:       r.FormValue("dataCenter"),
 		Rack:             r.FormValue("rack"),
 		DataNode:         r.FormValue("dataNode"),
+		Tier:             tier,
 	}
 	return volumeGrowOption, nil
 }
@@ -0,0 +1,96 @@
+package weedserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+)
+
+// dedupAcquireHandler references the file already stored with the content hash,
+// or registers the assigned fid for the hash if it is new.
+func (ms *MasterServer) dedupAcquireHandler(w http.ResponseWriter, r *http.Request) {
+	collection, hash, fid := r.FormValue("collection"), r.FormValue("hash"), r.FormValue("fid")
+	if err := ms.guard.Authorize(r, security.ActionWrite, collection, fid); err != nil {
+		writeJsonError(w, r, http.StatusUnauthorized, err)
+		return
+	}
+	if !ms.Topo.CollectionSettings.GetDedup(collection) {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("dedup is not enabled on collection %q", collection))
+		return
+	}
+	size, err := strconv.ParseUint(r.FormValue("size"), 10, 64)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid size %s", r.FormValue("size")))
+		return
+	}
+	existing, found, err := ms.Topo.AcquireDedup(collection, hash, size, fid)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"fid":   existing,
+		"found": found,
+	})
+}
+
+// dedupReleaseHandler removes a reference of each fid,
+// and returns the fids without references, which should be deleted.
+func (ms *MasterServer) dedupReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	fids := r.Form["fid"]
+	for _, fid := range fids {
+		collection, e, err := ms.Topo.DedupEntry(fid)
+		if err != nil {
+			writeJsonError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if e == nil {
+			continue
+		}
+		if err = ms.guard.Authorize(r, security.ActionDelete, collection, fid); err != nil {
+			writeJsonError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+	}
+	deletes := []string{}
+	for _, fid := range fids {
+		remove, err := ms.Topo.ReleaseDedup(fid)
+		if err != nil {
+			writeJsonError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if remove {
+			deletes = append(deletes, fid)
+		}
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"delete": deletes})
+}
+
+// dedupStatusHandler shows the references of the fid, or the savings of the collection
+func (ms *MasterServer) dedupStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if fid := r.FormValue("fid"); fid != "" {
+		collection, e, err := ms.Topo.DedupEntry(fid)
+		if err != nil {
+			writeJsonError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if e == nil {
+			e = &topology.DedupEntry{Fid: fid}
+		}
+		writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+			"collection": collection,
+			"entry":      e,
+		})
+		return
+	}
+	savings, err := ms.Topo.DedupSavings(r.FormValue("collection"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, savings)
+}
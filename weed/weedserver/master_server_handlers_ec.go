@@ -0,0 +1,47 @@
+package weedserver
+
+import (
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func (ms *MasterServer) dirLookupEcHandler(w http.ResponseWriter, r *http.Request) {
+	vid := r.FormValue("volumeId")
+	volumeId, err := storage.NewVolumeId(vid)
+	if err != nil {
+		writeJsonQuiet(w, r, http.StatusBadRequest, operation.LookupEcResult{VolumeId: vid, Error: "Unknown volumeId format."})
+		return
+	}
+	ecLocations, ok := ms.Topo.LookupEcShards(volumeId)
+	if !ok {
+		writeJsonQuiet(w, r, http.StatusNotFound, operation.LookupEcResult{VolumeId: vid, Error: "ec volumeId not found."})
+		return
+	}
+	ret := operation.LookupEcResult{VolumeId: vid, Collection: ecLocations.Collection}
+	for shardId, dns := range ecLocations.Locations {
+		if len(dns) == 0 {
+			continue
+		}
+		shard := operation.EcShardLocations{ShardId: shardId}
+		for _, dn := range dns {
+			shard.Locations = append(shard.Locations, operation.Location{Url: dn.Url(), PublicUrl: dn.PublicUrl})
+		}
+		ret.Shards = append(ret.Shards, shard)
+	}
+	writeJsonQuiet(w, r, http.StatusOK, ret)
+}
+
+func (ms *MasterServer) volumeEcEncodeHandler(w http.ResponseWriter, r *http.Request) {
+	volumeId, err := storage.NewVolumeId(r.FormValue("volumeId"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err = ms.Topo.EcEncodeVolume(volumeId, r.FormValue("collection")); err != nil {
+		writeJsonError(w, r, http.StatusNotAcceptable, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"volumeId": volumeId})
+}
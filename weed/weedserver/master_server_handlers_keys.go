@@ -0,0 +1,149 @@
+package weedserver
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/security"
+)
+
+// the tokens of a deleted key are still accepted by the volume servers and the filers until they expire
+const (
+	defaultAccessTokenTtl = 10 * time.Minute
+	maxAccessTokenTtl     = 15 * time.Minute
+)
+
+// keyListHandler lists the access keys without their secrets
+func (ms *MasterServer) keyListHandler(w http.ResponseWriter, r *http.Request) {
+	keys := []security.AccessKey{}
+	for _, k := range ms.Topo.AccessKeys.List() {
+		keys = append(keys, security.AccessKey{Id: k.Id, Scope: k.Scope})
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"Keys": keys,
+	})
+}
+
+// keySetHandler creates or replaces an access key, with a generated secret if not given.
+// The secret is only returned here. With a secure.secret, the secret is also kept sealed,
+// to derive the signing keys of the S3 requests.
+func (ms *MasterServer) keySetHandler(w http.ResponseWriter, r *http.Request) {
+	scope, err := parseScope(r)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if len(scope.Collections) == 0 || len(scope.Actions) == 0 {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("collections and actions are required"))
+		return
+	}
+	k := &security.AccessKey{
+		Id:     r.FormValue("id"),
+		Secret: r.FormValue("secret"),
+		Scope:  scope,
+	}
+	if k.Secret == "" {
+		k.Secret = security.GenAccessKeySecret()
+	}
+	if secret := ms.guard.GetSecretKey(); len(secret) > 0 {
+		if err = k.Seal(secret); err != nil {
+			writeJsonError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if err = ms.Topo.SetAccessKey(k); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, security.AccessKey{Id: k.Id, Secret: k.Secret, Scope: k.Scope})
+}
+
+func (ms *MasterServer) keyDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if err := ms.Topo.DeleteAccessKey(r.FormValue("id")); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]string{"id": r.FormValue("id")})
+}
+
+// keyTokenHandler exchanges the id and secret of an access key for a JWT of its scope.
+// The optional collections and actions narrow the scope of the JWT.
+func (ms *MasterServer) keyTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if len(ms.guard.GetSecretKey()) == 0 {
+		writeJsonError(w, r, http.StatusNotImplemented, errors.New("the master has no secure.secret to sign the tokens"))
+		return
+	}
+	k, err := ms.Topo.AccessKeys.Authenticate(r.FormValue("id"), r.FormValue("secret"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusUnauthorized, err)
+		return
+	}
+	requested, err := parseScope(r)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	ttl := defaultAccessTokenTtl
+	if s := r.FormValue("ttl"); s != "" {
+		if ttl, err = time.ParseDuration(s); err != nil || ttl <= 0 || ttl > maxAccessTokenTtl {
+			writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid ttl %s, at most %v", s, maxAccessTokenTtl))
+			return
+		}
+	}
+	scope := k.Scope.Narrow(requested)
+	if len(scope.Collections) == 0 || len(scope.Actions) == 0 {
+		writeJsonError(w, r, http.StatusForbidden, errors.New("the requested scope is not allowed for the key"))
+		return
+	}
+	jwt, err := security.GenScopedJwt(ms.guard.GetSecretKey(), k.Id, scope, ttl)
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"jwt":     jwt,
+		"expires": time.Now().Add(ttl).Unix(),
+		"scope":   scope,
+	})
+}
+
+// keySigningKeyHandler derives the SigV4 signing key of an access key for a date, a region
+// and a service, so the S3 gateways verify the signatures without knowing the secret.
+// The signing key is only good for the day, and is returned with the scope of the key.
+func (ms *MasterServer) keySigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+	k, ok := ms.Topo.AccessKeys.Get(r.FormValue("id"))
+	if !ok {
+		writeJsonError(w, r, http.StatusNotFound, fmt.Errorf("access key %s is not found", r.FormValue("id")))
+		return
+	}
+	date := r.FormValue("date")
+	if _, err := time.Parse("20060102", date); err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("invalid date %s, expecting yyyymmdd", date))
+		return
+	}
+	secret, err := k.OpenSecret(ms.guard.GetSecretKey())
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	signingKey := security.SigV4SigningKey(secret, date, r.FormValue("region"), r.FormValue("service"))
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"signingKey": hex.EncodeToString(signingKey),
+		"scope":      k.Scope,
+	})
+}
+
+// parseScope parses the comma separated "collections" and "actions" in the form
+func parseScope(r *http.Request) (scope security.Scope, err error) {
+	if s := r.FormValue("collections"); s != "" {
+		for _, c := range strings.Split(s, ",") {
+			scope.Collections = append(scope.Collections, strings.TrimSpace(c))
+		}
+	}
+	scope.Actions, err = security.ParseActions(r.FormValue("actions"))
+	return
+}
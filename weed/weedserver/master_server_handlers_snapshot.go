@@ -0,0 +1,95 @@
+package weedserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// snapshotServers lists the data nodes with the replicas of the volume,
+// or with any volume of the collection.
+func (ms *MasterServer) snapshotServers(r *http.Request) ([]*topology.DataNode, error) {
+	collection := r.FormValue("collection")
+	if volume := r.FormValue("volume"); volume != "" {
+		return ms.lookupCollectionVolume(collection, volume)
+	}
+	c, ok := ms.Topo.GetCollection(collection)
+	if !ok {
+		return nil, fmt.Errorf("collection %q does not exist", collection)
+	}
+	return c.ListVolumeServers(), nil
+}
+
+// forwardSnapshot calls the snapshot admin api of all volume servers
+// with the volume or the collection, and collects their responses by server.
+func (ms *MasterServer) forwardSnapshot(w http.ResponseWriter, r *http.Request, path string) {
+	servers, err := ms.snapshotServers(r)
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// the volume servers select the volumes of either
+	values := url.Values{"collection": {r.FormValue("collection")}}
+	if volume := r.FormValue("volume"); volume != "" {
+		values = url.Values{"volume": {volume}}
+	}
+	if name := r.FormValue("name"); name != "" {
+		values.Set("name", name)
+	}
+	result := make(map[string]interface{})
+	var failures []string
+	for _, dn := range servers {
+		jsonBlob, e := util.Post(dn.Url(), path, values)
+		var ret map[string]interface{}
+		if e == nil {
+			e = json.Unmarshal(jsonBlob, &ret)
+		}
+		if e != nil {
+			ret = map[string]interface{}{"error": e.Error()}
+		}
+		if msg, _ := ret["error"].(string); msg != "" {
+			failures = append(failures, dn.Url()+": "+msg)
+		}
+		result[dn.Url()] = ret
+	}
+	if len(failures) > 0 {
+		result["error"] = strings.Join(failures, "; ")
+		writeJsonQuiet(w, r, http.StatusInternalServerError, result)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, result)
+}
+
+func (ms *MasterServer) volumeSnapshotCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("name") == "" {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("snapshot name is required"))
+		return
+	}
+	ms.forwardSnapshot(w, r, "/admin/snapshot/create")
+}
+
+func (ms *MasterServer) volumeSnapshotListHandler(w http.ResponseWriter, r *http.Request) {
+	ms.forwardSnapshot(w, r, "/admin/snapshot/list")
+}
+
+func (ms *MasterServer) volumeSnapshotReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("name") == "" {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("snapshot name is required"))
+		return
+	}
+	ms.forwardSnapshot(w, r, "/admin/snapshot/release")
+}
+
+func (ms *MasterServer) volumeSnapshotRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("name") == "" {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("snapshot name is required"))
+		return
+	}
+	ms.forwardSnapshot(w, r, "/admin/snapshot/restore")
+}
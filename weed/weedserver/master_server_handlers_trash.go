@@ -0,0 +1,81 @@
+package weedserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// lookupCollectionVolume lists the data nodes with the replicas of the volume in the collection
+func (ms *MasterServer) lookupCollectionVolume(collection, volume string) ([]*topology.DataNode, error) {
+	vid, err := storage.NewVolumeId(volume)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume id %s", volume)
+	}
+	locations := ms.Topo.Lookup(collection, vid)
+	if locations == nil || locations.Length() == 0 {
+		return nil, fmt.Errorf("volume %s of collection %q is not found", volume, collection)
+	}
+	return locations.AllDataNode(), nil
+}
+
+// volumeTrashHandler lists the deleted files of the volume which can be undeleted
+func (ms *MasterServer) volumeTrashHandler(w http.ResponseWriter, r *http.Request) {
+	servers, err := ms.lookupCollectionVolume(r.FormValue("collection"), r.FormValue("volume"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	jsonBlob, err := util.Post(servers[0].Url(), "/admin/trash/list", url.Values{"volume": {r.FormValue("volume")}})
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	var ret map[string]interface{}
+	if err = json.Unmarshal(jsonBlob, &ret); err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, ret)
+}
+
+// volumeUndeleteHandler undeletes the file on all replicas of its volume
+func (ms *MasterServer) volumeUndeleteHandler(w http.ResponseWriter, r *http.Request) {
+	fid, err := storage.ParseFileId(r.FormValue("fid"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	servers, err := ms.lookupCollectionVolume(r.FormValue("collection"), fid.VolumeId.String())
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	var failures []string
+	for _, dn := range servers {
+		jsonBlob, e := util.Post(dn.Url(), "/admin/trash/undelete", url.Values{"fid": {fid.String()}})
+		if e == nil {
+			var ret struct {
+				Error string `json:"error"`
+			}
+			if e = json.Unmarshal(jsonBlob, &ret); e == nil && ret.Error != "" {
+				e = errors.New(ret.Error)
+			}
+		}
+		if e != nil {
+			failures = append(failures, dn.Url()+": "+e.Error())
+		}
+	}
+	if len(failures) > 0 {
+		writeJsonError(w, r, http.StatusInternalServerError, errors.New(strings.Join(failures, "; ")))
+		return
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]string{"fid": fid.String()})
+}
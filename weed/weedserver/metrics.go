@@ -0,0 +1,183 @@
+package weedserver
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/gorilla/mux"
+)
+
+// InstrumentRouter counts the requests of the master by the matched route
+func InstrumentRouter(server string, r *mux.Router) http.Handler {
+	return instrument(server, r, func(req *http.Request) string {
+		var match mux.RouteMatch
+		if r.Match(req, &match) {
+			if t, e := match.Route.GetPathTemplate(); e == nil {
+				return t
+			}
+		}
+		return "unknown"
+	})
+}
+
+// InstrumentServeMux counts the requests of the volume and filer servers by the matched pattern
+func InstrumentServeMux(server string, m *http.ServeMux) http.Handler {
+	return instrument(server, m, func(req *http.Request) string {
+		if _, pattern := m.Handler(req); pattern != "" {
+			return pattern
+		}
+		return "unknown"
+	})
+}
+
+func instrument(server string, h http.Handler, handlerName func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		handler := handlerName(r)
+		mw := &metricsResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		var body *metricsReadCloser
+		if r.Body != nil {
+			body = &metricsReadCloser{ReadCloser: r.Body}
+			r.Body = body
+		}
+		h.ServeHTTP(mw, r)
+		stats.HttpRequests.Add(1, server, handler, r.Method, strconv.Itoa(mw.status))
+		stats.HttpRequestDuration.Observe(time.Since(start).Seconds(), server, handler)
+		if body != nil {
+			stats.HttpRequestBytes.Add(float64(body.bytes), server, handler)
+		}
+		stats.HttpResponseBytes.Add(float64(mw.bytes), server, handler)
+	})
+}
+
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *metricsResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *metricsResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *metricsResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type metricsReadCloser struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (r *metricsReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func metricsHandler(collectors ...stats.Collector) http.HandlerFunc {
+	collectors = append(append([]stats.Collector{}, stats.HttpCollectors...), collectors...)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		stats.WriteMetrics(w, collectors...)
+	}
+}
+
+func (ms *MasterServer) metricsCollectors() []stats.Collector {
+	return []stats.Collector{
+		stats.NewGaugeFunc("seaweedfs_master_is_leader", "Whether this master is the raft leader.", nil,
+			func(set func(float64, ...string)) {
+				if ms.Topo.IsLeader() {
+					set(1)
+				} else {
+					set(0)
+				}
+			}),
+		stats.NewGaugeFunc("seaweedfs_master_heartbeat_age_seconds", "Seconds since the last heartbeat of the data node.",
+			[]string{"data_center", "rack", "node"},
+			func(set func(float64, ...string)) {
+				now := time.Now().Unix()
+				ms.Topo.WalkDataNode(func(dn *topology.DataNode) error {
+					set(float64(now-dn.LastSeen()), string(dn.GetDataCenter().Id()), string(dn.GetRack().Id()), dn.Url())
+					return nil
+				})
+			}),
+		stats.NewGaugeFunc("seaweedfs_master_data_node_volumes", "Number of volumes on the data node.",
+			[]string{"data_center", "rack", "node"},
+			func(set func(float64, ...string)) {
+				ms.Topo.WalkDataNode(func(dn *topology.DataNode) error {
+					set(float64(dn.GetVolumeCount()), string(dn.GetDataCenter().Id()), string(dn.GetRack().Id()), dn.Url())
+					return nil
+				})
+			}),
+		stats.NewGaugeFunc("seaweedfs_master_data_node_max_volumes", "Maximum number of volumes on the data node.",
+			[]string{"data_center", "rack", "node"},
+			func(set func(float64, ...string)) {
+				ms.Topo.WalkDataNode(func(dn *topology.DataNode) error {
+					set(float64(dn.GetMaxVolumeCount()), string(dn.GetDataCenter().Id()), string(dn.GetRack().Id()), dn.Url())
+					return nil
+				})
+			}),
+	}
+}
+
+func (vs *VolumeServer) metricsCollectors() []stats.Collector {
+	volumeGauge := func(name, help string, value func(v *storage.VolumeInfo) float64) stats.Collector {
+		return stats.NewGaugeFunc(name, help, []string{"collection", "volume"},
+			func(set func(float64, ...string)) {
+				for _, v := range vs.store.Status() {
+					set(value(v), v.Collection, v.Id.String())
+				}
+			})
+	}
+	return []stats.Collector{
+		volumeGauge("seaweedfs_volume_size_bytes", "Size of the volume.",
+			func(v *storage.VolumeInfo) float64 { return float64(v.Size) }),
+		volumeGauge("seaweedfs_volume_file_count", "Number of files in the volume.",
+			func(v *storage.VolumeInfo) float64 { return float64(v.FileCount) }),
+		volumeGauge("seaweedfs_volume_deleted_bytes", "Bytes of deleted files in the volume.",
+			func(v *storage.VolumeInfo) float64 { return float64(v.DeletedByteCount) }),
+		volumeGauge("seaweedfs_volume_read_only", "Whether the volume is read only.",
+			func(v *storage.VolumeInfo) float64 {
+				if v.ReadOnly {
+					return 1
+				}
+				return 0
+			}),
+		stats.NewGaugeFunc("seaweedfs_volume_tasks", "Number of tasks on the volume server.", []string{"type", "status"},
+			func(set func(float64, ...string)) {
+				counts := make(map[[2]string]int)
+				for _, t := range vs.store.TaskManager.AllTasks() {
+					taskType := t.Id
+					if i := strings.LastIndex(t.Id, "-"); i > 0 {
+						taskType = t.Id[:i]
+					}
+					status := "running"
+					if t.Error != "" {
+						status = "failed"
+					} else if t.Finished {
+						status = "finished"
+					}
+					counts[[2]string{taskType, status}]++
+				}
+				for k, c := range counts {
+					set(float64(c), k[0], k[1])
+				}
+			}),
+	}
+}
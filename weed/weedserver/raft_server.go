@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"github.com/chrislusf/raft"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/topology"
+	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/gorilla/mux"
 )
 
@@ -43,10 +45,15 @@ func NewRaftServer(r *mux.Router, peers []string, httpAddr string, dataDir strin
 	}
 
 	raft.RegisterCommand(&topology.MaxVolumeIdCommand{})
+	raft.RegisterCommand(&topology.FileIdReservationCommand{})
+	raft.RegisterCommand(&topology.CollectionSettingCommand{})
+	raft.RegisterCommand(&topology.AccessKeyCommand{})
+	raft.RegisterCommand(&topology.DedupCommand{})
 
 	var err error
 	transporter := raft.NewHTTPTransporter("/cluster", 0)
 	transporter.Transport.MaxIdleConnsPerHost = 1024
+	transporter.Transport.TLSClientConfig = util.Transport.TLSClientConfig
 	glog.V(1).Infof("Starting RaftServer with IP:%v:", httpAddr)
 
 	// Clear old cluster configurations if peers are set
@@ -78,7 +85,7 @@ func NewRaftServer(r *mux.Router, peers []string, httpAddr string, dataDir strin
 			glog.V(0).Infoln("No existing server found. Starting as leader in the new cluster.")
 			_, err := s.raftServer.Do(&raft.DefaultJoinCommand{
 				Name:             s.raftServer.Name(),
-				ConnectionString: "http://" + s.httpAddr,
+				ConnectionString: util.NormalizeUrl(s.httpAddr),
 			})
 			if err != nil {
 				glog.V(0).Infoln(err)
@@ -91,7 +98,7 @@ func NewRaftServer(r *mux.Router, peers []string, httpAddr string, dataDir strin
 
 		_, err := s.raftServer.Do(&raft.DefaultJoinCommand{
 			Name:             s.raftServer.Name(),
-			ConnectionString: "http://" + s.httpAddr,
+			ConnectionString: util.NormalizeUrl(s.httpAddr),
 		})
 
 		if err != nil {
@@ -110,7 +117,7 @@ func (s *RaftServer) Peers() (members []string) {
 	peers := s.raftServer.Peers()
 
 	for _, p := range peers {
-		members = append(members, strings.TrimPrefix(p.ConnectionString, "http://"))
+		members = append(members, strings.TrimPrefix(strings.TrimPrefix(p.ConnectionString, "http://"), "https://"))
 	}
 
 	return
@@ -120,7 +127,7 @@ func (s *RaftServer) Peers() (members []string) {
 func (s *RaftServer) Join(peers []string) error {
 	command := &raft.DefaultJoinCommand{
 		Name:             s.raftServer.Name(),
-		ConnectionString: "http://" + s.httpAddr,
+		ConnectionString: util.NormalizeUrl(s.httpAddr),
 	}
 
 	var err error
@@ -130,7 +137,7 @@ func (s *RaftServer) Join(peers []string) error {
 		if m == s.httpAddr {
 			continue
 		}
-		target := fmt.Sprintf("http://%s/cluster/join", strings.TrimSpace(m))
+		target := util.NormalizeUrl(strings.TrimSpace(m) + "/cluster/join")
 		glog.V(0).Infoln("Attempting to connect to:", target)
 
 		err = postFollowingOneRedirect(target, "application/json", &b)
@@ -152,7 +159,7 @@ func (s *RaftServer) Join(peers []string) error {
 // a workaround because http POST following redirection misses request body
 func postFollowingOneRedirect(target string, contentType string, b *bytes.Buffer) error {
 	backupReader := bytes.NewReader(b.Bytes())
-	resp, err := http.Post(target, contentType, b)
+	resp, err := httpPost(target, contentType, b)
 	if err != nil {
 		return err
 	}
@@ -167,7 +174,7 @@ func postFollowingOneRedirect(target string, contentType string, b *bytes.Buffer
 		}
 
 		glog.V(0).Infoln("Post redirected to ", urlStr)
-		resp2, err2 := http.Post(urlStr, contentType, backupReader)
+		resp2, err2 := httpPost(urlStr, contentType, backupReader)
 		if err2 != nil {
 			return err2
 		}
@@ -183,3 +190,13 @@ func postFollowingOneRedirect(target string, contentType string, b *bytes.Buffer
 
 	return nil
 }
+
+// httpPost posts with the shared client, which talks https if the servers use TLS
+func httpPost(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return util.HttpDo(req)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/chrislusf/raft"
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/util"
 )
 
 // Handles incoming RAFT joins.
@@ -44,8 +45,8 @@ func (s *RaftServer) HandleFunc(pattern string, handler func(http.ResponseWriter
 func (s *RaftServer) redirectToLeader(w http.ResponseWriter, req *http.Request) {
 	if leader, e := s.topo.Leader(); e == nil {
 		//http.StatusMovedPermanently does not cause http POST following redirection
-		glog.V(0).Infoln("Redirecting to", http.StatusMovedPermanently, "http://"+leader+req.URL.Path)
-		http.Redirect(w, req, "http://"+leader+req.URL.Path, http.StatusMovedPermanently)
+		glog.V(0).Infoln("Redirecting to", http.StatusMovedPermanently, util.NormalizeUrl(leader+req.URL.Path))
+		http.Redirect(w, req, util.NormalizeUrl(leader+req.URL.Path), http.StatusMovedPermanently)
 	} else {
 		glog.V(0).Infoln("Error: Leader Unknown")
 		http.Error(w, "Leader unknown", http.StatusInternalServerError)
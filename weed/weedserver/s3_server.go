@@ -0,0 +1,251 @@
+package weedserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// S3Server speaks a subset of the S3 REST API, with path style requests only.
+// It is a client of the filer: each bucket is a directory under bucketsDir,
+// and its objects are stored in the collection with the same name as the bucket,
+// so they are seen by the mount, the trash and the versioning like the other files.
+// With a secret, the requests are signed with AWS Signature Version 4 by an access key
+// of the master, or carry a JWT, allowing the request on the collection, see authorize.
+type S3Server struct {
+	filer              string
+	master             string
+	bucketsDir         string
+	defaultReplication string
+	secret             security.Secret
+	guard              *security.Guard
+	signingKeys        *s3SigningKeys
+}
+
+func NewS3Server(r *http.ServeMux, filerServer string, masterServer string, bucketsDir string, replication string,
+	secret string,
+) (s3 *S3Server, err error) {
+	s3 = &S3Server{
+		filer:              filerServer,
+		master:             masterServer,
+		bucketsDir:         path.Join("/", bucketsDir),
+		defaultReplication: replication,
+		secret:             security.Secret(secret),
+		guard:              security.NewGuard(nil, secret),
+		signingKeys:        newS3SigningKeys(),
+	}
+	if secret != "" {
+		// to get the signing keys of the access keys from the master
+		util.SetInternalJwt(s3.guard.InternalJwt)
+	}
+	if err = filer.CreateDirectory(s3.filer, s3.bucketsDir); err != nil {
+		return nil, err
+	}
+
+	r.HandleFunc("/", s3.s3Handler)
+
+	return s3, nil
+}
+
+func (s3 *S3Server) s3Handler(w http.ResponseWriter, r *http.Request) {
+	bucket, key := parseS3Path(r.URL.Path)
+	query := r.URL.Query()
+	_, hasUploadId := query["uploadId"]
+	if err := s3.authorize(r, bucket); err != nil {
+		glog.V(1).Infof("%s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+		if e, ok := err.(*S3Error); ok {
+			writeS3Error(w, r, e)
+		} else {
+			writeS3Error(w, r, ErrS3AccessDenied)
+		}
+		return
+	}
+	switch {
+	case bucket == "":
+		if r.Method != "GET" {
+			writeS3Error(w, r, ErrS3MethodNotAllowed)
+			return
+		}
+		s3.listBucketsHandler(w, r)
+	case key == "":
+		switch r.Method {
+		case "GET":
+			if _, ok := query["location"]; ok {
+				s3.getBucketLocationHandler(w, r, bucket)
+				return
+			}
+			s3.listObjectsHandler(w, r, bucket)
+		case "HEAD":
+			s3.headBucketHandler(w, r, bucket)
+		case "PUT":
+			s3.putBucketHandler(w, r, bucket)
+		case "DELETE":
+			s3.deleteBucketHandler(w, r, bucket)
+		default:
+			writeS3Error(w, r, ErrS3NotImplemented)
+		}
+	default:
+		switch r.Method {
+		case "GET", "HEAD":
+			if hasUploadId {
+				writeS3Error(w, r, ErrS3NotImplemented)
+				return
+			}
+			s3.getOrHeadObjectHandler(w, r, bucket, key)
+		case "PUT":
+			if r.Header.Get("X-Amz-Copy-Source") != "" {
+				writeS3Error(w, r, ErrS3NotImplemented)
+				return
+			}
+			if hasUploadId {
+				s3.uploadPartHandler(w, r, bucket, key)
+				return
+			}
+			s3.putObjectHandler(w, r, bucket, key)
+		case "POST":
+			if _, ok := query["uploads"]; ok {
+				s3.initiateMultipartUploadHandler(w, r, bucket, key)
+				return
+			}
+			if hasUploadId {
+				s3.completeMultipartUploadHandler(w, r, bucket, key)
+				return
+			}
+			writeS3Error(w, r, ErrS3NotImplemented)
+		case "DELETE":
+			if hasUploadId {
+				s3.abortMultipartUploadHandler(w, r, bucket, key)
+				return
+			}
+			s3.deleteObjectHandler(w, r, bucket, key)
+		default:
+			writeS3Error(w, r, ErrS3NotImplemented)
+		}
+	}
+}
+
+// parseS3Path splits "/bucket/path/to/key" into the bucket and the object key
+func parseS3Path(p string) (bucket, key string) {
+	p = strings.TrimPrefix(p, "/")
+	if i := strings.Index(p, "/"); i >= 0 {
+		return p[:i], p[i+1:]
+	}
+	return p, ""
+}
+
+func (s3 *S3Server) bucketDir(bucket string) string {
+	return s3.bucketsDir + "/" + bucket + "/"
+}
+
+func (s3 *S3Server) objectPath(bucket, key string) string {
+	return s3.bucketsDir + "/" + bucket + "/" + key
+}
+
+func (s3 *S3Server) hasBucket(bucket string) bool {
+	if !isValidBucketName(bucket) {
+		return false
+	}
+	// listing the files fails if the directory does not exist
+	_, err := filer.ListFilesAfter(s3.filer, s3.bucketDir(bucket), "", 1)
+	return err == nil
+}
+
+// findObject finds the filer entry of the object
+func (s3 *S3Server) findObject(bucket, key string) (*filer.FileEntry, error) {
+	objectPath := s3.objectPath(bucket, key)
+	ret, err := filer.ListFiles(s3.filer, path.Dir(objectPath), path.Base(objectPath))
+	if err != nil {
+		return nil, err
+	}
+	if len(ret.Files) == 0 {
+		return nil, fmt.Errorf("%s is not found", objectPath)
+	}
+	return &ret.Files[0], nil
+}
+
+// newFilerRequest makes the request on the path of the filer, the body is of the size
+func (s3 *S3Server) newFilerRequest(method, filePath string, query url.Values, body io.Reader, size int64) (*http.Request, error) {
+	request, err := http.NewRequest(method, util.MkUrl(s3.filer, filePath, query), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = size
+	}
+	return request, nil
+}
+
+// filerCall sends the request to the filer, and decodes the json response into ret, if not nil.
+// The status of the response is returned, with an error if the request failed.
+func filerCall(request *http.Request, ret interface{}) (int, error) {
+	resp, err := util.HttpDo(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var e filer.ApiResult
+		json.Unmarshal(data, &e)
+		return resp.StatusCode, fmt.Errorf("%s %s: %s %s", request.Method, request.URL.Path, resp.Status, e.Error)
+	}
+	if ret == nil {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.Unmarshal(data, ret)
+}
+
+// readJwt allows the filer to read the bucket from the volume servers, if the reads are secured
+func (s3 *S3Server) readJwt(bucket string) security.EncodedJwt {
+	encoded, err := security.GenScopedJwt(s3.secret, "",
+		security.Scope{Collections: []string{bucket}, Actions: []security.Action{security.ActionRead}}, 10*time.Second)
+	if err != nil {
+		glog.V(0).Infof("Failed to sign the read token of bucket %s: %v", bucket, err)
+	}
+	return encoded
+}
+
+func isValidBucketName(bucket string) bool {
+	if len(bucket) < 3 || len(bucket) > 63 {
+		return false
+	}
+	for i, c := range bucket {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-' || c == '.':
+			if i == 0 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isValidObjectKey rejects keys which can not be mapped onto a filer path as is
+func isValidObjectKey(key string) bool {
+	if key == "" || len(key) > 1024 {
+		return false
+	}
+	for _, part := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package weedserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// the signing keys are fetched again after a while, so a deleted or changed access key stops working
+const s3SigningKeyTtl = time.Minute
+
+// authorize checks the request is allowed on the collection of the bucket, all the collections
+// for the list of the buckets. The request is signed with AWS Signature Version 4 by an access key,
+// whose scope must allow it, or carries a JWT of the scope. Without a secret, the requests are not
+// authenticated.
+func (s3 *S3Server) authorize(r *http.Request, bucket string) error {
+	if len(s3.secret) == 0 {
+		return nil
+	}
+	action := security.ActionRead
+	switch r.Method {
+	case "PUT", "POST":
+		action = security.ActionWrite
+	case "DELETE":
+		action = security.ActionDelete
+	}
+	collection := bucket
+	if collection == "" {
+		collection = "*"
+	}
+	sig, err := security.ParseSigV4(r)
+	if err == security.ErrSigV4Missing {
+		return s3.guard.Authorize(r, action, collection, "")
+	} else if err != nil {
+		return err
+	}
+	if sig.PayloadHash == security.SigV4StreamingPayload {
+		return ErrS3NotImplemented
+	}
+	key, err := s3.signingKeys.get(s3.master, sig.Credential)
+	if err != nil {
+		return err
+	}
+	if err = sig.Verify(r, key.signingKey, time.Now()); err == security.ErrUnauthorized {
+		return ErrS3SignatureDoesNotMatch
+	} else if err != nil {
+		return err
+	}
+	if !key.scope.Allows(action, collection) {
+		return fmt.Errorf("access key %s is not allowed to %s on %s", sig.Credential.AccessKeyId, action, collection)
+	}
+	if sig.PayloadHash != security.SigV4UnsignedPayload {
+		expected, err := hex.DecodeString(sig.PayloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return fmt.Errorf("invalid x-amz-content-sha256 %s", sig.PayloadHash)
+		}
+		r.Body = &s3PayloadVerifier{ReadCloser: r.Body, hash: sha256.New(), expected: expected, remaining: r.ContentLength}
+	}
+	return nil
+}
+
+// s3PayloadVerifier fails the read of the end of the body if its sha256 is not the signed one.
+// The end is the content length if known, as the readers of the known lengths stop there.
+type s3PayloadVerifier struct {
+	io.ReadCloser
+	hash      hash.Hash
+	expected  []byte
+	remaining int64
+	mismatch  bool
+}
+
+func (v *s3PayloadVerifier) Read(p []byte) (n int, err error) {
+	n, err = v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	v.remaining -= int64(n)
+	if err == io.EOF || v.remaining == 0 {
+		if !bytes.Equal(v.hash.Sum(nil), v.expected) {
+			v.mismatch = true
+			return n, ErrS3ContentSHA256Mismatch
+		}
+	}
+	return n, err
+}
+
+// writeS3UploadError writes the error of sending the body of the request to the filer
+func writeS3UploadError(w http.ResponseWriter, r *http.Request, err error) {
+	if v, ok := r.Body.(*s3PayloadVerifier); ok && v.mismatch {
+		writeS3Error(w, r, ErrS3ContentSHA256Mismatch)
+		return
+	}
+	writeS3InternalError(w, r, err)
+}
+
+type s3SigningKey struct {
+	signingKey []byte
+	scope      security.Scope
+	fetched    time.Time
+}
+
+// s3SigningKeys caches the signing keys derived by the master, by access key and credential scope
+type s3SigningKeys struct {
+	keys  map[security.SigV4Credential]*s3SigningKey
+	mutex sync.Mutex
+}
+
+func newS3SigningKeys() *s3SigningKeys {
+	return &s3SigningKeys{keys: make(map[security.SigV4Credential]*s3SigningKey)}
+}
+
+func (c *s3SigningKeys) get(master string, credential security.SigV4Credential) (*s3SigningKey, error) {
+	now := time.Now()
+	c.mutex.Lock()
+	key, ok := c.keys[credential]
+	c.mutex.Unlock()
+	if ok && now.Sub(key.fetched) < s3SigningKeyTtl {
+		return key, nil
+	}
+	key, err := fetchS3SigningKey(master, credential)
+	if err != nil {
+		return nil, err
+	}
+	key.fetched = now
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for k, v := range c.keys {
+		if now.Sub(v.fetched) >= s3SigningKeyTtl {
+			delete(c.keys, k)
+		}
+	}
+	c.keys[credential] = key
+	return key, nil
+}
+
+func fetchS3SigningKey(master string, credential security.SigV4Credential) (*s3SigningKey, error) {
+	content, status, err := util.PostEx(master, "/key/signingKey", url.Values{
+		"id":      {credential.AccessKeyId},
+		"date":    {credential.Date},
+		"region":  {credential.Region},
+		"service": {credential.Service},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, ErrS3InvalidAccessKeyId
+	}
+	var ret struct {
+		SigningKey string         `json:"signingKey"`
+		Scope      security.Scope `json:"scope"`
+		Error      string         `json:"error"`
+	}
+	if err = json.Unmarshal(content, &ret); err != nil {
+		return nil, fmt.Errorf("signing key of %s: %v", credential.AccessKeyId, err)
+	}
+	if ret.Error != "" {
+		return nil, fmt.Errorf("signing key of %s: %s", credential.AccessKeyId, ret.Error)
+	}
+	signingKey, err := hex.DecodeString(ret.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("signing key of %s: %v", credential.AccessKeyId, err)
+	}
+	return &s3SigningKey{signingKey: signingKey, scope: ret.Scope}, nil
+}
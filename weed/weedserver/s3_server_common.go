@@ -0,0 +1,99 @@
+package weedserver
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+type S3Error struct {
+	Code       string
+	Message    string
+	HttpStatus int
+}
+
+func (e *S3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	ErrS3AccessDenied            = &S3Error{"AccessDenied", "Access Denied", http.StatusForbidden}
+	ErrS3BucketAlreadyOwnedByYou = &S3Error{"BucketAlreadyOwnedByYou", "The bucket you tried to create already exists.", http.StatusConflict}
+	ErrS3BucketNotEmpty          = &S3Error{"BucketNotEmpty", "The bucket you tried to delete is not empty.", http.StatusConflict}
+	ErrS3ContentSHA256Mismatch   = &S3Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	ErrS3InternalError           = &S3Error{"InternalError", "We encountered an internal error, please try again.", http.StatusInternalServerError}
+	ErrS3InvalidArgument         = &S3Error{"InvalidArgument", "Invalid Argument.", http.StatusBadRequest}
+	ErrS3InvalidAccessKeyId      = &S3Error{"InvalidAccessKeyId", "The AWS access key Id you provided does not exist in our records.", http.StatusForbidden}
+	ErrS3InvalidBucketName       = &S3Error{"InvalidBucketName", "The specified bucket is not valid.", http.StatusBadRequest}
+	ErrS3InvalidObjectKey        = &S3Error{"InvalidArgument", "The specified object key is not supported.", http.StatusBadRequest}
+	ErrS3InvalidPart             = &S3Error{"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	ErrS3InvalidPartOrder        = &S3Error{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	ErrS3MalformedXML            = &S3Error{"MalformedXML", "The XML you provided was not well-formed.", http.StatusBadRequest}
+	ErrS3MethodNotAllowed        = &S3Error{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	ErrS3MissingContentLength    = &S3Error{"MissingContentLength", "You must provide the Content-Length HTTP header.", http.StatusLengthRequired}
+	ErrS3NoSuchBucket            = &S3Error{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	ErrS3NoSuchKey               = &S3Error{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	ErrS3NoSuchUpload            = &S3Error{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	ErrS3NotImplemented          = &S3Error{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	ErrS3SignatureDoesNotMatch   = &S3Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+)
+
+type s3ErrorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, e *S3Error) {
+	glog.V(2).Infoln(r.Method, r.URL.Path, e)
+	if r.Method == "HEAD" {
+		w.WriteHeader(e.HttpStatus)
+		return
+	}
+	writeS3Xml(w, e.HttpStatus, s3ErrorResponse{Code: e.Code, Message: e.Message, Resource: r.URL.Path})
+}
+
+// writeS3InternalError logs the cause, which is not exposed to the client
+func writeS3InternalError(w http.ResponseWriter, r *http.Request, err error) {
+	glog.V(0).Infoln(r.Method, r.URL.Path, "error:", err)
+	writeS3Error(w, r, ErrS3InternalError)
+}
+
+func writeS3Xml(w http.ResponseWriter, httpStatus int, obj interface{}) {
+	bytes, err := xml.Marshal(obj)
+	if err != nil {
+		glog.V(0).Infof("error marshaling xml %+v: %v", obj, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(xml.Header)+len(bytes)))
+	w.WriteHeader(httpStatus)
+	if _, err = w.Write([]byte(xml.Header)); err == nil {
+		_, err = w.Write(bytes)
+	}
+	if err != nil {
+		glog.V(0).Infof("error writing xml %+v: %v", obj, err)
+	}
+}
+
+func s3Time(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// s3ETag derives the etag of an object, or of a part, from the file ids of its content,
+// which change with every write
+func s3ETag(fids ...string) string {
+	h := md5.New()
+	for _, fid := range fids {
+		io.WriteString(h, fid)
+	}
+	return "\"" + hex.EncodeToString(h.Sum(nil)) + "\""
+}
@@ -0,0 +1,332 @@
+package weedserver
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+)
+
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3LocationConstraint struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListBucketResult struct {
+	XMLName               xml.Name         `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Marker                string           `xml:"Marker,omitempty"`
+	NextMarker            string           `xml:"NextMarker,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int              `xml:"KeyCount,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+func (s3 *S3Server) listBucketsHandler(w http.ResponseWriter, r *http.Request) {
+	dirs, err := filer.ListDirectories(s3.filer, s3.bucketsDir)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	ret := s3ListAllMyBucketsResult{Owner: s3Owner{ID: "seaweedfs", DisplayName: "seaweedfs"}}
+	for _, dir := range dirs.Directories {
+		if !isValidBucketName(dir.Name) {
+			continue
+		}
+		// the filer does not keep the creation time of directories
+		ret.Buckets = append(ret.Buckets, s3Bucket{Name: dir.Name, CreationDate: s3Time(time.Unix(0, 0))})
+	}
+	sort.Sort(s3BucketsByName(ret.Buckets))
+	writeS3Xml(w, http.StatusOK, ret)
+}
+
+type s3BucketsByName []s3Bucket
+
+func (s s3BucketsByName) Len() int           { return len(s) }
+func (s s3BucketsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s s3BucketsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s3 *S3Server) getBucketLocationHandler(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	writeS3Xml(w, http.StatusOK, s3LocationConstraint{})
+}
+
+func (s3 *S3Server) headBucketHandler(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s3 *S3Server) putBucketHandler(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !isValidBucketName(bucket) {
+		writeS3Error(w, r, ErrS3InvalidBucketName)
+		return
+	}
+	if s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3BucketAlreadyOwnedByYou)
+		return
+	}
+	if err := filer.CreateDirectory(s3.filer, s3.bucketDir(bucket)); err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s3 *S3Server) deleteBucketHandler(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	dir := s3.bucketDir(bucket)
+	dirs, err := filer.ListDirectories(s3.filer, dir)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	files, err := filer.ListFilesAfter(s3.filer, dir, "", 1)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	if len(dirs.Directories) > 0 || len(files.Files) > 0 {
+		writeS3Error(w, r, ErrS3BucketNotEmpty)
+		return
+	}
+	// the unfinished multipart uploads are left to expire in the filer
+	if err = filer.DeleteDirectory(s3.filer, dir); err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listObjectsHandler serves both ListObjects and ListObjectsV2
+func (s3 *S3Server) listObjectsHandler(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	query := r.URL.Query()
+	isV2 := query.Get("list-type") == "2"
+	maxKeys := 1000
+	if s := query.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeS3Error(w, r, ErrS3InvalidArgument)
+			return
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+	ret := s3ListBucketResult{
+		Name:      bucket,
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+		MaxKeys:   maxKeys,
+	}
+	marker := query.Get("marker")
+	if isV2 {
+		ret.StartAfter = query.Get("start-after")
+		ret.ContinuationToken = query.Get("continuation-token")
+		marker = ret.StartAfter
+		if ret.ContinuationToken != "" {
+			marker = ret.ContinuationToken
+		}
+	} else {
+		ret.Marker = marker
+	}
+
+	entries, truncated, err := listS3Keys(s3.filer, s3.bucketDir(bucket), ret.Prefix, ret.Delimiter, marker, maxKeys)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	ret.IsTruncated = truncated
+	if truncated {
+		last := entries[len(entries)-1].Key
+		if isV2 {
+			ret.NextContinuationToken = last
+		} else {
+			ret.NextMarker = last
+		}
+	}
+	if isV2 {
+		ret.KeyCount = len(entries)
+	}
+
+	for _, entry := range entries {
+		if entry.File == nil {
+			ret.CommonPrefixes = append(ret.CommonPrefixes, s3CommonPrefix{Prefix: entry.Key})
+			continue
+		}
+		ret.Contents = append(ret.Contents, s3Object{
+			Key:          entry.Key,
+			LastModified: s3Time(entry.File.Mtime),
+			ETag:         s3ETag(entry.File.Fids()...),
+			Size:         int64(entry.File.Size),
+			StorageClass: "STANDARD",
+		})
+	}
+	writeS3Xml(w, http.StatusOK, ret)
+}
+
+// s3ListEntry is either an object, or a common prefix if File is nil
+type s3ListEntry struct {
+	Key  string
+	File *filer.FileEntry
+}
+
+// listS3Keys walks the directory tree under dir in the order of the object keys,
+// and returns at most maxKeys objects or common prefixes after marker.
+func listS3Keys(filerServer string, dir, prefix, delimiter, marker string, maxKeys int) (entries []s3ListEntry, truncated bool, err error) {
+	if maxKeys <= 0 {
+		return nil, false, nil
+	}
+	l := &s3KeyLister{
+		filer:     filerServer,
+		prefix:    prefix,
+		delimiter: delimiter,
+		marker:    marker,
+		maxKeys:   maxKeys,
+	}
+	_, err = l.walk(dir, "")
+	return l.entries, l.truncated, err
+}
+
+type s3KeyLister struct {
+	filer     string
+	prefix    string
+	delimiter string
+	marker    string
+	maxKeys   int
+	entries   []s3ListEntry
+	truncated bool
+}
+
+func (l *s3KeyLister) walk(dirPath, keyBase string) (stop bool, err error) {
+	listedDirs, err := filer.ListDirectories(l.filer, dirPath)
+	if err != nil {
+		return true, err
+	}
+	dirs := listedDirs.Directories
+	sort.Sort(directoriesByName(dirs))
+	listedFiles, err := filer.ListFilesAfter(l.filer, dirPath, "", 1000)
+	if err != nil {
+		return true, err
+	}
+	files := listedFiles.Files
+	for len(dirs) > 0 || len(files) > 0 {
+		if len(dirs) == 0 || len(files) > 0 && files[0].Name < dirs[0].Name+"/" {
+			file := files[0]
+			if files = files[1:]; len(files) == 0 {
+				if listedFiles, err = filer.ListFilesAfter(l.filer, dirPath, file.Name, 1000); err != nil {
+					return true, err
+				}
+				files = listedFiles.Files
+			}
+			if l.add(keyBase+file.Name, &file) {
+				return true, nil
+			}
+			continue
+		}
+		dir := dirs[0]
+		dirs = dirs[1:]
+		dirKey := keyBase + dir.Name + "/"
+		if !strings.HasPrefix(dirKey, l.prefix) && !strings.HasPrefix(l.prefix, dirKey) {
+			continue
+		}
+		if dirKey < l.marker && !strings.HasPrefix(l.marker, dirKey) {
+			continue
+		}
+		if l.delimiter != "" && strings.HasPrefix(dirKey, l.prefix) {
+			if i := strings.Index(dirKey[len(l.prefix):], l.delimiter); i >= 0 {
+				// all keys under this directory roll up into the same common prefix
+				if l.add(dirKey[:len(l.prefix)+i+len(l.delimiter)], nil) {
+					return true, nil
+				}
+				continue
+			}
+		}
+		if stop, err = l.walk(dirPath+dir.Name+"/", dirKey); stop {
+			return
+		}
+	}
+	return false, nil
+}
+
+// add appends an object, or a common prefix when file is nil, and returns true to stop the walk
+func (l *s3KeyLister) add(key string, file *filer.FileEntry) bool {
+	if !strings.HasPrefix(key, l.prefix) {
+		return false
+	}
+	if file != nil && l.delimiter != "" {
+		if i := strings.Index(key[len(l.prefix):], l.delimiter); i >= 0 {
+			key, file = key[:len(l.prefix)+i+len(l.delimiter)], nil
+		}
+	}
+	if key <= l.marker {
+		return false
+	}
+	if n := len(l.entries); n > 0 && l.entries[n-1].Key == key {
+		return false
+	}
+	if len(l.entries) == l.maxKeys {
+		l.truncated = true
+		return true
+	}
+	l.entries = append(l.entries, s3ListEntry{Key: key, File: file})
+	return false
+}
+
+type directoriesByName []filer.DirectoryEntry
+
+func (s directoriesByName) Len() int           { return len(s) }
+func (s directoriesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s directoriesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
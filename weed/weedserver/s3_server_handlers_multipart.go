@@ -0,0 +1,213 @@
+package weedserver
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+)
+
+const (
+	s3MaxPartNumber = 10000
+	// the list of all the parts is well below
+	s3MaxCompleteMultipartUploadSize = 4 << 20
+)
+
+type s3InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadId string   `xml:"UploadId"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	Parts []s3CompletedPart `xml:"Part"`
+}
+
+type s3CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// s3UploadId is the id of the resumable upload of the filer, which keeps the parts until
+// the upload is completed, aborted or abandoned for longer than the upload timeout of the filer
+func s3UploadId(r *http.Request) string {
+	return r.URL.Query().Get("uploadId")
+}
+
+func (s3 *S3Server) initiateMultipartUploadHandler(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	if !isValidObjectKey(key) || strings.HasSuffix(key, "/") {
+		writeS3Error(w, r, ErrS3InvalidObjectKey)
+		return
+	}
+	query := url.Values{"uploads": {""}, "collection": {bucket}, "replication": {s3.defaultReplication}}
+	request, err := s3.newFilerRequest("POST", s3.objectPath(bucket, key), query, nil, 0)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	var ret struct {
+		UploadId string `json:"uploadId"`
+	}
+	if _, err = filerCall(request, &ret); err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	glog.V(2).Infof("initiate multipart upload %s for %s/%s", ret.UploadId, bucket, key)
+	writeS3Xml(w, http.StatusOK, s3InitiateMultipartUploadResult{Bucket: bucket, Key: key, UploadId: ret.UploadId})
+}
+
+func (s3 *S3Server) uploadPartHandler(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	uploadId := s3UploadId(r)
+	if uploadId == "" || !isValidObjectKey(key) {
+		writeS3Error(w, r, ErrS3NoSuchUpload)
+		return
+	}
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > s3MaxPartNumber {
+		writeS3Error(w, r, ErrS3InvalidArgument)
+		return
+	}
+	if r.ContentLength < 0 {
+		writeS3Error(w, r, ErrS3MissingContentLength)
+		return
+	}
+	// the part is stored as one plain needle, since the parts are used as the chunks of the object as is
+	query := url.Values{"uploadId": {uploadId}, "partNumber": {strconv.Itoa(partNumber)}}
+	request, err := s3.newFilerRequest("PUT", s3.objectPath(bucket, key), query, r.Body, r.ContentLength)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	var ret struct {
+		Fid string `json:"fid"`
+	}
+	if status, err := filerCall(request, &ret); status == http.StatusNotFound {
+		writeS3Error(w, r, ErrS3NoSuchUpload)
+		return
+	} else if err != nil {
+		writeS3UploadError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", s3ETag(ret.Fid))
+	w.WriteHeader(http.StatusOK)
+}
+
+// completeMultipartUploadHandler joins the listed parts with a chunk manifest, by the filer,
+// so the parts themselves become the chunks of the object, and the other parts are deleted
+func (s3 *S3Server) completeMultipartUploadHandler(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	uploadId := s3UploadId(r)
+	if uploadId == "" || !isValidObjectKey(key) {
+		writeS3Error(w, r, ErrS3NoSuchUpload)
+		return
+	}
+	// read the body to its end, so its signed hash is verified
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, s3MaxCompleteMultipartUploadSize))
+	if err != nil {
+		writeS3UploadError(w, r, err)
+		return
+	}
+	var req s3CompleteMultipartUpload
+	if err = xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		writeS3Error(w, r, ErrS3MalformedXML)
+		return
+	}
+	objectPath := s3.objectPath(bucket, key)
+	request, err := s3.newFilerRequest("GET", objectPath, url.Values{"uploadId": {uploadId}}, nil, 0)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	var listed struct {
+		Collection string            `json:"collection"`
+		Parts      []filer.FileEntry `json:"parts"`
+	}
+	if status, err := filerCall(request, &listed); status == http.StatusNotFound || err == nil && listed.Collection != bucket {
+		writeS3Error(w, r, ErrS3NoSuchUpload)
+		return
+	} else if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	uploaded := make(map[string]filer.FileEntry)
+	for _, part := range listed.Parts {
+		uploaded[part.Name] = part
+	}
+	var partNumbers []string
+	for i, part := range req.Parts {
+		if i > 0 && part.PartNumber <= req.Parts[i-1].PartNumber {
+			writeS3Error(w, r, ErrS3InvalidPartOrder)
+			return
+		}
+		entry, found := uploaded[strconv.Itoa(part.PartNumber)]
+		if !found || part.ETag != "" && strings.Trim(part.ETag, "\"") != strings.Trim(s3ETag(string(entry.Id)), "\"") {
+			writeS3Error(w, r, ErrS3InvalidPart)
+			return
+		}
+		partNumbers = append(partNumbers, strconv.Itoa(part.PartNumber))
+	}
+
+	form := url.Values{"parts": {strings.Join(partNumbers, ",")}}
+	request, err = s3.newFilerRequest("POST", objectPath, url.Values{"uploadId": {uploadId}},
+		strings.NewReader(form.Encode()), int64(len(form.Encode())))
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var ret operation.UploadResult
+	if status, err := filerCall(request, &ret); status == http.StatusNotFound {
+		writeS3Error(w, r, ErrS3NoSuchUpload)
+		return
+	} else if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	writeS3Xml(w, http.StatusOK, s3CompleteMultipartUploadResult{
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     s3ETag(ret.Fid),
+	})
+}
+
+// abortMultipartUploadHandler makes the filer delete the uploaded parts together with the upload
+func (s3 *S3Server) abortMultipartUploadHandler(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	uploadId := s3UploadId(r)
+	if uploadId == "" || !isValidObjectKey(key) {
+		writeS3Error(w, r, ErrS3NoSuchUpload)
+		return
+	}
+	request, err := s3.newFilerRequest("DELETE", s3.objectPath(bucket, key), url.Values{"uploadId": {uploadId}}, nil, 0)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	if status, err := filerCall(request, nil); status == http.StatusNotFound {
+		writeS3Error(w, r, ErrS3NoSuchUpload)
+		return
+	} else if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
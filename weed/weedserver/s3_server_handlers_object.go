@@ -0,0 +1,186 @@
+package weedserver
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func (s3 *S3Server) putObjectHandler(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	if !isValidObjectKey(key) {
+		writeS3Error(w, r, ErrS3InvalidObjectKey)
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		// "folder" objects are just directories in the filer
+		if err := filer.CreateDirectory(s3.filer, s3.objectPath(bucket, key)); err != nil {
+			writeS3InternalError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.ContentLength < 0 {
+		writeS3Error(w, r, ErrS3MissingContentLength)
+		return
+	}
+	mimeType := r.Header.Get("Content-Type")
+	if mimeType == "binary/octet-stream" {
+		mimeType = ""
+	}
+	body, contentType, size, err := multipartUpload(path.Base(key), mimeType, r.Body, r.ContentLength)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	// the filer splits the large objects into chunks, and replaces the previous object once saved
+	query := url.Values{"collection": {bucket}, "replication": {s3.defaultReplication}}
+	request, err := s3.newFilerRequest("PUT", s3.objectPath(bucket, key), query, body, size)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	request.Header.Set("Content-Type", contentType)
+	var ret operation.UploadResult
+	if _, err = filerCall(request, &ret); err != nil {
+		writeS3UploadError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", s3ETag(ret.Fid))
+	w.WriteHeader(http.StatusOK)
+}
+
+// multipartUpload wraps the content into a multipart form with one file part, of a known length,
+// so the filer can tell the small uploads from the ones to split into chunks. The content is
+// stored as is, so the size in the filer is the size of the object, and the ranges are cheap.
+func multipartUpload(fileName, mimeType string, content io.Reader, size int64) (body io.Reader, contentType string, length int64, err error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": "file", "filename": fileName}))
+	header.Set("Content-Encoding", "identity")
+	if mimeType != "" {
+		header.Set("Content-Type", mimeType)
+	}
+	if _, err = writer.CreatePart(header); err != nil {
+		return nil, "", 0, err
+	}
+	head := append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err = writer.Close(); err != nil {
+		return nil, "", 0, err
+	}
+	tail := buf.Bytes()
+	body = io.MultiReader(bytes.NewReader(head), io.LimitReader(content, size), bytes.NewReader(tail))
+	return body, writer.FormDataContentType(), int64(len(head)) + size + int64(len(tail)), nil
+}
+
+func (s3 *S3Server) getOrHeadObjectHandler(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	if !isValidObjectKey(key) || strings.HasSuffix(key, "/") {
+		writeS3Error(w, r, ErrS3NoSuchKey)
+		return
+	}
+	entry, err := s3.findObject(bucket, key)
+	if err != nil {
+		glog.V(2).Infof("find %s/%s: %v", bucket, key, err)
+		writeS3Error(w, r, ErrS3NoSuchKey)
+		return
+	}
+	etag, lastModified := s3ETag(entry.Fids()...), entry.Mtime.UTC().Format(http.TimeFormat)
+	if isS3NotModified(r, etag, entry.Mtime) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	request, err := s3.newFilerRequest(r.Method, s3.objectPath(bucket, key), url.Values{"collection": {bucket}}, nil, 0)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	if v := r.Header.Get("Range"); v != "" {
+		request.Header.Set("Range", v)
+	}
+	// the filer passes the token on to the volume servers, which check it if the reads are secured
+	if jwt := s3.readJwt(bucket); jwt != "" {
+		request.Header.Set("Authorization", "BEARER "+string(jwt))
+	}
+	// ranges are on the original content, so never let the volume server send it gzipped
+	request.Header.Set("Accept-Encoding", "identity")
+	resp, err := util.HttpDo(request)
+	if err != nil {
+		writeS3InternalError(w, r, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		writeS3Error(w, r, ErrS3NoSuchKey)
+		return
+	}
+	for _, h := range []string{"Content-Length", "Content-Type", "Content-Range", "Accept-Ranges"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified)
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == "GET" {
+		io.Copy(w, resp.Body)
+	}
+}
+
+// isS3NotModified checks the conditional headers against the object, which is not sent if not modified
+func isS3NotModified(r *http.Request, etag string, mtime time.Time) bool {
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		return v == "*" || strings.Contains(v, etag)
+	}
+	if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		return !mtime.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+func (s3 *S3Server) deleteObjectHandler(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if !s3.hasBucket(bucket) {
+		writeS3Error(w, r, ErrS3NoSuchBucket)
+		return
+	}
+	if !isValidObjectKey(key) {
+		// deleting a key which can not exist is a success in S3
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// the filer deletes the content, or moves it into the trash
+	objectPath := s3.objectPath(bucket, key)
+	var err error
+	if strings.HasSuffix(key, "/") {
+		err = filer.DeleteDirectory(s3.filer, objectPath)
+	} else {
+		err = filer.DeleteFileEntry(s3.filer, path.Dir(objectPath), path.Base(objectPath))
+	}
+	if err != nil {
+		glog.V(1).Infof("delete %s/%s: %v", bucket, key, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,368 @@
+package weedserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/filer/embedded_filer"
+	"github.com/chrislusf/seaweedfs/weed/security"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+func TestListS3Keys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := embedded_filer.NewFilerEmbedded("127.0.0.1:1", dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: "127.0.0.1:1", filer: f}
+	ts := httptest.NewServer(http.HandlerFunc(fs.apiHandler))
+	defer ts.Close()
+	for _, key := range []string{"a.txt", "a/b/c.txt", "a/b/d.txt", "a/e.txt", "a-f.txt", "b.txt"} {
+		if err = f.CreateFileEntry("/buckets/test/"+key, &filer.FileEntry{Id: "1,01637037d6"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys := func(entries []s3ListEntry) (ret []string) {
+		for _, e := range entries {
+			ret = append(ret, e.Key)
+		}
+		return
+	}
+	cases := []struct {
+		prefix, delimiter, marker string
+		maxKeys                   int
+		expected                  []string
+		truncated                 bool
+	}{
+		{"", "", "", 1000, []string{"a-f.txt", "a.txt", "a/b/c.txt", "a/b/d.txt", "a/e.txt", "b.txt"}, false},
+		{"", "/", "", 1000, []string{"a-f.txt", "a.txt", "a/", "b.txt"}, false},
+		{"a/", "/", "", 1000, []string{"a/b/", "a/e.txt"}, false},
+		{"a/b", "", "", 1000, []string{"a/b/c.txt", "a/b/d.txt"}, false},
+		{"", "", "a/b/c.txt", 2, []string{"a/b/d.txt", "a/e.txt"}, true},
+		{"", "/", "a/", 1000, []string{"b.txt"}, false},
+		{"", "/", "", 2, []string{"a-f.txt", "a.txt"}, true},
+	}
+	for _, c := range cases {
+		entries, truncated, err := listS3Keys(strings.TrimPrefix(ts.URL, "http://"), "/buckets/test/", c.prefix, c.delimiter, c.marker, c.maxKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(keys(entries), c.expected) || truncated != c.truncated {
+			t.Errorf("prefix %q delimiter %q marker %q: got %v %v, expected %v %v",
+				c.prefix, c.delimiter, c.marker, keys(entries), truncated, c.expected, c.truncated)
+		}
+	}
+}
+
+func TestS3Names(t *testing.T) {
+	for _, name := range []string{"abc", "my-bucket.01"} {
+		if !isValidBucketName(name) {
+			t.Errorf("bucket name %s should be valid", name)
+		}
+	}
+	for _, name := range []string{"ab", ".uploads", "My-Bucket", "a_b"} {
+		if isValidBucketName(name) {
+			t.Errorf("bucket name %s should be invalid", name)
+		}
+	}
+	for _, key := range []string{"a", "a/b.txt", "a/b/"} {
+		if !isValidObjectKey(key) {
+			t.Errorf("object key %s should be valid", key)
+		}
+	}
+	for _, key := range []string{"", "a//b", "a/../b", "/a"} {
+		if isValidObjectKey(key) {
+			t.Errorf("object key %s should be invalid", key)
+		}
+	}
+}
+
+func TestS3Authorize(t *testing.T) {
+	// the master derives the signing keys of the access key "akid", of the secret "s1"
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/key/signingKey" || r.FormValue("id") != "akid" {
+			writeJsonError(w, r, http.StatusNotFound, fmt.Errorf("access key %s is not found", r.FormValue("id")))
+			return
+		}
+		writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+			"signingKey": hex.EncodeToString(security.SigV4SigningKey("s1", r.FormValue("date"), r.FormValue("region"), r.FormValue("service"))),
+			"scope":      security.Scope{Collections: []string{"pics"}, Actions: []security.Action{security.ActionRead, security.ActionWrite}},
+		})
+	}))
+	defer master.Close()
+	s3 := &S3Server{
+		master:      strings.TrimPrefix(master.URL, "http://"),
+		secret:      "secret",
+		guard:       security.NewGuard(nil, "secret"),
+		signingKeys: newS3SigningKeys(),
+	}
+	token, _ := security.GenScopedJwt("secret", "a", security.Scope{Collections: []string{"pics"}, Actions: []security.Action{security.ActionRead}}, time.Minute)
+	cases := []struct {
+		method, path string
+		token        security.EncodedJwt
+		allowed      bool
+	}{
+		{"GET", "/pics/a.jpg", "", false},
+		{"GET", "/pics/a.jpg", token, true},
+		{"HEAD", "/pics", token, true},
+		{"GET", "/logs/a.txt", token, false},
+		{"PUT", "/pics/a.jpg", token, false},
+		{"DELETE", "/pics/a.jpg", token, false},
+		{"GET", "/", token, false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		if c.token != "" {
+			r.Header.Set("Authorization", "BEARER "+string(c.token))
+		}
+		bucket, _ := parseS3Path(r.URL.Path)
+		if err := s3.authorize(r, bucket); (err == nil) != c.allowed {
+			t.Errorf("%s %s allowed %v, got %v", c.method, c.path, c.allowed, err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s3.s3Handler(w, httptest.NewRequest("GET", "/pics/a.jpg", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("the request without token got %d", w.Code)
+	}
+
+	signed := []struct {
+		method, path, id, secret string
+		allowed                  bool
+	}{
+		{"GET", "/pics/a%20b.jpg", "akid", "s1", true},
+		{"PUT", "/pics/a.jpg?partNumber=1&uploadId=x", "akid", "s1", true},
+		{"DELETE", "/pics/a.jpg", "akid", "s1", false},
+		{"GET", "/logs/a.txt", "akid", "s1", false},
+		{"GET", "/pics/a.jpg", "akid", "s2", false},
+		{"GET", "/pics/a.jpg", "other", "s1", false},
+	}
+	for _, c := range signed {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		signS3Request(r, c.id, c.secret, hex.EncodeToString(sha256Sum(nil)), time.Now())
+		bucket, _ := parseS3Path(r.URL.Path)
+		if err := s3.authorize(r, bucket); (err == nil) != c.allowed {
+			t.Errorf("signed %s %s by %s allowed %v, got %v", c.method, c.path, c.id, c.allowed, err)
+		}
+	}
+
+	// the signed hash of the body is verified when reading it
+	body := "<CompleteMultipartUpload></CompleteMultipartUpload>"
+	r := httptest.NewRequest("POST", "/pics/a.jpg?uploadId=x", strings.NewReader(body))
+	signS3Request(r, "akid", "s1", hex.EncodeToString(sha256Sum([]byte("other"))), time.Now())
+	if err := s3.authorize(r, "pics"); err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if _, err := ioutil.ReadAll(r.Body); err != ErrS3ContentSHA256Mismatch {
+		t.Errorf("expect the payload mismatch, got %v", err)
+	}
+	r = httptest.NewRequest("POST", "/pics/a.jpg?uploadId=x", strings.NewReader(body))
+	signS3Request(r, "akid", "s1", hex.EncodeToString(sha256Sum([]byte(body))), time.Now())
+	if err := s3.authorize(r, "pics"); err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if content, err := ioutil.ReadAll(r.Body); err != nil || string(content) != body {
+		t.Errorf("read the signed body: %s %v", content, err)
+	}
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// signS3Request signs the request with AWS Signature Version 4 in the Authorization header
+func signS3Request(r *http.Request, id, secret, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	query := r.URL.Query()
+	var pairs []string
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		strings.Replace(url.PathEscape(r.URL.Path), "%2F", "/", -1),
+		strings.Join(pairs, "&"),
+		"host:" + r.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := amzDate[:8] + "/us-east-1/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sha256Sum([]byte(canonicalRequest)))
+	mac := hmac.New(sha256.New, security.SigV4SigningKey(secret, amzDate[:8], "us-east-1", "s3"))
+	mac.Write([]byte(stringToSign))
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+id+"/"+scope+
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestS3Gateway(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the server is both the master and the volume server
+	var lock sync.Mutex
+	stored := make(map[string][]byte)
+	fileKey := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/dir/assign":
+			fileKey++
+			fmt.Fprintf(w, `{"fid":"11,%02x637037d6","url":"%s","count":1}`, fileKey, r.Host)
+		case r.URL.Path == "/dedup/release":
+			w.Write([]byte(`{"delete":["` + strings.Join(r.Form["fid"], `","`) + `"]}`))
+		case r.URL.Path == "/dir/lookup", r.URL.Path == "/vol/lookup":
+			w.Write([]byte(`{"11":{"volumeId":"11","locations":[{"url":"` + r.Host + `"}]}}`))
+		case r.URL.Path == "/delete":
+			for _, fid := range r.Form["fid"] {
+				delete(stored, fid)
+			}
+			w.Write([]byte(`[]`))
+		case (r.Method == "POST" || r.Method == "PUT") && strings.HasPrefix(r.URL.Path, "/11,"):
+			fileName, data, _, _, _, _, _, err := storage.ParseUpload(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stored[r.URL.Path[1:]] = data
+			fmt.Fprintf(w, `{"name":"%s","size":%d}`, fileName, len(data))
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/11,"):
+			if data, ok := stored[r.URL.Path[1:]]; ok {
+				w.Write(data)
+				return
+			}
+			http.NotFound(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	master := strings.TrimPrefix(server.URL, "http://")
+	f, err := embedded_filer.NewFilerEmbedded(master, dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FilerServer{master: master, filer: f, uploads: filer.NewUploadSessions(f)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__api__", fs.apiHandler)
+	mux.HandleFunc("/", fs.filerHandler)
+	filerServer := httptest.NewServer(mux)
+	defer filerServer.Close()
+	s3, err := NewS3Server(http.NewServeMux(), strings.TrimPrefix(filerServer.URL, "http://"), "", "/buckets", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, url string, body string, expected int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s3.s3Handler(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		if w.Code != expected {
+			t.Fatalf("%s %s: status %d instead of %d: %s", method, url, w.Code, expected, w.Body.String())
+		}
+		return w
+	}
+	count := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(stored)
+	}
+
+	do("PUT", "/pics", "", http.StatusOK)
+	// the concurrent writes of the same key replace each other exactly once
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			s3.s3Handler(w, httptest.NewRequest("PUT", "/pics/a/b.txt", strings.NewReader(fmt.Sprint("first ", i))))
+			if w.Code != http.StatusOK {
+				t.Errorf("concurrent put %d: status %d", i, w.Code)
+			}
+		}(i)
+	}
+	wg.Wait()
+	etag := do("PUT", "/pics/a/b.txt", "second", http.StatusOK).Header().Get("ETag")
+	if count() != 1 {
+		t.Errorf("the replaced object is not deleted: %d files are stored", count())
+	}
+	w := do("GET", "/pics/a/b.txt", "", http.StatusOK)
+	if w.Body.String() != "second" || w.Header().Get("ETag") != etag {
+		t.Errorf("unexpected object %q with etag %s instead of %s", w.Body.String(), w.Header().Get("ETag"), etag)
+	}
+	w = do("GET", "/pics?list-type=2&prefix=a/", "", http.StatusOK)
+	if !strings.Contains(w.Body.String(), "<Key>a/b.txt</Key>") || !strings.Contains(w.Body.String(), "<Size>6</Size>") {
+		t.Errorf("unexpected list %s", w.Body.String())
+	}
+	if entry, err := f.FindFileEntry("/buckets/pics/a/b.txt"); err != nil || entry.Collection != "pics" {
+		t.Errorf("the object is not a file of the bucket collection in the filer: %+v %v", entry, err)
+	}
+
+	// the parts not completed, or of the aborted uploads, are deleted with their entries
+	var initiated s3InitiateMultipartUploadResult
+	xml.Unmarshal(do("POST", "/pics/big.bin?uploads", "", http.StatusOK).Body.Bytes(), &initiated)
+	var etags []string
+	for i, part := range []string{"one", "two", "three"} {
+		url := fmt.Sprintf("/pics/big.bin?uploadId=%s&partNumber=%d", initiated.UploadId, i+1)
+		etags = append(etags, do("PUT", url, part, http.StatusOK).Header().Get("ETag"))
+	}
+	do("POST", "/pics/big.bin?uploadId="+initiated.UploadId, "<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>"+
+		etags[1]+"</ETag></Part></CompleteMultipartUpload>", http.StatusBadRequest)
+	do("POST", "/pics/big.bin?uploadId="+initiated.UploadId, "<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>"+
+		etags[0]+"</ETag></Part><Part><PartNumber>3</PartNumber><ETag>"+etags[2]+"</ETag></Part></CompleteMultipartUpload>", http.StatusOK)
+	// the object is the manifest of the parts 1 and 3
+	if count() != 1+2+1 {
+		t.Errorf("the unused part is not deleted: %d files are stored", count())
+	}
+	do("GET", "/pics/big.bin?uploadId="+initiated.UploadId, "", http.StatusNotImplemented)
+	do("DELETE", "/pics/big.bin?uploadId="+initiated.UploadId, "", http.StatusNotFound)
+
+	xml.Unmarshal(do("POST", "/pics/c.bin?uploads", "", http.StatusOK).Body.Bytes(), &initiated)
+	do("PUT", "/pics/c.bin?uploadId="+initiated.UploadId+"&partNumber=1", "lost", http.StatusOK)
+	do("DELETE", "/pics/c.bin?uploadId="+initiated.UploadId, "", http.StatusNoContent)
+	do("PUT", "/pics/c.bin?uploadId="+initiated.UploadId+"&partNumber=2", "lost", http.StatusNotFound)
+	if count() != 1+2+1 {
+		t.Errorf("the parts of the aborted upload are not deleted: %d files are stored", count())
+	}
+	if _, err = f.FindDirectory(filer.UploadsDir + "/" + initiated.UploadId); err == nil {
+		t.Errorf("the entries of the aborted upload are left")
+	}
+
+	do("DELETE", "/pics", "", http.StatusConflict)
+	do("DELETE", "/pics/a/b.txt", "", http.StatusNoContent)
+	do("DELETE", "/pics/big.bin", "", http.StatusNoContent)
+	do("DELETE", "/pics/a/", "", http.StatusNoContent)
+	do("DELETE", "/pics", "", http.StatusNoContent)
+	do("HEAD", "/pics", "", http.StatusNotFound)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
 	"github.com/chrislusf/seaweedfs/weed/weedpb"
 )
 
@@ -17,6 +18,7 @@ type VolumeServer struct {
 	pulseSeconds int
 	store        *storage.Store
 	guard        *security.Guard
+	imageCache   *imageCache
 
 	FixJpgOrientation bool
 	ReadRedirect      bool
@@ -29,50 +31,71 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	needleMapKind storage.NeedleMapType,
 	masterNode string, pulseSeconds int,
 	dataCenter string, rack string,
-	whiteList []string,
-	fixJpgOrientation bool,
-	readRedirect, readRemoteNeedle bool) *VolumeServer {
+	whiteList []string, secretKey string,
+	fixJpgOrientation bool, imageCacheMB int,
+	readRedirect, readRemoteNeedle bool,
+	keys storage.KeyProvider) *VolumeServer {
 	vs := &VolumeServer{
 		pulseSeconds:      pulseSeconds,
 		FixJpgOrientation: fixJpgOrientation,
 		ReadRedirect:      readRedirect,
 		ReadRemoteNeedle:  readRemoteNeedle,
 	}
+	if imageCacheMB > 0 {
+		vs.imageCache = newImageCache(imageCacheMB << 20)
+	}
 	vs.store = storage.NewStore(port, ip, publicUrl, folders, maxCounts, needleMapKind)
 	vs.store.SetBootstrapMaster(masterNode)
 	vs.store.SetDataCenter(dataCenter)
 	vs.store.SetRack(rack)
+	if keys != nil {
+		vs.store.SetKeyProvider(keys)
+	}
 
-	vs.guard = security.NewGuard(whiteList, "")
+	vs.guard = security.NewGuard(whiteList, secretKey)
+	if secretKey != "" {
+		util.SetInternalJwt(vs.guard.InternalJwt)
+	}
 
 	adminMux.HandleFunc("/ui/index.html", vs.uiStatusHandler)
 	adminMux.HandleFunc("/status", vs.guard.WhiteList(vs.statusHandler))
-	adminMux.HandleFunc("/admin/assign_volume", vs.guard.WhiteList(vs.assignVolumeHandler))
-	adminMux.HandleFunc("/admin/vacuum/check", vs.guard.WhiteList(vs.vacuumVolumeCheckHandler))
-	adminMux.HandleFunc("/admin/vacuum/compact", vs.guard.WhiteList(vs.vacuumVolumeCompactHandler))
-	adminMux.HandleFunc("/admin/vacuum/commit", vs.guard.WhiteList(vs.vacuumVolumeCommitHandler))
-	adminMux.HandleFunc("/admin/setting", vs.guard.WhiteList(vs.setVolumeOptionHandler))
-	adminMux.HandleFunc("/admin/delete_collection", vs.guard.WhiteList(vs.deleteCollectionHandler))
-	adminMux.HandleFunc("/admin/sync/status", vs.guard.WhiteList(vs.getVolumeSyncStatusHandler))
-	adminMux.HandleFunc("/admin/sync/index", vs.guard.WhiteList(vs.getVolumeIndexContentHandler))
-	adminMux.HandleFunc("/admin/sync/data", vs.guard.WhiteList(vs.getVolumeDataContentHandler))
-	adminMux.HandleFunc("/admin/sync/vol_data", vs.guard.WhiteList(vs.getVolumeRawDataHandler))
-	adminMux.HandleFunc("/admin/sync/needle", vs.guard.WhiteList(vs.getNeedleHandler))
-	adminMux.HandleFunc("/admin/task/new", vs.guard.WhiteList(vs.newTaskHandler))
-	adminMux.HandleFunc("/admin/task/query", vs.guard.WhiteList(vs.queryTaskHandler))
-	adminMux.HandleFunc("/admin/task/commit", vs.guard.WhiteList(vs.commitTaskHandler))
-	adminMux.HandleFunc("/admin/task/clean", vs.guard.WhiteList(vs.cleanTaskHandler))
-	adminMux.HandleFunc("/admin/task/all", vs.guard.WhiteList(vs.allTaskHandler))
+	adminMux.HandleFunc("/admin/assign_volume", vs.guard.AdminAll(vs.assignVolumeHandler))
+	adminMux.HandleFunc("/admin/vacuum/check", vs.guard.AdminAll(vs.vacuumVolumeCheckHandler))
+	adminMux.HandleFunc("/admin/vacuum/compact", vs.guard.AdminAll(vs.vacuumVolumeCompactHandler))
+	adminMux.HandleFunc("/admin/vacuum/commit", vs.guard.AdminAll(vs.vacuumVolumeCommitHandler))
+	adminMux.HandleFunc("/admin/setting", vs.guard.AdminAll(vs.setVolumeOptionHandler))
+	adminMux.HandleFunc("/admin/trash/list", vs.guard.AdminAll(vs.trashListHandler))
+	adminMux.HandleFunc("/admin/trash/undelete", vs.guard.AdminAll(vs.trashUndeleteHandler))
+	adminMux.HandleFunc("/admin/snapshot/create", vs.guard.AdminAll(vs.snapshotCreateHandler))
+	adminMux.HandleFunc("/admin/snapshot/list", vs.guard.AdminAll(vs.snapshotListHandler))
+	adminMux.HandleFunc("/admin/snapshot/release", vs.guard.AdminAll(vs.snapshotReleaseHandler))
+	adminMux.HandleFunc("/admin/snapshot/restore", vs.guard.AdminAll(vs.snapshotRestoreHandler))
+	adminMux.HandleFunc("/admin/delete_collection", vs.guard.AdminAll(vs.deleteCollectionHandler))
+	adminMux.HandleFunc("/admin/delete_volume", vs.guard.AdminAll(vs.deleteVolumeHandler))
+	adminMux.HandleFunc("/admin/sync/status", vs.guard.AdminAll(vs.getVolumeSyncStatusHandler))
+	adminMux.HandleFunc("/admin/sync/index", vs.guard.AdminAll(vs.getVolumeIndexContentHandler))
+	adminMux.HandleFunc("/admin/sync/data", vs.guard.AdminAll(vs.getVolumeDataContentHandler))
+	adminMux.HandleFunc("/admin/sync/vol_data", vs.guard.AdminAll(vs.getVolumeRawDataHandler))
+	adminMux.HandleFunc("/admin/sync/needle", vs.guard.AdminAll(vs.getNeedleHandler))
+	adminMux.HandleFunc("/admin/ec/read", vs.guard.AdminAll(vs.ecShardReadHandler))
+	adminMux.HandleFunc("/admin/ec/file", vs.guard.AdminAll(vs.ecShardFileHandler))
+	adminMux.HandleFunc("/admin/ec/delete", vs.guard.AdminAll(vs.ecShardDeleteHandler))
+	adminMux.HandleFunc("/admin/task/new", vs.guard.AdminAll(vs.newTaskHandler))
+	adminMux.HandleFunc("/admin/task/query", vs.guard.AdminAll(vs.queryTaskHandler))
+	adminMux.HandleFunc("/admin/task/commit", vs.guard.AdminAll(vs.commitTaskHandler))
+	adminMux.HandleFunc("/admin/task/clean", vs.guard.AdminAll(vs.cleanTaskHandler))
+	adminMux.HandleFunc("/admin/task/all", vs.guard.AdminAll(vs.allTaskHandler))
 	adminMux.HandleFunc("/stats/counter", vs.guard.WhiteList(statsCounterHandler))
 	adminMux.HandleFunc("/stats/memory", vs.guard.WhiteList(statsMemoryHandler))
 	adminMux.HandleFunc("/stats/disk", vs.guard.WhiteList(vs.statsDiskHandler))
+	adminMux.HandleFunc("/metrics", vs.guard.WhiteList(metricsHandler(vs.metricsCollectors()...)))
 	adminMux.HandleFunc("/debug/pprof/", vs.guard.WhiteList(pprof.Index))
 	adminMux.HandleFunc("/debug/pprof/trace", vs.guard.WhiteList(pprof.Trace))
 	adminMux.HandleFunc("/debug/pprof/profile", vs.guard.WhiteList(pprof.Profile))
 	adminMux.HandleFunc("/debug/pprof/cmdline", vs.guard.WhiteList(pprof.Cmdline))
 	adminMux.HandleFunc("/debug/pprof/symbol", vs.guard.WhiteList(pprof.Symbol))
 	adminMux.HandleFunc("/debug/pprof/{name}", vs.guard.WhiteList(pprof.Index))
-	adminMux.HandleFunc("/delete", vs.guard.WhiteList(vs.batchDeleteHandler))
+	adminMux.HandleFunc("/delete", vs.batchDeleteHandler)
 	adminMux.HandleFunc("/", vs.privateStoreHandler)
 	if publicMux != adminMux {
 		// separated admin and public port
@@ -85,7 +108,7 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 
 		for {
 			err := vs.store.SendHeartbeatToMaster(func(s *weedpb.JoinResponse) {
-				vs.guard.SetSecretKey(s.SecretKey)
+				vs.guard.SetReadSecured(s.SecureReads)
 			})
 			if err == nil {
 				if !connected {
@@ -109,6 +132,12 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	return vs
 }
 
+// SetClientCertRequired makes the admin calls and writes pass with a verified client certificate,
+// and fail without one unless in the white list or with a JWT.
+func (vs *VolumeServer) SetClientCertRequired(b bool) {
+	vs.guard.SetClientCertRequired(b)
+}
+
 func (vs *VolumeServer) GetMasterNode() string {
 	return vs.store.GetMaster()
 }
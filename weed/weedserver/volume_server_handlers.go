@@ -3,7 +3,9 @@ package weedserver
 import (
 	"net/http"
 
+	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/stats"
+	"github.com/chrislusf/seaweedfs/weed/storage"
 )
 
 /*
@@ -31,13 +33,13 @@ func (vs *VolumeServer) privateStoreHandler(w http.ResponseWriter, r *http.Reque
 		vs.GetOrHeadHandler(w, r)
 	case "DELETE":
 		stats.DeleteRequest()
-		vs.guard.WhiteList(vs.DeleteHandler)(w, r)
+		vs.DeleteHandler(w, r)
 	case "PUT":
 		stats.WriteRequest()
-		vs.guard.WhiteList(vs.PostHandler)(w, r)
+		vs.PostHandler(w, r)
 	case "POST":
 		stats.WriteRequest()
-		vs.guard.WhiteList(vs.PostHandler)(w, r)
+		vs.PostHandler(w, r)
 	}
 }
 
@@ -51,3 +53,23 @@ func (vs *VolumeServer) publicReadOnlyHandler(w http.ResponseWriter, r *http.Req
 		vs.GetOrHeadHandler(w, r)
 	}
 }
+
+// volumeCollection returns the collection of the volume on this server,
+// or "*" if it is not here, so only the tokens for all collections are allowed.
+func (vs *VolumeServer) volumeCollection(volumeId storage.VolumeId) string {
+	if v := vs.store.GetVolume(volumeId); v != nil {
+		return v.Collection
+	}
+	if ev := vs.store.GetEcVolume(volumeId); ev != nil {
+		return ev.Collection
+	}
+	return "*"
+}
+
+// authorize checks the request is allowed to do the action on the file id "vid,nid"
+func (vs *VolumeServer) authorize(r *http.Request, action security.Action, volumeId storage.VolumeId, fileId string) error {
+	if action == security.ActionRead {
+		return vs.guard.AuthorizeRead(r, vs.volumeCollection(volumeId), fileId)
+	}
+	return vs.guard.Authorize(r, action, vs.volumeCollection(volumeId), fileId)
+}
@@ -22,11 +22,12 @@ func (vs *VolumeServer) statusHandler(w http.ResponseWriter, r *http.Request) {
 	m := make(map[string]interface{})
 	m["Version"] = util.VERSION
 	m["Volumes"] = vs.store.Status()
+	m["EcVolumes"] = vs.store.EcStatus()
 	writeJsonQuiet(w, r, http.StatusOK, m)
 }
 
 func (vs *VolumeServer) assignVolumeHandler(w http.ResponseWriter, r *http.Request) {
-	err := vs.store.AddVolume(r.FormValue("volume"), r.FormValue("collection"), r.FormValue("ttl"))
+	err := vs.store.AddVolume(r.FormValue("volume"), r.FormValue("collection"), r.FormValue("ttl"), r.FormValue("tier"))
 	if err == nil {
 		writeJsonQuiet(w, r, http.StatusAccepted, map[string]string{"error": ""})
 	} else {
@@ -45,6 +46,20 @@ func (vs *VolumeServer) deleteCollectionHandler(w http.ResponseWriter, r *http.R
 	glog.V(2).Infoln("deleting collection =", r.FormValue("collection"), ", error =", err)
 }
 
+func (vs *VolumeServer) deleteVolumeHandler(w http.ResponseWriter, r *http.Request) {
+	vid, err := storage.NewVolumeId(r.FormValue("volume"))
+	if err == nil {
+		err = vs.store.DeleteVolume(vid)
+	}
+	if err == nil {
+		writeJsonQuiet(w, r, http.StatusOK, map[string]string{"error": ""})
+		vs.store.SendHeartbeatToMaster(nil)
+	} else {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+	}
+	glog.V(2).Infoln("deleting volume =", r.FormValue("volume"), ", error =", err)
+}
+
 func (vs *VolumeServer) statsDiskHandler(w http.ResponseWriter, r *http.Request) {
 	m := make(map[string]interface{})
 	m["Version"] = util.VERSION
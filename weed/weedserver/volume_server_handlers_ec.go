@@ -0,0 +1,89 @@
+package weedserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func (vs *VolumeServer) getEcVolume(volumeParameterName string, r *http.Request) (*storage.EcVolume, error) {
+	volumeIdString := r.FormValue(volumeParameterName)
+	vid, err := storage.NewVolumeId(volumeIdString)
+	if err != nil {
+		return nil, fmt.Errorf("Volume Id %s is not a valid unsigned integer", volumeIdString)
+	}
+	ev := vs.store.GetEcVolume(vid)
+	if ev == nil {
+		return nil, fmt.Errorf("Not Found Ec Volume Id %s: %d", volumeIdString, vid)
+	}
+	return ev, nil
+}
+
+// read a range of a local shard, used to read or recover needles on other data nodes
+func (vs *VolumeServer) ecShardReadHandler(w http.ResponseWriter, r *http.Request) {
+	ev, err := vs.getEcVolume("volume", r)
+	if ev == nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	shardId, err := strconv.Atoi(r.FormValue("shard"))
+	if err != nil || shardId < 0 || shardId >= storage.EcTotalShards {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("Invalid shard id %s", r.FormValue("shard")))
+		return
+	}
+	offset := int64(util.ParseUint64(r.FormValue("offset"), 0))
+	size := int64(util.ParseUint64(r.FormValue("size"), 0))
+	if size <= 0 || size > storage.EcBlockSize {
+		writeJsonError(w, r, http.StatusBadRequest, fmt.Errorf("Invalid size %s", r.FormValue("size")))
+		return
+	}
+	buf := make([]byte, size)
+	if _, err = ev.ReadShardAt(shardId, buf, offset); err != nil {
+		writeJsonError(w, r, http.StatusNotFound, err)
+		return
+	}
+	w.Write(buf)
+}
+
+// serve the shard or index file, used to copy shards to other data nodes
+func (vs *VolumeServer) ecShardFileHandler(w http.ResponseWriter, r *http.Request) {
+	ev, err := vs.getEcVolume("volume", r)
+	if ev == nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ext := r.FormValue("ext")
+	valid := ext == ".ecx"
+	for i := 0; i < storage.EcTotalShards && !valid; i++ {
+		valid = ext == storage.EcShardExt(i) && ev.HasShard(i)
+	}
+	if !valid {
+		http.Error(w, fmt.Sprintf("Invalid ec file %s", ext), http.StatusBadRequest)
+		return
+	}
+	http.ServeFile(w, r, ev.FileName()+ext)
+}
+
+func (vs *VolumeServer) ecShardDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	vid, err := storage.NewVolumeId(r.FormValue("volume"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	shardIds, err := storage.ParseEcShardIds(r.FormValue("shards"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err = vs.store.DeleteEcShards(vid, shardIds); err == nil {
+		writeJsonQuiet(w, r, http.StatusOK, map[string]string{"error": ""})
+		vs.store.SendHeartbeatToMaster(nil)
+	} else {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+	}
+	glog.V(2).Infoln("deleting ec volume =", vid, "shards =", shardIds, ", error =", err)
+}
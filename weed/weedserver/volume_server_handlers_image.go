@@ -0,0 +1,137 @@
+package weedserver
+
+import (
+	"container/list"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/images"
+)
+
+// imageRendition is a transformed image kept in the image cache
+type imageRendition struct {
+	key  string
+	data []byte
+	ext  string
+}
+
+// imageCache keeps the most recently used renditions, up to a number of bytes
+type imageCache struct {
+	sync.Mutex
+	limit, size int
+	ll          *list.List
+	items       map[string]*list.Element
+}
+
+func newImageCache(limit int) *imageCache {
+	return &imageCache{limit: limit, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *imageCache) get(key string) (*imageRendition, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*imageRendition), true
+	}
+	return nil, false
+}
+
+// add caches the rendition, evicting the least recently used ones over the limit.
+// The renditions larger than the whole cache are not kept.
+func (c *imageCache) add(rendition *imageRendition) {
+	size := len(rendition.key) + len(rendition.data)
+	if size > c.limit {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.items[rendition.key]; ok {
+		return
+	}
+	c.items[rendition.key] = c.ll.PushFront(rendition)
+	for c.size += size; c.size > c.limit; {
+		oldest := c.ll.Remove(c.ll.Back()).(*imageRendition)
+		delete(c.items, oldest.key)
+		c.size -= len(oldest.key) + len(oldest.data)
+	}
+}
+
+// imageOptions parses the image transformations of the request:
+//
+//	width=200&height=100    resize, only shrinking the image unless a mode is given
+//	mode=fit|fill|pad       how to resize to both the width and the height
+//	crop=x,y,width,height   keep only the area of the image, before the other transformations
+//	rotate=90|180|270       clockwise
+//	quality=80              the jpeg quality
+//	format=jpg|png|gif      convert the image
+//	strip=true              remove the exif metadata
+func imageOptions(r *http.Request) (o images.Options, err error) {
+	atoi := func(name string) (v int) {
+		if s := r.FormValue(name); s != "" && err == nil {
+			if v, err = strconv.Atoi(s); err != nil {
+				err = fmt.Errorf("invalid %s %q", name, s)
+			}
+		}
+		return
+	}
+	o.Width, o.Height = atoi("width"), atoi("height")
+	o.Rotate, o.Quality = atoi("rotate"), atoi("quality")
+	o.Mode = r.FormValue("mode")
+	if crop := r.FormValue("crop"); crop != "" {
+		var x, y, w, h int
+		if _, e := fmt.Sscanf(crop, "%d,%d,%d,%d", &x, &y, &w, &h); e != nil || w <= 0 || h <= 0 {
+			return o, fmt.Errorf("invalid crop %q, expecting x,y,width,height", crop)
+		}
+		o.Crop = image.Rect(x, y, x+w, y+h)
+	}
+	switch format := strings.ToLower(r.FormValue("format")); format {
+	case "":
+	case "jpg", "jpeg":
+		o.Format = ".jpg"
+	case "png", "gif":
+		o.Format = "." + format
+	default:
+		return o, errors.New("unsupported image format " + format)
+	}
+	o.StripExif = r.FormValue("strip") == "true"
+	if err == nil && (o.Width > images.MaxDimension || o.Height > images.MaxDimension ||
+		o.Crop.Dx() > images.MaxDimension || o.Crop.Dy() > images.MaxDimension) {
+		err = fmt.Errorf("the width and the height should be at most %d", images.MaxDimension)
+	}
+	return
+}
+
+// renditionEtag derives the etag of a rendition from the etag of the needle and the options,
+// as the renditions are cached by them
+func renditionEtag(etag string, o images.Options) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%s %+v", etag, o)))
+	return fmt.Sprintf("\"%x\"", sum[:8])
+}
+
+// transformImage renders the image of the needle, the renditions are cached by the fid,
+// the etag of the needle and the options
+func (vs *VolumeServer) transformImage(fid, etag, ext string, data []byte, o images.Options) ([]byte, string, error) {
+	cacheKey := fmt.Sprintf("%s %s %+v", fid, etag, o)
+	if vs.imageCache != nil {
+		if rendition, ok := vs.imageCache.get(cacheKey); ok {
+			return rendition.data, rendition.ext, nil
+		}
+	}
+	transformed, format, err := images.Transform(ext, data, o)
+	if err != nil {
+		return data, ext, err
+	}
+	glog.V(4).Infof("rendered %s with %+v: %d bytes of %s", fid, o, len(transformed), format)
+	if vs.imageCache != nil {
+		vs.imageCache.add(&imageRendition{key: cacheKey, data: transformed, ext: format})
+	}
+	return transformed, format, nil
+}
@@ -0,0 +1,63 @@
+package weedserver
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/images"
+)
+
+func TestImageOptions(t *testing.T) {
+	for query, valid := range map[string]bool{
+		"width=200&height=100&mode=fill":      true,
+		"crop=0,0,8192,100":                   true,
+		"width=100000&height=100000&mode=pad": false,
+		"width=100&height=8193":               false,
+		"crop=10,10,100,9000":                 false,
+		"crop=10,10,-5,10":                    false,
+		"width=100&format=png":                true,
+		"width=100&format=webp":               false,
+	} {
+		r, _ := http.NewRequest("GET", "http://127.0.0.1:8080/3/01637037d6.jpg?"+query, nil)
+		if _, err := imageOptions(r); (err == nil) != valid {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+}
+
+func TestImageCache(t *testing.T) {
+	c := newImageCache(100)
+	rendition := func(key string, size int) *imageRendition {
+		return &imageRendition{key: key, data: bytes.Repeat([]byte{1}, size), ext: ".jpg"}
+	}
+	c.add(rendition("a", 39))
+	c.add(rendition("b", 39))
+	c.get("a")
+	// the sizes count the keys, b is evicted as the least recently used
+	c.add(rendition("c", 20))
+	if _, ok := c.get("b"); ok {
+		t.Errorf("the least recently used rendition is kept")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Errorf("the recently used rendition is evicted")
+	}
+	if c.size != 61 || c.ll.Len() != 2 {
+		t.Errorf("%d renditions of %d bytes are cached", c.ll.Len(), c.size)
+	}
+	c.add(rendition("d", 100))
+	if _, ok := c.get("d"); ok || c.size != 61 {
+		t.Errorf("the rendition larger than the cache is kept")
+	}
+}
+
+func TestRenditionEtag(t *testing.T) {
+	small, large := images.Options{Width: 100}, images.Options{Width: 200}
+	if renditionEtag(`"0a0b0c0d"`, small) != renditionEtag(`"0a0b0c0d"`, small) {
+		t.Errorf("the etag of the same rendition should not change")
+	}
+	if renditionEtag(`"0a0b0c0d"`, small) == renditionEtag(`"0a0b0c0d"`, large) ||
+		renditionEtag(`"0a0b0c0d"`, small) == renditionEtag(`"0a0b0c0e"`, small) {
+		t.Errorf("the renditions of other options or content should have other etags")
+	}
+}
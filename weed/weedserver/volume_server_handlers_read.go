@@ -16,6 +16,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/images"
 	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/storage"
 	"github.com/chrislusf/seaweedfs/weed/util"
 )
@@ -40,8 +41,12 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if err = vs.authorize(r, security.ActionRead, volumeId, vid+","+nid); err != nil {
+		writeJsonError(w, r, http.StatusUnauthorized, err)
+		return
+	}
 	glog.V(4).Infoln("volume", volumeId, "reading", n)
-	if vs.store.HasVolume(volumeId) {
+	if vs.store.HasVolume(volumeId) || vs.store.HasEcVolume(volumeId) {
 		n, err = vs.store.ReadLocalNeedle(fid)
 		glog.V(4).Infoln("read local needle", fid, "error", err)
 		if err != nil {
@@ -86,7 +91,22 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 			}
 		}
 	}
-	etag := n.Etag()
+	needleEtag, etag := n.Etag(), n.Etag()
+	// the renditions of an image have their own etags, the chunked files are not transformed
+	imageExt := ext
+	if imageExt == "" && filename == "" && n.NameSize > 0 {
+		imageExt = path.Ext(string(n.Name))
+	}
+	var o images.Options
+	if images.IsImage(imageExt) && (!n.IsChunkedManifest() || r.FormValue("cm") == "false") {
+		if o, err = imageOptions(r); err != nil {
+			writeJsonError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if !o.IsZero() {
+			etag = renditionEtag(needleEtag, o)
+		}
+	}
 	if inm := r.Header.Get("If-None-Match"); inm == etag {
 		w.WriteHeader(http.StatusNotModified)
 		return
@@ -122,16 +142,13 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 			}
 		}
 	}
-	if ext == ".png" || ext == ".jpg" || ext == ".gif" {
-		width, height := 0, 0
-		if r.FormValue("width") != "" {
-			width, _ = strconv.Atoi(r.FormValue("width"))
-		}
-		if r.FormValue("height") != "" {
-			height, _ = strconv.Atoi(r.FormValue("height"))
-		}
-		if needleData, _, _, err = images.Resized(ext, needleData, width, height); err != nil {
-			glog.V(0).Infoln("resize image error,", err, r.URL.Path)
+	if !o.IsZero() {
+		var format string
+		if needleData, format, err = vs.transformImage(fid.String(), needleEtag, ext, needleData, o); err != nil {
+			glog.V(0).Infoln("transform image error,", err, r.URL.Path)
+		} else if format != strings.ToLower(ext) {
+			filename = strings.TrimSuffix(filename, path.Ext(filename)) + format
+			mtype = mime.TypeByExtension(format)
 		}
 	}
 
@@ -141,7 +158,7 @@ func (vs *VolumeServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (vs *VolumeServer) tryHandleChunkedFile(vid storage.VolumeId, n *storage.Needle, fileName string, w http.ResponseWriter, r *http.Request) (processed bool) {
-	if !n.IsChunkedManifest() {
+	if !n.IsChunkedManifest() || r.FormValue("cm") == "false" {
 		return false
 	}
 
@@ -167,6 +184,7 @@ func (vs *VolumeServer) tryHandleChunkedFile(vid storage.VolumeId, n *storage.Ne
 		Manifest: chunkManifest,
 		Master:   vs.GetMasterNode(),
 		Store:    vs.store,
+		Secret:   vs.guard.GetSecretKey(),
 	}
 	if v := vs.store.GetVolume(vid); v != nil {
 		chunkedFileReader.Collection = v.Collection
@@ -239,12 +257,7 @@ func writeResponseContent(filename, mimeType string, rs io.ReadSeeker, w http.Re
 		w.Header().Set("Content-Length", strconv.FormatInt(ra.length, 10))
 		w.Header().Set("Content-Range", ra.contentRange(totalSize))
 		w.WriteHeader(http.StatusPartialContent)
-		if _, e = rs.Seek(ra.start, 0); e != nil {
-			return e
-		}
-
-		_, e = io.CopyN(w, rs, ra.length)
-		return e
+		return writeRange(w, rs, ra.start, ra.length)
 	}
 	// process multiple ranges
 	for _, ra := range ranges {
@@ -266,11 +279,7 @@ func writeResponseContent(filename, mimeType string, rs io.ReadSeeker, w http.Re
 				pw.CloseWithError(e)
 				return
 			}
-			if _, e = rs.Seek(ra.start, 0); e != nil {
-				pw.CloseWithError(e)
-				return
-			}
-			if _, e = io.CopyN(part, rs, ra.length); e != nil {
+			if e = writeRange(part, rs, ra.start, ra.length); e != nil {
 				pw.CloseWithError(e)
 				return
 			}
@@ -285,3 +294,24 @@ func writeResponseContent(filename, mimeType string, rs io.ReadSeeker, w http.Re
 	_, e = io.CopyN(w, sendContent, sendSize)
 	return e
 }
+
+// rangeWriter writes a range of the content without reading past it,
+// as the chunked files do by reading only the chunks in the range
+type rangeWriter interface {
+	WriteRange(w io.Writer, offset, length int64) (int64, error)
+}
+
+func writeRange(w io.Writer, rs io.ReadSeeker, offset, length int64) error {
+	if rw, ok := rs.(rangeWriter); ok {
+		n, e := rw.WriteRange(w, offset, length)
+		if e == nil && n < length {
+			e = io.ErrUnexpectedEOF
+		}
+		return e
+	}
+	if _, e := rs.Seek(offset, 0); e != nil {
+		return e
+	}
+	_, e := io.CopyN(w, rs, length)
+	return e
+}
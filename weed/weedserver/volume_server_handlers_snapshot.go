@@ -0,0 +1,106 @@
+package weedserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+type VolumeSnapshots struct {
+	Volume     string                    `json:"volume"`
+	Collection string                    `json:"collection"`
+	Snapshots  []*storage.VolumeSnapshot `json:"snapshots"`
+}
+
+// snapshotVolumes selects the volumes by the "volume" and "collection" values,
+// or all volumes if neither is given.
+func (vs *VolumeServer) snapshotVolumes(r *http.Request) (volumes []*storage.Volume) {
+	r.ParseForm()
+	volumesSet := make(map[string]bool)
+	for _, volume := range r.Form["volume"] {
+		volumesSet[strings.TrimSpace(volume)] = true
+	}
+	collectionsSet := make(map[string]bool)
+	for _, c := range r.Form["collection"] {
+		collectionsSet[strings.TrimSpace(c)] = true
+	}
+	all := len(volumesSet) == 0 && len(collectionsSet) == 0
+	vs.store.WalkVolume(func(v *storage.Volume) error {
+		if all || collectionsSet[v.Collection] || volumesSet[v.Id.String()] {
+			volumes = append(volumes, v)
+		}
+		return nil
+	})
+	return
+}
+
+// walkSnapshotVolumes applies fn to the selected volumes, which have to be given explicitly.
+func (vs *VolumeServer) walkSnapshotVolumes(w http.ResponseWriter, r *http.Request, fn func(v *storage.Volume) error) {
+	name := r.FormValue("name")
+	if name == "" {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("snapshot name is required"))
+		return
+	}
+	if len(r.Form["volume"]) == 0 && len(r.Form["collection"]) == 0 {
+		writeJsonError(w, r, http.StatusBadRequest, errors.New("volume or collection is required"))
+		return
+	}
+	errs := []VolumeOptError{}
+	var messages []string
+	for _, v := range vs.snapshotVolumes(r) {
+		if e := fn(v); e != nil {
+			errs = append(errs, VolumeOptError{Volume: v.Id.String(), Err: e.Error()})
+			messages = append(messages, e.Error())
+		}
+	}
+	result := make(map[string]interface{})
+	if len(errs) > 0 {
+		result["error"] = strings.Join(messages, "; ")
+		result["errors"] = errs
+	}
+	writeJsonQuiet(w, r, http.StatusOK, result)
+}
+
+func (vs *VolumeServer) snapshotCreateHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	vs.walkSnapshotVolumes(w, r, func(v *storage.Volume) error {
+		_, e := v.CreateSnapshot(name)
+		return e
+	})
+}
+
+func (vs *VolumeServer) snapshotListHandler(w http.ResponseWriter, r *http.Request) {
+	ret := []VolumeSnapshots{}
+	for _, v := range vs.snapshotVolumes(r) {
+		snapshots, e := v.Snapshots()
+		if e != nil {
+			writeJsonError(w, r, http.StatusInternalServerError, e)
+			return
+		}
+		if len(snapshots) > 0 {
+			ret = append(ret, VolumeSnapshots{Volume: v.Id.String(), Collection: v.Collection, Snapshots: snapshots})
+		}
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"volumes": ret})
+}
+
+func (vs *VolumeServer) snapshotReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	vs.walkSnapshotVolumes(w, r, func(v *storage.Volume) error {
+		return v.ReleaseSnapshot(name)
+	})
+}
+
+func (vs *VolumeServer) snapshotRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	vs.walkSnapshotVolumes(w, r, func(v *storage.Volume) error {
+		e := v.RestoreSnapshot(name)
+		glog.V(0).Infoln("restore volume =", v.Id, "snapshot =", name, ", error =", e)
+		return e
+	})
+	// report the restored sizes without waiting for the next heartbeat
+	vs.store.SendHeartbeatToMaster(nil)
+}
@@ -175,5 +175,8 @@ func (vs *VolumeServer) getNeedleHandler(w http.ResponseWriter, r *http.Request)
 	if n.HasMime() && n.MimeSize > 0 {
 		w.Header().Set("Seaweed-Mime", string(n.Mime))
 	}
+	if n.HasTtl() && n.Ttl != nil {
+		w.Header().Set("Seaweed-Ttl", n.Ttl.String())
+	}
 	w.Write(n.Data)
 }
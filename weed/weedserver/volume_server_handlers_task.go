@@ -61,6 +61,5 @@ func (vs *VolumeServer) cleanTaskHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (vs *VolumeServer) allTaskHandler(w http.ResponseWriter, r *http.Request) {
-	//TODO get all task
-	glog.V(2).Infoln("TODO: get all task")
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{"tasks": vs.store.TaskManager.AllTasks()})
 }
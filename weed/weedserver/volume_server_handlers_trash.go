@@ -0,0 +1,66 @@
+package weedserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage"
+)
+
+type TrashedFile struct {
+	Fid       string `json:"fid"`
+	Size      uint32 `json:"size"`
+	DeletedAt int64  `json:"deletedAt"`
+}
+
+func (vs *VolumeServer) trashListHandler(w http.ResponseWriter, r *http.Request) {
+	vid, err := storage.NewVolumeId(r.FormValue("volume"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	v := vs.store.GetVolume(vid)
+	if v == nil {
+		writeJsonError(w, r, http.StatusNotFound, fmt.Errorf("volume %d not found", vid))
+		return
+	}
+	entries, err := v.Trash()
+	if err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	files := []TrashedFile{}
+	for _, e := range entries {
+		files = append(files, TrashedFile{
+			Fid:       storage.NewFileId(vid, e.Key, e.Cookie).String(),
+			Size:      e.Size,
+			DeletedAt: e.DeletedAt,
+		})
+	}
+	writeJsonQuiet(w, r, http.StatusOK, map[string]interface{}{
+		"volume":         vid.String(),
+		"collection":     v.Collection,
+		"trashRetention": v.TrashRetention().String(),
+		"files":          files,
+	})
+}
+
+func (vs *VolumeServer) trashUndeleteHandler(w http.ResponseWriter, r *http.Request) {
+	fid, err := storage.ParseFileId(r.FormValue("fid"))
+	if err != nil {
+		writeJsonError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	v := vs.store.GetVolume(fid.VolumeId)
+	if v == nil {
+		writeJsonError(w, r, http.StatusNotFound, fmt.Errorf("volume %d not found", fid.VolumeId))
+		return
+	}
+	if err = v.Undelete(&storage.Needle{Id: fid.Key, Cookie: fid.Cookie}); err != nil {
+		writeJsonError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	glog.V(0).Infoln("undeleted", r.FormValue("fid"))
+	writeJsonQuiet(w, r, http.StatusOK, map[string]string{"error": ""})
+}
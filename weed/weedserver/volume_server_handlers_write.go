@@ -3,10 +3,12 @@ package weedserver
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/security"
 	"github.com/chrislusf/seaweedfs/weed/storage"
 	"github.com/chrislusf/seaweedfs/weed/topology"
 )
@@ -17,22 +19,29 @@ func (vs *VolumeServer) PostHandler(w http.ResponseWriter, r *http.Request) {
 		writeJsonError(w, r, http.StatusBadRequest, e)
 		return
 	}
-	vid, _, _, _, _ := parseURLPath(r.URL.Path)
+	vid, nid, _, _, _ := parseURLPath(r.URL.Path)
 	volumeId, ve := storage.NewVolumeId(vid)
 	if ve != nil {
 		glog.V(0).Infoln("NewVolumeId error:", ve)
 		writeJsonError(w, r, http.StatusBadRequest, ve)
 		return
 	}
-	needle, ne := storage.NewNeedle(r, vs.FixJpgOrientation)
+	if err := vs.authorize(r, security.ActionWrite, volumeId, vid+","+nid); err != nil {
+		writeJsonError(w, r, http.StatusUnauthorized, err)
+		return
+	}
+	needle, data, ne := storage.NewNeedleStream(r, vs.FixJpgOrientation)
 	if ne != nil {
 		writeJsonError(w, r, http.StatusBadRequest, ne)
 		return
 	}
+	if closer, ok := data.(io.Closer); ok {
+		defer closer.Close()
+	}
 
 	ret := operation.UploadResult{}
-	size, errorStatus := topology.ReplicatedWrite(vs.GetMasterNode(),
-		vs.store, volumeId, needle, r)
+	size, errorStatus := topology.ReplicatedWriteStream(vs.GetMasterNode(),
+		vs.store, volumeId, needle, data, r, security.GenJwt(vs.guard.GetSecretKey(), vid+","+nid))
 	httpStatus := http.StatusCreated
 	if errorStatus != "" {
 		httpStatus = http.StatusInternalServerError
@@ -41,6 +50,9 @@ func (vs *VolumeServer) PostHandler(w http.ResponseWriter, r *http.Request) {
 	if needle.HasName() {
 		ret.Name = string(needle.Name)
 	}
+	if needle.HasMime() {
+		ret.Mime = string(needle.Mime)
+	}
 	ret.Size = size
 	writeJsonQuiet(w, r, httpStatus, ret)
 }
@@ -50,6 +62,10 @@ func (vs *VolumeServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	vid, nid, _, _, _ := parseURLPath(r.URL.Path)
 	volumeId, _ := storage.NewVolumeId(vid)
 	n.ParseNid(nid)
+	if err := vs.authorize(r, security.ActionDelete, volumeId, vid+","+nid); err != nil {
+		writeJsonError(w, r, http.StatusUnauthorized, err)
+		return
+	}
 
 	glog.V(2).Infoln("deleting", n)
 
@@ -70,21 +86,23 @@ func (vs *VolumeServer) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 
 	count := int64(n.Size)
 
-	if n.IsChunkedManifest() {
+	// the chunks are deleted only once, not again by the replicas, and are kept with cm=false
+	if n.IsChunkedManifest() && r.FormValue("cm") != "false" && r.FormValue("type") != "replicate" {
 		chunkManifest, e := operation.LoadChunkManifest(n.Data, n.IsGzipped())
 		if e != nil {
 			writeJsonError(w, r, http.StatusInternalServerError, fmt.Errorf("Load chunks manifest error: %v", e))
 			return
 		}
 		// make sure all chunks had deleted before delete manifest
-		if e := chunkManifest.DeleteChunks(vs.GetMasterNode(), r.FormValue("collection")); e != nil {
+		if e := chunkManifest.DeleteChunks(vs.GetMasterNode(), r.FormValue("collection"), vs.guard.GetSecretKey()); e != nil {
 			writeJsonError(w, r, http.StatusInternalServerError, fmt.Errorf("Delete chunks error: %v", e))
 			return
 		}
 		count = chunkManifest.Size
 	}
 
-	ret := topology.ReplicatedDelete(vs.GetMasterNode(), vs.store, volumeId, n, r)
+	ret := topology.ReplicatedDelete(vs.GetMasterNode(), vs.store, volumeId, n, r,
+		security.GenJwt(vs.guard.GetSecretKey(), vid+","+nid))
 
 	if ret != 0 {
 		m := make(map[string]int64)
@@ -112,6 +130,13 @@ func (vs *VolumeServer) batchDeleteHandler(w http.ResponseWriter, r *http.Reques
 		n := new(storage.Needle)
 		volumeId, _ := storage.NewVolumeId(vid)
 		n.ParseNid(id_cookie)
+		if err := vs.authorize(r, security.ActionDelete, volumeId, fid); err != nil {
+			ret = append(ret, operation.DeleteResult{
+				Fid:    fid,
+				Status: http.StatusUnauthorized,
+				Error:  err.Error()})
+			continue
+		}
 		glog.V(4).Infoln("batch deleting", n)
 		cookie := n.Cookie
 		if _, err := vs.store.ReadVolumeNeedleNoCache(volumeId, n); err != nil {